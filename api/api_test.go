@@ -0,0 +1,413 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/catalog"
+	"github.com/lian-rr/clio/command/file"
+	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+)
+
+const testToken = "secret"
+
+// newTestServer returns a server over a local library with the subscribed commands.
+func newTestServer(t *testing.T, subscribed ...command.Command) (*httptest.Server, *Client) {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := file.New(logger, t.TempDir())
+	require.NoError(t, err)
+
+	mng, err := manager.NewManager(store, store)
+	require.NoError(t, err)
+
+	library, err := catalog.NewLibrary(&mng, logger)
+	require.NoError(t, err)
+	library.Set(subscribed)
+
+	server, err := NewServer(library, logger, testToken)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	client, err := NewClient(ts.URL, testToken, WithHTTPClient(ts.Client()))
+	require.NoError(t, err)
+
+	return ts, client
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestServer(t)
+
+	cmd, err := client.Add(ctx, command.Command{
+		Name:        "pods",
+		Description: "List the pods",
+		Command:     "kubectl get pods -n {{.namespace}} -l {{.label}}",
+		Params: []command.Parameter{
+			{Name: "namespace", DefaultValue: "default"},
+			{Name: "label", Description: "selector"},
+		},
+		Tags:   []string{"k8s"},
+		Target: "ssh:bastion",
+		Env:    []command.EnvVar{{Name: "KUBECONFIG", Value: "/etc/kube/{{.namespace}}"}},
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, cmd.ID)
+	require.Len(t, cmd.Params, 2)
+	assert.Equal(t, "default", cmd.Params[0].DefaultValue, "the params are kept")
+	assert.NotEqual(t, uuid.Nil, cmd.Params[0].ID, "the new params get an ID")
+
+	t.Run("get", func(t *testing.T) {
+		got, err := client.GetOne(ctx, cmd.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, cmd, got)
+
+		_, err = client.GetOne(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+	})
+
+	t.Run("list and search", func(t *testing.T) {
+		all, err := client.GetAll(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []command.Command{cmd}, all)
+
+		found, err := client.Search(ctx, "kube pods")
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+		assert.Equal(t, cmd, found[0].Command)
+		assert.Positive(t, found[0].Score)
+
+		found, err = client.Search(ctx, "kubctl")
+		require.NoError(t, err)
+		require.Len(t, found, 1, "typos match")
+		assert.Equal(t, cmd.ID, found[0].Command.ID)
+
+		found, err = client.Search(ctx, "selector")
+		require.NoError(t, err)
+		require.Len(t, found, 1, "the parameters are searched")
+		assert.Equal(t, "matched in parameter", found[0].Reason())
+		assert.Equal(t, "label «selector»", found[0].Snippet)
+
+		found, err = client.Search(ctx, "missing")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		found, err = client.Search(ctx, "kube tag:k8s")
+		require.NoError(t, err)
+		require.Len(t, found, 1, "the tags are kept")
+		assert.Equal(t, cmd.ID, found[0].Command.ID)
+
+		_, err = client.Search(ctx, "tag:")
+		assert.ErrorIs(t, err, search.ErrInvalidQuery)
+	})
+
+	t.Run("compile", func(t *testing.T) {
+		compiled, err := client.Compile(ctx, cmd.ID, map[string]string{"label": "app=web"})
+		require.NoError(t, err)
+		assert.Equal(t, "env KUBECONFIG=/etc/kube/default kubectl get pods -n default -l app=web", compiled)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated := cmd
+		updated.Command = "kubectl get pods -n {{.namespace}}"
+
+		got, err := client.UpdateCommand(ctx, updated)
+		require.NoError(t, err)
+		assert.Equal(t, []command.Parameter{cmd.Params[0]}, got.Params, "the params follow the command")
+
+		cmd = got
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		revisions, err := client.Revisions(ctx, cmd.ID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, "kubectl get pods -n {{.namespace}} -l {{.label}}", revisions[0].Command.Command)
+		assert.Len(t, revisions[0].Command.Params, 2)
+
+		restored, err := client.RestoreRevision(ctx, cmd.ID, revisions[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, revisions[0].Command.Command, restored.Command)
+		assert.Equal(t, revisions[0].Command.Params, restored.Params)
+		assert.Equal(t, []string{"k8s"}, restored.Tags, "the current tags are kept")
+		assert.Equal(t, "ssh:bastion", restored.Target, "the current target is kept")
+
+		_, err = client.RestoreRevision(ctx, cmd.ID, 42)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+
+		cmd = restored
+	})
+
+	t.Run("history", func(t *testing.T) {
+		require.NoError(t, client.InsertUsage(ctx, cmd.ID, command.Usage{
+			Command: "kubectl get pods -n kube-system",
+			Target:  "ssh:bastion",
+			Result:  &command.RunResult{ExitCode: 2, Duration: 40 * time.Millisecond},
+		}))
+
+		history, err := client.GetHistory(ctx, cmd.ID)
+		require.NoError(t, err)
+		require.Len(t, history.Usages, 1)
+		assert.Equal(t, "kubectl get pods -n kube-system", history.Usages[0].Command)
+		assert.Equal(t, "ssh:bastion", history.Usages[0].Target)
+		assert.Equal(t, &command.RunResult{ExitCode: 2, Duration: 40 * time.Millisecond}, history.Usages[0].Result)
+		assert.False(t, history.Usages[0].Timestamp.IsZero())
+	})
+
+	t.Run("notebook", func(t *testing.T) {
+		_, err := client.ReadExplanation(ctx, cmd.ID)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+
+		explanation := command.Explanation{
+			Content: "Lists the pods.",
+			Signature: command.ExplanationSignature{
+				CommandHash: cmd.Hash(),
+				Source:      "manpage",
+				Template:    "default",
+			},
+		}
+		require.NoError(t, client.WriteExplanation(ctx, cmd.ID, explanation))
+
+		got, err := client.ReadExplanation(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, explanation, got)
+
+		transcript := []command.Message{
+			{Role: command.UserRole, Content: "What is -n?"},
+			{Role: command.AssistantRole, Content: "The namespace."},
+		}
+		require.NoError(t, client.WriteTranscript(ctx, cmd.ID, transcript))
+
+		gotTranscript, err := client.ReadTranscript(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, transcript, gotTranscript)
+
+		require.NoError(t, client.DeleteExplanation(ctx, cmd.ID))
+		_, err = client.ReadExplanation(ctx, cmd.ID)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		require.NoError(t, client.DeleteCommand(ctx, cmd.ID.String()))
+
+		_, err := client.GetOne(ctx, cmd.ID.String())
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+
+		trash, err := client.Trash(ctx)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, cmd.ID, trash[0].Command.ID)
+		assert.False(t, trash[0].DeletedAt.IsZero())
+
+		restored, err := client.RestoreCommand(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, cmd, restored)
+
+		_, err = client.RestoreCommand(ctx, cmd.ID)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound, "not in the trash")
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		require.NoError(t, client.DeleteCommand(ctx, cmd.ID.String()))
+		require.NoError(t, client.PurgeCommand(ctx, cmd.ID))
+
+		trash, err := client.Trash(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		_, err = client.RestoreCommand(ctx, cmd.ID)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+	})
+}
+
+func TestServer_Requests(t *testing.T) {
+	subscribed := command.Command{ID: uuid.New(), Name: "nodes", Command: "kubectl get nodes", Source: "platform"}
+	ts, client := newTestServer(t, subscribed)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "missing token",
+			method:         http.MethodGet,
+			path:           "/v1/commands",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "invalid token",
+			method:         http.MethodGet,
+			path:           "/v1/commands",
+			token:          "invalid",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "invalid id",
+			method:         http.MethodGet,
+			path:           "/v1/commands/invalid",
+			token:          testToken,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPost,
+			path:           "/v1/commands",
+			token:          testToken,
+			body:           "{",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid target",
+			method:         http.MethodPost,
+			path:           "/v1/commands",
+			token:          testToken,
+			body:           `{"name": "pods", "command": "kubectl get pods", "target": "podman:api"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid query",
+			method:         http.MethodGet,
+			path:           "/v1/search?q=tag%3A",
+			token:          testToken,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown command",
+			method:         http.MethodPost,
+			path:           "/v1/commands/" + uuid.NewString() + "/compile",
+			token:          testToken,
+			body:           "{}",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "update read-only command",
+			method:         http.MethodPut,
+			path:           "/v1/commands/" + subscribed.ID.String(),
+			token:          testToken,
+			body:           `{"name": "nodes", "command": "kubectl get nodes -o wide"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "delete read-only command",
+			method:         http.MethodDelete,
+			path:           "/v1/commands/" + subscribed.ID.String(),
+			token:          testToken,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPatch,
+			path:           "/v1/commands",
+			token:          testToken,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+
+	t.Run("read-only error", func(t *testing.T) {
+		err := client.DeleteCommand(context.Background(), subscribed.ID.String())
+		assert.ErrorIs(t, err, catalog.ErrReadOnly)
+	})
+}
+
+func TestServer_IndexEmbeddings(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := file.New(logger, t.TempDir())
+	require.NoError(t, err)
+	mng, err := manager.NewManager(store, store)
+	require.NoError(t, err)
+
+	library := &indexingLibrary{Manager: &mng, runs: make(chan struct{}, 1)}
+	server, err := NewServer(library, logger, testToken)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = server.Serve(ctx, listener)
+	}()
+
+	waitRun := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-library.runs:
+		case <-time.After(time.Second):
+			t.Fatal("the embeddings weren't computed")
+		}
+	}
+
+	t.Run("on startup", waitRun)
+
+	t.Run("after saving", func(t *testing.T) {
+		client, err := NewClient("http://"+listener.Addr().String(), testToken)
+		require.NoError(t, err)
+
+		_, err = client.Add(ctx, command.Command{Name: "pods", Command: "kubectl get pods"})
+		require.NoError(t, err)
+		waitRun(t)
+	})
+}
+
+func TestNew_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(&manager.Manager{}, logger, "")
+	assert.ErrorIs(t, err, ErrMissingToken)
+
+	_, err = NewClient("http://127.0.0.1:7070", "")
+	assert.ErrorIs(t, err, ErrMissingToken)
+
+	_, err = NewClient("127.0.0.1:7070", testToken)
+	assert.Error(t, err)
+}
+
+// indexingLibrary records the runs computing the embeddings.
+type indexingLibrary struct {
+	*manager.Manager
+	runs chan struct{}
+}
+
+func (l *indexingLibrary) IndexEmbeddings(context.Context) (int, error) {
+	l.runs <- struct{}{}
+	return 0, nil
+}
@@ -0,0 +1,322 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/catalog"
+	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+)
+
+// Client uses the library served by a remote clio server.
+// It can be used by the TUI in place of the local manager.
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// ClientOptFunc used for customizing the Client.
+type ClientOptFunc func(*Client)
+
+// NewClient returns a new Client for the server in the url.
+func NewClient(baseURL, token string, opts ...ClientOptFunc) (*Client, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid server url %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// WithHTTPClient sets the http client used for the requests.
+func WithHTTPClient(client *http.Client) ClientOptFunc {
+	return func(c *Client) {
+		if client != nil {
+			c.client = client
+		}
+	}
+}
+
+// GetAll returns all the commands.
+func (c *Client) GetAll(ctx context.Context) ([]command.Command, error) {
+	var body []commandBody
+	if err := c.do(ctx, http.MethodGet, "/v1/commands", nil, &body); err != nil {
+		return nil, err
+	}
+	return toCommands(body), nil
+}
+
+// GetOne returns a command by ID.
+func (c *Client) GetOne(ctx context.Context, rawID string) (command.Command, error) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return command.Command{}, err
+	}
+
+	var body commandBody
+	if err := c.do(ctx, http.MethodGet, commandPath(id), nil, &body); err != nil {
+		return command.Command{}, err
+	}
+	return body.toCommand(), nil
+}
+
+// Search returns the commands matching the term, best first.
+func (c *Client) Search(ctx context.Context, term string) ([]command.SearchResult, error) {
+	// the query is checked before sending it, for reporting the same errors as the local search.
+	if _, err := search.Parse(term); err != nil {
+		return nil, err
+	}
+
+	var body []searchResultBody
+	if err := c.do(ctx, http.MethodGet, "/v1/search?q="+url.QueryEscape(term), nil, &body); err != nil {
+		return nil, err
+	}
+
+	results := make([]command.SearchResult, 0, len(body))
+	for _, b := range body {
+		results = append(results, command.SearchResult{
+			Command: b.Command.toCommand(),
+			Score:   b.Score,
+			Matches: b.Matches,
+			Source:  command.MatchSource(b.Source),
+			Snippet: b.Snippet,
+		})
+	}
+	return results, nil
+}
+
+// Add creates a new command.
+func (c *Client) Add(ctx context.Context, cmd command.Command) (command.Command, error) {
+	var body commandBody
+	if err := c.do(ctx, http.MethodPost, "/v1/commands", toCommandBody(cmd), &body); err != nil {
+		return command.Command{}, err
+	}
+	return body.toCommand(), nil
+}
+
+// DeleteCommand moves a command to the trash.
+func (c *Client) DeleteCommand(ctx context.Context, rawID string) error {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodDelete, commandPath(id), nil, nil)
+}
+
+// UpdateCommand updates a command.
+func (c *Client) UpdateCommand(ctx context.Context, cmd command.Command) (command.Command, error) {
+	var body commandBody
+	if err := c.do(ctx, http.MethodPut, commandPath(cmd.ID), toCommandBody(cmd), &body); err != nil {
+		return command.Command{}, err
+	}
+	return body.toCommand(), nil
+}
+
+// Trash returns the deleted commands, the last deleted first.
+func (c *Client) Trash(ctx context.Context) ([]command.DeletedCommand, error) {
+	var body []deletedCommandBody
+	if err := c.do(ctx, http.MethodGet, "/v1/trash", nil, &body); err != nil {
+		return nil, err
+	}
+
+	trash := make([]command.DeletedCommand, 0, len(body))
+	for _, deleted := range body {
+		trash = append(trash, command.DeletedCommand{Command: deleted.Command.toCommand(), DeletedAt: deleted.DeletedAt})
+	}
+	return trash, nil
+}
+
+// RestoreCommand takes a command out of the trash.
+func (c *Client) RestoreCommand(ctx context.Context, id uuid.UUID) (command.Command, error) {
+	var body commandBody
+	if err := c.do(ctx, http.MethodPost, trashPath(id)+"/restore", nil, &body); err != nil {
+		return command.Command{}, err
+	}
+	return body.toCommand(), nil
+}
+
+// PurgeCommand removes permanently a command.
+func (c *Client) PurgeCommand(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, trashPath(id), nil, nil)
+}
+
+// Compile returns the command with the arguments applied. The missing arguments take the default value.
+func (c *Client) Compile(ctx context.Context, id uuid.UUID, args map[string]string) (string, error) {
+	var body compileResponse
+	if err := c.do(ctx, http.MethodPost, commandPath(id)+"/compile", compileRequest{Arguments: args}, &body); err != nil {
+		return "", err
+	}
+	return body.Command, nil
+}
+
+// InsertUsage records a usage of the command.
+func (c *Client) InsertUsage(ctx context.Context, id uuid.UUID, usage command.Usage) error {
+	return c.do(ctx, http.MethodPost, commandPath(id)+"/history", toUsageBody(usage), nil)
+}
+
+// GetHistory returns the usages of the command.
+func (c *Client) GetHistory(ctx context.Context, id uuid.UUID) (command.History, error) {
+	var body historyBody
+	if err := c.do(ctx, http.MethodGet, commandPath(id)+"/history", nil, &body); err != nil {
+		return command.History{}, err
+	}
+
+	history := command.History{Usages: make([]command.Usage, 0, len(body.Usages))}
+	for _, usage := range body.Usages {
+		history.Usages = append(history.Usages, usage.toUsage())
+	}
+	return history, nil
+}
+
+// Revisions returns the prior versions of the command, the newest first.
+func (c *Client) Revisions(ctx context.Context, id uuid.UUID) ([]command.Revision, error) {
+	var body []revisionBody
+	if err := c.do(ctx, http.MethodGet, commandPath(id)+"/revisions", nil, &body); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]command.Revision, 0, len(body))
+	for _, rev := range body {
+		revisions = append(revisions, command.Revision{ID: rev.ID, Command: rev.Command.toCommand(), CreatedAt: rev.CreatedAt})
+	}
+	return revisions, nil
+}
+
+// RestoreRevision updates the command with the content of one of its revisions.
+func (c *Client) RestoreRevision(ctx context.Context, id uuid.UUID, revisionID int64) (command.Command, error) {
+	var body commandBody
+	path := fmt.Sprintf("%s/revisions/%d/restore", commandPath(id), revisionID)
+	if err := c.do(ctx, http.MethodPost, path, nil, &body); err != nil {
+		return command.Command{}, err
+	}
+	return body.toCommand(), nil
+}
+
+// WriteExplanation writes the explanation of the command.
+func (c *Client) WriteExplanation(ctx context.Context, id uuid.UUID, explanation command.Explanation) error {
+	return c.do(ctx, http.MethodPut, commandPath(id)+"/explanation", toExplanationBody(explanation), nil)
+}
+
+// ReadExplanation reads the explanation of the command.
+func (c *Client) ReadExplanation(ctx context.Context, id uuid.UUID) (command.Explanation, error) {
+	var body explanationBody
+	if err := c.do(ctx, http.MethodGet, commandPath(id)+"/explanation", nil, &body); err != nil {
+		return command.Explanation{}, err
+	}
+	return body.toExplanation(), nil
+}
+
+// DeleteExplanation removes the explanation of the command.
+func (c *Client) DeleteExplanation(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, http.MethodDelete, commandPath(id)+"/explanation", nil, nil)
+}
+
+// WriteTranscript writes the follow-up conversation of the command.
+func (c *Client) WriteTranscript(ctx context.Context, id uuid.UUID, transcript []command.Message) error {
+	return c.do(ctx, http.MethodPut, commandPath(id)+"/transcript", transcriptBody{Messages: transcript}, nil)
+}
+
+// ReadTranscript reads the follow-up conversation of the command.
+func (c *Client) ReadTranscript(ctx context.Context, id uuid.UUID) ([]command.Message, error) {
+	var body transcriptBody
+	if err := c.do(ctx, http.MethodGet, commandPath(id)+"/transcript", nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Messages, nil
+}
+
+// do sends the request with the body encoded as JSON, and decodes the response into out if not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling the server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr errorBody
+		_ = json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&apiErr)
+
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return manager.ErrElementNotFound
+		case http.StatusForbidden:
+			return catalog.ErrReadOnly
+		case http.StatusNotImplemented:
+			if apiErr.Error == manager.ErrSemanticSearchNotEnabled.Error() {
+				return manager.ErrSemanticSearchNotEnabled
+			}
+			return manager.ErrNotebookNotEnabled
+		default:
+			return fmt.Errorf("server error (status=%d): %s", resp.StatusCode, apiErr.Error)
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+func commandPath(id uuid.UUID) string {
+	return "/v1/commands/" + id.String()
+}
+
+func trashPath(id uuid.UUID) string {
+	return "/v1/trash/" + id.String()
+}
+
+func toCommands(bodies []commandBody) []command.Command {
+	cmds := make([]command.Command, 0, len(bodies))
+	for _, body := range bodies {
+		cmds = append(cmds, body.toCommand())
+	}
+	return cmds
+}
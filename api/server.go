@@ -0,0 +1,641 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/catalog"
+	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+	"github.com/lian-rr/clio/command/target"
+)
+
+const (
+	// maxBodySize limits the size of the request bodies.
+	maxBodySize = 1 << 20
+
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+	// indexEmbeddingsTimeout bounds each run computing the missing embeddings.
+	indexEmbeddingsTimeout = 5 * time.Minute
+)
+
+var (
+	// ErrMissingToken thrown when the server or client is created without a token.
+	ErrMissingToken = errors.New("missing api token")
+	errInvalidID    = errors.New("invalid command id")
+	errInvalidRev   = errors.New("invalid revision id")
+)
+
+type library interface {
+	GetAll(context.Context) ([]command.Command, error)
+	GetOne(context.Context, string) (command.Command, error)
+	Search(context.Context, string) ([]command.SearchResult, error)
+	Add(context.Context, command.Command) (command.Command, error)
+	DeleteCommand(context.Context, string) error
+	UpdateCommand(context.Context, command.Command) (command.Command, error)
+	WriteExplanation(context.Context, uuid.UUID, command.Explanation) error
+	ReadExplanation(context.Context, uuid.UUID) (command.Explanation, error)
+	DeleteExplanation(context.Context, uuid.UUID) error
+	WriteTranscript(context.Context, uuid.UUID, []command.Message) error
+	ReadTranscript(context.Context, uuid.UUID) ([]command.Message, error)
+	InsertUsage(context.Context, uuid.UUID, command.Usage) error
+	GetHistory(context.Context, uuid.UUID) (command.History, error)
+	Revisions(context.Context, uuid.UUID) ([]command.Revision, error)
+	RestoreRevision(context.Context, uuid.UUID, int64) (command.Command, error)
+	Trash(context.Context) ([]command.DeletedCommand, error)
+	RestoreCommand(context.Context, uuid.UUID) (command.Command, error)
+	PurgeCommand(context.Context, uuid.UUID) error
+}
+
+// embeddingIndex is implemented by the libraries keeping the embeddings of the commands.
+type embeddingIndex interface {
+	IndexEmbeddings(context.Context) (int, error)
+}
+
+// Server serves the library as a REST API.
+// Every request must be authenticated with the token as a bearer token.
+type Server struct {
+	library library
+	token   string
+	logger  *slog.Logger
+	handler http.Handler
+	// reindex requests computing the missing embeddings of the commands.
+	reindex chan struct{}
+}
+
+// NewServer returns a new Server.
+func NewServer(library library, logger *slog.Logger, token string) (*Server, error) {
+	if library == nil {
+		return nil, errors.New("nil library")
+	}
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	s := &Server{
+		library: library,
+		token:   token,
+		logger:  logger,
+		reindex: make(chan struct{}, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/commands", s.listCommands)
+	mux.HandleFunc("GET /v1/search", s.search)
+	mux.HandleFunc("POST /v1/commands", s.addCommand)
+	mux.HandleFunc("GET /v1/commands/{id}", s.getCommand)
+	mux.HandleFunc("PUT /v1/commands/{id}", s.updateCommand)
+	mux.HandleFunc("DELETE /v1/commands/{id}", s.deleteCommand)
+	mux.HandleFunc("POST /v1/commands/{id}/compile", s.compileCommand)
+	mux.HandleFunc("GET /v1/commands/{id}/history", s.getHistory)
+	mux.HandleFunc("POST /v1/commands/{id}/history", s.insertUsage)
+	mux.HandleFunc("GET /v1/trash", s.listTrash)
+	mux.HandleFunc("POST /v1/trash/{id}/restore", s.restoreCommand)
+	mux.HandleFunc("DELETE /v1/trash/{id}", s.purgeCommand)
+	mux.HandleFunc("GET /v1/commands/{id}/revisions", s.listRevisions)
+	mux.HandleFunc("POST /v1/commands/{id}/revisions/{revision}/restore", s.restoreRevision)
+	mux.HandleFunc("GET /v1/commands/{id}/explanation", s.readExplanation)
+	mux.HandleFunc("PUT /v1/commands/{id}/explanation", s.writeExplanation)
+	mux.HandleFunc("DELETE /v1/commands/{id}/explanation", s.deleteExplanation)
+	mux.HandleFunc("GET /v1/commands/{id}/transcript", s.readTranscript)
+	mux.HandleFunc("PUT /v1/commands/{id}/transcript", s.writeTranscript)
+
+	s.handler = s.authenticate(mux)
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the API on the address until the context is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves the API on the listener until the context is done.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	s.logger.Info("serving the library", slog.String("address", listener.Addr().String()))
+
+	// the embeddings are computed in the background, they are slow with a remote embedder.
+	if index, ok := s.library.(embeddingIndex); ok {
+		go s.indexEmbeddings(ctx, index)
+		s.requestEmbeddings()
+	}
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("error shutting down the server: %w", err)
+		}
+		return nil
+	}
+}
+
+// indexEmbeddings computes the missing embeddings of the commands each time they are requested, until the context
+// is done. The requests made while computing them are merged into the next run.
+func (s *Server) indexEmbeddings(ctx context.Context, index embeddingIndex) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.reindex:
+		}
+
+		indexCtx, cancel := context.WithTimeout(ctx, indexEmbeddingsTimeout)
+		n, err := index.IndexEmbeddings(indexCtx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("error computing the embeddings", slog.Any("error", err))
+			continue
+		}
+		s.logger.Debug("embeddings computed", slog.Int("commands", n))
+	}
+}
+
+// requestEmbeddings requests computing the missing embeddings, e.g. the one of a saved command.
+func (s *Server) requestEmbeddings() {
+	select {
+	case s.reindex <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listCommands(w http.ResponseWriter, r *http.Request) {
+	var (
+		cmds []command.Command
+		err  error
+	)
+
+	if term := r.URL.Query().Get("q"); term != "" {
+		var results []command.SearchResult
+		results, err = s.library.Search(r.Context(), term)
+		for _, result := range results {
+			cmds = append(cmds, result.Command)
+		}
+	} else {
+		cmds, err = s.library.GetAll(r.Context())
+	}
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCommandBodies(cmds))
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	results, err := s.library.Search(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	bodies := make([]searchResultBody, 0, len(results))
+	for _, result := range results {
+		bodies = append(bodies, searchResultBody{
+			Command: toCommandBody(result.Command),
+			Score:   result.Score,
+			Matches: result.Matches,
+			Source:  string(result.Source),
+			Snippet: result.Snippet,
+		})
+	}
+	writeJSON(w, http.StatusOK, bodies)
+}
+
+func (s *Server) addCommand(w http.ResponseWriter, r *http.Request) {
+	var body commandBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	cmd, err := buildCommand(body.toCommand())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cmd, err = s.library.Add(r.Context(), cmd)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.requestEmbeddings()
+
+	writeJSON(w, http.StatusCreated, toCommandBody(cmd))
+}
+
+func (s *Server) getCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	cmd, err := s.library.GetOne(r.Context(), id.String())
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toCommandBody(cmd))
+}
+
+func (s *Server) updateCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var body commandBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	cmd := body.toCommand()
+	cmd.ID = id
+	cmd, err := buildCommand(cmd)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cmd, err = s.library.UpdateCommand(r.Context(), cmd)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.requestEmbeddings()
+
+	writeJSON(w, http.StatusOK, toCommandBody(cmd))
+}
+
+func (s *Server) deleteCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.library.DeleteCommand(r.Context(), id.String()); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := s.library.Trash(r.Context())
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	body := make([]deletedCommandBody, 0, len(trash))
+	for _, deleted := range trash {
+		body = append(body, deletedCommandBody{Command: toCommandBody(deleted.Command), DeletedAt: deleted.DeletedAt})
+	}
+
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) restoreCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	cmd, err := s.library.RestoreCommand(r.Context(), id)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.requestEmbeddings()
+
+	writeJSON(w, http.StatusOK, toCommandBody(cmd))
+}
+
+func (s *Server) purgeCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.library.PurgeCommand(r.Context(), id); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) compileCommand(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var body compileRequest
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	cmd, err := s.library.GetOne(r.Context(), id.String())
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	args := make([]command.Argument, 0, len(cmd.Params))
+	for _, param := range cmd.Params {
+		value, ok := body.Arguments[param.Name]
+		if !ok {
+			value = param.DefaultValue
+		}
+		args = append(args, command.Argument{Name: param.Name, Value: value})
+	}
+
+	// the command is typed in a shell, the env and the working directory are set by the line.
+	inv, err := cmd.Invocation(args)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, compileResponse{Command: inv.ShellLine()})
+}
+
+func (s *Server) getHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	history, err := s.library.GetHistory(r.Context(), id)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	body := historyBody{Usages: make([]usageBody, 0, len(history.Usages))}
+	for _, usage := range history.Usages {
+		body.Usages = append(body.Usages, toUsageBody(usage))
+	}
+
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) insertUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var body usageBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	if err := s.library.InsertUsage(r.Context(), id, body.toUsage()); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listRevisions(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := s.library.Revisions(r.Context(), id)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	body := make([]revisionBody, 0, len(revisions))
+	for _, rev := range revisions {
+		body = append(body, revisionBody{ID: rev.ID, Command: toCommandBody(rev.Command), CreatedAt: rev.CreatedAt})
+	}
+
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (s *Server) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	revision, err := strconv.ParseInt(r.PathValue("revision"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidRev)
+		return
+	}
+
+	cmd, err := s.library.RestoreRevision(r.Context(), id, revision)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.requestEmbeddings()
+
+	writeJSON(w, http.StatusOK, toCommandBody(cmd))
+}
+
+func (s *Server) readExplanation(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	explanation, err := s.library.ReadExplanation(r.Context(), id)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toExplanationBody(explanation))
+}
+
+func (s *Server) writeExplanation(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var body explanationBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	if err := s.library.WriteExplanation(r.Context(), id, body.toExplanation()); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteExplanation(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.library.DeleteExplanation(r.Context(), id); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) readTranscript(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	transcript, err := s.library.ReadTranscript(r.Context(), id)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, transcriptBody{Messages: transcript})
+}
+
+func (s *Server) writeTranscript(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var body transcriptBody
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	if err := s.library.WriteTranscript(r.Context(), id, body.Messages); err != nil {
+		s.fail(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// fail writes the error returned by the library.
+func (s *Server) fail(w http.ResponseWriter, err error) {
+	switch {
+	case manager.IsNotFound(err):
+		writeError(w, http.StatusNotFound, manager.ErrElementNotFound)
+	case errors.Is(err, manager.ErrNotebookNotEnabled), errors.Is(err, manager.ErrSemanticSearchNotEnabled):
+		writeError(w, http.StatusNotImplemented, err)
+	case errors.Is(err, search.ErrInvalidQuery):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, catalog.ErrReadOnly):
+		writeError(w, http.StatusForbidden, err)
+	default:
+		s.logger.Error("error handling api request", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+	}
+}
+
+// buildCommand syncs the params with the command, giving an ID to the new ones.
+func buildCommand(cmd command.Command) (command.Command, error) {
+	if err := cmd.Build(); err != nil {
+		return command.Command{}, err
+	}
+	if _, err := target.Parse(cmd.Target); err != nil {
+		return command.Command{}, err
+	}
+
+	for i, param := range cmd.Params {
+		if param.ID == uuid.Nil {
+			cmd.Params[i].ID = uuid.New()
+		}
+	}
+	return cmd, nil
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errInvalidID)
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+)
+
+type (
+	commandBody struct {
+		ID          uuid.UUID   `json:"id"`
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Command     string      `json:"command"`
+		Params      []paramBody `json:"params"`
+		// Source is the subscription of a read-only command.
+		Source string   `json:"source,omitempty"`
+		Tags   []string `json:"tags,omitempty"`
+		// Target is where the command runs by default, e.g. ssh:host.
+		Target string `json:"target,omitempty"`
+		// Env are the env vars the command runs with, their values can use the params.
+		Env []envBody `json:"env,omitempty"`
+		// Dir is the working directory the command runs in.
+		Dir string `json:"dir,omitempty"`
+		// Requires are the programs the command needs besides the ones of its command line.
+		Requires []string `json:"requires,omitempty"`
+	}
+
+	envBody struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	paramBody struct {
+		ID          uuid.UUID `json:"id"`
+		Name        string    `json:"name"`
+		Description string    `json:"description"`
+		Default     string    `json:"default"`
+	}
+
+	compileRequest struct {
+		// Arguments by parameter name. The missing ones take the default value.
+		Arguments map[string]string `json:"arguments"`
+	}
+
+	compileResponse struct {
+		Command string `json:"command"`
+	}
+
+	usageBody struct {
+		Command   string    `json:"command"`
+		Timestamp time.Time `json:"timestamp,omitempty"`
+		Target    string    `json:"target,omitempty"`
+		// set for the commands run inside clio.
+		ExitCode   *int  `json:"exitCode,omitempty"`
+		DurationMs int64 `json:"durationMs,omitempty"`
+	}
+
+	historyBody struct {
+		Usages []usageBody `json:"usages"`
+	}
+
+	revisionBody struct {
+		ID        int64       `json:"id"`
+		Command   commandBody `json:"command"`
+		CreatedAt time.Time   `json:"createdAt"`
+	}
+
+	searchResultBody struct {
+		Command commandBody `json:"command"`
+		Score   float64     `json:"score"`
+		// Matches are the indexes of the runes of the name matching the search.
+		Matches []int `json:"matches"`
+		// Source is the content that matched when the name, command and description didn't, e.g. explanation.
+		Source  string `json:"source,omitempty"`
+		Snippet string `json:"snippet,omitempty"`
+	}
+
+	deletedCommandBody struct {
+		Command   commandBody `json:"command"`
+		DeletedAt time.Time   `json:"deletedAt"`
+	}
+
+	explanationBody struct {
+		Content   string        `json:"content"`
+		Signature signatureBody `json:"signature"`
+	}
+
+	signatureBody struct {
+		CommandHash   string `json:"commandHash"`
+		Source        string `json:"source"`
+		PromptVersion string `json:"promptVersion"`
+		Template      string `json:"template"`
+	}
+
+	transcriptBody struct {
+		Messages []command.Message `json:"messages"`
+	}
+
+	errorBody struct {
+		Error string `json:"error"`
+	}
+)
+
+func toCommandBody(cmd command.Command) commandBody {
+	body := commandBody{
+		ID:          cmd.ID,
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Command:     cmd.Command,
+		Params:      make([]paramBody, 0, len(cmd.Params)),
+		Source:      cmd.Source,
+		Tags:        cmd.Tags,
+		Target:      cmd.Target,
+		Dir:         cmd.Dir,
+		Requires:    cmd.Requires,
+	}
+	for _, env := range cmd.Env {
+		body.Env = append(body.Env, envBody(env))
+	}
+	for _, param := range cmd.Params {
+		body.Params = append(body.Params, paramBody{
+			ID:          param.ID,
+			Name:        param.Name,
+			Description: param.Description,
+			Default:     param.DefaultValue,
+		})
+	}
+	return body
+}
+
+func (b commandBody) toCommand() command.Command {
+	cmd := command.Command{
+		ID:          b.ID,
+		Name:        b.Name,
+		Description: b.Description,
+		Command:     b.Command,
+		Params:      make([]command.Parameter, 0, len(b.Params)),
+		Source:      b.Source,
+		Tags:        b.Tags,
+		Target:      b.Target,
+		Dir:         b.Dir,
+		Requires:    b.Requires,
+	}
+	for _, env := range b.Env {
+		cmd.Env = append(cmd.Env, command.EnvVar(env))
+	}
+	for _, param := range b.Params {
+		cmd.Params = append(cmd.Params, command.Parameter{
+			ID:           param.ID,
+			Name:         param.Name,
+			Description:  param.Description,
+			DefaultValue: param.Default,
+		})
+	}
+	return cmd
+}
+
+func toCommandBodies(cmds []command.Command) []commandBody {
+	bodies := make([]commandBody, 0, len(cmds))
+	for _, cmd := range cmds {
+		bodies = append(bodies, toCommandBody(cmd))
+	}
+	return bodies
+}
+
+func toExplanationBody(explanation command.Explanation) explanationBody {
+	return explanationBody{
+		Content: explanation.Content,
+		Signature: signatureBody{
+			CommandHash:   explanation.Signature.CommandHash,
+			Source:        explanation.Signature.Source,
+			PromptVersion: explanation.Signature.PromptVersion,
+			Template:      explanation.Signature.Template,
+		},
+	}
+}
+
+func (b explanationBody) toExplanation() command.Explanation {
+	return command.Explanation{
+		Content: b.Content,
+		Signature: command.ExplanationSignature{
+			CommandHash:   b.Signature.CommandHash,
+			Source:        b.Signature.Source,
+			PromptVersion: b.Signature.PromptVersion,
+			Template:      b.Signature.Template,
+		},
+	}
+}
+
+func toUsageBody(usage command.Usage) usageBody {
+	body := usageBody{Command: usage.Command, Timestamp: usage.Timestamp, Target: usage.Target}
+	if usage.Result != nil {
+		exitCode := usage.Result.ExitCode
+		body.ExitCode = &exitCode
+		body.DurationMs = usage.Result.Duration.Milliseconds()
+	}
+	return body
+}
+
+func (b usageBody) toUsage() command.Usage {
+	usage := command.Usage{Command: b.Command, Timestamp: b.Timestamp, Target: b.Target}
+	if b.ExitCode != nil {
+		usage.Result = &command.RunResult{
+			ExitCode: *b.ExitCode,
+			Duration: time.Duration(b.DurationMs) * time.Millisecond,
+		}
+	}
+	return usage
+}
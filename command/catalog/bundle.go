@@ -0,0 +1,282 @@
+package catalog
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+)
+
+const (
+	// maxBundleSize limits the size of the fetched bundles.
+	maxBundleSize = 10 << 20
+	// signatureExt is appended to the bundle location for getting its signature.
+	signatureExt = ".sig"
+)
+
+var (
+	// ErrChecksumMismatch thrown when the bundle doesn't match the expected checksum.
+	ErrChecksumMismatch = errors.New("bundle checksum mismatch")
+	// ErrInvalidSignature thrown when the bundle signature can't be verified.
+	ErrInvalidSignature = errors.New("invalid bundle signature")
+)
+
+// Subscription is a remote command bundle.
+type Subscription struct {
+	// Name identifies the subscription, it's shown as the source of its commands.
+	Name string
+	// Location is the url or the file path of the bundle.
+	Location string
+	// SHA256 is the expected hex checksum of the bundle. Optional.
+	SHA256 string
+	// PublicKey is the base64 ed25519 key the bundle is signed with. Optional.
+	// The signature is read from the location with the .sig extension.
+	PublicKey string
+}
+
+type (
+	// bundle is the published file, e.g.
+	//
+	//	{"commands": [{"name": "pods", "command": "kubectl get pods -n {{.namespace}}",
+	//	  "params": [{"name": "namespace", "default": "default"}]}]}
+	bundle struct {
+		Commands []bundleCommand `json:"commands"`
+	}
+
+	bundleCommand struct {
+		ID          uuid.UUID     `json:"id"`
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Command     string        `json:"command"`
+		Params      []bundleParam `json:"params"`
+		Tags        []string      `json:"tags,omitempty"`
+		Target      string        `json:"target,omitempty"`
+		Env         []bundleEnv   `json:"env,omitempty"`
+		Dir         string        `json:"dir,omitempty"`
+		Requires    []string      `json:"requires,omitempty"`
+	}
+
+	bundleEnv struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	bundleParam struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Default     string `json:"default"`
+	}
+)
+
+// Fetcher gets the bundles of the subscriptions, keeping a copy in the cache dir.
+type Fetcher struct {
+	cacheDir string
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+// FetcherOptFunc used for customizing the Fetcher.
+type FetcherOptFunc func(*Fetcher)
+
+// NewFetcher returns a new Fetcher.
+func NewFetcher(logger *slog.Logger, cacheDir string, opts ...FetcherOptFunc) *Fetcher {
+	f := &Fetcher{
+		cacheDir: cacheDir,
+		client:   http.DefaultClient,
+		logger:   logger,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+// WithHTTPClient sets the http client used for the remote bundles.
+func WithHTTPClient(client *http.Client) FetcherOptFunc {
+	return func(f *Fetcher) {
+		if client != nil {
+			f.client = client
+		}
+	}
+}
+
+// Fetch returns the commands of the subscription.
+// If the bundle can't be fetched, the cached copy is used.
+func (f *Fetcher) Fetch(ctx context.Context, sub Subscription) ([]command.Command, error) {
+	raw, err := f.download(ctx, sub)
+	if err != nil {
+		cached, cacheErr := os.ReadFile(f.cachePath(sub))
+		if cacheErr != nil {
+			return nil, err
+		}
+
+		f.logger.Warn("error fetching subscription, using the cached copy",
+			slog.String("subscription", sub.Name),
+			slog.Any("error", err),
+		)
+		// the cached copy was verified when fetched.
+		raw = cached
+	} else if err := f.cache(sub, raw); err != nil {
+		f.logger.Warn("error caching subscription", slog.String("subscription", sub.Name), slog.Any("error", err))
+	}
+
+	return decode(sub.Name, raw)
+}
+
+// download gets and verifies the bundle.
+func (f *Fetcher) download(ctx context.Context, sub Subscription) ([]byte, error) {
+	raw, err := f.read(ctx, sub.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.SHA256 != "" {
+		sum := sha256.Sum256(raw)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), sub.SHA256) {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, sub.Name)
+		}
+	}
+
+	if sub.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(sub.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key of subscription %s", sub.Name)
+		}
+
+		sig, err := f.read(ctx, sub.Location+signatureExt)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the signature: %w", err)
+		}
+
+		if !ed25519.Verify(key, raw, decodeSignature(sig)) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSignature, sub.Name)
+		}
+	}
+
+	return raw, nil
+}
+
+// read reads the content of a url or a file path.
+func (f *Fetcher) read(ctx context.Context, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		file, err := os.Open(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return io.ReadAll(io.LimitReader(file, maxBundleSize))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %d", location, resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxBundleSize))
+}
+
+func (f *Fetcher) cache(sub Subscription, raw []byte) error {
+	if err := os.MkdirAll(f.cacheDir, 0o740); err != nil {
+		return err
+	}
+
+	tmp := f.cachePath(sub) + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.cachePath(sub))
+}
+
+func (f *Fetcher) cachePath(sub Subscription) string {
+	return filepath.Join(f.cacheDir, sub.Name+".json")
+}
+
+// decode returns the commands of the bundle, from the named source.
+func decode(source string, raw []byte) ([]command.Command, error) {
+	var b bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle %s: %w", source, err)
+	}
+
+	// the IDs are derived from the source, so they are stable across fetches.
+	namespace := uuid.NewSHA1(uuid.NameSpaceURL, []byte("clio:"+source))
+
+	cmds := make([]command.Command, 0, len(b.Commands))
+	for _, c := range b.Commands {
+		if c.Name == "" || c.Command == "" {
+			continue
+		}
+
+		cmd := command.Command{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: c.Description,
+			Command:     c.Command,
+			Params:      make([]command.Parameter, 0, len(c.Params)),
+			Source:      source,
+			Tags:        command.NormalizeTags(c.Tags),
+			Target:      strings.TrimSpace(c.Target),
+			Dir:         strings.TrimSpace(c.Dir),
+			Requires:    command.NormalizeRequires(c.Requires),
+		}
+		for _, env := range c.Env {
+			cmd.Env = append(cmd.Env, command.EnvVar(env))
+		}
+		if cmd.ID == uuid.Nil {
+			cmd.ID = uuid.NewSHA1(namespace, []byte(c.Name))
+		}
+
+		for _, p := range c.Params {
+			cmd.Params = append(cmd.Params, command.Parameter{
+				ID:           uuid.NewSHA1(cmd.ID, []byte(p.Name)),
+				Name:         p.Name,
+				Description:  p.Description,
+				DefaultValue: p.Default,
+			})
+		}
+
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds, nil
+}
+
+// decodeSignature accepts the signature raw or base64 encoded.
+func decodeSignature(sig []byte) []byte {
+	if len(sig) == ed25519.SignatureSize {
+		return sig
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return sig
+	}
+	return decoded
+}
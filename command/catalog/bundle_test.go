@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBundle = `{
+	"commands": [
+		{
+			"name": "pods",
+			"description": "List the pods",
+			"command": "kubectl get pods -n {{.namespace}}",
+			"params": [{"name": "namespace", "default": "default"}]
+		},
+		{"name": "nodes", "command": "kubectl get nodes"},
+		{"name": "invalid"}
+	]
+}`
+
+func newBundleServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func newTestFetcher(t *testing.T, ts *httptest.Server) *Fetcher {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return NewFetcher(logger, t.TempDir(), WithHTTPClient(ts.Client()))
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(testBundle))
+	signature := ed25519.Sign(priv, []byte(testBundle))
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	ts := newBundleServer(t, map[string][]byte{
+		"/catalog.json":     []byte(testBundle),
+		"/catalog.json.sig": []byte(base64.StdEncoding.EncodeToString(signature)),
+		"/unsigned.json":    []byte(testBundle),
+	})
+
+	tests := []struct {
+		name          string
+		sub           Subscription
+		expectedError error
+		wantErr       bool
+	}{
+		{
+			name: "plain",
+			sub:  Subscription{Name: "platform", Location: ts.URL + "/catalog.json"},
+		},
+		{
+			name: "checksum",
+			sub:  Subscription{Name: "platform", Location: ts.URL + "/catalog.json", SHA256: hex.EncodeToString(sum[:])},
+		},
+		{
+			name:          "checksum mismatch",
+			sub:           Subscription{Name: "platform", Location: ts.URL + "/catalog.json", SHA256: hex.EncodeToString(make([]byte, 32))},
+			expectedError: ErrChecksumMismatch,
+			wantErr:       true,
+		},
+		{
+			name: "signature",
+			sub:  Subscription{Name: "platform", Location: ts.URL + "/catalog.json", PublicKey: base64.StdEncoding.EncodeToString(pub)},
+		},
+		{
+			name:          "signed by another key",
+			sub:           Subscription{Name: "platform", Location: ts.URL + "/catalog.json", PublicKey: base64.StdEncoding.EncodeToString(otherPub)},
+			expectedError: ErrInvalidSignature,
+			wantErr:       true,
+		},
+		{
+			name:    "missing signature",
+			sub:     Subscription{Name: "platform", Location: ts.URL + "/unsigned.json", PublicKey: base64.StdEncoding.EncodeToString(pub)},
+			wantErr: true,
+		},
+		{
+			name:    "missing bundle",
+			sub:     Subscription{Name: "platform", Location: ts.URL + "/missing.json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := newTestFetcher(t, ts)
+
+			cmds, err := fetcher.Fetch(context.Background(), tt.sub)
+			if tt.wantErr {
+				assert.Error(t, err)
+				if tt.expectedError != nil {
+					assert.ErrorIs(t, err, tt.expectedError)
+				}
+				assert.NoFileExists(t, fetcher.cachePath(tt.sub), "an invalid bundle isn't cached")
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, cmds, 2, "the invalid commands are skipped")
+			assert.Equal(t, "pods", cmds[0].Name)
+			assert.Equal(t, "platform", cmds[0].Source)
+			require.Len(t, cmds[0].Params, 1)
+			assert.Equal(t, "default", cmds[0].Params[0].DefaultValue)
+
+			again, err := fetcher.Fetch(context.Background(), tt.sub)
+			require.NoError(t, err)
+			assert.Equal(t, cmds, again, "the IDs are stable")
+		})
+	}
+}
+
+func TestFetcher_FetchCached(t *testing.T) {
+	files := map[string][]byte{"/catalog.json": []byte(testBundle)}
+	ts := newBundleServer(t, files)
+	fetcher := newTestFetcher(t, ts)
+	sub := Subscription{Name: "platform", Location: ts.URL + "/catalog.json"}
+
+	cmds, err := fetcher.Fetch(context.Background(), sub)
+	require.NoError(t, err)
+
+	// the server is down.
+	delete(files, "/catalog.json")
+
+	cached, err := fetcher.Fetch(context.Background(), sub)
+	require.NoError(t, err)
+	assert.Equal(t, cmds, cached)
+}
+
+func TestFetcher_FetchPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalog.json")
+	require.NoError(t, os.WriteFile(path, []byte(testBundle), 0o640))
+
+	fetcher := NewFetcher(slog.New(slog.NewTextHandler(io.Discard, nil)), t.TempDir())
+
+	cmds, err := fetcher.Fetch(context.Background(), Subscription{Name: "team", Location: path})
+	require.NoError(t, err)
+	require.Len(t, cmds, 2)
+	assert.Equal(t, "team", cmds[1].Source)
+}
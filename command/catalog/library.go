@@ -0,0 +1,285 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+)
+
+// ErrReadOnly thrown when changing a command of a subscription.
+var ErrReadOnly = errors.New("read-only command: copy it into the library for changing it")
+
+type library interface {
+	GetAll(context.Context) ([]command.Command, error)
+	GetOne(context.Context, string) (command.Command, error)
+	Search(context.Context, string) ([]command.SearchResult, error)
+	Add(context.Context, command.Command) (command.Command, error)
+	DeleteCommand(context.Context, string) error
+	UpdateCommand(context.Context, command.Command) (command.Command, error)
+	WriteExplanation(context.Context, uuid.UUID, command.Explanation) error
+	ReadExplanation(context.Context, uuid.UUID) (command.Explanation, error)
+	DeleteExplanation(context.Context, uuid.UUID) error
+	WriteTranscript(context.Context, uuid.UUID, []command.Message) error
+	ReadTranscript(context.Context, uuid.UUID) ([]command.Message, error)
+	InsertUsage(context.Context, uuid.UUID, command.Usage) error
+	GetHistory(context.Context, uuid.UUID) (command.History, error)
+	Revisions(context.Context, uuid.UUID) ([]command.Revision, error)
+	RestoreRevision(context.Context, uuid.UUID, int64) (command.Command, error)
+	Trash(context.Context) ([]command.DeletedCommand, error)
+	RestoreCommand(context.Context, uuid.UUID) (command.Command, error)
+	PurgeCommand(context.Context, uuid.UUID) error
+}
+
+// embeddingIndex is implemented by the libraries keeping the embeddings of the commands.
+type embeddingIndex interface {
+	IndexEmbeddings(context.Context) (int, error)
+}
+
+// Library adds the commands of the subscriptions to the local library, as read-only.
+//
+// The notebook and the history of the subscribed commands aren't stored,
+// they only live in the local library once the command is copied.
+type Library struct {
+	library
+	logger *slog.Logger
+
+	mu       sync.RWMutex
+	commands []command.Command
+	byID     map[uuid.UUID]command.Command
+}
+
+// NewLibrary returns a new Library over the local one.
+func NewLibrary(local library, logger *slog.Logger) (*Library, error) {
+	if local == nil {
+		return nil, errors.New("nil library")
+	}
+
+	return &Library{
+		library: local,
+		logger:  logger,
+		byID:    make(map[uuid.UUID]command.Command),
+	}, nil
+}
+
+// Load fetches the subscriptions, replacing the subscribed commands.
+// A subscription that can't be fetched is skipped.
+func (l *Library) Load(ctx context.Context, fetcher *Fetcher, subs []Subscription) {
+	commands := make([]command.Command, 0)
+	for _, sub := range subs {
+		cmds, err := fetcher.Fetch(ctx, sub)
+		if err != nil {
+			l.logger.Error("error loading subscription", slog.String("subscription", sub.Name), slog.Any("error", err))
+			continue
+		}
+		commands = append(commands, cmds...)
+	}
+
+	l.Set(commands)
+}
+
+// Set replaces the subscribed commands.
+func (l *Library) Set(cmds []command.Command) {
+	sort.SliceStable(cmds, func(i, j int) bool {
+		if cmds[i].Source != cmds[j].Source {
+			return cmds[i].Source < cmds[j].Source
+		}
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	byID := make(map[uuid.UUID]command.Command, len(cmds))
+	for _, cmd := range cmds {
+		byID[cmd.ID] = cmd
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.commands = cmds
+	l.byID = byID
+}
+
+// GetAll returns the local commands followed by the subscribed ones.
+func (l *Library) GetAll(ctx context.Context) ([]command.Command, error) {
+	cmds, err := l.library.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append(cmds, l.commands...), nil
+}
+
+// GetOne returns a command by ID, local or subscribed.
+func (l *Library) GetOne(ctx context.Context, rawID string) (command.Command, error) {
+	if cmd, ok := l.subscribed(rawID); ok {
+		return cmd, nil
+	}
+	return l.library.GetOne(ctx, rawID)
+}
+
+// Search returns the matching local commands followed by the subscribed ones.
+func (l *Library) Search(ctx context.Context, term string) ([]command.SearchResult, error) {
+	results, err := l.library.Search(ctx, term)
+	if err != nil {
+		return nil, err
+	}
+
+	// the local search already rejected the invalid queries.
+	q, _ := search.Parse(term)
+	if q.Semantic {
+		// the subscribed commands have no embeddings.
+		return results, nil
+	}
+	now := time.Now()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// the usages of the subscribed commands aren't tracked.
+	cmds := make([]command.Command, 0, len(l.commands))
+	for _, cmd := range l.commands {
+		if q.Match(cmd, nil, now) {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	subscribed := search.Rank(q, search.Sources{
+		Indexed:  search.Prefix(cmds, search.Tokenize(q.Text())),
+		Commands: cmds,
+		Now:      now,
+	})
+	return append(results, subscribed...), nil
+}
+
+// IndexEmbeddings computes the missing embeddings of the local commands, the subscribed ones aren't
+// searched by meaning.
+func (l *Library) IndexEmbeddings(ctx context.Context) (int, error) {
+	index, ok := l.library.(embeddingIndex)
+	if !ok {
+		return 0, nil
+	}
+	return index.IndexEmbeddings(ctx)
+}
+
+// Add adds the command to the local library, e.g. a copy of a subscribed one.
+func (l *Library) Add(ctx context.Context, cmd command.Command) (command.Command, error) {
+	cmd.Source = ""
+	return l.library.Add(ctx, cmd)
+}
+
+// DeleteCommand moves a local command to the trash.
+func (l *Library) DeleteCommand(ctx context.Context, rawID string) error {
+	if _, ok := l.subscribed(rawID); ok {
+		return ErrReadOnly
+	}
+	return l.library.DeleteCommand(ctx, rawID)
+}
+
+// UpdateCommand updates a local command.
+func (l *Library) UpdateCommand(ctx context.Context, cmd command.Command) (command.Command, error) {
+	if _, ok := l.subscribed(cmd.ID.String()); ok {
+		return command.Command{}, ErrReadOnly
+	}
+	return l.library.UpdateCommand(ctx, cmd)
+}
+
+// WriteExplanation writes the explanation of a local command. It's a no-op for the subscribed ones.
+func (l *Library) WriteExplanation(ctx context.Context, id uuid.UUID, explanation command.Explanation) error {
+	if _, ok := l.subscribed(id.String()); ok {
+		return nil
+	}
+	return l.library.WriteExplanation(ctx, id, explanation)
+}
+
+// ReadExplanation reads the explanation of a local command.
+func (l *Library) ReadExplanation(ctx context.Context, id uuid.UUID) (command.Explanation, error) {
+	if _, ok := l.subscribed(id.String()); ok {
+		return command.Explanation{}, manager.ErrElementNotFound
+	}
+	return l.library.ReadExplanation(ctx, id)
+}
+
+// DeleteExplanation removes the explanation of a local command. It's a no-op for the subscribed ones.
+func (l *Library) DeleteExplanation(ctx context.Context, id uuid.UUID) error {
+	if _, ok := l.subscribed(id.String()); ok {
+		return nil
+	}
+	return l.library.DeleteExplanation(ctx, id)
+}
+
+// WriteTranscript writes the follow-up conversation of a local command. It's a no-op for the subscribed ones.
+func (l *Library) WriteTranscript(ctx context.Context, id uuid.UUID, transcript []command.Message) error {
+	if _, ok := l.subscribed(id.String()); ok {
+		return nil
+	}
+	return l.library.WriteTranscript(ctx, id, transcript)
+}
+
+// ReadTranscript reads the follow-up conversation of a local command.
+func (l *Library) ReadTranscript(ctx context.Context, id uuid.UUID) ([]command.Message, error) {
+	if _, ok := l.subscribed(id.String()); ok {
+		return nil, manager.ErrElementNotFound
+	}
+	return l.library.ReadTranscript(ctx, id)
+}
+
+// InsertUsage records the usage of a local command. It's a no-op for the subscribed ones.
+func (l *Library) InsertUsage(ctx context.Context, id uuid.UUID, usage command.Usage) error {
+	if _, ok := l.subscribed(id.String()); ok {
+		return nil
+	}
+	return l.library.InsertUsage(ctx, id, usage)
+}
+
+// GetHistory returns the usages of a local command. The subscribed ones have no history.
+func (l *Library) GetHistory(ctx context.Context, id uuid.UUID) (command.History, error) {
+	if _, ok := l.subscribed(id.String()); ok {
+		return command.History{Usages: []command.Usage{}}, nil
+	}
+	return l.library.GetHistory(ctx, id)
+}
+
+// Revisions returns the prior versions of a local command. The subscribed ones have no revisions.
+func (l *Library) Revisions(ctx context.Context, id uuid.UUID) ([]command.Revision, error) {
+	if _, ok := l.subscribed(id.String()); ok {
+		return []command.Revision{}, nil
+	}
+	return l.library.Revisions(ctx, id)
+}
+
+// RestoreRevision restores a revision of a local command.
+func (l *Library) RestoreRevision(ctx context.Context, id uuid.UUID, revisionID int64) (command.Command, error) {
+	if _, ok := l.subscribed(id.String()); ok {
+		return command.Command{}, ErrReadOnly
+	}
+	return l.library.RestoreRevision(ctx, id, revisionID)
+}
+
+// PurgeCommand removes permanently a local command.
+func (l *Library) PurgeCommand(ctx context.Context, id uuid.UUID) error {
+	if _, ok := l.subscribed(id.String()); ok {
+		return ErrReadOnly
+	}
+	return l.library.PurgeCommand(ctx, id)
+}
+
+func (l *Library) subscribed(rawID string) (command.Command, bool) {
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return command.Command{}, false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	cmd, ok := l.byID[id]
+	return cmd, ok
+}
@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/file"
+	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+)
+
+func TestLibrary(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := file.New(logger, t.TempDir())
+	require.NoError(t, err)
+	mng, err := manager.NewManager(store, store)
+	require.NoError(t, err)
+
+	local, err := mng.Add(ctx, command.Command{Name: "disk", Command: "du -sh ."})
+	require.NoError(t, err)
+
+	lib, err := NewLibrary(&mng, logger)
+	require.NoError(t, err)
+
+	nodes := command.Command{ID: uuid.New(), Name: "nodes", Command: "kubectl get nodes", Source: "platform"}
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods", Source: "platform"}
+	lib.Set([]command.Command{pods, nodes})
+
+	t.Run("list", func(t *testing.T) {
+		cmds, err := lib.GetAll(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []command.Command{local, nodes, pods}, cmds)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		results, err := lib.Search(ctx, "kube pods")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, pods, results[0].Command)
+		assert.Equal(t, []int{0, 1, 2, 3}, results[0].Matches)
+
+		results, err = lib.Search(ctx, "du")
+		require.NoError(t, err)
+		assert.Equal(t, local.ID, results[0].Command.ID)
+
+		results, err = lib.Search(ctx, "ndoes")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, nodes, results[0].Command, "typos match the subscribed commands")
+
+		results, err = lib.Search(ctx, "kubectl -name:pods")
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, nodes, results[0].Command, "the filters apply to the subscribed commands")
+
+		_, err = lib.Search(ctx, "tag:")
+		assert.ErrorIs(t, err, search.ErrInvalidQuery)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		got, err := lib.GetOne(ctx, pods.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, pods, got)
+
+		got, err = lib.GetOne(ctx, local.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, local.ID, got.ID)
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		_, err := lib.UpdateCommand(ctx, pods)
+		assert.ErrorIs(t, err, ErrReadOnly)
+		assert.ErrorIs(t, lib.DeleteCommand(ctx, pods.ID.String()), ErrReadOnly)
+		assert.ErrorIs(t, lib.PurgeCommand(ctx, pods.ID), ErrReadOnly)
+
+		assert.NoError(t, lib.InsertUsage(ctx, pods.ID, command.Usage{Command: "kubectl get pods"}))
+		history, err := lib.GetHistory(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Empty(t, history.Usages)
+
+		_, err = lib.RestoreRevision(ctx, pods.ID, 1)
+		assert.ErrorIs(t, err, ErrReadOnly)
+		revisions, err := lib.Revisions(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		assert.NoError(t, lib.WriteExplanation(ctx, pods.ID, command.Explanation{Content: "Lists the pods."}))
+		_, err = lib.ReadExplanation(ctx, pods.ID)
+		assert.ErrorIs(t, err, manager.ErrElementNotFound)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		fork := pods
+		fork.ID = uuid.Nil
+
+		added, err := lib.Add(ctx, fork)
+		require.NoError(t, err)
+		assert.Empty(t, added.Source, "the copy is local")
+
+		got, err := mng.GetOne(ctx, added.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods", got.Command)
+
+		_, err = lib.UpdateCommand(ctx, got)
+		assert.NoError(t, err, "the copy can be changed")
+	})
+}
@@ -2,20 +2,31 @@ package command
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command/shell"
 )
 
 var regex = regexp.MustCompile(`{{\s?\.\w+\s?}}`)
 
-// ErrInvalidNumOfParams returned when the number of params provided doesn't match the command
-var ErrInvalidNumOfParams = errors.New("invalid number of params provided")
+var (
+	// ErrInvalidNumOfParams returned when the number of params provided doesn't match the command
+	ErrInvalidNumOfParams = errors.New("invalid number of params provided")
+	// ErrNotFound returned by the stores when the searched element wasn't found.
+	ErrNotFound = errors.New("not found")
+)
 
 type (
 	// Command represents a shell.
@@ -25,6 +36,24 @@ type (
 		Description string
 		Command     string
 		Params      []Parameter
+		// Source is the name of the subscription the command comes from. Empty for the local library.
+		Source string
+		// Tags label the command, e.g. with the environment it targets. Lowercase and sorted.
+		Tags []string
+		// Target is where the command runs by default, e.g. ssh:host. Empty for the local shell.
+		Target string
+		// Env are the environment variables the command runs with, their values can use the params.
+		Env []EnvVar
+		// Dir is the working directory the command runs in, the current one if empty. It can use the params.
+		Dir string
+		// Requires are the programs the command needs besides the ones of its command line, e.g. the ones of a script. Sorted.
+		Requires []string
+	}
+
+	// EnvVar is an environment variable of a command.
+	EnvVar struct {
+		Name  string
+		Value string
 	}
 
 	// Parameter represents the Command Parameter
@@ -49,9 +78,108 @@ type (
 	Usage struct {
 		Command   string
 		Timestamp time.Time
+		// Target is where the command ran, e.g. ssh:host. Empty for the local shell.
+		Target string
+		// Result is set when the command was run inside clio.
+		Result *RunResult
+	}
+
+	// RunResult is the outcome of a command run inside clio.
+	RunResult struct {
+		ExitCode int
+		Duration time.Duration
+	}
+
+	// Revision is a prior version of a command, kept when the command is updated.
+	Revision struct {
+		ID        int64
+		Command   Command
+		CreatedAt time.Time
+	}
+
+	// DeletedCommand is a command in the trash.
+	DeletedCommand struct {
+		Command   Command
+		DeletedAt time.Time
+	}
+
+	// SearchResult is a command matching a search, with the relevance of the match.
+	SearchResult struct {
+		Command Command
+		// Score is the relevance of the match, higher is better.
+		Score float64
+		// Matches are the indexes of the runes of the name matching the search.
+		Matches []int
+		// Source is the content that matched when the name, command and description didn't, e.g. the explanation.
+		Source MatchSource
+		// Snippet is an excerpt of the content that matched, with the matching words between « and ».
+		Snippet string
+	}
+
+	// ContentMatch is a command whose content other than the name, command and description matched a search.
+	ContentMatch struct {
+		Command Command
+		Source  MatchSource
+		// Snippet is an excerpt of the content that matched, with the matching words between « and ».
+		Snippet string
+	}
+
+	// MatchSource is the content of a command matched by a search.
+	MatchSource string
+
+	// Embedding is the vector representing the meaning of a command, for the semantic search.
+	Embedding struct {
+		CommandID uuid.UUID
+		// Model is the model that computed the vector, the vectors of different models can't be compared.
+		Model string
+		// Hash is the hash of the text embedded, for detecting the outdated vectors.
+		Hash   string
+		Vector []float32
+	}
+
+	// Message is a single entry of a conversation about the command.
+	Message struct {
+		Role    Role   `json:"role"`
+		Content string `json:"content"`
+	}
+
+	// Role is the author of a conversation message.
+	Role string
+
+	// Explanation holds the explanation of the command.
+	Explanation struct {
+		Content   string
+		Signature ExplanationSignature
+	}
+
+	// ExplanationSignature identifies the inputs used for generating an explanation.
+	ExplanationSignature struct {
+		CommandHash   string
+		Source        string
+		PromptVersion string
+		// Template is the name of the prompt template used.
+		Template string
 	}
 )
 
+const (
+	// SystemRole used for the instructions given to the professor source.
+	SystemRole Role = "system"
+	// UserRole used for the messages written by the user.
+	UserRole Role = "user"
+	// AssistantRole used for the messages written by the professor.
+	AssistantRole Role = "assistant"
+)
+
+const (
+	// ParameterMatch is a match in the name or description of a parameter.
+	ParameterMatch MatchSource = "parameter"
+	// ExplanationMatch is a match in the cached explanation.
+	ExplanationMatch MatchSource = "explanation"
+	// HistoryMatch is a match in a past usage.
+	HistoryMatch MatchSource = "history"
+)
+
 type cmdOpt func(*Command) error
 
 // New returns a new Command.
@@ -86,6 +214,12 @@ func (c *Command) Build() error {
 	}
 
 	news := parseParams(c.Command)
+	// the params only used by the env or the working directory go after the ones of the command.
+	for _, param := range parseParams(c.settingsTemplate()) {
+		if !slices.ContainsFunc(news, func(p Parameter) bool { return p.Name == param.Name }) {
+			news = append(news, param)
+		}
+	}
 	params := make([]Parameter, 0, len(news))
 	for _, param := range news {
 		for j := 0; j < len(c.Params); j++ {
@@ -101,33 +235,178 @@ func (c *Command) Build() error {
 	}
 
 	c.Params = params
+	c.Tags = NormalizeTags(c.Tags)
+	c.Target = strings.TrimSpace(c.Target)
+	c.Dir = strings.TrimSpace(c.Dir)
+	c.Requires = NormalizeRequires(c.Requires)
 	return nil
 }
 
 // Compile returns the command with the arguments applied.
 func (c *Command) Compile(args []Argument) (string, error) {
-	tmpl, err := template.New(c.Name).Parse(c.Command)
+	if len(args) != len(c.Params) {
+		return "", ErrInvalidNumOfParams
+	}
+
+	cmd, err := compileTemplate(c.Name, c.Command, arguments(args))
 	if err != nil {
 		return "", fmt.Errorf("invalid command: %w", err)
 	}
+	return cmd, nil
+}
 
-	if len(args) != len(c.Params) {
-		return "", ErrInvalidNumOfParams
+// Invocation returns the command, its env and its working directory with the arguments applied.
+func (c *Command) Invocation(args []Argument) (Invocation, error) {
+	cmd, err := c.Compile(args)
+	if err != nil {
+		return Invocation{}, err
+	}
+
+	values := arguments(args)
+	inv := Invocation{Command: cmd}
+	for _, env := range c.Env {
+		value, err := compileTemplate(env.Name, env.Value, values)
+		if err != nil {
+			return Invocation{}, fmt.Errorf("invalid env var %s: %w", env.Name, err)
+		}
+		inv.Env = append(inv.Env, EnvVar{Name: env.Name, Value: value})
 	}
 
-	arguments := make(map[string]string, len(args))
+	if c.Dir != "" {
+		inv.Dir, err = compileTemplate("dir", c.Dir, values)
+		if err != nil {
+			return Invocation{}, fmt.Errorf("invalid working directory: %w", err)
+		}
+	}
+
+	return inv, nil
+}
+
+// settingsTemplate returns the env values and the working directory, for finding their params.
+func (c *Command) settingsTemplate() string {
+	texts := make([]string, 0, len(c.Env)+1)
+	for _, env := range c.Env {
+		texts = append(texts, env.Value)
+	}
+	return strings.Join(append(texts, c.Dir), "\n")
+}
+
+func arguments(args []Argument) map[string]string {
+	values := make(map[string]string, len(args))
 	for _, arg := range args {
-		arguments[arg.Name] = arg.Value
+		values[arg.Name] = arg.Value
+	}
+	return values
+}
+
+func compileTemplate(name, text string, values map[string]string) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
 	}
 
 	var buffer bytes.Buffer
-	if err := tmpl.Execute(&buffer, arguments); err != nil {
+	if err := tmpl.Execute(&buffer, values); err != nil {
 		return "", err
 	}
 
 	return buffer.String(), nil
 }
 
+// ReadOnly returns if the command comes from a subscription, and can't be changed.
+func (c *Command) ReadOnly() bool {
+	return c.Source != ""
+}
+
+// Programs returns the programs the command needs: the ones run by its command line and the required ones.
+// The shell builtins, the relative paths and the programs set by a param or a variable are skipped.
+func (c *Command) Programs() []string {
+	programs := make([]string, 0)
+	for _, segment := range shell.Split(c.Command) {
+		name := segment.Runs()
+		if shell.Builtin(name) || strings.ContainsAny(name, "${}()`") || (strings.Contains(name, "/") && !filepath.IsAbs(name)) {
+			continue
+		}
+		if !slices.Contains(programs, name) {
+			programs = append(programs, name)
+		}
+	}
+
+	for _, name := range c.Requires {
+		if !slices.Contains(programs, name) {
+			programs = append(programs, name)
+		}
+	}
+	return programs
+}
+
+// Hash returns the hash of the command template.
+func (c *Command) Hash() string {
+	sum := sha256.Sum256([]byte(c.Command))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reason returns why the command matched the search, e.g. "matched in explanation".
+// Empty when the name, command or description matched.
+func (r SearchResult) Reason() string {
+	if r.Source == "" {
+		return ""
+	}
+	return "matched in " + string(r.Source)
+}
+
+// EmbeddingText returns the text of the command embedded for the semantic search.
+func (c *Command) EmbeddingText() string {
+	return strings.Join([]string{c.Name, c.Description, c.Command}, "\n")
+}
+
+// EmbeddingHash returns the hash of the text embedded for the semantic search.
+func (c *Command) EmbeddingHash() string {
+	sum := sha256.Sum256([]byte(c.EmbeddingText()))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseTags returns the tags in the text, separated by commas or spaces.
+func ParseTags(raw string) []string {
+	return NormalizeTags(strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}))
+}
+
+// NormalizeTags returns the tags trimmed, lowercase, sorted and without duplicates. Nil if there's none.
+func NormalizeTags(tags []string) []string {
+	var out []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// ParseRequires returns the required programs in the text, separated by commas or spaces.
+func ParseRequires(raw string) []string {
+	return NormalizeRequires(strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}))
+}
+
+// NormalizeRequires returns the required programs trimmed, sorted and without duplicates. Nil if there's none.
+// Unlike the tags, the case is kept, the names of the programs are case sensitive.
+func NormalizeRequires(programs []string) []string {
+	var out []string
+	for _, program := range programs {
+		program = strings.TrimSpace(program)
+		if program != "" && !slices.Contains(out, program) {
+			out = append(out, program)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
 func parseParams(raw string) []Parameter {
 	rawParams := regex.FindAllString(raw, -1)
 
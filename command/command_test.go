@@ -167,3 +167,84 @@ func TestCommand_Compile(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_Hash(t *testing.T) {
+	cmd := Command{Name: "echo", Command: "echo '{{.text}}'"}
+	same := Command{Name: "other name", Command: "echo '{{.text}}'"}
+	changed := Command{Name: "echo", Command: "echo -n '{{.text}}'"}
+
+	assert.Equal(t, cmd.Hash(), same.Hash(), "hash should only depend on the command")
+	assert.NotEqual(t, cmd.Hash(), changed.Hash(), "hash should change with the command")
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{raw: "prod, k8s", expected: []string{"k8s", "prod"}},
+		{raw: " Prod prod,,db ", expected: []string{"db", "prod"}},
+		{raw: " , ", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseTags(tt.raw))
+		})
+	}
+}
+
+func TestParseRequires(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected []string
+	}{
+		{raw: "psql, pg_dump", expected: []string{"pg_dump", "psql"}},
+		{raw: " MyTool mytool,,MyTool ", expected: []string{"MyTool", "mytool"}},
+		{raw: " , ", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseRequires(tt.raw))
+		})
+	}
+}
+
+func TestCommand_Programs(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      Command
+		expected []string
+	}{
+		{
+			name:     "pipeline",
+			cmd:      Command{Command: "sudo journalctl -u {{.unit}} | grep -i error | tail -n 20"},
+			expected: []string{"journalctl", "grep", "tail"},
+		},
+		{
+			name:     "builtins",
+			cmd:      Command{Command: "cd /var/log && for f in *.log; do gzip $f; done"},
+			expected: []string{"gzip"},
+		},
+		{
+			name:     "params, variables and relative paths",
+			cmd:      Command{Command: "{{.editor}} notes.md; $PAGER notes.md; ./deploy.sh; /usr/local/bin/backup"},
+			expected: []string{"/usr/local/bin/backup"},
+		},
+		{
+			name: "required",
+			cmd: Command{
+				Command:  "pg_dump {{.db}} | gzip > dump.gz",
+				Requires: []string{"gzip", "psql"},
+			},
+			expected: []string{"pg_dump", "gzip", "psql"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.cmd.Programs())
+		})
+	}
+}
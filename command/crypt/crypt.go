@@ -0,0 +1,108 @@
+// Package crypt encrypts the sensitive values of the store with AES-GCM, using a key derived from a passphrase.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// prefix marks the encrypted values, the version allows changing the format later.
+const prefix = "enc:v1:"
+
+const (
+	saltSize = 16
+	keySize  = 32
+
+	// argon2id parameters, see the recommendations of RFC 9106.
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+)
+
+var (
+	// ErrMissingPassphrase used when the passphrase is empty.
+	ErrMissingPassphrase = errors.New("missing passphrase")
+	// ErrDecrypt used when the value can't be decrypted, e.g. the key isn't the one that encrypted it.
+	ErrDecrypt = errors.New("error decrypting value")
+)
+
+// Cipher encrypts and decrypts the values. A nil Cipher leaves the values as they are.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt for deriving the key.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+	return salt, nil
+}
+
+// New returns a Cipher with the key derived from the passphrase and the salt.
+func New(passphrase string, salt []byte) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, ErrMissingPassphrase
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, argonTime, argonMemory, argonThreads, keySize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypt returns the value encrypted and encoded as text.
+func (c *Cipher) Encrypt(value string) (string, error) {
+	if c == nil {
+		return value, nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plain value. The values not encrypted are returned as they are.
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if c == nil || !IsEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrDecrypt
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plain), nil
+}
+
+// IsEncrypted reports whether the value was encrypted by a Cipher.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
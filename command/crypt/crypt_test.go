@@ -0,0 +1,88 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCipher(t *testing.T) {
+	salt, err := NewSalt()
+	require.NoError(t, err)
+
+	c, err := New("correct horse battery staple", salt)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "command", value: "psql -h db.internal -U admin -W hunter2"},
+		{name: "empty", value: ""},
+		{name: "unicode", value: "echo ✓ ñandú"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := c.Encrypt(tt.value)
+			require.NoError(t, err)
+			assert.True(t, IsEncrypted(encrypted))
+			if tt.value != "" {
+				assert.NotContains(t, encrypted, tt.value)
+			}
+
+			again, err := c.Encrypt(tt.value)
+			require.NoError(t, err)
+			assert.NotEqual(t, encrypted, again, "every value gets its own nonce")
+
+			got, err := c.Decrypt(encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		encrypted, err := c.Encrypt("secret")
+		require.NoError(t, err)
+
+		other, err := New("wrong", salt)
+		require.NoError(t, err)
+
+		_, err = other.Decrypt(encrypted)
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		encrypted, err := c.Encrypt("secret")
+		require.NoError(t, err)
+
+		_, err = c.Decrypt(encrypted[:len(encrypted)-2] + "AA")
+		assert.ErrorIs(t, err, ErrDecrypt)
+
+		_, err = c.Decrypt(prefix + "!")
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("plain value", func(t *testing.T) {
+		got, err := c.Decrypt("ls -la")
+		require.NoError(t, err)
+		assert.Equal(t, "ls -la", got)
+	})
+
+	t.Run("nil cipher", func(t *testing.T) {
+		var none *Cipher
+		got, err := none.Encrypt("ls -la")
+		require.NoError(t, err)
+		assert.Equal(t, "ls -la", got)
+
+		got, err = none.Decrypt("ls -la")
+		require.NoError(t, err)
+		assert.Equal(t, "ls -la", got)
+	})
+
+	t.Run("missing passphrase", func(t *testing.T) {
+		_, err := New("", salt)
+		assert.ErrorIs(t, err, ErrMissingPassphrase)
+	})
+}
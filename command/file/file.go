@@ -0,0 +1,938 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/search"
+)
+
+const (
+	commandsDir = "commands"
+	notebookDir = "notebook"
+	historyDir  = "history"
+	revisionDir = "revisions"
+	trashDir    = "trash"
+
+	commandExt = ".toml"
+	historyExt = ".jsonl"
+
+	dirPerm  = 0o740
+	filePerm = 0o640
+)
+
+var (
+	// ErrNotFound used when the searched element wasn't found.
+	ErrNotFound = command.ErrNotFound
+	// ErrModifiedExternally thrown when writing a command whose file was changed by someone else since it was read.
+	ErrModifiedExternally = errors.New("command file modified externally")
+)
+
+// Store keeps the library in a directory with one human-readable TOML file per command,
+// e.g. for versioning it in a dotfiles repository.
+//
+// Layout:
+//
+//	commands/<id>.toml  the command and its parameters.
+//	notebook/<id>.toml  the cached explanation and follow-ups.
+//	history/<id>.jsonl  the usages, one per line.
+//	revisions/<id>.jsonl  the prior versions of the command, one per line.
+//	trash/<id>.toml  the deleted commands, until they are restored or purged.
+type Store struct {
+	dir    string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	index map[uuid.UUID]entry
+}
+
+// entry is a command loaded in the index, with the state of its file when read.
+type entry struct {
+	cmd     command.Command
+	modTime time.Time
+	size    int64
+}
+
+type (
+	commandFile struct {
+		ID          uuid.UUID   `toml:"id"`
+		Name        string      `toml:"name"`
+		Description string      `toml:"description,omitempty"`
+		Command     string      `toml:"command"`
+		Params      []paramFile `toml:"params,omitempty"`
+		Tags        []string    `toml:"tags,omitempty"`
+		Target      string      `toml:"target,omitempty"`
+		Dir         string      `toml:"dir,omitempty"`
+		Env         []envFile   `toml:"env,omitempty"`
+		Requires    []string    `toml:"requires,omitempty"`
+	}
+
+	envFile struct {
+		Name  string `toml:"name"`
+		Value string `toml:"value"`
+	}
+
+	paramFile struct {
+		ID          uuid.UUID `toml:"id"`
+		Name        string    `toml:"name"`
+		Description string    `toml:"description,omitempty"`
+		Default     string    `toml:"default,omitempty"`
+	}
+
+	notebookFile struct {
+		Explanation   string `toml:"explanation,omitempty"`
+		CommandHash   string `toml:"commandHash,omitempty"`
+		Source        string `toml:"source,omitempty"`
+		PromptVersion string `toml:"promptVersion,omitempty"`
+		Template      string `toml:"template,omitempty"`
+		Transcript    string `toml:"transcript,omitempty"`
+	}
+
+	usageLine struct {
+		Usage     string    `json:"usage"`
+		Timestamp time.Time `json:"timestamp"`
+		Target    string    `json:"target,omitempty"`
+		// the result of the runs inside clio.
+		ExitCode *int  `json:"exitCode,omitempty"`
+		Duration int64 `json:"durationMs,omitempty"`
+	}
+
+	revisionLine struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description,omitempty"`
+		Command     string      `json:"command"`
+		Params      []paramLine `json:"params,omitempty"`
+		Timestamp   time.Time   `json:"timestamp"`
+	}
+
+	paramLine struct {
+		ID          uuid.UUID `json:"id"`
+		Name        string    `json:"name"`
+		Description string    `json:"description,omitempty"`
+		Default     string    `json:"default,omitempty"`
+	}
+)
+
+// New returns a new file Store over the directory, creating it if needed.
+func New(logger *slog.Logger, dir string) (*Store, error) {
+	for _, sub := range []string{commandsDir, notebookDir, historyDir, revisionDir, trashDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), dirPerm); err != nil {
+			return nil, fmt.Errorf("error preparing the store dir: %w", err)
+		}
+	}
+
+	store := &Store{
+		dir:    dir,
+		logger: logger,
+		index:  make(map[uuid.UUID]entry),
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := store.refresh(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Save stores the command in its file.
+// It fails with ErrModifiedExternally if the file changed since it was read.
+func (s *Store) Save(_ context.Context, cmd command.Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.commandPath(cmd.ID)
+	if curr, ok := s.index[cmd.ID]; ok {
+		info, err := os.Stat(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error checking command file: %w", err)
+		}
+		if err == nil && changed(curr, info) {
+			return fmt.Errorf("%w: %s", ErrModifiedExternally, path)
+		}
+	}
+
+	raw, err := encodeCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	info, err := writeAtomic(path, raw)
+	if err != nil {
+		return fmt.Errorf("error storing command: %w", err)
+	}
+
+	// a saved command is no longer deleted.
+	if err := os.Remove(s.trashPath(cmd.ID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing command from the trash: %w", err)
+	}
+
+	s.index[cmd.ID] = entry{cmd: cmd, modTime: info.ModTime(), size: info.Size()}
+	s.logger.Debug("command stored successfully", slog.String("path", path))
+	return nil
+}
+
+// ListCommands returns a list of all the commands.
+func (s *Store) ListCommands(_ context.Context) ([]command.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]command.Command, 0, len(s.index))
+	for _, e := range s.index {
+		cmds = append(cmds, e.cmd)
+	}
+	sortCommands(cmds)
+
+	return cmds, nil
+}
+
+// GetCommandByID returns a command. If the command doesn't exists, returns an ErrNotFound error.
+func (s *Store) GetCommandByID(_ context.Context, id uuid.UUID) (command.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return command.Command{}, err
+	}
+
+	e, ok := s.index[id]
+	if !ok {
+		return command.Command{}, ErrNotFound
+	}
+
+	return e.cmd, nil
+}
+
+// SearchCommand returns a list of the commands with the matching term, best matches first.
+func (s *Store) SearchCommand(_ context.Context, term string) ([]command.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]command.Command, 0, len(s.index))
+	for _, e := range s.index {
+		cmds = append(cmds, e.cmd)
+	}
+
+	return search.Prefix(cmds, search.Tokenize(term)), nil
+}
+
+// SearchContent returns the commands whose parameters or history match the term, with an excerpt of the first match
+// of each. The explanations are stored compressed by the manager, so they aren't searched.
+func (s *Store) SearchContent(_ context.Context, term string) ([]command.ContentMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := make([]command.ContentMatch, 0)
+	tokens := search.Tokenize(term)
+	if len(tokens) == 0 {
+		return matches, nil
+	}
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]command.Command, 0, len(s.index))
+	for _, e := range s.index {
+		cmds = append(cmds, e.cmd)
+	}
+	sortCommands(cmds)
+
+	for _, cmd := range cmds {
+		match := command.ContentMatch{Command: cmd}
+		for _, param := range cmd.Params {
+			if snippet, ok := search.Excerpt(strings.TrimSpace(param.Name+" "+param.Description), tokens); ok {
+				match.Source, match.Snippet = command.ParameterMatch, snippet
+				break
+			}
+		}
+
+		if match.Source == "" {
+			usages, err := s.readHistory(cmd.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, usage := range usages {
+				if snippet, ok := search.Excerpt(usage.Command, tokens); ok {
+					match.Source, match.Snippet = command.HistoryMatch, snippet
+					break
+				}
+			}
+		}
+
+		if match.Source != "" {
+			matches = append(matches, match)
+		}
+	}
+
+	return matches, nil
+}
+
+// FilterCommands returns the commands passing the filters of the query, the terms aren't matched.
+func (s *Store) FilterCommands(_ context.Context, q search.Query) ([]command.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cmds := make([]command.Command, 0, len(s.index))
+	for id, e := range s.index {
+		var times []time.Time
+		// the history is only read when filtering by usage.
+		if len(q.Usage) > 0 {
+			usages, err := s.readHistory(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, usage := range usages {
+				times = append(times, usage.Timestamp)
+			}
+		}
+
+		if q.Match(e.cmd, times, now) {
+			cmds = append(cmds, e.cmd)
+		}
+	}
+	sortCommands(cmds)
+
+	return cmds, nil
+}
+
+// DeleteCommand removes permanently a command, its notebook, history and revisions.
+func (s *Store) DeleteCommand(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, path := range []string{s.commandPath(id), s.trashPath(id), s.notebookPath(id), s.historyPath(id), s.revisionsPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing command with ID %q: %w", id, err)
+		}
+	}
+
+	delete(s.index, id)
+	return nil
+}
+
+// TrashCommand moves a command to the trash. If the command doesn't exists, returns an ErrNotFound error.
+// The modification time of the file in the trash is the deletion time.
+func (s *Store) TrashCommand(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := move(s.commandPath(id), s.trashPath(id)); err != nil {
+		return err
+	}
+
+	delete(s.index, id)
+	return nil
+}
+
+// RestoreCommand takes a command out of the trash. If the command isn't in the trash, returns an ErrNotFound error.
+func (s *Store) RestoreCommand(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := move(s.trashPath(id), s.commandPath(id)); err != nil {
+		return err
+	}
+
+	return s.refresh()
+}
+
+// ListTrash returns the commands in the trash, the last deleted first.
+func (s *Store) ListTrash(_ context.Context) ([]command.DeletedCommand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, trashDir))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the trash dir: %w", err)
+	}
+
+	cmds := make([]command.DeletedCommand, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		id, err := uuid.Parse(strings.TrimSuffix(name, commandExt))
+		if e.IsDir() || filepath.Ext(name) != commandExt || err != nil {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("error reading the trash dir: %w", err)
+		}
+
+		cmd, err := readCommand(s.trashPath(id))
+		if err != nil {
+			s.logger.Warn("skipping invalid command file", slog.String("file", name), slog.Any("error", err))
+			continue
+		}
+		cmd.ID = id
+
+		cmds = append(cmds, command.DeletedCommand{Command: cmd, DeletedAt: info.ModTime()})
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].DeletedAt.After(cmds[j].DeletedAt)
+	})
+
+	return cmds, nil
+}
+
+// DeleteParameters is a no-op, the parameters are stored with their command.
+func (s *Store) DeleteParameters(_ context.Context, _ []uuid.UUID) error {
+	return nil
+}
+
+// WriteExplanation writes the explanation for a command.
+func (s *Store) WriteExplanation(_ context.Context, cmdID uuid.UUID, explanation command.Explanation) error {
+	return s.updateNotebook(cmdID, func(nb *notebookFile) {
+		nb.Explanation = explanation.Content
+		nb.CommandHash = explanation.Signature.CommandHash
+		nb.Source = explanation.Signature.Source
+		nb.PromptVersion = explanation.Signature.PromptVersion
+		nb.Template = explanation.Signature.Template
+	})
+}
+
+// ReadExplanation reads the explanation of a command.
+func (s *Store) ReadExplanation(_ context.Context, cmdID uuid.UUID) (command.Explanation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nb, err := s.readNotebook(cmdID)
+	if err != nil {
+		return command.Explanation{}, err
+	}
+
+	// the entry can exist only with the transcript.
+	if nb.Explanation == "" {
+		return command.Explanation{}, ErrNotFound
+	}
+
+	return command.Explanation{
+		Content: nb.Explanation,
+		Signature: command.ExplanationSignature{
+			CommandHash:   nb.CommandHash,
+			Source:        nb.Source,
+			PromptVersion: nb.PromptVersion,
+			Template:      nb.Template,
+		},
+	}, nil
+}
+
+// DeleteExplanation removes the explanation of a command.
+func (s *Store) DeleteExplanation(_ context.Context, cmdID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.notebookPath(cmdID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error delete explanation: %w", err)
+	}
+	return nil
+}
+
+// WriteTranscript writes the follow-up conversation for a command.
+func (s *Store) WriteTranscript(_ context.Context, cmdID uuid.UUID, transcript string) error {
+	return s.updateNotebook(cmdID, func(nb *notebookFile) {
+		nb.Transcript = transcript
+	})
+}
+
+// ReadTranscript reads the follow-up conversation of a command.
+func (s *Store) ReadTranscript(_ context.Context, cmdID uuid.UUID) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nb, err := s.readNotebook(cmdID)
+	if err != nil {
+		return "", err
+	}
+
+	if nb.Transcript == "" {
+		return "", ErrNotFound
+	}
+
+	return nb.Transcript, nil
+}
+
+// InsertUsage appends the usage of a command to its history.
+func (s *Store) InsertUsage(_ context.Context, cmdID uuid.UUID, usage command.Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := usageLine{Usage: usage.Command, Timestamp: time.Now().UTC(), Target: usage.Target}
+	if usage.Result != nil {
+		entry.ExitCode = &usage.Result.ExitCode
+		entry.Duration = usage.Result.Duration.Milliseconds()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding usage: %w", err)
+	}
+
+	if err := appendLine(s.historyPath(cmdID), line); err != nil {
+		return fmt.Errorf("error writing usage: %w", err)
+	}
+
+	return nil
+}
+
+// GetHistory returns the history of usages of a given command.
+func (s *Store) GetHistory(_ context.Context, cmdID uuid.UUID) (command.History, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usages, err := s.readHistory(cmdID)
+	if err != nil {
+		return command.History{}, err
+	}
+
+	return command.History{Usages: usages}, nil
+}
+
+// ListUsages returns the times each command was used, by command.
+func (s *Store) ListUsages(_ context.Context) (map[uuid.UUID][]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[uuid.UUID][]time.Time)
+	for id := range s.index {
+		usages, err := s.readHistory(id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, usage := range usages {
+			out[id] = append(out[id], usage.Timestamp)
+		}
+	}
+
+	return out, nil
+}
+
+// readHistory returns the usages in the history file of the command, empty if there's none.
+func (s *Store) readHistory(cmdID uuid.UUID) ([]command.Usage, error) {
+	file, err := os.Open(s.historyPath(cmdID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []command.Usage{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	usages := make([]command.Usage, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var line usageLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			s.logger.Warn("skipping invalid history line", slog.String("command", cmdID.String()), slog.Any("error", err))
+			continue
+		}
+
+		usage := command.Usage{Command: line.Usage, Timestamp: line.Timestamp, Target: line.Target}
+		if line.ExitCode != nil {
+			usage.Result = &command.RunResult{
+				ExitCode: *line.ExitCode,
+				Duration: time.Duration(line.Duration) * time.Millisecond,
+			}
+		}
+		usages = append(usages, usage)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+
+	return usages, nil
+}
+
+// InsertRevision appends the version of a command, before it's changed, to its revisions.
+func (s *Store) InsertRevision(_ context.Context, cmd command.Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev := revisionLine{
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Command:     cmd.Command,
+		Params:      make([]paramLine, 0, len(cmd.Params)),
+		Timestamp:   time.Now().UTC(),
+	}
+	for _, param := range cmd.Params {
+		rev.Params = append(rev.Params, paramLine{
+			ID:          param.ID,
+			Name:        param.Name,
+			Description: param.Description,
+			Default:     param.DefaultValue,
+		})
+	}
+
+	line, err := json.Marshal(rev)
+	if err != nil {
+		return fmt.Errorf("error encoding revision: %w", err)
+	}
+
+	if err := appendLine(s.revisionsPath(cmd.ID), line); err != nil {
+		return fmt.Errorf("error writing revision: %w", err)
+	}
+
+	return nil
+}
+
+// ListRevisions returns the prior versions of a command, the newest first.
+// The ID of a revision is its line number in the file.
+func (s *Store) ListRevisions(_ context.Context, cmdID uuid.UUID) ([]command.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.Open(s.revisionsPath(cmdID))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []command.Revision{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	revisions := make([]command.Revision, 0)
+	scanner := bufio.NewScanner(file)
+	var n int64
+	for scanner.Scan() {
+		n++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var line revisionLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			s.logger.Warn("skipping invalid revision line", slog.String("command", cmdID.String()), slog.Any("error", err))
+			continue
+		}
+
+		cmd := command.Command{
+			ID:          cmdID,
+			Name:        line.Name,
+			Description: line.Description,
+			Command:     line.Command,
+			Params:      make([]command.Parameter, 0, len(line.Params)),
+		}
+		for _, param := range line.Params {
+			cmd.Params = append(cmd.Params, command.Parameter{
+				ID:           param.ID,
+				Name:         param.Name,
+				Description:  param.Description,
+				DefaultValue: param.Default,
+			})
+		}
+
+		revisions = append(revisions, command.Revision{ID: n, Command: cmd, CreatedAt: line.Timestamp})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading revisions: %w", err)
+	}
+
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// Close is a no-op, there is nothing to release.
+func (s *Store) Close() error {
+	return nil
+}
+
+// refresh syncs the index with the command files, picking up the external edits.
+// Must be called holding the lock.
+func (s *Store) refresh() error {
+	dir := filepath.Join(s.dir, commandsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading the store dir: %w", err)
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(entries))
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() || filepath.Ext(name) != commandExt {
+			continue
+		}
+
+		id, err := uuid.Parse(strings.TrimSuffix(name, commandExt))
+		if err != nil {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+
+		if curr, ok := s.index[id]; ok && !changed(curr, info) {
+			continue
+		}
+
+		cmd, err := readCommand(filepath.Join(dir, name))
+		if err != nil {
+			s.logger.Warn("skipping invalid command file", slog.String("file", name), slog.Any("error", err))
+			delete(s.index, id)
+			continue
+		}
+		// the file name is the source of truth for the ID.
+		cmd.ID = id
+
+		s.index[id] = entry{cmd: cmd, modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for id := range s.index {
+		if _, ok := seen[id]; !ok {
+			delete(s.index, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) updateNotebook(cmdID uuid.UUID, update func(*notebookFile)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nb, err := s.readNotebook(cmdID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	update(&nb)
+
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(nb); err != nil {
+		return fmt.Errorf("error encoding notebook: %w", err)
+	}
+
+	if _, err := writeAtomic(s.notebookPath(cmdID), b.Bytes()); err != nil {
+		return fmt.Errorf("error writing notebook: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) readNotebook(cmdID uuid.UUID) (notebookFile, error) {
+	var nb notebookFile
+	if _, err := toml.DecodeFile(s.notebookPath(cmdID), &nb); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return notebookFile{}, ErrNotFound
+		}
+		return notebookFile{}, fmt.Errorf("error reading notebook: %w", err)
+	}
+	return nb, nil
+}
+
+func (s *Store) commandPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, commandsDir, id.String()+commandExt)
+}
+
+func (s *Store) notebookPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, notebookDir, id.String()+commandExt)
+}
+
+func (s *Store) historyPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, historyDir, id.String()+historyExt)
+}
+
+func (s *Store) trashPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, trashDir, id.String()+commandExt)
+}
+
+func (s *Store) revisionsPath(id uuid.UUID) string {
+	return filepath.Join(s.dir, revisionDir, id.String()+historyExt)
+}
+
+// move renames the file, touching it. Returns an ErrNotFound error if the file doesn't exist.
+func move(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("error moving command file: %w", err)
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(to, now, now); err != nil {
+		return fmt.Errorf("error moving command file: %w", err)
+	}
+	return nil
+}
+
+// appendLine appends the line to the file, creating it if needed.
+// Appends of a single line are atomic enough, the file is never rewritten.
+func appendLine(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+func changed(e entry, info fs.FileInfo) bool {
+	return !e.modTime.Equal(info.ModTime()) || e.size != info.Size()
+}
+
+func encodeCommand(cmd command.Command) ([]byte, error) {
+	file := commandFile{
+		ID:          cmd.ID,
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Command:     cmd.Command,
+		Tags:        cmd.Tags,
+		Target:      cmd.Target,
+		Dir:         cmd.Dir,
+		Requires:    cmd.Requires,
+	}
+	for _, env := range cmd.Env {
+		file.Env = append(file.Env, envFile(env))
+	}
+	for _, param := range cmd.Params {
+		file.Params = append(file.Params, paramFile{
+			ID:          param.ID,
+			Name:        param.Name,
+			Description: param.Description,
+			Default:     param.DefaultValue,
+		})
+	}
+
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(file); err != nil {
+		return nil, fmt.Errorf("error encoding command: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+func readCommand(path string) (command.Command, error) {
+	var file commandFile
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return command.Command{}, err
+	}
+
+	if file.Name == "" || file.Command == "" {
+		return command.Command{}, errors.New("missing name or command")
+	}
+
+	cmd := command.Command{
+		ID:          file.ID,
+		Name:        file.Name,
+		Description: file.Description,
+		Command:     file.Command,
+		Params:      make([]command.Parameter, 0, len(file.Params)),
+		Tags:        command.NormalizeTags(file.Tags),
+		Target:      strings.TrimSpace(file.Target),
+		Dir:         strings.TrimSpace(file.Dir),
+		Requires:    command.NormalizeRequires(file.Requires),
+	}
+	for _, env := range file.Env {
+		cmd.Env = append(cmd.Env, command.EnvVar(env))
+	}
+	for _, param := range file.Params {
+		// parameters added by hand can skip the ID.
+		if param.ID == uuid.Nil {
+			param.ID = uuid.NewSHA1(file.ID, []byte(param.Name))
+		}
+
+		cmd.Params = append(cmd.Params, command.Parameter{
+			ID:           param.ID,
+			Name:         param.Name,
+			Description:  param.Description,
+			DefaultValue: param.Default,
+		})
+	}
+
+	return cmd, nil
+}
+
+// writeAtomic writes the file through a temporary file renamed over it,
+// so readers never see a partial write.
+func writeAtomic(path string, data []byte) (fs.FileInfo, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := tmp.Chmod(filePerm); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return os.Stat(path)
+}
+
+func sortCommands(cmds []command.Command) {
+	sort.Slice(cmds, func(i, j int) bool {
+		if cmds[i].Name != cmds[j].Name {
+			return cmds[i].Name < cmds[j].Name
+		}
+		return cmds[i].ID.String() < cmds[j].ID.String()
+	})
+}
@@ -0,0 +1,403 @@
+package file
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/search"
+)
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), dir)
+	require.NoError(t, err)
+
+	return store, dir
+}
+
+func newCmd(name, cmd, desc string, params ...string) command.Command {
+	c := command.Command{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: desc,
+		Command:     cmd,
+		Params:      []command.Parameter{},
+	}
+	for _, p := range params {
+		c.Params = append(c.Params, command.Parameter{
+			ID:           uuid.New(),
+			Name:         p,
+			Description:  p + " description",
+			DefaultValue: p + " default",
+		})
+	}
+	return c
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestStore(t)
+
+	squash := newCmd("squash", "git reset --soft HEAD~{{.commits}} && git commit", "Squash the last commits", "commits")
+	pods := newCmd("pods", "kubectl get pods -n {{.namespace}} -l {{.label}}", "List the pods", "namespace", "label")
+	disk := newCmd("disk", "du -sh * | sort -h", "Disk usage of the current directory")
+	pods.Tags = []string{"k8s", "prod"}
+	pods.Target = "kubectl:payments/api"
+	pods.Env = []command.EnvVar{{Name: "KUBECONFIG", Value: "~/.kube/{{.namespace}}"}}
+	pods.Dir = "~/k8s"
+	pods.Requires = []string{"kubectl"}
+
+	t.Run("save and get", func(t *testing.T) {
+		for _, cmd := range []command.Command{squash, pods, disk} {
+			require.NoError(t, store.Save(ctx, cmd))
+		}
+
+		got, err := store.GetCommandByID(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Equal(t, pods, got)
+
+		_, err = store.GetCommandByID(ctx, uuid.New())
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// only the command files are left, no temporary files.
+		entries, err := os.ReadDir(filepath.Join(dir, commandsDir))
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		got, err := store.ListCommands(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{disk.ID, pods.ID, squash.ID}, ids(got))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		tests := []struct {
+			term     string
+			expected []uuid.UUID
+		}{
+			{term: "squash", expected: []uuid.UUID{squash.ID}},
+			{term: "kube", expected: []uuid.UUID{pods.ID}},
+			{term: "Disk", expected: []uuid.UUID{disk.ID}},
+			{term: "git comm", expected: []uuid.UUID{squash.ID}},
+			{term: "git pods", expected: []uuid.UUID{}},
+			{term: "missing", expected: []uuid.UUID{}},
+			{term: "  ", expected: []uuid.UUID{}},
+		}
+
+		for _, tt := range tests {
+			got, err := store.SearchCommand(ctx, tt.term)
+			require.NoError(t, err, tt.term)
+			assert.Equal(t, tt.expected, ids(got), tt.term)
+		}
+	})
+
+	t.Run("search ranks name matches first", func(t *testing.T) {
+		ls := newCmd("list files", "ls -la", "List the files")
+		require.NoError(t, store.Save(ctx, ls))
+		defer func() {
+			require.NoError(t, store.DeleteCommand(ctx, ls.ID))
+		}()
+
+		got, err := store.SearchCommand(ctx, "list")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{ls.ID, pods.ID}, ids(got))
+
+		got, err = store.SearchCommand(ctx, "pods")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, ids(got))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated := pods
+		updated.Command = "kubectl get pods -n {{.namespace}} -o wide"
+		updated.Params = []command.Parameter{pods.Params[0]}
+
+		require.NoError(t, store.Save(ctx, updated))
+		require.NoError(t, store.DeleteParameters(ctx, []uuid.UUID{pods.Params[1].ID}))
+
+		got, err := store.GetCommandByID(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+
+		found, err := store.SearchCommand(ctx, "wide")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, ids(found))
+	})
+
+	t.Run("notebook", func(t *testing.T) {
+		_, err := store.ReadExplanation(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		require.NoError(t, store.WriteTranscript(ctx, squash.ID, "transcript"))
+		_, err = store.ReadExplanation(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound, "an entry with only the transcript has no explanation")
+
+		expl := command.Explanation{
+			Content: "explanation",
+			Signature: command.ExplanationSignature{
+				CommandHash:   squash.Hash(),
+				Source:        "fake",
+				PromptVersion: "1",
+				Template:      "default",
+			},
+		}
+		require.NoError(t, store.WriteExplanation(ctx, squash.ID, expl))
+
+		got, err := store.ReadExplanation(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expl, got)
+
+		transcript, err := store.ReadTranscript(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "transcript", transcript, "writing the explanation keeps the transcript")
+
+		require.NoError(t, store.DeleteExplanation(ctx, squash.ID))
+		_, err = store.ReadTranscript(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		got, err := store.GetHistory(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Usages)
+
+		require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{Command: "du -sh * | sort -h"}))
+		require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{
+			Command: "du -sh * | sort -hr",
+			Target:  "ssh:prod-1",
+			Result:  &command.RunResult{ExitCode: 0, Duration: 250 * time.Millisecond},
+		}))
+
+		got, err = store.GetHistory(ctx, disk.ID)
+		require.NoError(t, err)
+		require.Len(t, got.Usages, 2)
+		assert.Equal(t, "du -sh * | sort -h", got.Usages[0].Command)
+		assert.Nil(t, got.Usages[0].Result)
+		assert.Equal(t, "du -sh * | sort -hr", got.Usages[1].Command)
+		assert.Equal(t, &command.RunResult{ExitCode: 0, Duration: 250 * time.Millisecond}, got.Usages[1].Result)
+		assert.Empty(t, got.Usages[0].Target)
+		assert.Equal(t, "ssh:prod-1", got.Usages[1].Target)
+		assert.False(t, got.Usages[0].Timestamp.IsZero())
+
+		usages, err := store.ListUsages(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[uuid.UUID][]time.Time{
+			disk.ID: {got.Usages[0].Timestamp, got.Usages[1].Timestamp},
+		}, usages)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		tests := []struct {
+			query    string
+			expected []uuid.UUID
+		}{
+			{query: "tag:k8s", expected: []uuid.UUID{pods.ID}},
+			{query: "cmd:git -tag:prod param:commits", expected: []uuid.UUID{squash.ID}},
+			{query: "used:<1d", expected: []uuid.UUID{disk.ID}},
+			{query: "-used:<1d name:pods", expected: []uuid.UUID{pods.ID}},
+		}
+
+		for _, tt := range tests {
+			q, err := search.Parse(tt.query)
+			require.NoError(t, err, tt.query)
+
+			got, err := store.FilterCommands(ctx, q)
+			require.NoError(t, err, tt.query)
+			assert.ElementsMatch(t, tt.expected, ids(got), tt.query)
+		}
+	})
+
+	t.Run("content search", func(t *testing.T) {
+		got, err := store.SearchContent(ctx, "namespace desc")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, pods.ID, got[0].Command.ID)
+		assert.Equal(t, command.ParameterMatch, got[0].Source)
+		assert.Equal(t, "«namespace» «namespace» «desc»ription", got[0].Snippet)
+
+		got, err = store.SearchContent(ctx, "hr")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, disk.ID, got[0].Command.ID)
+		assert.Equal(t, command.HistoryMatch, got[0].Source)
+		assert.Equal(t, "du -sh * | sort -«hr»", got[0].Snippet)
+
+		got, err = store.SearchContent(ctx, "kubernetes")
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		got, err := store.ListRevisions(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		older := squash
+		older.Command = "git reset --soft HEAD~{{.commits}}"
+		require.NoError(t, store.InsertRevision(ctx, older))
+		require.NoError(t, store.InsertRevision(ctx, squash))
+
+		got, err = store.ListRevisions(ctx, squash.ID)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, squash, got[0].Command, "the newest first")
+		assert.Equal(t, older, got[1].Command)
+		assert.Equal(t, []int64{2, 1}, []int64{got[0].ID, got[1].ID})
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		require.NoError(t, store.TrashCommand(ctx, disk.ID))
+		assert.ErrorIs(t, store.TrashCommand(ctx, disk.ID), ErrNotFound, "already in the trash")
+
+		_, err := store.GetCommandByID(ctx, disk.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		trash, err := store.ListTrash(ctx)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, disk.ID, trash[0].Command.ID)
+		assert.WithinDuration(t, time.Now(), trash[0].DeletedAt, time.Minute)
+
+		require.NoError(t, store.RestoreCommand(ctx, disk.ID))
+		assert.ErrorIs(t, store.RestoreCommand(ctx, disk.ID), ErrNotFound, "not in the trash")
+
+		got, err := store.GetCommandByID(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Equal(t, disk.Command, got.Command)
+
+		history, err := store.GetHistory(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Len(t, history.Usages, 2, "the history is kept in the trash")
+
+		require.NoError(t, store.TrashCommand(ctx, disk.ID))
+		require.NoError(t, store.Save(ctx, disk))
+		trash, err = store.ListTrash(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, trash, "saving the command takes it out of the trash")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.InsertUsage(ctx, squash.ID, command.Usage{Command: "git reset --soft HEAD~2 && git commit"}))
+		require.NoError(t, store.DeleteCommand(ctx, squash.ID))
+
+		_, err := store.GetCommandByID(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		found, err := store.SearchCommand(ctx, "squash")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		assert.NoFileExists(t, store.historyPath(squash.ID))
+		assert.NoFileExists(t, store.revisionsPath(squash.ID))
+		assert.NoFileExists(t, store.trashPath(squash.ID))
+		assert.NoError(t, store.DeleteCommand(ctx, squash.ID), "deleting twice is a no-op")
+	})
+}
+
+func TestStore_ExternalEdits(t *testing.T) {
+	ctx := context.Background()
+	store, dir := newTestStore(t)
+
+	cmd := newCmd("pods", "kubectl get pods", "List the pods")
+	require.NoError(t, store.Save(ctx, cmd))
+
+	path := store.commandPath(cmd.ID)
+	edit := func(t *testing.T, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(content), filePerm))
+		// make sure the change is visible even with a coarse mtime.
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, future, future))
+	}
+
+	t.Run("edits are picked up", func(t *testing.T) {
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		edit(t, strings.Replace(string(raw), "kubectl get pods", "kubectl get pods -A", 1))
+
+		got, err := store.GetCommandByID(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "kubectl get pods -A", got.Command)
+	})
+
+	t.Run("save after an external edit conflicts", func(t *testing.T) {
+		edit(t, "id = \""+cmd.ID.String()+"\"\nname = \"pods\"\ncommand = \"kubectl get pods -w\"\n")
+
+		updated := cmd
+		updated.Command = "kubectl get pods -o wide"
+		err := store.Save(ctx, updated)
+		assert.ErrorIs(t, err, ErrModifiedExternally)
+
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), "kubectl get pods -w", "the external edit isn't clobbered")
+
+		// once read again, it can be saved.
+		_, err = store.GetCommandByID(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.NoError(t, store.Save(ctx, updated))
+	})
+
+	t.Run("new files are picked up", func(t *testing.T) {
+		id := uuid.New()
+		content := `id = "` + id.String() + `"
+name = "disk"
+command = "du -sh {{.path}}"
+
+[[params]]
+name = "path"
+default = "."
+`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, commandsDir, id.String()+commandExt), []byte(content), filePerm))
+
+		got, err := store.GetCommandByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "disk", got.Name)
+		require.Len(t, got.Params, 1)
+		assert.Equal(t, ".", got.Params[0].DefaultValue)
+		assert.NotEqual(t, uuid.Nil, got.Params[0].ID, "a missing parameter ID is derived")
+
+		again, err := store.GetCommandByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, got.Params[0].ID, again.Params[0].ID, "the derived ID is stable")
+	})
+
+	t.Run("invalid files are skipped", func(t *testing.T) {
+		invalid := filepath.Join(dir, commandsDir, uuid.NewString()+commandExt)
+		require.NoError(t, os.WriteFile(invalid, []byte("name = "), filePerm))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, commandsDir, "README.md"), []byte("notes"), filePerm))
+
+		got, err := store.ListCommands(ctx)
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+	})
+
+	t.Run("removed files are dropped", func(t *testing.T) {
+		require.NoError(t, os.Remove(path))
+
+		_, err := store.GetCommandByID(ctx, cmd.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func ids(cmds []command.Command) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(cmds))
+	for _, cmd := range cmds {
+		out = append(out, cmd.ID)
+	}
+	return out
+}
@@ -0,0 +1,493 @@
+package gitsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/file"
+)
+
+const (
+	defaultBranch = "main"
+	remoteName    = "origin"
+	// syncedMarker is kept inside the git dir, it tells the library was synced at least once.
+	syncedMarker = "clio-synced"
+	// commandsPrefix is where the file store keeps the commands, relative to the repository.
+	commandsPrefix = "commands/"
+	// maxRebaseSteps bounds the conflict resolution, one step per replayed commit.
+	maxRebaseSteps = 100
+)
+
+var (
+	// ErrGit thrown when a git command fails.
+	ErrGit = errors.New("git command failed")
+)
+
+type library interface {
+	GetAll(context.Context) ([]command.Command, error)
+	Import(context.Context, command.Command) (command.Command, error)
+	DeleteCommand(context.Context, string) error
+}
+
+// Syncer syncs the library with a git repository, using the layout of the file store.
+//
+// The local changes win: when a command was changed on both sides, the local
+// version is kept and the command is reported as a conflict.
+type Syncer struct {
+	library library
+	dir     string
+	remote  string
+	branch  string
+	message string
+	logger  *slog.Logger
+}
+
+// Conflict is a command changed both locally and in the remote.
+type Conflict struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// Report is the summary of a sync.
+type Report struct {
+	// Exported is the number of commands changed locally and pushed.
+	Exported int
+	// Imported is the number of commands created or updated from the remote.
+	Imported int
+	// Deleted is the number of commands removed from the library because they were removed in the remote.
+	Deleted int
+	// Conflicts are the commands changed on both sides, where the local version was kept.
+	Conflicts []Conflict
+}
+
+// OptFunc used for customizing the Syncer.
+type OptFunc func(*Syncer)
+
+// New returns a new Syncer over the repository dir.
+// The repository is initialized on the first sync if it doesn't exist.
+func New(library library, logger *slog.Logger, dir string, opts ...OptFunc) (*Syncer, error) {
+	if library == nil {
+		return nil, errors.New("nil library")
+	}
+	if dir == "" {
+		return nil, errors.New("missing repository dir")
+	}
+
+	s := &Syncer{
+		library: library,
+		dir:     dir,
+		branch:  defaultBranch,
+		message: "Sync library",
+		logger:  logger,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// WithRemote sets the url of the remote repository.
+func WithRemote(url string) OptFunc {
+	return func(s *Syncer) {
+		s.remote = url
+	}
+}
+
+// WithBranch sets the branch to sync.
+func WithBranch(branch string) OptFunc {
+	return func(s *Syncer) {
+		if branch != "" {
+			s.branch = branch
+		}
+	}
+}
+
+// WithMessage sets the message of the sync commits.
+func WithMessage(message string) OptFunc {
+	return func(s *Syncer) {
+		if message != "" {
+			s.message = message
+		}
+	}
+}
+
+// Sync exports the library into the repository, commits the changes, rebases
+// them on top of the remote ones, pushes and imports the result back.
+func (s *Syncer) Sync(ctx context.Context) (Report, error) {
+	var report Report
+
+	first, err := s.prepare(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	store, err := file.New(s.logger, s.dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	local, err := s.library.GetAll(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("error reading the library: %w", err)
+	}
+
+	// the first sync merges both sides, afterwards the commands missing in the library were deleted.
+	if err := s.export(ctx, store, local, !first); err != nil {
+		return Report{}, err
+	}
+
+	report.Exported, err = s.commit(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if s.remote != "" {
+		report.Conflicts, err = s.pull(ctx, store)
+		if err != nil {
+			return Report{}, err
+		}
+
+		if _, err := s.git(ctx, "push", remoteName, "HEAD:refs/heads/"+s.branch); err != nil {
+			return Report{}, err
+		}
+	}
+
+	report.Imported, report.Deleted, err = s.importCommands(ctx, store, local)
+	if err != nil {
+		return Report{}, err
+	}
+
+	if err := os.WriteFile(filepath.Join(s.dir, ".git", syncedMarker), nil, 0o640); err != nil {
+		return Report{}, fmt.Errorf("error marking the library as synced: %w", err)
+	}
+
+	return report, nil
+}
+
+// prepare initializes the repository if needed, and returns if it's the first sync.
+func (s *Syncer) prepare(ctx context.Context) (bool, error) {
+	_, err := os.Stat(filepath.Join(s.dir, ".git"))
+	switch {
+	case err == nil:
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(s.dir, 0o740); err != nil {
+			return false, fmt.Errorf("error creating the repository dir: %w", err)
+		}
+
+		if _, err := s.git(ctx, "init", "--initial-branch", s.branch); err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("error reading the repository: %w", err)
+	}
+
+	if s.remote != "" {
+		if _, err := s.git(ctx, "remote", "get-url", remoteName); err != nil {
+			if _, err := s.git(ctx, "remote", "add", remoteName, s.remote); err != nil {
+				return false, err
+			}
+		} else if _, err := s.git(ctx, "remote", "set-url", remoteName, s.remote); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(s.dir, ".git", syncedMarker))
+	return errors.Is(err, fs.ErrNotExist), nil
+}
+
+// export writes the library in the repository. With prune, the commands not in the library are removed.
+func (s *Syncer) export(ctx context.Context, store *file.Store, local []command.Command, prune bool) error {
+	current, err := store.ListCommands(ctx)
+	if err != nil {
+		return err
+	}
+
+	inLibrary := make(map[uuid.UUID]struct{}, len(local))
+	for _, cmd := range local {
+		inLibrary[cmd.ID] = struct{}{}
+		if err := store.Save(ctx, cmd); err != nil {
+			return fmt.Errorf("error exporting command %q: %w", cmd.Name, err)
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+
+	for _, cmd := range current {
+		if _, ok := inLibrary[cmd.ID]; ok {
+			continue
+		}
+		if err := store.DeleteCommand(ctx, cmd.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// commit commits the exported changes, returning the number of changed commands.
+func (s *Syncer) commit(ctx context.Context) (int, error) {
+	if _, err := s.git(ctx, "add", "--all"); err != nil {
+		return 0, err
+	}
+
+	out, err := s.git(ctx, "status", "--porcelain")
+	if err != nil {
+		return 0, err
+	}
+
+	if out == "" {
+		return 0, nil
+	}
+
+	changed := 0
+	for _, line := range lines(out) {
+		if strings.Contains(line, commandsPrefix) {
+			changed++
+		}
+	}
+
+	if _, err := s.git(ctx, "commit", "--quiet", "--message", s.message); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// pull rebases the local commits on top of the remote branch, keeping the local version of the conflicting commands.
+func (s *Syncer) pull(ctx context.Context, store *file.Store) ([]Conflict, error) {
+	if _, err := s.git(ctx, "fetch", "--quiet", remoteName); err != nil {
+		return nil, err
+	}
+
+	upstream := remoteName + "/" + s.branch
+	if _, err := s.git(ctx, "rev-parse", "--verify", "--quiet", upstream); err != nil {
+		// the remote is empty, nothing to merge.
+		return nil, nil
+	}
+
+	if _, err := s.git(ctx, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// nothing committed locally, the remote is taken as is.
+		_, err := s.git(ctx, "reset", "--hard", "--quiet", upstream)
+		return nil, err
+	}
+
+	conflicts, err := s.conflicts(ctx, store, upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	// while rebasing, theirs are the local commits being replayed.
+	if _, err := s.git(ctx, "rebase", "--strategy-option", "theirs", upstream); err != nil {
+		if err := s.resolveRebase(ctx); err != nil {
+			_, _ = s.git(ctx, "rebase", "--abort")
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// conflicts returns the commands changed on both sides since the last common commit.
+func (s *Syncer) conflicts(ctx context.Context, store *file.Store, upstream string) ([]Conflict, error) {
+	base, err := s.git(ctx, "merge-base", "HEAD", upstream)
+	if err != nil {
+		// unrelated histories, every command on both sides with differences conflicts.
+		base = ""
+	}
+
+	changedSince := func(rev string) (map[string]struct{}, error) {
+		args := []string{"diff", "--name-only", base, rev, "--", commandsPrefix}
+		if base == "" {
+			args = []string{"ls-tree", "-r", "--name-only", rev, "--", commandsPrefix}
+		}
+
+		out, err := s.git(ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		paths := make(map[string]struct{})
+		for _, path := range lines(out) {
+			paths[path] = struct{}{}
+		}
+		return paths, nil
+	}
+
+	local, err := changedSince("HEAD")
+	if err != nil {
+		return nil, err
+	}
+	remote, err := changedSince(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	cmds, err := store.ListCommands(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uuid.UUID]string, len(cmds))
+	for _, cmd := range cmds {
+		names[cmd.ID] = cmd.Name
+	}
+
+	var conflicts []Conflict
+	for path := range local {
+		if _, ok := remote[path]; !ok {
+			continue
+		}
+
+		// both sides made the same change.
+		if _, err := s.git(ctx, "diff", "--quiet", "HEAD", upstream, "--", path); err == nil {
+			continue
+		}
+
+		id, err := uuid.Parse(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		if err != nil {
+			continue
+		}
+
+		conflicts = append(conflicts, Conflict{ID: id, Name: names[id]})
+		s.logger.Warn("command changed on both sides, keeping the local version", slog.String("id", id.String()))
+	}
+
+	return conflicts, nil
+}
+
+// resolveRebase resolves the conflicts the strategy can't, like a command edited on one side and deleted on the other,
+// keeping the local side.
+func (s *Syncer) resolveRebase(ctx context.Context) error {
+	for range maxRebaseSteps {
+		out, err := s.git(ctx, "diff", "--name-only", "--diff-filter=U")
+		if err != nil {
+			return err
+		}
+
+		unmerged := lines(out)
+		if len(unmerged) == 0 {
+			return fmt.Errorf("%w: rebase stopped without conflicts", ErrGit)
+		}
+
+		for _, path := range unmerged {
+			if _, err := s.git(ctx, "checkout", "--theirs", "--", path); err == nil {
+				if _, err := s.git(ctx, "add", "--", path); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// deleted locally.
+			if _, err := s.git(ctx, "rm", "--quiet", "--", path); err != nil {
+				return err
+			}
+		}
+
+		_, err = s.git(ctx, "-c", "core.editor=true", "rebase", "--continue")
+		if err == nil {
+			return nil
+		}
+
+		if _, statErr := os.Stat(filepath.Join(s.dir, ".git", "rebase-merge")); errors.Is(statErr, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: too many rebase steps", ErrGit)
+}
+
+// importCommands syncs the library with the repository, returning the number of imported and deleted commands.
+func (s *Syncer) importCommands(ctx context.Context, store *file.Store, local []command.Command) (int, int, error) {
+	synced, err := store.ListCommands(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	current := make(map[uuid.UUID]command.Command, len(local))
+	for _, cmd := range local {
+		current[cmd.ID] = cmd
+	}
+
+	var imported, deleted int
+	for _, cmd := range synced {
+		curr, ok := current[cmd.ID]
+		delete(current, cmd.ID)
+		if ok && equal(curr, cmd) {
+			continue
+		}
+
+		if _, err := s.library.Import(ctx, cmd); err != nil {
+			return 0, 0, fmt.Errorf("error importing command %q: %w", cmd.Name, err)
+		}
+		imported++
+	}
+
+	for id := range current {
+		if err := s.library.DeleteCommand(ctx, id.String()); err != nil {
+			return 0, 0, fmt.Errorf("error deleting command %q: %w", id, err)
+		}
+		deleted++
+	}
+
+	return imported, deleted, nil
+}
+
+// git runs a git command in the repository, returning its trimmed output.
+func (s *Syncer) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w: git %s: %v: %s", ErrGit, args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// equal compares the commands, ignoring the order of the parameters.
+func equal(a, b command.Command) bool {
+	if a.Name != b.Name || a.Description != b.Description || a.Command != b.Command || a.Target != b.Target || len(a.Params) != len(b.Params) {
+		return false
+	}
+	if a.Dir != b.Dir || !slices.Equal(a.Tags, b.Tags) || !slices.Equal(a.Env, b.Env) || !slices.Equal(a.Requires, b.Requires) {
+		return false
+	}
+
+	params := make(map[uuid.UUID]command.Parameter, len(a.Params))
+	for _, param := range a.Params {
+		params[param.ID] = param
+	}
+	for _, param := range b.Params {
+		if !reflect.DeepEqual(params[param.ID], param) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func lines(out string) []string {
+	if out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
@@ -0,0 +1,208 @@
+package gitsync
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/file"
+	"github.com/lian-rr/clio/command/manager"
+)
+
+type device struct {
+	library *manager.Manager
+	syncer  *Syncer
+}
+
+func newDevice(t *testing.T, remote string) device {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := file.New(logger, t.TempDir())
+	require.NoError(t, err)
+
+	mng, err := manager.NewManager(store, store)
+	require.NoError(t, err)
+
+	var opts []OptFunc
+	if remote != "" {
+		opts = append(opts, WithRemote(remote))
+	}
+
+	syncer, err := New(&mng, logger, filepath.Join(t.TempDir(), "repo"), opts...)
+	require.NoError(t, err)
+
+	return device{library: &mng, syncer: syncer}
+}
+
+func (d device) sync(t *testing.T) Report {
+	t.Helper()
+
+	report, err := d.syncer.Sync(context.Background())
+	require.NoError(t, err)
+	return report
+}
+
+func (d device) add(t *testing.T, name, cmd string) command.Command {
+	t.Helper()
+
+	added, err := d.library.Add(context.Background(), command.Command{
+		Name:    name,
+		Command: cmd,
+		Params: []command.Parameter{
+			{ID: uuid.New(), Name: "arg", DefaultValue: "default"},
+		},
+	})
+	require.NoError(t, err)
+	return added
+}
+
+func (d device) update(t *testing.T, cmd command.Command, line string) {
+	t.Helper()
+
+	cmd.Command = line
+	_, err := d.library.UpdateCommand(context.Background(), cmd)
+	require.NoError(t, err)
+}
+
+func (d device) get(t *testing.T, id uuid.UUID) (command.Command, bool) {
+	t.Helper()
+
+	cmd, err := d.library.GetOne(context.Background(), id.String())
+	if err != nil {
+		require.ErrorIs(t, err, file.ErrNotFound)
+		return command.Command{}, false
+	}
+	return cmd, true
+}
+
+func (d device) delete(t *testing.T, id uuid.UUID) {
+	t.Helper()
+	require.NoError(t, d.library.DeleteCommand(context.Background(), id.String()))
+}
+
+func newRemote(t *testing.T) string {
+	t.Helper()
+
+	// isolate the tests from the user git config.
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "clio")
+	t.Setenv("GIT_AUTHOR_EMAIL", "clio@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "clio")
+	t.Setenv("GIT_COMMITTER_EMAIL", "clio@example.com")
+
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	out, err := exec.Command("git", "init", "--bare", "--quiet", dir).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	return dir
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	remote := newRemote(t)
+	laptop := newDevice(t, remote)
+	server := newDevice(t, remote)
+
+	pods := laptop.add(t, "pods", "kubectl get pods -n {{.arg}}")
+	disk := laptop.add(t, "disk", "du -sh {{.arg}}")
+
+	t.Run("first sync pushes the library", func(t *testing.T) {
+		report := laptop.sync(t)
+		assert.Equal(t, Report{Exported: 2, Conflicts: nil}, report)
+	})
+
+	t.Run("first sync on another device merges both libraries", func(t *testing.T) {
+		logs := server.add(t, "logs", "journalctl -u {{.arg}}")
+
+		report := server.sync(t)
+		assert.Equal(t, 1, report.Exported)
+		assert.Equal(t, 2, report.Imported)
+		assert.Empty(t, report.Conflicts)
+
+		got, ok := server.get(t, pods.ID)
+		require.True(t, ok)
+		assert.Equal(t, pods, got)
+
+		report = laptop.sync(t)
+		assert.Equal(t, Report{Imported: 1}, report)
+		_, ok = laptop.get(t, logs.ID)
+		assert.True(t, ok)
+	})
+
+	t.Run("changes on different commands are merged", func(t *testing.T) {
+		laptop.update(t, pods, "kubectl get pods -A")
+		server.delete(t, disk.ID)
+
+		server.sync(t)
+		report := laptop.sync(t)
+		assert.Equal(t, 1, report.Exported)
+		assert.Equal(t, 1, report.Deleted)
+		assert.Empty(t, report.Conflicts)
+
+		server.sync(t)
+		got, ok := server.get(t, pods.ID)
+		require.True(t, ok)
+		assert.Equal(t, "kubectl get pods -A", got.Command)
+		_, ok = laptop.get(t, disk.ID)
+		assert.False(t, ok)
+	})
+
+	t.Run("conflicting changes keep the local version", func(t *testing.T) {
+		laptop.update(t, pods, "kubectl get pods -o wide")
+		server.update(t, pods, "kubectl get pods -w")
+
+		laptop.sync(t)
+		report := server.sync(t)
+		assert.Equal(t, []Conflict{{ID: pods.ID, Name: "pods"}}, report.Conflicts)
+
+		got, ok := server.get(t, pods.ID)
+		require.True(t, ok)
+		assert.Equal(t, "kubectl get pods -w", got.Command)
+
+		laptop.sync(t)
+		got, ok = laptop.get(t, pods.ID)
+		require.True(t, ok)
+		assert.Equal(t, "kubectl get pods -w", got.Command, "the devices converge")
+	})
+
+	t.Run("a local edit wins over a remote delete", func(t *testing.T) {
+		laptop.delete(t, pods.ID)
+		server.update(t, pods, "kubectl get pods --show-labels")
+
+		laptop.sync(t)
+		report := server.sync(t)
+		assert.Len(t, report.Conflicts, 1)
+
+		laptop.sync(t)
+		got, ok := laptop.get(t, pods.ID)
+		require.True(t, ok, "the command is restored")
+		assert.Equal(t, "kubectl get pods --show-labels", got.Command)
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		assert.Equal(t, Report{}, server.sync(t))
+		assert.Equal(t, Report{}, laptop.sync(t))
+	})
+}
+
+func TestSyncer_SyncWithoutRemote(t *testing.T) {
+	newRemote(t)
+	laptop := newDevice(t, "")
+
+	laptop.add(t, "pods", "kubectl get pods")
+	assert.Equal(t, Report{Exported: 1}, laptop.sync(t))
+
+	out, err := exec.Command("git", "-C", laptop.syncer.dir, "log", "--oneline").CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "Sync library")
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/lian-rr/clio/command/shell"
+)
+
+// Invocation is a compiled command with the env and the working directory it runs with.
+type Invocation struct {
+	Command string
+	Env     []EnvVar
+	Dir     string
+}
+
+// ShellLine returns the command line setting the env and the working directory, e.g. for typing it in a shell.
+//
+//	cd ~/infra && env AWS_PROFILE=ops terraform plan
+//
+// The lines env can't run as a program, e.g. pipes or builtins, run in `sh -c` getting the env.
+func (i Invocation) ShellLine() string {
+	line := i.Command
+	if len(i.Env) > 0 {
+		if i.needsShell() {
+			line = "sh -c " + shell.Quote(line)
+		}
+		line = "env " + FormatEnv(i.Env) + " " + line
+	}
+
+	if i.Dir != "" {
+		line = "cd " + quoteDir(i.Dir) + " && " + line
+	}
+	return line
+}
+
+// needsShell returns true if the command can't be run by env as a program: the lines with more than a command,
+// the builtins of the shell, e.g. cd or export, and the ones expanding the env vars, expanded before env sets them.
+func (i Invocation) needsShell() bool {
+	segments := shell.Split(i.Command)
+	if len(segments) > 1 || (len(segments) == 1 && shell.Builtin(segments[0].Program)) {
+		return true
+	}
+	return slices.ContainsFunc(i.Env, func(v EnvVar) bool {
+		return strings.Contains(i.Command, "$"+v.Name) || strings.Contains(i.Command, "${"+v.Name)
+	})
+}
+
+// quoteDir quotes the directory, keeping the home directory prefix expandable by the shell.
+func quoteDir(dir string) string {
+	switch {
+	case dir == "~":
+		return dir
+	case strings.HasPrefix(dir, "~/"):
+		if dir == "~/" {
+			return dir
+		}
+		return "~/" + shell.Quote(dir[2:])
+	default:
+		return shell.Quote(dir)
+	}
+}
+
+// ParseEnv returns the env vars in the text, as NAME=value separated by spaces. The values with spaces are quoted.
+func ParseEnv(raw string) ([]EnvVar, error) {
+	var env []EnvVar
+	for _, word := range shell.Words(raw) {
+		name, value, ok := strings.Cut(word, "=")
+		if !ok || !validEnvName(name) {
+			return nil, fmt.Errorf("invalid env var %q, expected NAME=value", word)
+		}
+		env = append(env, EnvVar{Name: name, Value: value})
+	}
+	return env, nil
+}
+
+// FormatEnv returns the env vars as NAME=value separated by spaces, the values quoted for the shell.
+func FormatEnv(env []EnvVar) string {
+	vars := make([]string, 0, len(env))
+	for _, v := range env {
+		vars = append(vars, v.Name+"="+shell.Quote(v.Value))
+	}
+	return strings.Join(vars, " ")
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if r != '_' && !(r < unicode.MaxASCII && unicode.IsLetter(r)) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand_Invocation(t *testing.T) {
+	cmd := Command{
+		Name:    "plan",
+		Command: "terraform plan -var env={{.env}}",
+		Env: []EnvVar{
+			{Name: "AWS_PROFILE", Value: "{{.profile}}"},
+			{Name: "TF_IN_AUTOMATION", Value: "1"},
+		},
+		Dir: "~/infra/{{.env}}",
+	}
+	require.NoError(t, cmd.Build())
+	require.Len(t, cmd.Params, 2, "the params of the env and the working directory are added once")
+	assert.Equal(t, "env", cmd.Params[0].Name)
+	assert.Equal(t, "profile", cmd.Params[1].Name)
+
+	got, err := cmd.Invocation([]Argument{
+		{Name: "env", Value: "prod"},
+		{Name: "profile", Value: "ops"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Invocation{
+		Command: "terraform plan -var env=prod",
+		Env: []EnvVar{
+			{Name: "AWS_PROFILE", Value: "ops"},
+			{Name: "TF_IN_AUTOMATION", Value: "1"},
+		},
+		Dir: "~/infra/prod",
+	}, got)
+
+	_, err = cmd.Invocation([]Argument{{Name: "env", Value: "prod"}})
+	assert.ErrorIs(t, err, ErrInvalidNumOfParams)
+}
+
+func TestInvocation_ShellLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		invocation Invocation
+		expected   string
+	}{
+		{
+			name:       "command",
+			invocation: Invocation{Command: "terraform plan"},
+			expected:   "terraform plan",
+		},
+		{
+			name: "env",
+			invocation: Invocation{
+				Command: "terraform plan",
+				Env:     []EnvVar{{Name: "AWS_PROFILE", Value: "ops"}, {Name: "TF_VAR_owner", Value: "platform team"}},
+			},
+			expected: "env AWS_PROFILE=ops TF_VAR_owner='platform team' terraform plan",
+		},
+		{
+			name: "env with pipes",
+			invocation: Invocation{
+				Command: "aws s3 ls | grep 'logs'",
+				Env:     []EnvVar{{Name: "AWS_PROFILE", Value: "ops"}},
+			},
+			expected: `env AWS_PROFILE=ops sh -c 'aws s3 ls | grep '\''logs'\'''`,
+		},
+		{
+			name: "env with builtin",
+			invocation: Invocation{
+				Command: "export KUBECONFIG=~/.kube/prod",
+				Env:     []EnvVar{{Name: "AWS_PROFILE", Value: "ops"}},
+			},
+			expected: `env AWS_PROFILE=ops sh -c 'export KUBECONFIG=~/.kube/prod'`,
+		},
+		{
+			name: "env with cd",
+			invocation: Invocation{
+				Command: "cd /srv/app",
+				Env:     []EnvVar{{Name: "STAGE", Value: "prod"}},
+			},
+			expected: `env STAGE=prod sh -c 'cd /srv/app'`,
+		},
+		{
+			name: "env expanded by the command",
+			invocation: Invocation{
+				Command: "echo $STAGE ${AWS_PROFILE}",
+				Env:     []EnvVar{{Name: "STAGE", Value: "prod"}},
+			},
+			expected: `env STAGE=prod sh -c 'echo $STAGE ${AWS_PROFILE}'`,
+		},
+		{
+			name:       "home directory",
+			invocation: Invocation{Command: "terraform plan", Dir: "~/my infra"},
+			expected:   "cd ~/'my infra' && terraform plan",
+		},
+		{
+			name: "directory and env",
+			invocation: Invocation{
+				Command: "make deploy",
+				Env:     []EnvVar{{Name: "STAGE", Value: "prod"}},
+				Dir:     "/srv/app",
+			},
+			expected: "cd /srv/app && env STAGE=prod make deploy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.invocation.ShellLine())
+		})
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected []EnvVar
+		err      bool
+	}{
+		{
+			name: "empty",
+			raw:  "  ",
+		},
+		{
+			name: "vars",
+			raw:  `AWS_PROFILE={{.profile}} TF_VAR_owner='platform team' EMPTY=`,
+			expected: []EnvVar{
+				{Name: "AWS_PROFILE", Value: "{{.profile}}"},
+				{Name: "TF_VAR_owner", Value: "platform team"},
+				{Name: "EMPTY", Value: ""},
+			},
+		},
+		{
+			name: "missing value",
+			raw:  "AWS_PROFILE",
+			err:  true,
+		},
+		{
+			name: "invalid name",
+			raw:  "1PROFILE=ops",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEnv(tt.raw)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+
+			// the formatted vars are parsed back.
+			again, err := ParseEnv(FormatEnv(got))
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
@@ -5,55 +5,104 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
-	"github.com/lian-rr/clio/command/sql"
+	"github.com/lian-rr/clio/command/search"
 )
 
 var (
 	// ErrNotebookNotEnabled thrown when the notebook wasn't set.
 	ErrNotebookNotEnabled error = errors.New("notebook not enabled")
 	// ErrElementNotFound thrown when the element was not found in the store.
-	ErrElementNotFound error = errors.New("element not found")
+	ErrElementNotFound error = fmt.Errorf("element %w", command.ErrNotFound)
+	// ErrSemanticSearchNotEnabled thrown when there's no embedder or the store can't keep the embeddings.
+	ErrSemanticSearchNotEnabled error = errors.New("semantic search not enabled")
 )
 
 type store interface {
 	Save(context.Context, command.Command) error
 	GetCommandByID(context.Context, uuid.UUID) (command.Command, error)
 	SearchCommand(context.Context, string) ([]command.Command, error)
+	SearchContent(context.Context, string) ([]command.ContentMatch, error)
 	ListCommands(context.Context) ([]command.Command, error)
+	FilterCommands(context.Context, search.Query) ([]command.Command, error)
 	DeleteCommand(context.Context, uuid.UUID) error
 	DeleteParameters(context.Context, []uuid.UUID) error
-	InsertUsage(context.Context, uuid.UUID, string) error
+	InsertUsage(context.Context, uuid.UUID, command.Usage) error
 	GetHistory(context.Context, uuid.UUID) (command.History, error)
+	ListUsages(context.Context) (map[uuid.UUID][]time.Time, error)
+	InsertRevision(context.Context, command.Command) error
+	ListRevisions(context.Context, uuid.UUID) ([]command.Revision, error)
+	TrashCommand(context.Context, uuid.UUID) error
+	RestoreCommand(context.Context, uuid.UUID) error
+	ListTrash(context.Context) ([]command.DeletedCommand, error)
 }
 
 type notebook interface {
-	WriteExplanation(context.Context, uuid.UUID, string) error
-	ReadExplanation(context.Context, uuid.UUID) (string, error)
+	WriteExplanation(context.Context, uuid.UUID, command.Explanation) error
+	ReadExplanation(context.Context, uuid.UUID) (command.Explanation, error)
 	DeleteExplanation(context.Context, uuid.UUID) error
+	WriteTranscript(context.Context, uuid.UUID, string) error
+	ReadTranscript(context.Context, uuid.UUID) (string, error)
+}
+
+// explanationIndex is implemented by the notebooks with a full-text index of the explanations,
+// which can't index them by themselves as they're stored compressed.
+type explanationIndex interface {
+	IndexExplanation(context.Context, uuid.UUID, string) error
+	UnindexedExplanations(context.Context) ([]uuid.UUID, error)
+}
+
+// embeddingStore is implemented by the stores keeping the embeddings of the commands.
+type embeddingStore interface {
+	WriteEmbedding(context.Context, command.Embedding) error
+	ListEmbeddings(context.Context) (map[uuid.UUID]command.Embedding, error)
+}
+
+// embedder returns the embeddings of the texts for the semantic search.
+type embedder interface {
+	Embed(context.Context, string) ([]float32, error)
+	EmbeddingModel() string
+}
+
+// OptFunc is used to apply optional settings to the Manager.
+type OptFunc func(*Manager)
+
+// WithEmbedder sets the embedder used by the semantic search.
+func WithEmbedder(e embedder) OptFunc {
+	return func(m *Manager) {
+		m.embedder = e
+	}
 }
 
 // Manager handles the command admin operations.
 type Manager struct {
 	store    store
 	notebook notebook
+	embedder embedder
 }
 
 // NewManager returns a new Manager.
-func NewManager(store store, notebook notebook) (Manager, error) {
+func NewManager(store store, notebook notebook, opts ...OptFunc) (Manager, error) {
 	if store == nil {
 		return Manager{}, errors.New("nil store")
 	}
-	return Manager{
+
+	m := Manager{
 		store:    store,
 		notebook: notebook,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m, nil
 }
 
 // Add creates, saves and returns a new command validated.
@@ -86,14 +135,158 @@ func (m *Manager) GetOne(ctx context.Context, rawID string) (command.Command, er
 	return cmd, nil
 }
 
-// SearchCommand returns a list of commands with a matching term.
-func (m *Manager) Search(ctx context.Context, term string) ([]command.Command, error) {
-	commands, err := m.store.SearchCommand(ctx, term)
+// Search returns the commands matching the query, best first. The full-text search of the store
+// is merged with a typo-tolerant matching of the names and commands, and the usage frecency.
+// The parameters, explanations and history are searched too, the results only matching there say where.
+// The query can have filters, e.g. `deploy name:web -tag:prod param:namespace used:<7d`, see search.Parse.
+// The queries starting with `~` are semantic, ranked by the similarity of their embeddings instead.
+func (m *Manager) Search(ctx context.Context, term string) ([]command.SearchResult, error) {
+	q, err := search.Parse(term)
 	if err != nil {
 		return nil, err
 	}
+	if q.Empty() {
+		return []command.SearchResult{}, nil
+	}
+	if q.Semantic {
+		return m.semanticSearch(ctx, q)
+	}
 
-	return commands, nil
+	indexed := []command.Command{}
+	content := []command.ContentMatch{}
+	if len(search.Tokenize(q.Text())) > 0 {
+		indexed, err = m.store.SearchCommand(ctx, q.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		content, err = m.store.SearchContent(ctx, q.Text())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commands []command.Command
+	if q.Filtered() {
+		commands, err = m.store.FilterCommands(ctx, q)
+	} else {
+		commands, err = m.store.ListCommands(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usages, err := m.store.ListUsages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return search.Rank(q, search.Sources{
+		Indexed:  indexed,
+		Content:  content,
+		Commands: commands,
+		Usages:   usages,
+		Now:      time.Now(),
+	}), nil
+}
+
+// semanticSearch returns the commands most similar in meaning to the text of the query.
+// Only the commands with an embedding are ranked, they are computed by IndexEmbeddings.
+func (m *Manager) semanticSearch(ctx context.Context, q search.Query) ([]command.SearchResult, error) {
+	embeddings, ok := m.semanticStore()
+	if !ok {
+		return nil, ErrSemanticSearchNotEnabled
+	}
+	if len(search.Tokenize(q.Text())) == 0 {
+		return []command.SearchResult{}, nil
+	}
+
+	var (
+		commands []command.Command
+		err      error
+	)
+	if q.Filtered() {
+		commands, err = m.store.FilterCommands(ctx, q)
+	} else {
+		commands, err = m.store.ListCommands(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := embeddings.ListEmbeddings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, err := m.embedder.Embed(ctx, q.Text())
+	if err != nil {
+		return nil, fmt.Errorf("error embedding query: %w", err)
+	}
+
+	return search.Similar(commands, stored, vector, m.embedder.EmbeddingModel()), nil
+}
+
+// IndexEmbeddings computes the missing or outdated embeddings of the commands, returning how many were computed.
+// It's slow with a remote embedder, so it's meant to run in the background on startup and after the changes.
+func (m *Manager) IndexEmbeddings(ctx context.Context) (int, error) {
+	embeddings, ok := m.semanticStore()
+	if !ok {
+		return 0, nil
+	}
+
+	commands, err := m.store.ListCommands(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stored, err := embeddings.ListEmbeddings(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	model := m.embedder.EmbeddingModel()
+	count := 0
+	for _, cmd := range commands {
+		curr, ok := stored[cmd.ID]
+		if ok && curr.Model == model && curr.Hash == cmd.EmbeddingHash() {
+			continue
+		}
+
+		embedding, err := m.embedding(ctx, cmd)
+		if err != nil {
+			return count, fmt.Errorf("error embedding command: %w", err)
+		}
+		if err := embeddings.WriteEmbedding(ctx, embedding); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// semanticStore returns the store of the embeddings, if the semantic search is enabled.
+func (m *Manager) semanticStore() (embeddingStore, bool) {
+	embeddings, ok := m.store.(embeddingStore)
+	if !ok || m.embedder == nil || m.embedder.EmbeddingModel() == "" {
+		return nil, false
+	}
+	return embeddings, true
+}
+
+// embedding returns the embedding of the command text.
+func (m *Manager) embedding(ctx context.Context, cmd command.Command) (command.Embedding, error) {
+	vector, err := m.embedder.Embed(ctx, cmd.EmbeddingText())
+	if err != nil {
+		return command.Embedding{}, err
+	}
+
+	return command.Embedding{
+		CommandID: cmd.ID,
+		Model:     m.embedder.EmbeddingModel(),
+		Hash:      cmd.EmbeddingHash(),
+		Vector:    vector,
+	}, nil
 }
 
 // GetAll returns a list with all the commands.
@@ -106,23 +299,72 @@ func (m *Manager) GetAll(ctx context.Context) ([]command.Command, error) {
 	return commands, nil
 }
 
-// GetCommand returns a command by ID.
+// DeleteCommand moves a command to the trash, it can be restored until it's purged.
 func (m *Manager) DeleteCommand(ctx context.Context, rawID string) error {
 	id, err := uuid.Parse(rawID)
 	if err != nil {
 		return err
 	}
 
+	return m.store.TrashCommand(ctx, id)
+}
+
+// Trash returns the deleted commands, the last deleted first.
+func (m *Manager) Trash(ctx context.Context) ([]command.DeletedCommand, error) {
+	return m.store.ListTrash(ctx)
+}
+
+// RestoreCommand takes a command out of the trash.
+func (m *Manager) RestoreCommand(ctx context.Context, id uuid.UUID) (command.Command, error) {
+	if err := m.store.RestoreCommand(ctx, id); err != nil {
+		return command.Command{}, err
+	}
+
+	return m.store.GetCommandByID(ctx, id)
+}
+
+// PurgeCommand removes permanently a command, with its notebook, history and revisions.
+func (m *Manager) PurgeCommand(ctx context.Context, id uuid.UUID) error {
 	return m.store.DeleteCommand(ctx, id)
 }
 
-// UpdateCommand updates the command on the store.
+// PurgeTrash removes permanently the commands deleted longer than the retention ago.
+// Returns the number of commands purged.
+func (m *Manager) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	trash, err := m.store.ListTrash(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing the trash: %v", err)
+	}
+
+	var purged int
+	limit := time.Now().Add(-retention)
+	for _, deleted := range trash {
+		if deleted.DeletedAt.After(limit) {
+			continue
+		}
+
+		if err := m.store.DeleteCommand(ctx, deleted.Command.ID); err != nil {
+			return purged, fmt.Errorf("error purging command: %v", err)
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// UpdateCommand updates the command on the store, keeping the current version as a revision.
 func (m *Manager) UpdateCommand(ctx context.Context, cmd command.Command) (command.Command, error) {
 	curr, err := m.store.GetCommandByID(ctx, cmd.ID)
 	if err != nil {
 		return command.Command{}, fmt.Errorf("error getting current command: %v", err)
 	}
 
+	if changed(curr, cmd) {
+		if err := m.store.InsertRevision(ctx, curr); err != nil {
+			return command.Command{}, fmt.Errorf("error storing revision: %v", err)
+		}
+	}
+
 	toDeleteParams := make([]uuid.UUID, 0)
 	for _, param := range curr.Params {
 		var found bool
@@ -145,11 +387,64 @@ func (m *Manager) UpdateCommand(ctx context.Context, cmd command.Command) (comma
 	if err := m.store.DeleteParameters(ctx, toDeleteParams); err != nil {
 		return command.Command{}, err
 	}
+
+	return cmd, nil
+}
+
+// Revisions returns the prior versions of the command, the newest first.
+func (m *Manager) Revisions(ctx context.Context, commandID uuid.UUID) ([]command.Revision, error) {
+	return m.store.ListRevisions(ctx, commandID)
+}
+
+// RestoreRevision updates the command with the content of one of its revisions.
+// The replaced version is kept as a new revision, so the restore can be undone.
+func (m *Manager) RestoreRevision(ctx context.Context, commandID uuid.UUID, revisionID int64) (command.Command, error) {
+	revisions, err := m.store.ListRevisions(ctx, commandID)
+	if err != nil {
+		return command.Command{}, fmt.Errorf("error getting revisions: %v", err)
+	}
+
+	for _, rev := range revisions {
+		if rev.ID == revisionID {
+			// the revisions don't keep the tags, the target, the env, the working directory and the required programs, the current ones are kept.
+			curr, err := m.store.GetCommandByID(ctx, commandID)
+			if err != nil {
+				return command.Command{}, fmt.Errorf("error getting current command: %v", err)
+			}
+
+			cmd := rev.Command
+			cmd.ID = commandID
+			cmd.Tags = curr.Tags
+			cmd.Target = curr.Target
+			cmd.Env = curr.Env
+			cmd.Dir = curr.Dir
+			cmd.Requires = curr.Requires
+			return m.UpdateCommand(ctx, cmd)
+		}
+	}
+
+	return command.Command{}, ErrElementNotFound
+}
+
+// Import saves the command keeping its ID, creating it or updating the current one.
+func (m *Manager) Import(ctx context.Context, cmd command.Command) (command.Command, error) {
+	_, err := m.store.GetCommandByID(ctx, cmd.ID)
+	if err == nil {
+		return m.UpdateCommand(ctx, cmd)
+	}
+	if !IsNotFound(err) {
+		return command.Command{}, fmt.Errorf("error getting current command: %v", err)
+	}
+
+	if err := m.store.Save(ctx, cmd); err != nil {
+		return command.Command{}, err
+	}
+
 	return cmd, nil
 }
 
 // InsertUsage inserts the usage of a command
-func (m *Manager) InsertUsage(ctx context.Context, commandID uuid.UUID, usage string) error {
+func (m *Manager) InsertUsage(ctx context.Context, commandID uuid.UUID, usage command.Usage) error {
 	err := m.store.InsertUsage(ctx, commandID, usage)
 	if err != nil {
 		return err
@@ -168,65 +463,119 @@ func (m *Manager) GetHistory(ctx context.Context, commandID uuid.UUID) (command.
 }
 
 // WriteExplanation writes the explanation in the notebook.
-func (m *Manager) WriteExplanation(ctx context.Context, commandID uuid.UUID, explanation string) error {
+func (m *Manager) WriteExplanation(ctx context.Context, commandID uuid.UUID, explanation command.Explanation) error {
 	if m.notebook == nil {
 		return ErrNotebookNotEnabled
 	}
 
-	var buf bytes.Buffer
-	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	text, err := compress(explanation.Content)
 	if err != nil {
-		return fmt.Errorf("error creating writer: %v", err)
+		return err
 	}
 
-	_, err = writer.Write([]byte(explanation))
-	if err != nil {
-		return fmt.Errorf("error compressing explanation: %v", err)
+	plain := explanation.Content
+	explanation.Content = text
+	if err := m.notebook.WriteExplanation(ctx, commandID, explanation); err != nil {
+		return err
 	}
 
-	err = writer.Close()
+	if index, ok := m.notebook.(explanationIndex); ok {
+		return index.IndexExplanation(ctx, commandID, plain)
+	}
+	return nil
+}
+
+// IndexExplanations adds the explanations missing in the search index of the notebook, e.g. the ones
+// written before it existed, returning how many were added.
+func (m *Manager) IndexExplanations(ctx context.Context) (int, error) {
+	index, ok := m.notebook.(explanationIndex)
+	if !ok {
+		return 0, nil
+	}
+
+	ids, err := index.UnindexedExplanations(ctx)
 	if err != nil {
-		return fmt.Errorf("error clossing writer: %v", err)
+		return 0, err
 	}
 
-	text := base64.StdEncoding.EncodeToString(buf.Bytes())
-	return m.notebook.WriteExplanation(ctx, commandID, text)
+	for i, id := range ids {
+		explanation, err := m.ReadExplanation(ctx, id)
+		if err != nil {
+			return i, err
+		}
+		if err := index.IndexExplanation(ctx, id, explanation.Content); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
 }
 
 // ReadExplanation reads the explanation from the notebook.
-func (m *Manager) ReadExplanation(ctx context.Context, commandID uuid.UUID) (string, error) {
+func (m *Manager) ReadExplanation(ctx context.Context, commandID uuid.UUID) (command.Explanation, error) {
 	if m.notebook == nil {
-		return "", ErrNotebookNotEnabled
+		return command.Explanation{}, ErrNotebookNotEnabled
 	}
 
-	encoded, err := m.notebook.ReadExplanation(ctx, commandID)
+	explanation, err := m.notebook.ReadExplanation(ctx, commandID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNotFound) {
-			return "", ErrElementNotFound
+		if IsNotFound(err) {
+			return command.Explanation{}, ErrElementNotFound
 		}
-		return "", err
+		return command.Explanation{}, err
 	}
 
-	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	explanation.Content, err = decompress(explanation.Content)
 	if err != nil {
-		return "", fmt.Errorf("error base64 decoding explanation: %v", err)
+		return command.Explanation{}, err
 	}
 
-	reader, err := gzip.NewReader(bytes.NewReader([]byte(compressed)))
+	return explanation, nil
+}
+
+// WriteTranscript writes the follow-up conversation of the command in the notebook.
+func (m *Manager) WriteTranscript(ctx context.Context, commandID uuid.UUID, transcript []command.Message) error {
+	if m.notebook == nil {
+		return ErrNotebookNotEnabled
+	}
+
+	raw, err := json.Marshal(transcript)
 	if err != nil {
-		return "", fmt.Errorf("error creating reader: %v", err)
+		return fmt.Errorf("error encoding transcript: %v", err)
 	}
 
-	defer func() {
-		_ = reader.Close()
-	}()
+	text, err := compress(string(raw))
+	if err != nil {
+		return err
+	}
+
+	return m.notebook.WriteTranscript(ctx, commandID, text)
+}
 
-	explanation, err := io.ReadAll(reader)
+// ReadTranscript reads the follow-up conversation of the command from the notebook.
+func (m *Manager) ReadTranscript(ctx context.Context, commandID uuid.UUID) ([]command.Message, error) {
+	if m.notebook == nil {
+		return nil, ErrNotebookNotEnabled
+	}
+
+	encoded, err := m.notebook.ReadTranscript(ctx, commandID)
 	if err != nil {
-		return "", err
+		if IsNotFound(err) {
+			return nil, ErrElementNotFound
+		}
+		return nil, err
 	}
 
-	return string(explanation), nil
+	raw, err := decompress(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var transcript []command.Message
+	if err := json.Unmarshal([]byte(raw), &transcript); err != nil {
+		return nil, fmt.Errorf("error decoding transcript: %v", err)
+	}
+
+	return transcript, nil
 }
 
 // DeleteExplanation deletes the explanation from the notebook.
@@ -236,10 +585,84 @@ func (m *Manager) DeleteExplanation(ctx context.Context, commandID uuid.UUID) er
 	}
 
 	if err := m.notebook.DeleteExplanation(ctx, commandID); err != nil {
-		if !errors.Is(err, sql.ErrNotFound) {
+		if !IsNotFound(err) {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// IsNotFound checks if the error is a not found error, from the manager or any of the stores.
+func IsNotFound(err error) bool {
+	return errors.Is(err, command.ErrNotFound)
+}
+
+// changed returns if the new version of the command differs from the current one.
+func changed(curr, cmd command.Command) bool {
+	if curr.Name != cmd.Name || curr.Description != cmd.Description || curr.Command != cmd.Command {
+		return true
+	}
+
+	if len(curr.Params) != len(cmd.Params) {
+		return true
+	}
+
+	params := make(map[uuid.UUID]command.Parameter, len(curr.Params))
+	for _, param := range curr.Params {
+		params[param.ID] = param
+	}
+
+	for _, param := range cmd.Params {
+		if old, ok := params[param.ID]; !ok || old != param {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compress returns the text gzipped and base64 encoded.
+func compress(text string) (string, error) {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("error creating writer: %v", err)
+	}
+
+	_, err = writer.Write([]byte(text))
+	if err != nil {
+		return "", fmt.Errorf("error compressing text: %v", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", fmt.Errorf("error clossing writer: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompress returns the text from its compressed form.
+func decompress(encoded string) (string, error) {
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("error base64 decoding text: %v", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader([]byte(compressed)))
+	if err != nil {
+		return "", fmt.Errorf("error creating reader: %v", err)
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
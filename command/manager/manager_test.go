@@ -3,7 +3,10 @@ package manager
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +14,9 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/file"
+	"github.com/lian-rr/clio/command/search"
+	"github.com/lian-rr/clio/command/sql"
 )
 
 func TestManager_Add(t *testing.T) {
@@ -138,39 +144,18 @@ func TestManager_GetOne(t *testing.T) {
 
 func TestManager_Search(t *testing.T) {
 	mockErr := errors.New("mock error")
-	id, err := uuid.NewV7()
-	require.NoError(t, err)
-	id2, err := uuid.NewV7()
-	require.NoError(t, err)
-	id3, err := uuid.NewV7()
-	require.NoError(t, err)
 
-	testCmds := []command.Command{
-		{
-			ID:          id,
-			Name:        "test command",
-			Description: "this is a test command",
-			Command:     "echo 'hello world'",
-		},
-		{
-			ID:          id2,
-			Name:        "test command 2",
-			Description: "this is a test command 2",
-			Command:     "echo 'hello world 2'",
-		},
-		{
-			ID:          id3,
-			Name:        "test command 3",
-			Description: "this is a test command 3",
-			Command:     "echo 'hello world 3'",
-		},
-	}
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods"}
+	deploys := command.Command{ID: uuid.New(), Name: "deploys", Command: "kubectl get deployments"}
+	squash := command.Command{ID: uuid.New(), Name: "squash", Command: "git reset --soft HEAD~2"}
+	testCmds := []command.Command{pods, deploys, squash}
 
 	tests := []struct {
 		name           string
 		expectedError  error
 		input          string
 		setExpectation func(mock *mockStore, ctx context.Context)
+		expectedOut    []uuid.UUID
 	}{
 		{
 			name:          "store returned an error",
@@ -180,12 +165,100 @@ func TestManager_Search(t *testing.T) {
 				testMock.On("SearchCommand", ctx, "test").Return(nil, mockErr)
 			},
 		},
+		{
+			name:          "error listing the usages",
+			expectedError: mockErr,
+			input:         "pods",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("SearchCommand", ctx, "pods").Return([]command.Command{pods}, nil)
+				testMock.On("SearchContent", ctx, "pods").Return([]command.ContentMatch{}, nil)
+				testMock.On("ListCommands", ctx).Return(testCmds, nil)
+				testMock.On("ListUsages", ctx).Return(nil, mockErr)
+			},
+		},
+		{
+			name:          "error searching the content",
+			expectedError: mockErr,
+			input:         "pods",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("SearchCommand", ctx, "pods").Return([]command.Command{pods}, nil)
+				testMock.On("SearchContent", ctx, "pods").Return(nil, mockErr)
+			},
+		},
+		{
+			name:        "term without words",
+			input:       ` - : `,
+			expectedOut: []uuid.UUID{},
+		},
+		{
+			name:          "invalid query",
+			input:         "pods tag:",
+			expectedError: errors.New(`invalid "tag:": missing value`),
+		},
+		{
+			name:  "filters",
+			input: "kubectl tag:k8s",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("SearchCommand", ctx, "kubectl").Return([]command.Command{pods, deploys}, nil)
+				testMock.On("SearchContent", ctx, "kubectl").Return([]command.ContentMatch{}, nil)
+				testMock.On("FilterCommands", ctx, search.Query{
+					Terms:   []string{"kubectl"},
+					Filters: []search.Filter{{Field: search.TagField, Value: "k8s"}},
+				}).Return([]command.Command{deploys}, nil)
+				testMock.On("ListUsages", ctx).Return(map[uuid.UUID][]time.Time{}, nil)
+			},
+			expectedOut: []uuid.UUID{deploys.ID},
+		},
+		{
+			name:  "only filters",
+			input: "used:<7d",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("FilterCommands", ctx, search.Query{
+					Usage: []search.UsageFilter{{Period: 7 * 24 * time.Hour, Within: true}},
+				}).Return([]command.Command{squash, pods}, nil)
+				testMock.On("ListUsages", ctx).Return(map[uuid.UUID][]time.Time{
+					pods.ID:   {time.Now()},
+					squash.ID: {time.Now().Add(-6 * 24 * time.Hour)},
+				}, nil)
+			},
+			expectedOut: []uuid.UUID{pods.ID, squash.ID},
+		},
 		{
 			name:  "happy path",
-			input: "test",
+			input: "pods",
 			setExpectation: func(testMock *mockStore, ctx context.Context) {
-				testMock.On("SearchCommand", ctx, "test").Return(testCmds, nil)
+				testMock.On("SearchCommand", ctx, "pods").Return([]command.Command{pods}, nil)
+				testMock.On("SearchContent", ctx, "pods").Return([]command.ContentMatch{}, nil)
+				testMock.On("ListCommands", ctx).Return(testCmds, nil)
+				testMock.On("ListUsages", ctx).Return(map[uuid.UUID][]time.Time{}, nil)
 			},
+			expectedOut: []uuid.UUID{pods.ID},
+		},
+		{
+			name:  "content",
+			input: "namespace",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("SearchCommand", ctx, "namespace").Return([]command.Command{}, nil)
+				testMock.On("SearchContent", ctx, "namespace").Return([]command.ContentMatch{
+					{Command: deploys, Source: command.ExplanationMatch, Snippet: "in the «namespace»"},
+				}, nil)
+				testMock.On("ListCommands", ctx).Return(testCmds, nil)
+				testMock.On("ListUsages", ctx).Return(map[uuid.UUID][]time.Time{}, nil)
+			},
+			expectedOut: []uuid.UUID{deploys.ID},
+		},
+		{
+			name:  "typo",
+			input: "kubetcl",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("SearchCommand", ctx, "kubetcl").Return([]command.Command{}, nil)
+				testMock.On("SearchContent", ctx, "kubetcl").Return([]command.ContentMatch{}, nil)
+				testMock.On("ListCommands", ctx).Return(testCmds, nil)
+				testMock.On("ListUsages", ctx).Return(map[uuid.UUID][]time.Time{
+					pods.ID: {time.Now()},
+				}, nil)
+			},
+			expectedOut: []uuid.UUID{pods.ID, deploys.ID},
 		},
 	}
 
@@ -202,14 +275,19 @@ func TestManager_Search(t *testing.T) {
 				store: store,
 			}
 
-			cmds, err := manager.Search(ctx, tt.input)
+			results, err := manager.Search(ctx, tt.input)
+			store.AssertExpectations(t)
 			if tt.expectedError != nil {
 				assert.EqualError(t, err, tt.expectedError.Error(), "error not the expected")
 				return
 			}
 
 			assert.NoError(t, err, "unexpected error")
-			assert.Equal(t, testCmds, cmds, "cmd no the expected")
+			ids := make([]uuid.UUID, 0, len(results))
+			for _, r := range results {
+				ids = append(ids, r.Command.ID)
+			}
+			assert.Equal(t, tt.expectedOut, ids, "results not the expected")
 		})
 	}
 }
@@ -289,6 +367,574 @@ func TestManager_GetAll(t *testing.T) {
 	}
 }
 
+func TestManager_Import(t *testing.T) {
+	mockErr := errors.New("mock error")
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	param := command.Parameter{ID: uuid.New(), Name: "path"}
+	testCmd := command.Command{
+		ID:      id,
+		Name:    "test command",
+		Command: "echo 'hello world'",
+	}
+
+	tests := []struct {
+		name           string
+		expectedError  error
+		setExpectation func(mock *mockStore, ctx context.Context)
+	}{
+		{
+			name: "new command",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("GetCommandByID", ctx, id).Return(command.Command{}, sql.ErrNotFound)
+				testMock.On("Save", ctx, testCmd).Return(nil)
+			},
+		},
+		{
+			name: "existing command",
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				curr := testCmd
+				curr.Params = []command.Parameter{param}
+				testMock.On("GetCommandByID", ctx, id).Return(curr, nil)
+				testMock.On("InsertRevision", ctx, curr).Return(nil)
+				testMock.On("Save", ctx, testCmd).Return(nil)
+				testMock.On("DeleteParameters", ctx, []uuid.UUID{param.ID}).Return(nil)
+			},
+		},
+		{
+			name:          "store returned an error",
+			expectedError: mockErr,
+			setExpectation: func(testMock *mockStore, ctx context.Context) {
+				testMock.On("GetCommandByID", ctx, id).Return(command.Command{}, mockErr)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			ctx := context.Background()
+
+			tt.setExpectation(store, ctx)
+
+			manager := Manager{
+				store: store,
+			}
+
+			cmd, err := manager.Import(ctx, testCmd)
+			if tt.expectedError != nil {
+				assert.ErrorContains(t, err, tt.expectedError.Error(), "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, testCmd, cmd, "cmd no the expected")
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestManager_UpdateCommand(t *testing.T) {
+	mockErr := errors.New("mock error")
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	curr := command.Command{
+		ID:      id,
+		Name:    "list",
+		Command: "ls {{.path}}",
+		Params:  []command.Parameter{{ID: uuid.New(), Name: "path", DefaultValue: "."}},
+	}
+
+	tests := []struct {
+		name           string
+		cmd            func() command.Command
+		expectedError  error
+		setExpectation func(mock *mockStore, ctx context.Context, cmd command.Command)
+	}{
+		{
+			name: "changed command",
+			cmd: func() command.Command {
+				cmd := curr
+				cmd.Command = "ls -la {{.path}}"
+				return cmd
+			},
+			setExpectation: func(testMock *mockStore, ctx context.Context, cmd command.Command) {
+				testMock.On("GetCommandByID", ctx, id).Return(curr, nil)
+				testMock.On("InsertRevision", ctx, curr).Return(nil)
+				testMock.On("Save", ctx, cmd).Return(nil)
+				testMock.On("DeleteParameters", ctx, []uuid.UUID{}).Return(nil)
+			},
+		},
+		{
+			name: "changed parameter",
+			cmd: func() command.Command {
+				cmd := curr
+				cmd.Params = []command.Parameter{curr.Params[0]}
+				cmd.Params[0].DefaultValue = "/tmp"
+				return cmd
+			},
+			setExpectation: func(testMock *mockStore, ctx context.Context, cmd command.Command) {
+				testMock.On("GetCommandByID", ctx, id).Return(curr, nil)
+				testMock.On("InsertRevision", ctx, curr).Return(nil)
+				testMock.On("Save", ctx, cmd).Return(nil)
+				testMock.On("DeleteParameters", ctx, []uuid.UUID{}).Return(nil)
+			},
+		},
+		{
+			name: "unchanged command",
+			cmd: func() command.Command {
+				return curr
+			},
+			setExpectation: func(testMock *mockStore, ctx context.Context, cmd command.Command) {
+				testMock.On("GetCommandByID", ctx, id).Return(curr, nil)
+				testMock.On("Save", ctx, cmd).Return(nil)
+				testMock.On("DeleteParameters", ctx, []uuid.UUID{}).Return(nil)
+			},
+		},
+		{
+			name: "error storing revision",
+			cmd: func() command.Command {
+				cmd := curr
+				cmd.Name = "list all"
+				return cmd
+			},
+			expectedError: mockErr,
+			setExpectation: func(testMock *mockStore, ctx context.Context, cmd command.Command) {
+				testMock.On("GetCommandByID", ctx, id).Return(curr, nil)
+				testMock.On("InsertRevision", ctx, curr).Return(mockErr)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockStore{}
+			ctx := context.Background()
+			cmd := tt.cmd()
+
+			tt.setExpectation(store, ctx, cmd)
+
+			manager := Manager{
+				store: store,
+			}
+
+			got, err := manager.UpdateCommand(ctx, cmd)
+			if tt.expectedError != nil {
+				assert.ErrorContains(t, err, tt.expectedError.Error(), "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, cmd, got, "cmd no the expected")
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestManager_RestoreRevision(t *testing.T) {
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	curr := command.Command{ID: id, Name: "list", Command: "ls -la", Tags: []string{"fs"}, Target: "ssh:nas"}
+	older := command.Command{ID: id, Name: "list", Command: "ls"}
+	revisions := []command.Revision{{ID: 7, Command: older}}
+	restored := command.Command{ID: id, Name: "list", Command: "ls", Tags: []string{"fs"}, Target: "ssh:nas"}
+
+	t.Run("restore", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+
+		store.On("ListRevisions", ctx, id).Return(revisions, nil)
+		store.On("GetCommandByID", ctx, id).Return(curr, nil)
+		store.On("InsertRevision", ctx, curr).Return(nil)
+		store.On("Save", ctx, restored).Return(nil)
+		store.On("DeleteParameters", ctx, []uuid.UUID{}).Return(nil)
+
+		manager := Manager{store: store}
+
+		got, err := manager.RestoreRevision(ctx, id, 7)
+		require.NoError(t, err)
+		assert.Equal(t, restored, got, "the current tags are kept")
+		store.AssertExpectations(t)
+	})
+
+	t.Run("missing revision", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+
+		store.On("ListRevisions", ctx, id).Return(revisions, nil)
+
+		manager := Manager{store: store}
+
+		_, err := manager.RestoreRevision(ctx, id, 8)
+		assert.ErrorIs(t, err, ErrElementNotFound)
+	})
+}
+
+func TestManager_Trash(t *testing.T) {
+	mockErr := errors.New("mock error")
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	cmd := command.Command{ID: id, Name: "list", Command: "ls"}
+
+	t.Run("delete", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+		store.On("TrashCommand", ctx, id).Return(nil)
+
+		manager := Manager{store: store}
+
+		assert.NoError(t, manager.DeleteCommand(ctx, id.String()))
+		store.AssertExpectations(t)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+		store.On("RestoreCommand", ctx, id).Return(nil)
+		store.On("GetCommandByID", ctx, id).Return(cmd, nil)
+
+		manager := Manager{store: store}
+
+		got, err := manager.RestoreCommand(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, cmd, got)
+	})
+
+	t.Run("restore missing command", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+		store.On("RestoreCommand", ctx, id).Return(sql.ErrNotFound)
+
+		manager := Manager{store: store}
+
+		_, err := manager.RestoreCommand(ctx, id)
+		assert.True(t, IsNotFound(err))
+	})
+
+	t.Run("purge expired", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+
+		old := command.DeletedCommand{Command: cmd, DeletedAt: time.Now().Add(-48 * time.Hour)}
+		recent := command.DeletedCommand{Command: command.Command{ID: uuid.New()}, DeletedAt: time.Now().Add(-time.Hour)}
+		store.On("ListTrash", ctx).Return([]command.DeletedCommand{recent, old}, nil)
+		store.On("DeleteCommand", ctx, id).Return(nil)
+
+		manager := Manager{store: store}
+
+		purged, err := manager.PurgeTrash(ctx, 24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("purge error", func(t *testing.T) {
+		store := &mockStore{}
+		ctx := context.Background()
+		store.On("ListTrash", ctx).Return(nil, mockErr)
+
+		manager := Manager{store: store}
+
+		_, err := manager.PurgeTrash(ctx, 24*time.Hour)
+		assert.ErrorContains(t, err, mockErr.Error())
+	})
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "manager", err: ErrElementNotFound, expected: true},
+		{name: "sql store", err: fmt.Errorf("error fetching command: %w", sql.ErrNotFound), expected: true},
+		{name: "file store", err: fmt.Errorf("error fetching command: %w", file.ErrNotFound), expected: true},
+		{name: "other", err: errors.New("boom"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsNotFound(tt.err))
+		})
+	}
+}
+
+func TestManager_Explanation(t *testing.T) {
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	explanation := command.Explanation{
+		Content: "# Summary\nprints the text",
+		Signature: command.ExplanationSignature{
+			CommandHash:   "hash",
+			Source:        "openai/gpt-4o",
+			PromptVersion: "1",
+		},
+	}
+
+	notebook := &mockNotebook{}
+	ctx := context.Background()
+
+	var stored command.Explanation
+	notebook.On("WriteExplanation", ctx, id, mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(2).(command.Explanation)
+		}).
+		Return(nil)
+	notebook.On("ReadExplanation", ctx, id).
+		Return(func() command.Explanation { return stored }, nil)
+
+	manager := Manager{
+		store:    &mockStore{},
+		notebook: notebook,
+	}
+
+	require.NoError(t, manager.WriteExplanation(ctx, id, explanation))
+	assert.NotEqual(t, explanation.Content, stored.Content, "content stored uncompressed")
+	assert.Equal(t, explanation.Signature, stored.Signature, "signature not stored")
+
+	got, err := manager.ReadExplanation(ctx, id)
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, explanation, got, "explanation not the expected")
+}
+
+func TestManager_IndexExplanations(t *testing.T) {
+	mockErr := errors.New("mock error")
+	ctx := context.Background()
+
+	indexed, err := uuid.NewV7()
+	require.NoError(t, err)
+	unindexed, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	content := "# Summary\nlists the pods of the namespace"
+	compressed, err := compress(content)
+	require.NoError(t, err)
+
+	t.Run("write", func(t *testing.T) {
+		notebook := &mockIndexedNotebook{}
+		notebook.On("WriteExplanation", ctx, indexed, mock.Anything).Return(nil)
+		notebook.On("IndexExplanation", ctx, indexed, content).Return(nil)
+
+		manager := Manager{store: &mockStore{}, notebook: notebook}
+		require.NoError(t, manager.WriteExplanation(ctx, indexed, command.Explanation{Content: content}))
+		notebook.AssertExpectations(t)
+	})
+
+	t.Run("unindexed", func(t *testing.T) {
+		notebook := &mockIndexedNotebook{}
+		notebook.On("UnindexedExplanations", ctx).Return([]uuid.UUID{unindexed}, nil)
+		notebook.On("ReadExplanation", ctx, unindexed).Return(command.Explanation{Content: compressed}, nil)
+		notebook.On("IndexExplanation", ctx, unindexed, content).Return(nil)
+
+		manager := Manager{store: &mockStore{}, notebook: notebook}
+		n, err := manager.IndexExplanations(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		notebook.AssertExpectations(t)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		notebook := &mockIndexedNotebook{}
+		notebook.On("UnindexedExplanations", ctx).Return(nil, mockErr)
+
+		manager := Manager{store: &mockStore{}, notebook: notebook}
+		_, err := manager.IndexExplanations(ctx)
+		assert.ErrorIs(t, err, mockErr)
+	})
+
+	t.Run("notebook without index", func(t *testing.T) {
+		manager := Manager{store: &mockStore{}, notebook: &mockNotebook{}}
+		n, err := manager.IndexExplanations(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+}
+
+func TestManager_SemanticSearch(t *testing.T) {
+	ctx := context.Background()
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Description: "list the pods", Command: "kubectl get pods"}
+	disk := command.Command{ID: uuid.New(), Name: "disk", Description: "disk usage", Command: "du -sh"}
+	embedder := fakeEmbedder{model: "fake"}
+
+	t.Run("ranks the stored embeddings", func(t *testing.T) {
+		store := &mockEmbeddingStore{}
+		store.On("ListCommands", ctx).Return([]command.Command{pods, disk}, nil)
+		store.On("ListEmbeddings", ctx).Return(map[uuid.UUID]command.Embedding{
+			pods.ID: {CommandID: pods.ID, Model: "fake", Hash: pods.EmbeddingHash(), Vector: embedder.vector(pods.EmbeddingText())},
+		}, nil)
+
+		manager, err := NewManager(store, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+
+		got, err := manager.Search(ctx, "~ kubectl pods")
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, pods.ID, got[0].Command.ID)
+		store.AssertNotCalled(t, "WriteEmbedding", mock.Anything, mock.Anything)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		manager, err := NewManager(&mockStore{}, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+		_, err = manager.Search(ctx, "~pods")
+		assert.ErrorIs(t, err, ErrSemanticSearchNotEnabled)
+
+		manager, err = NewManager(&mockEmbeddingStore{}, nil)
+		require.NoError(t, err)
+		_, err = manager.Search(ctx, "~pods")
+		assert.ErrorIs(t, err, ErrSemanticSearchNotEnabled)
+	})
+
+	t.Run("update leaves the embedding to the index", func(t *testing.T) {
+		updated := pods
+		updated.Command = "kubectl get pods -A"
+
+		store := &mockEmbeddingStore{}
+		store.On("GetCommandByID", ctx, pods.ID).Return(pods, nil)
+		store.On("InsertRevision", ctx, pods).Return(nil)
+		store.On("Save", ctx, updated).Return(nil)
+		store.On("DeleteParameters", ctx, []uuid.UUID{}).Return(nil)
+
+		manager, err := NewManager(store, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+		_, err = manager.UpdateCommand(ctx, updated)
+		require.NoError(t, err)
+		store.AssertNotCalled(t, "WriteEmbedding", mock.Anything, mock.Anything)
+	})
+}
+
+func TestManager_IndexEmbeddings(t *testing.T) {
+	ctx := context.Background()
+	mockErr := errors.New("mock error")
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Description: "list the pods", Command: "kubectl get pods"}
+	disk := command.Command{ID: uuid.New(), Name: "disk", Description: "disk usage", Command: "du -sh"}
+	logs := command.Command{ID: uuid.New(), Name: "logs", Description: "follow the logs", Command: "kubectl logs -f"}
+	embedder := fakeEmbedder{model: "fake"}
+
+	stored := map[uuid.UUID]command.Embedding{
+		pods.ID: {CommandID: pods.ID, Model: "fake", Hash: pods.EmbeddingHash(), Vector: embedder.vector(pods.EmbeddingText())},
+		disk.ID: {CommandID: disk.ID, Model: "fake", Hash: "outdated", Vector: []float32{1}},
+		logs.ID: {CommandID: logs.ID, Model: "other", Hash: logs.EmbeddingHash(), Vector: []float32{1}},
+	}
+
+	t.Run("computes the missing and outdated ones", func(t *testing.T) {
+		store := &mockEmbeddingStore{}
+		store.On("ListCommands", ctx).Return([]command.Command{pods, disk, logs}, nil)
+		store.On("ListEmbeddings", ctx).Return(stored, nil)
+		for _, cmd := range []command.Command{disk, logs} {
+			store.On("WriteEmbedding", ctx, command.Embedding{
+				CommandID: cmd.ID,
+				Model:     "fake",
+				Hash:      cmd.EmbeddingHash(),
+				Vector:    embedder.vector(cmd.EmbeddingText()),
+			}).Return(nil).Once()
+		}
+
+		manager, err := NewManager(store, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+
+		n, err := manager.IndexEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		store := &mockEmbeddingStore{}
+		store.On("ListCommands", ctx).Return([]command.Command{pods, disk}, nil)
+		store.On("ListEmbeddings", ctx).Return(stored, nil)
+		store.On("WriteEmbedding", ctx, mock.Anything).Return(mockErr)
+
+		manager, err := NewManager(store, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+
+		n, err := manager.IndexEmbeddings(ctx)
+		assert.ErrorIs(t, err, mockErr)
+		assert.Zero(t, n)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		manager, err := NewManager(&mockStore{}, nil, WithEmbedder(embedder))
+		require.NoError(t, err)
+
+		n, err := manager.IndexEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+}
+
+func TestManager_Transcript(t *testing.T) {
+	mockErr := errors.New("mock error")
+	id, err := uuid.NewV7()
+	require.NoError(t, err)
+
+	transcript := []command.Message{
+		{Role: command.UserRole, Content: "what if I add -z?"},
+		{Role: command.AssistantRole, Content: "it will compress the output"},
+	}
+
+	tests := []struct {
+		name           string
+		expectedError  error
+		setExpectation func(mock *mockNotebook, ctx context.Context)
+	}{
+		{
+			name:          "notebook returned an error",
+			expectedError: mockErr,
+			setExpectation: func(testMock *mockNotebook, ctx context.Context) {
+				testMock.On("WriteTranscript", ctx, id, mock.Anything).Return(mockErr)
+			},
+		},
+		{
+			name: "happy path",
+			setExpectation: func(testMock *mockNotebook, ctx context.Context) {
+				var stored string
+				testMock.On("WriteTranscript", ctx, id, mock.Anything).
+					Run(func(args mock.Arguments) {
+						stored = args.String(2)
+					}).
+					Return(nil)
+				testMock.On("ReadTranscript", ctx, id).
+					Return(func() string { return stored }, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notebook := &mockNotebook{}
+			ctx := context.Background()
+
+			if tt.setExpectation != nil {
+				tt.setExpectation(notebook, ctx)
+			}
+
+			manager := Manager{
+				store:    &mockStore{},
+				notebook: notebook,
+			}
+
+			err := manager.WriteTranscript(ctx, id, transcript)
+			if tt.expectedError != nil {
+				assert.EqualError(t, err, tt.expectedError.Error(), "error not the expected")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+
+			got, err := manager.ReadTranscript(ctx, id)
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, transcript, got, "transcript not the expected")
+		})
+	}
+}
+
 type mockStore struct {
 	mock.Mock
 }
@@ -318,6 +964,15 @@ func (m *mockStore) SearchCommand(ctx context.Context, term string) ([]command.C
 	return cmds.([]command.Command), args.Error(1)
 }
 
+func (m *mockStore) SearchContent(ctx context.Context, term string) ([]command.ContentMatch, error) {
+	args := m.Called(ctx, term)
+	matches := args.Get(0)
+	if matches == nil {
+		return nil, args.Error(1)
+	}
+	return matches.([]command.ContentMatch), args.Error(1)
+}
+
 func (m *mockStore) ListCommands(ctx context.Context) ([]command.Command, error) {
 	args := m.Called(ctx)
 	cmds := args.Get(0)
@@ -327,6 +982,15 @@ func (m *mockStore) ListCommands(ctx context.Context) ([]command.Command, error)
 	return cmds.([]command.Command), args.Error(1)
 }
 
+func (m *mockStore) FilterCommands(ctx context.Context, q search.Query) ([]command.Command, error) {
+	args := m.Called(ctx, q)
+	cmds := args.Get(0)
+	if cmds == nil {
+		return nil, args.Error(1)
+	}
+	return cmds.([]command.Command), args.Error(1)
+}
+
 func (m *mockStore) DeleteCommand(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -346,7 +1010,154 @@ func (m *mockStore) GetHistory(ctx context.Context, id uuid.UUID) (command.Histo
 	return cmd.(command.History), args.Error(1)
 }
 
-func (m *mockStore) InsertUsage(ctx context.Context, id uuid.UUID, usage string) error {
+func (m *mockStore) ListUsages(ctx context.Context) (map[uuid.UUID][]time.Time, error) {
+	args := m.Called(ctx)
+	usages := args.Get(0)
+	if usages == nil {
+		return nil, args.Error(1)
+	}
+	return usages.(map[uuid.UUID][]time.Time), args.Error(1)
+}
+
+func (m *mockStore) InsertUsage(ctx context.Context, id uuid.UUID, usage command.Usage) error {
 	args := m.Called(ctx, id, usage)
 	return args.Error(0)
 }
+
+func (m *mockStore) InsertRevision(ctx context.Context, cmd command.Command) error {
+	args := m.Called(ctx, cmd)
+	return args.Error(0)
+}
+
+func (m *mockStore) ListRevisions(ctx context.Context, id uuid.UUID) ([]command.Revision, error) {
+	args := m.Called(ctx, id)
+	revisions := args.Get(0)
+	if revisions == nil {
+		return nil, args.Error(1)
+	}
+	return revisions.([]command.Revision), args.Error(1)
+}
+
+func (m *mockStore) TrashCommand(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStore) RestoreCommand(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockStore) ListTrash(ctx context.Context) ([]command.DeletedCommand, error) {
+	args := m.Called(ctx)
+	cmds := args.Get(0)
+	if cmds == nil {
+		return nil, args.Error(1)
+	}
+	return cmds.([]command.DeletedCommand), args.Error(1)
+}
+
+type mockNotebook struct {
+	mock.Mock
+}
+
+var _ notebook = (*mockNotebook)(nil)
+
+func (m *mockNotebook) WriteExplanation(ctx context.Context, id uuid.UUID, explanation command.Explanation) error {
+	args := m.Called(ctx, id, explanation)
+	return args.Error(0)
+}
+
+func (m *mockNotebook) ReadExplanation(ctx context.Context, id uuid.UUID) (command.Explanation, error) {
+	args := m.Called(ctx, id)
+	if fn, ok := args.Get(0).(func() command.Explanation); ok {
+		return fn(), args.Error(1)
+	}
+	return args.Get(0).(command.Explanation), args.Error(1)
+}
+
+func (m *mockNotebook) DeleteExplanation(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockNotebook) WriteTranscript(ctx context.Context, id uuid.UUID, transcript string) error {
+	args := m.Called(ctx, id, transcript)
+	return args.Error(0)
+}
+
+func (m *mockNotebook) ReadTranscript(ctx context.Context, id uuid.UUID) (string, error) {
+	args := m.Called(ctx, id)
+	return stringArg(args, 0), args.Error(1)
+}
+
+type mockIndexedNotebook struct {
+	mockNotebook
+}
+
+var _ explanationIndex = (*mockIndexedNotebook)(nil)
+
+func (m *mockIndexedNotebook) IndexExplanation(ctx context.Context, id uuid.UUID, content string) error {
+	args := m.Called(ctx, id, content)
+	return args.Error(0)
+}
+
+func (m *mockIndexedNotebook) UnindexedExplanations(ctx context.Context) ([]uuid.UUID, error) {
+	args := m.Called(ctx)
+	ids := args.Get(0)
+	if ids == nil {
+		return nil, args.Error(1)
+	}
+	return ids.([]uuid.UUID), args.Error(1)
+}
+
+type mockEmbeddingStore struct {
+	mockStore
+}
+
+var _ embeddingStore = (*mockEmbeddingStore)(nil)
+
+func (m *mockEmbeddingStore) WriteEmbedding(ctx context.Context, embedding command.Embedding) error {
+	args := m.Called(ctx, embedding)
+	return args.Error(0)
+}
+
+func (m *mockEmbeddingStore) ListEmbeddings(ctx context.Context) (map[uuid.UUID]command.Embedding, error) {
+	args := m.Called(ctx)
+	embeddings := args.Get(0)
+	if embeddings == nil {
+		return nil, args.Error(1)
+	}
+	return embeddings.(map[uuid.UUID]command.Embedding), args.Error(1)
+}
+
+// fakeEmbedder embeds the texts deterministically, counting the letters.
+type fakeEmbedder struct {
+	model string
+}
+
+func (f fakeEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	return f.vector(text), nil
+}
+
+func (f fakeEmbedder) EmbeddingModel() string {
+	return f.model
+}
+
+func (f fakeEmbedder) vector(text string) []float32 {
+	vector := make([]float32, 26)
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' {
+			vector[r-'a']++
+		}
+	}
+	return vector
+}
+
+// stringArg returns the string argument, resolving it if it was set as a func.
+func stringArg(args mock.Arguments, idx int) string {
+	if fn, ok := args.Get(idx).(func() string); ok {
+		return fn()
+	}
+	return args.String(idx)
+}
@@ -0,0 +1,84 @@
+// Package prereq checks the programs needed by the commands are installed, e.g. for the libraries synced across machines.
+package prereq
+
+import (
+	"os/exec"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/target"
+)
+
+// Checker finds the programs of the commands missing in the PATH. The lookups are cached.
+type Checker struct {
+	lookPath func(string) (string, error)
+
+	mu    sync.Mutex
+	found map[string]bool
+}
+
+// OptFunc is used to apply optional settings to the Checker.
+type OptFunc func(*Checker)
+
+// WithLookPath sets the func finding the programs, exec.LookPath by default.
+func WithLookPath(lookPath func(string) (string, error)) OptFunc {
+	return func(c *Checker) {
+		c.lookPath = lookPath
+	}
+}
+
+// New returns a new Checker.
+func New(opts ...OptFunc) *Checker {
+	c := &Checker{
+		lookPath: exec.LookPath,
+		found:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Missing returns the programs of the command missing in the PATH, empty if it can run.
+// The commands with an execution target only need the program wrapping them, e.g. ssh,
+// their programs are in the host, container or pod.
+func (c *Checker) Missing(cmd command.Command) []string {
+	programs := cmd.Programs()
+	if t, err := target.Parse(cmd.Target); err == nil && !t.Local() {
+		programs = []string{string(t.Kind)}
+	}
+
+	var missing []string
+	for _, program := range programs {
+		if !c.installed(program) {
+			missing = append(missing, program)
+		}
+	}
+	return missing
+}
+
+// MissingAll returns the missing programs of the commands, by command ID. The commands that can run are left out.
+func (c *Checker) MissingAll(cmds []command.Command) map[uuid.UUID][]string {
+	missing := make(map[uuid.UUID][]string)
+	for _, cmd := range cmds {
+		if programs := c.Missing(cmd); len(programs) > 0 {
+			missing[cmd.ID] = programs
+		}
+	}
+	return missing
+}
+
+func (c *Checker) installed(program string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found, ok := c.found[program]
+	if !ok {
+		_, err := c.lookPath(program)
+		found = err == nil
+		c.found[program] = found
+	}
+	return found
+}
@@ -0,0 +1,79 @@
+package prereq
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestChecker_Missing(t *testing.T) {
+	installed := map[string]bool{"grep": true, "ssh": true}
+	lookups := make(map[string]int)
+	checker := New(WithLookPath(func(program string) (string, error) {
+		lookups[program]++
+		if installed[program] {
+			return "/usr/bin/" + program, nil
+		}
+		return "", errors.New("not found")
+	}))
+
+	tests := []struct {
+		name     string
+		cmd      command.Command
+		expected []string
+	}{
+		{
+			name: "installed",
+			cmd:  command.Command{Command: "grep -r {{.term}} ."},
+		},
+		{
+			name:     "missing",
+			cmd:      command.Command{Command: "journalctl -u {{.unit}} | grep error"},
+			expected: []string{"journalctl"},
+		},
+		{
+			name:     "missing required",
+			cmd:      command.Command{Command: "./backup.sh", Requires: []string{"pg_dump"}},
+			expected: []string{"pg_dump"},
+		},
+		{
+			name: "installed target",
+			cmd:  command.Command{Command: "journalctl -u {{.unit}}", Target: "ssh:prod-1"},
+		},
+		{
+			name:     "missing target",
+			cmd:      command.Command{Command: "grep error /var/log/app.log", Target: "kubectl:payments/api-0"},
+			expected: []string{"kubectl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, checker.Missing(tt.cmd))
+		})
+	}
+
+	for program, count := range lookups {
+		assert.Equal(t, 1, count, "%s should be looked up once", program)
+	}
+}
+
+func TestChecker_MissingAll(t *testing.T) {
+	checker := New(WithLookPath(func(program string) (string, error) {
+		if program == "pg_dump" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + program, nil
+	}))
+
+	dump := command.Command{ID: uuid.New(), Command: "pg_dump {{.db}}"}
+	list := command.Command{ID: uuid.New(), Command: "ls -la"}
+
+	assert.Equal(t,
+		map[uuid.UUID][]string{dump.ID: {"pg_dump"}},
+		checker.MissingAll([]command.Command{dump, list}))
+}
@@ -0,0 +1,236 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/professor"
+)
+
+const (
+	providerName     = "anthropic"
+	promptVersion    = "1"
+	apiVersion       = "2023-06-01"
+	messagesPath     = "/v1/messages"
+	defaultUrl       = "https://api.anthropic.com"
+	defaultModel     = "claude-3-5-sonnet-latest"
+	defaultMaxTokens = 2048
+)
+
+// ErrNoResponse thrown when there is no text in the response of the API.
+var ErrNoResponse = errors.New("no response")
+
+// Client holds the Anthropic Messages API client and some configuration.
+type Client struct {
+	logger     *slog.Logger
+	apiKey     string
+	baseUrl    string
+	model      string
+	maxTokens  int
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// OptFunc used for setting optional configs.
+type OptFunc func(client *Client)
+
+type (
+	request struct {
+		Model     string    `json:"model"`
+		MaxTokens int       `json:"max_tokens"`
+		System    string    `json:"system,omitempty"`
+		Messages  []message `json:"messages"`
+	}
+
+	message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	response struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+
+	errorResponse struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+// New returns a new Anthropic client.
+func New(logger *slog.Logger, apiKey string, opts ...OptFunc) Client {
+	client := Client{
+		logger:     logger,
+		apiKey:     apiKey,
+		baseUrl:    defaultUrl,
+		model:      defaultModel,
+		maxTokens:  defaultMaxTokens,
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(&client)
+	}
+
+	return client
+}
+
+// Prompt sends the conversation to the Messages API.
+// System messages are sent as the system prompt.
+func (c Client) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	req := request{
+		Model:     c.model,
+		MaxTokens: c.maxTokens,
+		Messages:  make([]message, 0, len(conversation)),
+	}
+
+	system := make([]string, 0, 1)
+	for _, msg := range conversation {
+		switch msg.Role {
+		case command.SystemRole:
+			system = append(system, msg.Content)
+		case command.AssistantRole:
+			req.Messages = append(req.Messages, message{Role: "assistant", Content: msg.Content})
+		default:
+			req.Messages = append(req.Messages, message{Role: "user", Content: msg.Content})
+		}
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("error encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.baseUrl, "/")+messagesPath, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("anthropic-version", apiVersion)
+	if c.apiKey != "" {
+		httpReq.Header.Set("x-api-key", c.apiKey)
+	}
+	for key, value := range c.headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		err = fmt.Errorf("error prompting: %w", err)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return "", professor.Transient(err)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("error prompting: %s", apiError(resp.StatusCode, raw))
+		if isTransient(resp.StatusCode) {
+			return "", professor.Transient(err)
+		}
+		return "", err
+	}
+
+	var res response
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range res.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	if text.Len() == 0 {
+		return "", ErrNoResponse
+	}
+
+	return text.String(), nil
+}
+
+// Name returns the provider and model used.
+func (c Client) Name() string {
+	return fmt.Sprintf("%s/%s", providerName, c.model)
+}
+
+// PromptVersion returns the version of the conversation format sent to the API.
+func (c Client) PromptVersion() string {
+	return promptVersion
+}
+
+// apiError returns the error message of the response, or the status if it can't be decoded.
+func apiError(status int, body []byte) string {
+	var res errorResponse
+	if err := json.Unmarshal(body, &res); err != nil || res.Error.Message == "" {
+		return fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+
+	return fmt.Sprintf("%d %s: %s", status, res.Error.Type, res.Error.Message)
+}
+
+// isTransient returns true for the status codes worth retrying: timeouts, rate limits and server errors (overloaded included).
+func isTransient(status int) bool {
+	return status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests ||
+		status >= http.StatusInternalServerError
+}
+
+// WithBaseUrl sets the Client baseUrl optional param.
+func WithBaseUrl(url string) OptFunc {
+	return func(client *Client) {
+		client.baseUrl = url
+	}
+}
+
+// WithModel sets the Client model optional param.
+func WithModel(model string) OptFunc {
+	return func(client *Client) {
+		client.model = model
+	}
+}
+
+// WithMaxTokens sets the max number of tokens of the answer.
+func WithMaxTokens(maxTokens int) OptFunc {
+	return func(client *Client) {
+		client.maxTokens = maxTokens
+	}
+}
+
+// WithHeaders sets headers sent on every request, e.g. for a gateway.
+func WithHeaders(headers map[string]string) OptFunc {
+	return func(client *Client) {
+		client.headers = headers
+	}
+}
+
+// WithHTTPClient sets the http client used for the requests.
+func WithHTTPClient(httpClient *http.Client) OptFunc {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
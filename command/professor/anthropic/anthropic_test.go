@@ -0,0 +1,132 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/professor"
+)
+
+func TestClient_Prompt(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	conversation := []command.Message{
+		{Role: command.SystemRole, Content: "instructions"},
+		{Role: command.UserRole, Content: "ls -la"},
+		{Role: command.AssistantRole, Content: "explanation"},
+		{Role: command.UserRole, Content: "and -h?"},
+	}
+
+	tests := []struct {
+		name             string
+		status           int
+		body             string
+		expectedResponse string
+		expectedErr      string
+		transient        bool
+	}{
+		{
+			name:             "success",
+			status:           http.StatusOK,
+			body:             `{"content":[{"type":"text","text":"human "},{"type":"text","text":"readable"}]}`,
+			expectedResponse: "human readable",
+		},
+		{
+			name:        "empty response",
+			status:      http.StatusOK,
+			body:        `{"content":[]}`,
+			expectedErr: ErrNoResponse.Error(),
+		},
+		{
+			name:        "invalid request",
+			status:      http.StatusBadRequest,
+			body:        `{"type":"error","error":{"type":"invalid_request_error","message":"bad model"}}`,
+			expectedErr: "400 invalid_request_error: bad model",
+		},
+		{
+			name:        "overloaded is transient",
+			status:      529,
+			body:        `{"type":"error","error":{"type":"overloaded_error","message":"overloaded"}}`,
+			expectedErr: "overloaded",
+			transient:   true,
+		},
+		{
+			name:        "rate limit is transient",
+			status:      http.StatusTooManyRequests,
+			body:        `not json`,
+			expectedErr: "429 Too Many Requests",
+			transient:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, messagesPath, r.URL.Path, "path not the expected")
+				assert.Equal(t, "key", r.Header.Get("x-api-key"), "api key not the expected")
+				assert.Equal(t, apiVersion, r.Header.Get("anthropic-version"), "version not the expected")
+				assert.Equal(t, "team", r.Header.Get("x-gateway-team"), "custom header not the expected")
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := New(logger, "key",
+				WithBaseUrl(server.URL),
+				WithModel("model"),
+				WithHeaders(map[string]string{"x-gateway-team": "team"}),
+			)
+
+			resp, err := client.Prompt(context.Background(), conversation)
+
+			assert.Equal(t, request{
+				Model:     "model",
+				MaxTokens: defaultMaxTokens,
+				System:    "instructions",
+				Messages: []message{
+					{Role: "user", Content: "ls -la"},
+					{Role: "assistant", Content: "explanation"},
+					{Role: "user", Content: "and -h?"},
+				},
+			}, got, "request not the expected")
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr, "error not the expected")
+				assert.Equal(t, tt.transient, errors.Is(err, professor.ErrTransient), "transient not the expected")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedResponse, resp, "response not the expected")
+		})
+	}
+}
+
+func TestProvider(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	source, err := professor.NewSource(logger, providerName, func(v any) error {
+		cfg := v.(*Config)
+		cfg.ApiKey = "key"
+		cfg.Model = "model"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic/model", source.Name())
+
+	_, err = professor.NewSource(logger, providerName, func(v any) error { return nil })
+	assert.Error(t, err, "missing key should fail")
+}
@@ -0,0 +1,57 @@
+package anthropic
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/lian-rr/clio/command/professor"
+)
+
+func init() {
+	professor.Register(providerName, professor.Provider{
+		Decode: professor.DecodeAs(Config.validate),
+		New: func(logger *slog.Logger, cfg any) (professor.Source, error) {
+			return cfg.(Config).source(logger), nil
+		},
+	})
+}
+
+// Config holds the configuration of the anthropic provider.
+type Config struct {
+	ApiKey    string `toml:"key"`
+	Url       string `toml:"url"`
+	Model     string `toml:"model"`
+	MaxTokens int    `toml:"maxTokens"`
+	// Headers are sent on every request.
+	Headers map[string]string `toml:"headers"`
+}
+
+func (c Config) validate() error {
+	var errs error
+	if c.ApiKey == "" && len(c.Headers) == 0 {
+		errs = errors.Join(errs, errors.New("missing anthropic api key"))
+	}
+	if c.MaxTokens < 0 {
+		errs = errors.Join(errs, errors.New("invalid max tokens"))
+	}
+
+	return errs
+}
+
+func (c Config) source(logger *slog.Logger) Client {
+	opts := make([]OptFunc, 0)
+	if c.Url != "" {
+		opts = append(opts, WithBaseUrl(c.Url))
+	}
+	if c.Model != "" {
+		opts = append(opts, WithModel(c.Model))
+	}
+	if c.MaxTokens > 0 {
+		opts = append(opts, WithMaxTokens(c.MaxTokens))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, WithHeaders(c.Headers))
+	}
+
+	return New(logger, c.ApiKey, opts...)
+}
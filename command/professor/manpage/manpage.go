@@ -0,0 +1,317 @@
+package manpage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/shell"
+)
+
+const (
+	sourceName    = "manpage"
+	promptVersion = "1"
+	manWidth      = "100"
+)
+
+var (
+	// ErrNoCommand thrown when the conversation doesn't contain the command to explain.
+	ErrNoCommand = errors.New("no command to explain")
+	// ErrNoDocs thrown when there is no documentation for any of the programs.
+	ErrNoDocs = errors.New("no documentation found")
+
+	// overstrike used by man for bold (x\bx) and underline (_\bx).
+	overstrikeRegex = regexp.MustCompile(".\x08")
+	ansiRegex       = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+)
+
+// LookupFunc returns the documentation of the program.
+type LookupFunc func(ctx context.Context, program string) (string, error)
+
+// Source explains commands using the local man pages, no network needed.
+type Source struct {
+	logger       *slog.Logger
+	lookup       LookupFunc
+	helpFallback bool
+}
+
+// OptFunc used for setting optional configs.
+type OptFunc func(source *Source)
+
+// New returns a new man page Source.
+func New(logger *slog.Logger, opts ...OptFunc) Source {
+	source := Source{
+		logger: logger,
+	}
+
+	for _, opt := range opts {
+		opt(&source)
+	}
+
+	if source.lookup == nil {
+		source.lookup = source.readDocs
+	}
+
+	return source
+}
+
+// Prompt explains the command in the conversation.
+// The first user message is the command; follow-up questions are answered for the flags they mention.
+func (s Source) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	var (
+		cmd      string
+		question string
+	)
+	for _, msg := range conversation {
+		if msg.Role != command.UserRole {
+			continue
+		}
+		if cmd == "" {
+			cmd = msg.Content
+			continue
+		}
+		question = msg.Content
+	}
+
+	if cmd == "" {
+		return "", ErrNoCommand
+	}
+
+	segments := shell.Split(cmd)
+	if len(segments) == 0 {
+		return "", ErrNoCommand
+	}
+
+	if question != "" {
+		return s.answer(ctx, segments, question)
+	}
+
+	return s.explain(ctx, cmd, segments)
+}
+
+// Name returns the source name.
+func (s Source) Name() string {
+	return sourceName
+}
+
+// PromptVersion returns the version of the explanation format.
+func (s Source) PromptVersion() string {
+	return promptVersion
+}
+
+func (s Source) explain(ctx context.Context, cmd string, segments []shell.Segment) (string, error) {
+	var (
+		doc   strings.Builder
+		found bool
+	)
+
+	fmt.Fprintf(&doc, "# Breakdown\n\n```fish\n%s\n```\n", cmd)
+
+	seen := make(map[string]struct{}, len(segments))
+	for _, seg := range segments {
+		if _, ok := seen[seg.Program]; ok {
+			continue
+		}
+		seen[seg.Program] = struct{}{}
+
+		fmt.Fprintf(&doc, "\n## %s\n\n", seg.Program)
+
+		raw, err := s.lookup(ctx, seg.Program)
+		if err != nil {
+			s.logger.Debug("no docs found for program", slog.String("program", seg.Program), slog.Any("error", err))
+			doc.WriteString("_No documentation found._\n")
+			continue
+		}
+		found = true
+
+		page := Parse(raw)
+		if page.Summary != "" {
+			fmt.Fprintf(&doc, "%s\n", page.Summary)
+		}
+
+		flags := usedFlags(segments, seg.Program)
+		if len(flags) == 0 {
+			continue
+		}
+
+		doc.WriteString("\n### Flags\n\n")
+		for _, flag := range flags {
+			writeFlag(&doc, page, flag)
+		}
+	}
+
+	if !found {
+		return "", ErrNoDocs
+	}
+
+	doc.WriteString("\n# Cautions\n\nThis explanation was generated from the local documentation, double check the flags before running the command.\n")
+	return doc.String(), nil
+}
+
+func (s Source) answer(ctx context.Context, segments []shell.Segment, question string) (string, error) {
+	flags := make([]string, 0)
+	for _, word := range strings.Fields(question) {
+		word = strings.Trim(word, "`'\"?,.!:;()")
+		if shell.IsFlag(word) {
+			flags = append(flags, word)
+		}
+	}
+
+	if len(flags) == 0 {
+		return "Without a language model I can only answer questions about flags, e.g. _what does `-z` do?_", nil
+	}
+
+	var doc strings.Builder
+	for _, seg := range segments {
+		raw, err := s.lookup(ctx, seg.Program)
+		if err != nil {
+			continue
+		}
+
+		page := Parse(raw)
+		for _, flag := range flags {
+			if entry, ok := page.Lookup(flag); ok {
+				fmt.Fprintf(&doc, "- `%s` (%s): %s\n", flag, seg.Program, entry.Description)
+			}
+		}
+	}
+
+	if doc.Len() == 0 {
+		return fmt.Sprintf("No documentation found for %s.", strings.Join(flags, ", ")), nil
+	}
+
+	return doc.String(), nil
+}
+
+func (s Source) readDocs(ctx context.Context, program string) (string, error) {
+	man := exec.CommandContext(ctx, "man", program)
+	man.Env = append(os.Environ(),
+		"MANPAGER=cat",
+		"PAGER=cat",
+		"MANWIDTH="+manWidth,
+		"GROFF_NO_SGR=1",
+	)
+
+	out, err := man.Output()
+	if err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return string(out), nil
+	}
+
+	if !s.helpFallback {
+		return "", fmt.Errorf("error reading man page: %w", err)
+	}
+
+	path, lerr := helpPath(program)
+	if lerr != nil {
+		return "", fmt.Errorf("error reading man page: %w", errors.Join(err, lerr))
+	}
+
+	help := exec.CommandContext(ctx, path, "--help")
+	out, herr := help.CombinedOutput()
+	if len(bytes.TrimSpace(out)) == 0 {
+		return "", fmt.Errorf("error reading help: %w", errors.Join(err, herr))
+	}
+
+	return string(out), nil
+}
+
+// helpPath returns the path of the program whose --help can be run: a bare name found in the PATH,
+// outside the current directory. Explaining a command must never run the scripts of the user, e.g. ./deploy.sh.
+func helpPath(program string) (string, error) {
+	if program == "" || strings.ContainsRune(program, '/') {
+		return "", fmt.Errorf("%q isn't a program of the PATH", program)
+	}
+
+	path, err := exec.LookPath(program)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// the PATH can have the current directory, e.g. "." or its absolute path.
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(cwd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%q is in the current directory", program)
+	}
+
+	return path, nil
+}
+
+// usedFlags returns the flags used with the program, splitting grouped short flags.
+func usedFlags(segments []shell.Segment, program string) []string {
+	flags := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, seg := range segments {
+		if seg.Program != program {
+			continue
+		}
+		for _, flag := range seg.Flags() {
+			if _, ok := seen[flag]; ok {
+				continue
+			}
+			seen[flag] = struct{}{}
+			flags = append(flags, flag)
+		}
+	}
+
+	return flags
+}
+
+func writeFlag(doc *strings.Builder, page Page, flag string) {
+	if entry, ok := page.Lookup(flag); ok {
+		fmt.Fprintf(doc, "- `%s`: %s\n", flag, entry.Description)
+		return
+	}
+
+	// grouped short flags, e.g. -la
+	if !strings.HasPrefix(flag, "--") && len(flag) > 2 {
+		parts := make([]string, 0, len(flag)-1)
+		for _, r := range flag[1:] {
+			short := "-" + string(r)
+			if entry, ok := page.Lookup(short); ok {
+				parts = append(parts, fmt.Sprintf("`%s` %s", short, entry.Description))
+			}
+		}
+		if len(parts) > 0 {
+			fmt.Fprintf(doc, "- `%s`:\n  - %s\n", flag, strings.Join(parts, "\n  - "))
+			return
+		}
+	}
+
+	fmt.Fprintf(doc, "- `%s`: _not documented_\n", flag)
+}
+
+// WithLookup sets the func used for reading the programs documentation.
+func WithLookup(lookup LookupFunc) OptFunc {
+	return func(source *Source) {
+		source.lookup = lookup
+	}
+}
+
+// WithHelpFallback enables running `program --help` when there is no man page.
+func WithHelpFallback() OptFunc {
+	return func(source *Source) {
+		source.helpFallback = true
+	}
+}
+
+// clean removes the formatting from the man output.
+func clean(raw string) string {
+	raw = ansiRegex.ReplaceAllString(raw, "")
+	raw = overstrikeRegex.ReplaceAllString(raw, "")
+	return strings.ReplaceAll(raw, "\r\n", "\n")
+}
@@ -0,0 +1,184 @@
+package manpage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name            string
+		file            string
+		expectedSummary string
+		expected        map[string]string
+		missing         []string
+	}{
+		{
+			name:            "man page",
+			file:            "testdata/ls.1.txt",
+			expectedSummary: "ls - list directory contents",
+			expected: map[string]string{
+				"-a":           "do not ignore entries starting with .",
+				"--all":        "do not ignore entries starting with .",
+				"--block-size": "with -l, scale sizes by SIZE when printing them; e.g., '--block-size=M'; see SIZE format below",
+				"--ignore=*.o": "do not list implied entries matching shell PATTERN",
+				"-l":           "use a long listing format",
+				"-z":           "end each output line with NUL, not newline",
+			},
+			missing: []string{"-x"},
+		},
+		{
+			name: "help output",
+			file: "testdata/grep.help.txt",
+			expected: map[string]string{
+				"-E":       "PATTERNS are extended regular expressions",
+				"--regexp": "use PATTERNS for matching",
+				"-i":       "ignore case distinctions in patterns and data",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := os.ReadFile(tt.file)
+			require.NoError(t, err)
+
+			page := Parse(string(raw))
+			assert.Equal(t, tt.expectedSummary, page.Summary, "summary not the expected")
+
+			for flag, desc := range tt.expected {
+				entry, ok := page.Lookup(flag)
+				if assert.True(t, ok, "flag %q not found", flag) {
+					assert.Equal(t, desc, entry.Description, "description of %q not the expected", flag)
+				}
+			}
+
+			for _, flag := range tt.missing {
+				_, ok := page.Lookup(flag)
+				assert.False(t, ok, "flag %q shouldn't be found", flag)
+			}
+		})
+	}
+}
+
+func TestSource_Prompt(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	lookup := func(_ context.Context, program string) (string, error) {
+		if program != "ls" {
+			return "", errors.New("no man page")
+		}
+		raw, err := os.ReadFile("testdata/ls.1.txt")
+		return string(raw), err
+	}
+
+	tests := []struct {
+		name           string
+		conversation   []command.Message
+		expectedErr    error
+		expectContains []string
+	}{
+		{
+			name:        "missing command",
+			expectedErr: ErrNoCommand,
+		},
+		{
+			name: "no docs",
+			conversation: []command.Message{
+				{Role: command.UserRole, Content: "unknown -x"},
+			},
+			expectedErr: ErrNoDocs,
+		},
+		{
+			name: "explanation",
+			conversation: []command.Message{
+				{Role: command.UserRole, Content: "ls -la --block-size=M {{.dir}} | wc -l"},
+			},
+			expectContains: []string{
+				"## ls",
+				"ls - list directory contents",
+				"`-l` use a long listing format",
+				"`-a` do not ignore entries starting with .",
+				"`--block-size=M`: with -l, scale sizes",
+				"## wc",
+				"_No documentation found._",
+			},
+		},
+		{
+			name: "follow-up",
+			conversation: []command.Message{
+				{Role: command.UserRole, Content: "ls -la"},
+				{Role: command.AssistantRole, Content: "explanation"},
+				{Role: command.UserRole, Content: "what if I add -z?"},
+			},
+			expectContains: []string{
+				"`-z` (ls): end each output line with NUL, not newline",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := New(logger, WithLookup(lookup))
+
+			got, err := source.Prompt(context.Background(), tt.conversation)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr, "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			for _, expected := range tt.expectContains {
+				assert.Contains(t, got, expected, "explanation missing content")
+			}
+		})
+	}
+}
+
+func TestHelpPath(t *testing.T) {
+	bin := t.TempDir()
+	cwd := t.TempDir()
+	for _, path := range []string{filepath.Join(bin, "tool"), filepath.Join(cwd, "deploy.sh")} {
+		require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755))
+	}
+
+	prev, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(cwd))
+	t.Cleanup(func() { _ = os.Chdir(prev) })
+	// the current directory in the PATH, by its absolute path and as ".".
+	t.Setenv("PATH", strings.Join([]string{bin, cwd, "."}, string(os.PathListSeparator)))
+
+	tests := []struct {
+		program string
+		err     bool
+	}{
+		{program: "tool"},
+		{program: "deploy.sh", err: true},
+		{program: "./deploy.sh", err: true},
+		{program: filepath.Join(bin, "tool"), err: true},
+		{program: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.program, func(t *testing.T) {
+			path, err := helpPath(tt.program)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(bin, tt.program), path)
+		})
+	}
+}
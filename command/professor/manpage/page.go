@@ -0,0 +1,143 @@
+package manpage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Page is the parsed documentation of a program.
+type Page struct {
+	Summary string
+	Entries []Entry
+}
+
+// Entry is a documented flag.
+type Entry struct {
+	Flags       []string
+	Description string
+}
+
+// Parse parses the man page or --help output.
+func Parse(raw string) Page {
+	lines := strings.Split(clean(raw), "\n")
+
+	var page Page
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "NAME" && page.Summary == "" {
+			for j := i + 1; j < len(lines); j++ {
+				if s := strings.TrimSpace(lines[j]); s != "" {
+					page.Summary = s
+					i = j
+					break
+				}
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+
+		flags, rest := parseFlags(trimmed)
+		if len(flags) == 0 {
+			continue
+		}
+
+		indent := indentation(line)
+		desc := make([]string, 0)
+		if rest != "" {
+			desc = append(desc, rest)
+		}
+
+		// the description is the following lines with more indentation.
+		j := i + 1
+		for ; j < len(lines); j++ {
+			next := lines[j]
+			if strings.TrimSpace(next) == "" {
+				// only the first paragraph
+				if len(desc) > 0 {
+					break
+				}
+				continue
+			}
+			if indentation(next) <= indent {
+				break
+			}
+			desc = append(desc, strings.TrimSpace(next))
+		}
+		i = j - 1
+
+		page.Entries = append(page.Entries, Entry{
+			Flags:       flags,
+			Description: strings.Join(desc, " "),
+		})
+	}
+
+	return page
+}
+
+// Lookup returns the entry documenting the flag.
+func (p Page) Lookup(flag string) (Entry, bool) {
+	name, _, _ := strings.Cut(flag, "=")
+	for _, entry := range p.Entries {
+		for _, f := range entry.Flags {
+			if f == name {
+				return entry, true
+			}
+		}
+	}
+
+	return Entry{}, false
+}
+
+// parseFlags parses the header of a flag entry, e.g. "-a, --all  do not ignore entries".
+// Returns the flags and the text after them.
+func parseFlags(header string) ([]string, string) {
+	flags := make([]string, 0)
+	rest := header
+	for strings.HasPrefix(rest, "-") {
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return unicode.IsSpace(r) || r == ','
+		})
+		if end < 0 {
+			end = len(rest)
+		}
+
+		flag := rest[:end]
+		// strip the value, e.g. --block-size=SIZE or --color[=WHEN]
+		if idx := strings.IndexAny(flag, "=["); idx > 0 {
+			flag = flag[:idx]
+		}
+		if len(flag) > 1 {
+			flags = append(flags, flag)
+		}
+
+		rest = rest[end:]
+		// skip the value placeholder, e.g. -I PATTERN
+		if next, ok := strings.CutPrefix(rest, " "); ok && !strings.HasPrefix(next, "-") && !strings.HasPrefix(next, " ") {
+			if word, after, _ := strings.Cut(next, " "); isPlaceholder(word) {
+				rest = " " + after
+			}
+		}
+		rest = strings.TrimLeft(rest, ", ")
+	}
+
+	return flags, strings.TrimSpace(rest)
+}
+
+// isPlaceholder returns true for flag values like FILE or <num>.
+func isPlaceholder(word string) bool {
+	if strings.HasPrefix(word, "<") {
+		return true
+	}
+
+	word = strings.Trim(word, "[]=,")
+	return word != "" && strings.ToUpper(word) == word
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
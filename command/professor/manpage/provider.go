@@ -0,0 +1,27 @@
+package manpage
+
+import (
+	"log/slog"
+
+	"github.com/lian-rr/clio/command/professor"
+)
+
+func init() {
+	professor.Register(sourceName, professor.Provider{
+		Decode: professor.DecodeAs[Config](nil),
+		New: func(logger *slog.Logger, cfg any) (professor.Source, error) {
+			opts := make([]OptFunc, 0)
+			if cfg.(Config).HelpFallback {
+				opts = append(opts, WithHelpFallback())
+			}
+
+			return New(logger, opts...), nil
+		},
+	})
+}
+
+// Config holds the configuration of the manpage provider.
+type Config struct {
+	// HelpFallback runs `program --help` for programs without man page.
+	HelpFallback bool `toml:"helpFallback"`
+}
@@ -2,7 +2,13 @@ package openai
 
 import "github.com/openai/openai-go"
 
+const (
+	providerName           = "openai"
+	compatibleProviderName = "openai-compatible"
+)
+
 var (
-	defaultModel   = openai.ChatModelGPT4o
-	defaultContext = "Explain the given command and give me your answer using markdown; this explanation should contain the following sections, summary, breakdown, example of use and cautions; these sections encode them as markdown headings. The command can contain parameters of the form {{.name}} where name is the name of the parameter, which are meant to be replaced. When formatting the code in the explanation, use fish as the format. Don't mention how to replace the parameters. Here is the command:%s"
+	// promptVersion must be bumped every time the conversation sent to the API changes.
+	promptVersion = "2"
+	defaultModel  = openai.ChatModelGPT4o
 )
@@ -5,9 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/professor"
 )
 
 // ErrNoResponse thrown when there is no response from the open ai API.
@@ -15,10 +21,13 @@ var ErrNoResponse = errors.New("no reponse")
 
 // Client holds the OpenAI client and some configuration.
 type Client struct {
-	baseUrl       string
-	model         string
-	promptContext string
-	client        *openai.Client
+	name    string
+	baseUrl string
+	model   string
+	// embeddingModel is the model used for the embeddings, they aren't supported without it.
+	embeddingModel string
+	headers        map[string]string
+	client         *openai.Client
 }
 
 // OptFunc used for setting optional configs.
@@ -27,35 +36,53 @@ type OptFunc func(store *Client)
 // New  returns a new OpenAI client.
 func New(logger *slog.Logger, apiKey string, opts ...OptFunc) Client {
 	client := Client{
-		model:         defaultModel,
-		promptContext: defaultContext,
+		name:  providerName,
+		model: defaultModel,
 	}
 
 	for _, opt := range opts {
 		opt(&client)
 	}
 
-	opiOpts := []option.RequestOption{option.WithAPIKey(apiKey)}
+	// retries are handled by the professor pipeline.
+	opiOpts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
 	if client.baseUrl != "" {
 		opiOpts = append(opiOpts, option.WithBaseURL(client.baseUrl))
 	}
+	for key, value := range client.headers {
+		opiOpts = append(opiOpts, option.WithHeader(key, value))
+	}
 
 	c := openai.NewClient(opiOpts...)
 	client.client = c
 	return client
 }
 
-// Prompt executes a prompt to the OpenAI endpoints
-func (c Client) Prompt(ctx context.Context, prompt string) (string, error) {
+// Prompt executes a prompt to the OpenAI endpoints.
+// The full conversation is sent.
+func (c Client) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(conversation))
+	for _, msg := range conversation {
+		switch msg.Role {
+		case command.SystemRole:
+			messages = append(messages, openai.SystemMessage(msg.Content))
+		case command.AssistantRole:
+			messages = append(messages, openai.AssistantMessage(msg.Content))
+		default:
+			messages = append(messages, openai.UserMessage(msg.Content))
+		}
+	}
+
 	completion, err := c.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(c.promptContext),
-			openai.UserMessage(prompt),
-		}),
-		Model: openai.F(c.model),
+		Messages: openai.F(messages),
+		Model:    openai.F(c.model),
 	})
 	if err != nil {
-		return "", fmt.Errorf("error prompting: %v", err)
+		err = fmt.Errorf("error prompting: %w", err)
+		if isTransient(err) {
+			return "", professor.Transient(err)
+		}
+		return "", err
 	}
 
 	if len(completion.Choices) == 0 {
@@ -65,6 +92,70 @@ func (c Client) Prompt(ctx context.Context, prompt string) (string, error) {
 	return completion.Choices[0].Message.Content, nil
 }
 
+// Embed returns the embedding vector of the text, computed by the embedding model.
+func (c Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	if c.embeddingModel == "" {
+		return nil, professor.ErrEmbeddingNotSupported
+	}
+
+	resp, err := c.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input:          openai.F[openai.EmbeddingNewParamsInputUnion](shared.UnionString(text)),
+		Model:          openai.F(openai.EmbeddingModel(c.embeddingModel)),
+		EncodingFormat: openai.F(openai.EmbeddingNewParamsEncodingFormatFloat),
+	})
+	if err != nil {
+		err = fmt.Errorf("error embedding: %w", err)
+		if isTransient(err) {
+			return nil, professor.Transient(err)
+		}
+		return nil, err
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, ErrNoResponse
+	}
+
+	vector := make([]float32, 0, len(resp.Data[0].Embedding))
+	for _, v := range resp.Data[0].Embedding {
+		vector = append(vector, float32(v))
+	}
+	return vector, nil
+}
+
+// EmbeddingModel returns the provider and embedding model used, empty if there's none.
+func (c Client) EmbeddingModel() string {
+	if c.embeddingModel == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", c.name, c.embeddingModel)
+}
+
+// Name returns the provider and model used.
+func (c Client) Name() string {
+	return fmt.Sprintf("%s/%s", c.name, c.model)
+}
+
+// PromptVersion returns the version of the conversation format sent to the API.
+func (c Client) PromptVersion() string {
+	return promptVersion
+}
+
+// isTransient returns true for the errors worth retrying: rate limits, server errors and network timeouts.
+func isTransient(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // WithBaseUrl sets the Client baseUrl optional param.
 func WithBaseUrl(url string) OptFunc {
 	return func(client *Client) {
@@ -72,16 +163,23 @@ func WithBaseUrl(url string) OptFunc {
 	}
 }
 
-// WithBaseUrl sets the Client model optional param.
+// WithModel sets the Client model optional param.
 func WithModel(model string) OptFunc {
 	return func(client *Client) {
 		client.model = model
 	}
 }
 
-// WithBaseUrl sets the Client prompt context optional param.
-func WithCustomContext(ctx string) OptFunc {
+// WithEmbeddingModel sets the model used for the embeddings.
+func WithEmbeddingModel(model string) OptFunc {
+	return func(client *Client) {
+		client.embeddingModel = model
+	}
+}
+
+// WithHeaders sets headers sent on every request, e.g. for a gateway.
+func WithHeaders(headers map[string]string) OptFunc {
 	return func(client *Client) {
-		client.promptContext = ctx
+		client.headers = headers
 	}
 }
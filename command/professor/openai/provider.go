@@ -0,0 +1,97 @@
+package openai
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/lian-rr/clio/command/professor"
+)
+
+func init() {
+	professor.Register(providerName, professor.Provider{
+		Decode: professor.DecodeAs(Config.validate),
+		New: func(logger *slog.Logger, cfg any) (professor.Source, error) {
+			return cfg.(Config).source(logger), nil
+		},
+	})
+
+	professor.Register(compatibleProviderName, professor.Provider{
+		Decode: professor.DecodeAs(CompatibleConfig.validate),
+		New: func(logger *slog.Logger, cfg any) (professor.Source, error) {
+			return cfg.(CompatibleConfig).source(logger), nil
+		},
+	})
+}
+
+// Config holds the configuration of the openai provider.
+type Config struct {
+	ApiKey string `toml:"key"`
+	Url    string `toml:"url"`
+	Model  string `toml:"model"`
+	// EmbeddingModel enables the semantic search, e.g. text-embedding-3-small.
+	EmbeddingModel string `toml:"embeddingModel"`
+}
+
+// CompatibleConfig holds the configuration of the openai-compatible provider,
+// used for any server implementing the OpenAI chat completions API, e.g. LM Studio, vLLM or a gateway.
+type CompatibleConfig struct {
+	// ApiKey is optional, local servers usually don't need one.
+	ApiKey string `toml:"key"`
+	Url    string `toml:"url"`
+	Model  string `toml:"model"`
+	// EmbeddingModel enables the semantic search, e.g. nomic-embed-text.
+	EmbeddingModel string `toml:"embeddingModel"`
+	// Headers are sent on every request.
+	Headers map[string]string `toml:"headers"`
+}
+
+func (c Config) validate() error {
+	if c.ApiKey == "" {
+		return errors.New("missing openai api key")
+	}
+
+	return nil
+}
+
+func (c Config) source(logger *slog.Logger) Client {
+	opts := make([]OptFunc, 0)
+	if c.Url != "" {
+		opts = append(opts, WithBaseUrl(c.Url))
+	}
+	if c.Model != "" {
+		opts = append(opts, WithModel(c.Model))
+	}
+	if c.EmbeddingModel != "" {
+		opts = append(opts, WithEmbeddingModel(c.EmbeddingModel))
+	}
+
+	return New(logger, c.ApiKey, opts...)
+}
+
+func (c CompatibleConfig) validate() error {
+	var errs error
+	if c.Url == "" {
+		errs = errors.Join(errs, errors.New("missing url"))
+	}
+	if c.Model == "" {
+		errs = errors.Join(errs, errors.New("missing model"))
+	}
+
+	return errs
+}
+
+func (c CompatibleConfig) source(logger *slog.Logger) Client {
+	return New(logger, c.ApiKey,
+		withName(compatibleProviderName),
+		WithBaseUrl(c.Url),
+		WithModel(c.Model),
+		WithEmbeddingModel(c.EmbeddingModel),
+		WithHeaders(c.Headers),
+	)
+}
+
+func withName(name string) OptFunc {
+	return func(client *Client) {
+		client.name = name
+	}
+}
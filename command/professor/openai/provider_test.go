@@ -0,0 +1,157 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/professor"
+)
+
+func TestCompatibleProvider(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	type chatRequest struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role string `json:"role"`
+			// plain text or text parts.
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+
+	tests := []struct {
+		name             string
+		status           int
+		body             string
+		expectedResponse string
+		transient        bool
+	}{
+		{
+			name:             "success",
+			status:           http.StatusOK,
+			body:             `{"id":"1","object":"chat.completion","model":"local","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"lists the files"}}]}`,
+			expectedResponse: "lists the files",
+		},
+		{
+			name:      "server error is transient",
+			status:    http.StatusServiceUnavailable,
+			body:      `{"error":{"message":"loading model"}}`,
+			transient: true,
+		},
+		{
+			name:   "bad request is not transient",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"message":"bad request"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got chatRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/chat/completions", r.URL.Path, "path not the expected")
+				assert.Equal(t, "team", r.Header.Get("X-Gateway-Team"), "custom header not the expected")
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+				w.Header().Set("content-type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			source, err := professor.NewSource(logger, compatibleProviderName, func(v any) error {
+				cfg := v.(*CompatibleConfig)
+				cfg.Url = server.URL + "/v1/"
+				cfg.Model = "local"
+				cfg.Headers = map[string]string{"X-Gateway-Team": "team"}
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "openai-compatible/local", source.Name(), "name not the expected")
+
+			resp, err := source.Prompt(context.Background(), []command.Message{
+				{Role: command.SystemRole, Content: "instructions"},
+				{Role: command.UserRole, Content: "ls -la"},
+			})
+
+			assert.Equal(t, "local", got.Model, "model not the expected")
+			if assert.Len(t, got.Messages, 2) {
+				assert.Equal(t, "system", got.Messages[0].Role)
+				assert.Contains(t, string(got.Messages[0].Content), "instructions")
+				assert.Equal(t, "user", got.Messages[1].Role)
+				assert.Contains(t, string(got.Messages[1].Content), "ls -la")
+			}
+
+			if tt.expectedResponse == "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.transient, errors.Is(err, professor.ErrTransient), "transient not the expected")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedResponse, resp, "response not the expected")
+		})
+	}
+}
+
+func TestCompatibleProvider_Embed(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var got struct {
+		Model string `json:"model"`
+		Input string `json:"input"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path, "path not the expected")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","model":"embed","data":[{"object":"embedding","index":0,"embedding":[0.5,-0.25,1]}],"usage":{"prompt_tokens":1,"total_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	newSource := func(embeddingModel string) professor.Source {
+		source, err := professor.NewSource(logger, compatibleProviderName, func(v any) error {
+			cfg := v.(*CompatibleConfig)
+			cfg.Url = server.URL + "/v1/"
+			cfg.Model = "local"
+			cfg.EmbeddingModel = embeddingModel
+			return nil
+		})
+		require.NoError(t, err)
+		return source
+	}
+
+	profe := professor.New(newSource("embed"), logger)
+	assert.Equal(t, "openai-compatible/embed", profe.EmbeddingModel())
+
+	vector, err := profe.Embed(context.Background(), "free up disk space")
+	require.NoError(t, err)
+	assert.Equal(t, []float32{0.5, -0.25, 1}, vector)
+	assert.Equal(t, "embed", got.Model, "model not the expected")
+	assert.Equal(t, "free up disk space", got.Input, "input not the expected")
+
+	_, err = professor.New(newSource(""), logger).Embed(context.Background(), "free up disk space")
+	assert.ErrorIs(t, err, professor.ErrEmbeddingNotSupported, "the embeddings need a model")
+}
+
+func TestCompatibleConfig_validate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := professor.NewSource(logger, compatibleProviderName, func(v any) error { return nil })
+	assert.ErrorContains(t, err, "missing url")
+	assert.ErrorContains(t, err, "missing model")
+
+	_, err = professor.NewSource(logger, providerName, func(v any) error { return nil })
+	assert.ErrorContains(t, err, "missing openai api key")
+}
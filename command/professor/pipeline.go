@@ -0,0 +1,262 @@
+package professor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// ErrTransient wraps the errors that could succeed if retried, e.g. rate limits or timeouts.
+var ErrTransient = errors.New("transient error")
+
+// Transient marks the error as transient.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrTransient, err)
+}
+
+// RetryPolicy configures the retries of a Source.
+type RetryPolicy struct {
+	// Attempts is the max number of times the prompt is sent, including the first one.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled on each retry.
+	Backoff time.Duration
+	// MaxBackoff is the max wait between retries.
+	MaxBackoff time.Duration
+}
+
+// WithRetry returns the source retrying the prompts that failed with transient errors.
+func WithRetry(source Source, policy RetryPolicy, logger *slog.Logger) Source {
+	if policy.Attempts <= 1 {
+		return source
+	}
+
+	return retrySource{
+		Source: source,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// WithTimeout returns the source with a timeout for each prompt.
+func WithTimeout(source Source, timeout time.Duration) Source {
+	if timeout <= 0 {
+		return source
+	}
+
+	return timeoutSource{
+		Source:  source,
+		timeout: timeout,
+	}
+}
+
+// Chain returns a source that prompts the sources in order until one of them answers.
+func Chain(logger *slog.Logger, sources ...Source) Source {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+
+	return chainSource{
+		sources: sources,
+		logger:  logger,
+	}
+}
+
+type retrySource struct {
+	Source
+	policy RetryPolicy
+	logger *slog.Logger
+}
+
+// Prompt sends the prompt, retrying with exponential backoff on transient errors.
+func (s retrySource) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	return retry(ctx, s, "prompt", func() (string, error) {
+		return s.Source.Prompt(ctx, conversation)
+	})
+}
+
+// Embed embeds the text, retrying with exponential backoff on transient errors.
+func (s retrySource) Embed(ctx context.Context, text string) ([]float32, error) {
+	return retry(ctx, s, "embedding", func() ([]float32, error) {
+		return embed(ctx, s.Source, text)
+	})
+}
+
+// EmbeddingModel returns the embedding model of the source.
+func (s retrySource) EmbeddingModel() string {
+	return embeddingModel(s.Source)
+}
+
+// retry calls fn until it succeeds, fails with a non transient error or the attempts run out.
+func retry[T any](ctx context.Context, s retrySource, call string, fn func() (T, error)) (T, error) {
+	var zero T
+	backoff := s.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := fn()
+		if err == nil {
+			return resp, nil
+		}
+
+		if !errors.Is(err, ErrTransient) || attempt >= s.policy.Attempts {
+			return zero, err
+		}
+
+		s.logger.Debug("retrying "+call,
+			slog.String("source", s.Name()),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return zero, errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if s.policy.MaxBackoff > 0 && backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+}
+
+type timeoutSource struct {
+	Source
+	timeout time.Duration
+}
+
+// Prompt sends the prompt with a timeout.
+// A timeout is considered transient, so it can be retried.
+func (s timeoutSource) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	tctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	resp, err := s.Source.Prompt(tctx, conversation)
+	if err != nil && ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+		return "", Transient(fmt.Errorf("%s timed out after %s: %w", s.Name(), s.timeout, err))
+	}
+
+	return resp, err
+}
+
+// Embed embeds the text with a timeout, considered transient like the one of the prompts.
+func (s timeoutSource) Embed(ctx context.Context, text string) ([]float32, error) {
+	tctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	vector, err := embed(tctx, s.Source, text)
+	if err != nil && ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+		return nil, Transient(fmt.Errorf("%s timed out after %s: %w", s.Name(), s.timeout, err))
+	}
+
+	return vector, err
+}
+
+// EmbeddingModel returns the embedding model of the source.
+func (s timeoutSource) EmbeddingModel() string {
+	return embeddingModel(s.Source)
+}
+
+type chainSource struct {
+	sources []Source
+	logger  *slog.Logger
+}
+
+// Prompt prompts the sources in order, returning the first answer.
+func (s chainSource) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	resp, _, err := s.PromptBy(ctx, conversation)
+	return resp, err
+}
+
+// PromptBy prompts the sources in order, returning the first answer and the source that gave it.
+func (s chainSource) PromptBy(ctx context.Context, conversation []command.Message) (string, Source, error) {
+	var errs error
+	for _, source := range s.sources {
+		resp, err := source.Prompt(ctx, conversation)
+		if err == nil {
+			return resp, source, nil
+		}
+
+		if ctx.Err() != nil {
+			return "", nil, errors.Join(errs, err)
+		}
+
+		s.logger.Warn("source failed, falling back to the next one",
+			slog.String("source", source.Name()),
+			slog.Any("error", err),
+		)
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", source.Name(), err))
+	}
+
+	return "", nil, errs
+}
+
+// Preferred returns the first source, the one answering when all of them are up.
+func (s chainSource) Preferred() Source {
+	return s.sources[0]
+}
+
+// Name returns the names of the chained sources.
+func (s chainSource) Name() string {
+	names := make([]string, 0, len(s.sources))
+	for _, source := range s.sources {
+		names = append(names, source.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// PromptVersion returns the prompt versions of the chained sources.
+func (s chainSource) PromptVersion() string {
+	versions := make([]string, 0, len(s.sources))
+	for _, source := range s.sources {
+		versions = append(versions, source.PromptVersion())
+	}
+	return strings.Join(versions, ",")
+}
+
+// Embed embeds the text with the first source able to. There's no fallback to the next ones,
+// the vectors of different models can't be compared.
+func (s chainSource) Embed(ctx context.Context, text string) ([]float32, error) {
+	for _, source := range s.sources {
+		if embeddingModel(source) != "" {
+			return embed(ctx, source, text)
+		}
+	}
+	return nil, ErrEmbeddingNotSupported
+}
+
+// EmbeddingModel returns the embedding model of the first source able to embed texts.
+func (s chainSource) EmbeddingModel() string {
+	for _, source := range s.sources {
+		if model := embeddingModel(source); model != "" {
+			return model
+		}
+	}
+	return ""
+}
+
+// embed embeds the text with the source, if it's able to.
+func embed(ctx context.Context, source Source, text string) ([]float32, error) {
+	embedder, ok := source.(Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return nil, ErrEmbeddingNotSupported
+	}
+	return embedder.Embed(ctx, text)
+}
+
+// embeddingModel returns the embedding model of the source, empty if it isn't able to embed texts.
+func embeddingModel(source Source) string {
+	embedder, ok := source.(Embedder)
+	if !ok {
+		return ""
+	}
+	return embedder.EmbeddingModel()
+}
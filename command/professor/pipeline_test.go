@@ -0,0 +1,213 @@
+package professor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestWithRetry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockErr := errors.New("mock error")
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectedErr   error
+	}{
+		{
+			name:          "no error",
+			errs:          []error{nil},
+			expectedCalls: 1,
+		},
+		{
+			name:          "non transient error is not retried",
+			errs:          []error{mockErr},
+			expectedCalls: 1,
+			expectedErr:   mockErr,
+		},
+		{
+			name:          "transient error recovered",
+			errs:          []error{Transient(mockErr), Transient(mockErr), nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "attempts exhausted",
+			errs:          []error{Transient(mockErr), Transient(mockErr), Transient(mockErr), nil},
+			expectedCalls: 3,
+			expectedErr:   ErrTransient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{name: "fake", errs: tt.errs}
+
+			resp, err := WithRetry(source, policy, logger).Prompt(context.Background(), nil)
+			assert.Equal(t, tt.expectedCalls, source.calls, "calls not the expected")
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr, "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, "fake answer", resp, "response not the expected")
+		})
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	source := &fakeSource{name: "slow", delay: 50 * time.Millisecond}
+
+	_, err := WithTimeout(source, time.Millisecond).Prompt(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrTransient, "timeout should be transient")
+	assert.ErrorContains(t, err, "slow timed out", "error message not the expected")
+}
+
+func TestChain(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockErr := errors.New("mock error")
+
+	t.Run("falls back to the next source", func(t *testing.T) {
+		first := &fakeSource{name: "first", errs: []error{mockErr}}
+		second := &fakeSource{name: "second"}
+
+		chain := Chain(logger, first, second)
+		resp, err := chain.Prompt(context.Background(), nil)
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, "second answer", resp, "response not the expected")
+		assert.Equal(t, "first,second", chain.Name(), "name not the expected")
+
+		fallback := Chain(logger, &fakeSource{name: "first", errs: []error{mockErr}}, second).(fallbackSource)
+		_, by, err := fallback.PromptBy(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "second", by.Name(), "the answering source not the expected")
+		assert.Equal(t, "first", fallback.Preferred().Name())
+	})
+
+	t.Run("all sources failed", func(t *testing.T) {
+		first := &fakeSource{name: "first", errs: []error{mockErr}}
+		second := &fakeSource{name: "second", errs: []error{mockErr}}
+
+		_, err := Chain(logger, first, second).Prompt(context.Background(), nil)
+		assert.ErrorContains(t, err, "first: mock error", "error not the expected")
+		assert.ErrorContains(t, err, "second: mock error", "error not the expected")
+	})
+}
+
+func TestEmbed(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockErr := errors.New("mock error")
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	t.Run("source without embeddings", func(t *testing.T) {
+		profe := New(&fakeSource{name: "fake"}, logger)
+		assert.Empty(t, profe.EmbeddingModel())
+
+		_, err := profe.Embed(context.Background(), "free up disk space")
+		assert.ErrorIs(t, err, ErrEmbeddingNotSupported)
+
+		_, err = New(nil, logger).Embed(context.Background(), "free up disk space")
+		assert.ErrorIs(t, err, ErrSourceNotSet)
+	})
+
+	t.Run("through the pipeline", func(t *testing.T) {
+		embedder := &fakeEmbedder{fakeSource: fakeSource{name: "embedder"}, errs: []error{Transient(mockErr)}}
+		source := Chain(logger,
+			&fakeSource{name: "chat"},
+			WithTimeout(WithRetry(embedder, policy, logger), time.Second),
+		)
+
+		profe := New(source, logger)
+		assert.Equal(t, "embedder/embed", profe.EmbeddingModel())
+
+		vector, err := profe.Embed(context.Background(), "abc")
+		require.NoError(t, err)
+		assert.Equal(t, []float32{1, 1, 1}, vector[:3])
+		assert.Equal(t, 2, embedder.embeds, "the transient errors are retried")
+	})
+
+	t.Run("no fallback", func(t *testing.T) {
+		first := &fakeEmbedder{fakeSource: fakeSource{name: "first"}, errs: []error{mockErr}}
+		second := &fakeEmbedder{fakeSource: fakeSource{name: "second"}}
+
+		_, err := New(Chain(logger, first, second), logger).Embed(context.Background(), "abc")
+		assert.ErrorIs(t, err, mockErr, "the vectors of other models can't be compared")
+		assert.Zero(t, second.embeds)
+	})
+}
+
+type fakeSource struct {
+	name         string
+	errs         []error
+	delay        time.Duration
+	calls        int
+	conversation []command.Message
+}
+
+func (s *fakeSource) Prompt(ctx context.Context, conversation []command.Message) (string, error) {
+	s.calls++
+	s.conversation = conversation
+	if s.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(s.delay):
+		}
+	}
+
+	if len(s.errs) >= s.calls {
+		if err := s.errs[s.calls-1]; err != nil {
+			return "", err
+		}
+	}
+	return s.name + " answer", nil
+}
+
+func (s *fakeSource) Name() string {
+	return s.name
+}
+
+func (s *fakeSource) PromptVersion() string {
+	return "1"
+}
+
+// fakeEmbedder embeds the texts deterministically, counting the letters.
+type fakeEmbedder struct {
+	fakeSource
+	errs   []error
+	embeds int
+}
+
+func (s *fakeEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	s.embeds++
+	if len(s.errs) >= s.embeds {
+		if err := s.errs[s.embeds-1]; err != nil {
+			return nil, err
+		}
+	}
+
+	vector := make([]float32, 26)
+	for _, r := range strings.ToLower(text) {
+		if 'a' <= r && r <= 'z' {
+			vector[r-'a']++
+		}
+	}
+	return vector, nil
+}
+
+func (s *fakeEmbedder) EmbeddingModel() string {
+	return s.name + "/embed"
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"slices"
 
 	"github.com/lian-rr/clio/command"
 )
@@ -13,7 +14,33 @@ var ErrSourceNotSet error = errors.New("source not set")
 
 // Source is the source of information for the professor.
 type Source interface {
-	Prompt(context.Context, string) (string, error)
+	// Prompt sends the conversation to the source and returns its answer.
+	Prompt(context.Context, []command.Message) (string, error)
+	// Name returns the name of the source, e.g. type/model.
+	Name() string
+	// PromptVersion returns the version of the prompt used by the source.
+	PromptVersion() string
+}
+
+// fallbackSource is implemented by the sources prompting other ones until one answers, e.g. the chain.
+// The explanations are signed by the source that answered, so the fallback answers are stale once the preferred one is back.
+type fallbackSource interface {
+	// PromptBy sends the conversation and returns the answer with the source that gave it.
+	PromptBy(context.Context, []command.Message) (string, Source, error)
+	// Preferred returns the source answering when all of them are up.
+	Preferred() Source
+}
+
+// ErrEmbeddingNotSupported thrown when the source can't embed texts.
+var ErrEmbeddingNotSupported error = errors.New("embeddings not supported by the source")
+
+// Embedder is implemented by the sources able to embed texts, for the semantic search.
+type Embedder interface {
+	// Embed returns the embedding vector of the text.
+	Embed(context.Context, string) ([]float32, error)
+	// EmbeddingModel returns the name of the model computing the vectors, the vectors of different
+	// models can't be compared. Empty when the source can't embed texts, e.g. it isn't configured.
+	EmbeddingModel() string
 }
 
 // OptFunc used for setting optional configs.
@@ -21,34 +48,218 @@ type OptFunc func(profe *Professor)
 
 // Professor handles the explanation of commands.
 type Professor struct {
-	source Source
-	logger *slog.Logger
+	source          Source
+	logger          *slog.Logger
+	templates       map[string]Template
+	templateNames   []string
+	defaultTemplate string
+	shell           string
+	locale          string
 }
 
 // New returns a new Professor.
 func New(source Source, logger *slog.Logger, opts ...OptFunc) Professor {
 	teach := Professor{
-		source: source,
-		logger: logger,
+		source:          source,
+		logger:          logger,
+		templates:       make(map[string]Template, len(builtinTemplates)),
+		defaultTemplate: DefaultTemplate,
+		shell:           userShell(),
+		locale:          userLocale(),
+	}
+
+	for _, t := range builtinTemplates {
+		tmpl, err := NewTemplate(t.name, t.description, t.text)
+		if err != nil {
+			panic(err)
+		}
+		teach.addTemplate(tmpl)
 	}
 
 	for _, opt := range opts {
 		opt(&teach)
 	}
 
+	if _, ok := teach.templates[teach.defaultTemplate]; !ok {
+		logger.Warn("default template not found, using the built-in one",
+			slog.String("template", teach.defaultTemplate),
+		)
+		teach.defaultTemplate = DefaultTemplate
+	}
+
 	return teach
 }
 
-// Explain the passed command. If the source is not set, then it will return ErrSourceNotSet.
-func (p Professor) Explain(ctx context.Context, cmd command.Command) (string, error) {
+// Templates returns the names of the available prompt templates.
+func (p Professor) Templates() []string {
+	return slices.Clone(p.templateNames)
+}
+
+// Explain the passed command using the prompt template with the given name.
+// If the name is empty or unknown, the default template is used.
+// If the source is not set, then it will return ErrSourceNotSet.
+func (p Professor) Explain(ctx context.Context, cmd command.Command, template string) (command.Explanation, error) {
+	if p.source == nil {
+		return command.Explanation{}, ErrSourceNotSet
+	}
+
+	instructions, err := p.instructions(cmd, template)
+	if err != nil {
+		return command.Explanation{}, err
+	}
+
+	conversation := []command.Message{
+		instructions,
+		{Role: command.UserRole, Content: cmd.Command},
+	}
+
+	var (
+		resp string
+		by   = p.source
+	)
+	if fallback, ok := p.source.(fallbackSource); ok {
+		resp, by, err = fallback.PromptBy(ctx, conversation)
+	} else {
+		resp, err = p.source.Prompt(ctx, conversation)
+	}
+	if err != nil {
+		return command.Explanation{}, err
+	}
+
+	return command.Explanation{
+		Content:   resp,
+		Signature: p.signature(cmd, template, by),
+	}, nil
+}
+
+// Signature returns the signature an explanation of the command would have if generated now
+// with the given template by the preferred source. Used for detecting stale explanations.
+func (p Professor) Signature(cmd command.Command, template string) command.ExplanationSignature {
+	source := p.source
+	if fallback, ok := source.(fallbackSource); ok {
+		source = fallback.Preferred()
+	}
+	return p.signature(cmd, template, source)
+}
+
+// signature returns the signature of an explanation of the command generated by the source.
+func (p Professor) signature(cmd command.Command, template string, source Source) command.ExplanationSignature {
+	tmpl := p.template(template)
+	sig := command.ExplanationSignature{
+		CommandHash:   cmd.Hash(),
+		PromptVersion: tmpl.Version(),
+		Template:      tmpl.Name,
+	}
+
+	if source != nil {
+		sig.Source = source.Name()
+		sig.PromptVersion = tmpl.Version() + "+" + source.PromptVersion()
+	}
+
+	return sig
+}
+
+// FollowUp asks a follow-up question about the command.
+// The whole conversation (explanation and previous follow-ups) is sent to the source,
+// using the same template as the explanation.
+// If the source is not set, then it will return ErrSourceNotSet.
+func (p Professor) FollowUp(ctx context.Context, cmd command.Command, explanation command.Explanation, transcript []command.Message, question string) (string, error) {
 	if p.source == nil {
 		return "", ErrSourceNotSet
 	}
 
-	resp, err := p.source.Prompt(ctx, cmd.Command)
+	instructions, err := p.instructions(cmd, explanation.Signature.Template)
+	if err != nil {
+		return "", err
+	}
+
+	conversation := make([]command.Message, 0, len(transcript)+4)
+	conversation = append(conversation,
+		instructions,
+		command.Message{Role: command.UserRole, Content: cmd.Command},
+		command.Message{Role: command.AssistantRole, Content: explanation.Content},
+	)
+	conversation = append(conversation, transcript...)
+	conversation = append(conversation, command.Message{Role: command.UserRole, Content: question})
+
+	resp, err := p.source.Prompt(ctx, conversation)
 	if err != nil {
 		return "", err
 	}
 
 	return resp, nil
 }
+
+// Embed returns the embedding vector of the text.
+// If the source is not set or can't embed texts, then it will return ErrSourceNotSet or ErrEmbeddingNotSupported.
+func (p Professor) Embed(ctx context.Context, text string) ([]float32, error) {
+	if p.source == nil {
+		return nil, ErrSourceNotSet
+	}
+	return embed(ctx, p.source, text)
+}
+
+// EmbeddingModel returns the model used by Embed, empty if the source can't embed texts.
+func (p Professor) EmbeddingModel() string {
+	if p.source == nil {
+		return ""
+	}
+	return embeddingModel(p.source)
+}
+
+// instructions renders the template for the command as a system message.
+func (p Professor) instructions(cmd command.Command, template string) (command.Message, error) {
+	content, err := p.template(template).Render(PromptData{
+		Command: cmd,
+		Shell:   p.shell,
+		Locale:  p.locale,
+	})
+	if err != nil {
+		return command.Message{}, err
+	}
+
+	return command.Message{Role: command.SystemRole, Content: content}, nil
+}
+
+// template returns the template with the name, or the default one if not found.
+func (p Professor) template(name string) Template {
+	if tmpl, ok := p.templates[name]; ok {
+		return tmpl
+	}
+	return p.templates[p.defaultTemplate]
+}
+
+func (p *Professor) addTemplate(tmpl Template) {
+	if _, ok := p.templates[tmpl.Name]; !ok {
+		p.templateNames = append(p.templateNames, tmpl.Name)
+	}
+	p.templates[tmpl.Name] = tmpl
+}
+
+// WithTemplate adds a prompt template, replacing the one with the same name.
+func WithTemplate(tmpl Template) OptFunc {
+	return func(profe *Professor) {
+		profe.addTemplate(tmpl)
+	}
+}
+
+// WithDefaultTemplate sets the template used when none is requested.
+func WithDefaultTemplate(name string) OptFunc {
+	return func(profe *Professor) {
+		profe.defaultTemplate = name
+	}
+}
+
+// WithShell sets the user shell passed to the templates.
+func WithShell(shell string) OptFunc {
+	return func(profe *Professor) {
+		profe.shell = shell
+	}
+}
+
+// WithLocale sets the user locale passed to the templates.
+func WithLocale(locale string) OptFunc {
+	return func(profe *Professor) {
+		profe.locale = locale
+	}
+}
@@ -0,0 +1,171 @@
+package professor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestProfessor_Explain(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cmd := command.Command{
+		ID:          uuid.New(),
+		Name:        "list",
+		Description: "list the files",
+		Command:     "ls -la {{.path}}",
+		Params: []command.Parameter{
+			{Name: "path", Description: "directory to list", DefaultValue: "."},
+		},
+	}
+
+	custom, err := NewTemplate("custom", "", "Explain {{ .Command.Name }} for {{ .Shell }}")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		opts             []OptFunc
+		template         string
+		expectedTemplate string
+		expectedContains []string
+	}{
+		{
+			name:             "default template",
+			expectedTemplate: DefaultTemplate,
+			expectedContains: []string{
+				`named "list"`,
+				"Its description is: list the files",
+				"- path: directory to list (default: .)",
+				"use fish as the format",
+				"locale es_CR",
+			},
+		},
+		{
+			name:             "requested template",
+			template:         SecurityTemplate,
+			expectedTemplate: SecurityTemplate,
+			expectedContains: []string{"Review the security", `named "list"`},
+		},
+		{
+			name:             "unknown template uses the default",
+			template:         "unknown",
+			expectedTemplate: DefaultTemplate,
+			expectedContains: []string{"Explain the given command"},
+		},
+		{
+			name:             "custom default template",
+			opts:             []OptFunc{WithTemplate(custom), WithDefaultTemplate("custom")},
+			expectedTemplate: "custom",
+			expectedContains: []string{"Explain list for fish"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{name: "fake"}
+			opts := append([]OptFunc{WithShell("fish"), WithLocale("es_CR")}, tt.opts...)
+			profe := New(source, logger, opts...)
+
+			expl, err := profe.Explain(context.Background(), cmd, tt.template)
+			require.NoError(t, err)
+
+			assert.Equal(t, "fake answer", expl.Content, "content not the expected")
+			assert.Equal(t, tt.expectedTemplate, expl.Signature.Template, "template not the expected")
+			assert.Equal(t, profe.Signature(cmd, tt.template), expl.Signature, "signature not the expected")
+
+			require.Len(t, source.conversation, 2)
+			assert.Equal(t, command.SystemRole, source.conversation[0].Role, "first message should be the instructions")
+			for _, str := range tt.expectedContains {
+				assert.Contains(t, source.conversation[0].Content, str, "instructions not the expected")
+			}
+			assert.Equal(t, command.Message{Role: command.UserRole, Content: cmd.Command}, source.conversation[1])
+		})
+	}
+}
+
+func TestProfessor_Signature(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cmd := command.Command{Command: "ls -la"}
+	profe := New(&fakeSource{name: "fake"}, logger)
+
+	def := profe.Signature(cmd, "")
+	assert.Equal(t, DefaultTemplate, def.Template)
+	assert.Equal(t, "fake", def.Source)
+	assert.Equal(t, def, profe.Signature(cmd, DefaultTemplate), "empty should use the default template")
+	assert.NotEqual(t, def.PromptVersion, profe.Signature(cmd, BeginnerTemplate).PromptVersion, "templates should have different versions")
+	assert.NotEqual(t, def.CommandHash, profe.Signature(command.Command{Command: "ls"}, "").CommandHash)
+}
+
+func TestProfessor_ExplainFallback(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cmd := command.Command{Command: "ls -la"}
+	llm := &fakeSource{name: "llm", errs: []error{errors.New("mock error")}}
+	man := &fakeSource{name: "manpage"}
+	profe := New(Chain(logger, llm, man), logger)
+
+	// the fallback answer is signed by its source, so it's stale once the preferred one is back.
+	expl, err := profe.Explain(context.Background(), cmd, "")
+	require.NoError(t, err)
+	assert.Equal(t, "manpage answer", expl.Content)
+	assert.Equal(t, "manpage", expl.Signature.Source)
+	assert.NotEqual(t, profe.Signature(cmd, ""), expl.Signature, "fallback explanation should be stale")
+
+	expl, err = profe.Explain(context.Background(), cmd, "")
+	require.NoError(t, err)
+	assert.Equal(t, "llm answer", expl.Content)
+	assert.Equal(t, profe.Signature(cmd, ""), expl.Signature, "preferred explanation should be up to date")
+}
+
+func TestProfessor_FollowUp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cmd := command.Command{Command: "ls -la"}
+	source := &fakeSource{name: "fake"}
+	profe := New(source, logger)
+
+	expl := command.Explanation{
+		Content:   "explanation",
+		Signature: profe.Signature(cmd, BeginnerTemplate),
+	}
+	transcript := []command.Message{
+		{Role: command.UserRole, Content: "first question"},
+		{Role: command.AssistantRole, Content: "first answer"},
+	}
+
+	answer, err := profe.FollowUp(context.Background(), cmd, expl, transcript, "second question")
+	require.NoError(t, err)
+	assert.Equal(t, "fake answer", answer)
+
+	require.Len(t, source.conversation, 6)
+	assert.Equal(t, command.SystemRole, source.conversation[0].Role)
+	assert.Contains(t, source.conversation[0].Content, "new to the command line", "should use the explanation template")
+	assert.Equal(t, []command.Message{
+		{Role: command.UserRole, Content: "ls -la"},
+		{Role: command.AssistantRole, Content: "explanation"},
+		{Role: command.UserRole, Content: "first question"},
+		{Role: command.AssistantRole, Content: "first answer"},
+		{Role: command.UserRole, Content: "second question"},
+	}, source.conversation[1:])
+}
+
+func TestProfessor_Templates(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	override, err := NewTemplate(BeginnerTemplate, "", "simpler")
+	require.NoError(t, err)
+	extra, err := NewTemplate("extra", "", "extra")
+	require.NoError(t, err)
+
+	profe := New(&fakeSource{}, logger, WithTemplate(override), WithTemplate(extra))
+	assert.Equal(t, []string{DefaultTemplate, BeginnerTemplate, SecurityTemplate, "extra"}, profe.Templates())
+	assert.Equal(t, override.Version(), profe.template(BeginnerTemplate).Version(), "template should be replaced")
+
+	_, err = NewTemplate("broken", "", "{{ .Command")
+	assert.Error(t, err)
+}
@@ -0,0 +1,81 @@
+package professor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// PromptData is the data available in the prompt templates.
+type PromptData struct {
+	// Command is the command to explain.
+	Command command.Command
+	// Shell is the name of the user shell, e.g. fish.
+	Shell string
+	// Locale is the user locale, e.g. en_US.
+	Locale string
+}
+
+// Template is a prompt template used for instructing the source.
+type Template struct {
+	Name        string
+	Description string
+	version     string
+	tmpl        *template.Template
+}
+
+// NewTemplate parses the text as a text/template and returns a new Template.
+// The template receives PromptData.
+func NewTemplate(name, description, text string) (Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return Template{}, fmt.Errorf("error parsing template %q: %w", name, err)
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	return Template{
+		Name:        name,
+		Description: description,
+		version:     hex.EncodeToString(sum[:4]),
+		tmpl:        tmpl,
+	}, nil
+}
+
+// Version returns the version of the template, derived from its content.
+func (t Template) Version() string {
+	return t.version
+}
+
+// Render executes the template with the data.
+func (t Template) Render(data PromptData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering template %q: %w", t.Name, err)
+	}
+	return b.String(), nil
+}
+
+// userShell returns the name of the user shell.
+func userShell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return filepath.Base(sh)
+	}
+	return defaultShell
+}
+
+// userLocale returns the user locale without the encoding.
+func userLocale() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale, _, _ := strings.Cut(os.Getenv(env), ".")
+		if locale != "" && locale != "C" && locale != "POSIX" {
+			return locale
+		}
+	}
+	return defaultLocale
+}
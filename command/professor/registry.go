@@ -0,0 +1,99 @@
+package professor
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownProvider thrown when there is no provider registered with the name.
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// DecodeFunc decodes the config of a source into v.
+type DecodeFunc func(v any) error
+
+// Provider creates sources of a type, e.g. openai.
+type Provider struct {
+	// Decode decodes and validates the provider config.
+	Decode func(decode DecodeFunc) (any, error)
+	// New returns a new source from the config returned by Decode.
+	New func(logger *slog.Logger, cfg any) (Source, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// Register makes a provider available by the name.
+// Meant to be called from the init function of the provider package.
+// It panics if the name is registered twice or the provider is incomplete.
+func Register(name string, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if provider.Decode == nil || provider.New == nil {
+		panic("professor: incomplete provider " + name)
+	}
+	if _, ok := providers[name]; ok {
+		panic("professor: provider registered twice " + name)
+	}
+	providers[name] = provider
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewSource returns a new source from the registered provider with the name.
+// The decode func is used for reading the source config.
+func NewSource(logger *slog.Logger, name string, decode DecodeFunc) (Source, error) {
+	providersMu.RLock()
+	provider, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, available: %v", ErrUnknownProvider, name, Providers())
+	}
+
+	cfg, err := provider.Decode(decode)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s config: %w", name, err)
+	}
+
+	source, err := provider.New(logger, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s source: %w", name, err)
+	}
+
+	return source, nil
+}
+
+// DecodeAs returns a Provider.Decode function that decodes the config as C and validates it.
+// validate can be nil.
+func DecodeAs[C any](validate func(C) error) func(DecodeFunc) (any, error) {
+	return func(decode DecodeFunc) (any, error) {
+		var cfg C
+		if err := decode(&cfg); err != nil {
+			return nil, err
+		}
+
+		if validate != nil {
+			if err := validate(cfg); err != nil {
+				return nil, err
+			}
+		}
+
+		return cfg, nil
+	}
+}
@@ -0,0 +1,99 @@
+package professor
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConfig struct {
+	Name string
+}
+
+func TestNewSource(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mockErr := errors.New("mock error")
+
+	Register("fake-registry", Provider{
+		Decode: DecodeAs(func(cfg fakeConfig) error {
+			if cfg.Name == "" {
+				return mockErr
+			}
+			return nil
+		}),
+		New: func(_ *slog.Logger, cfg any) (Source, error) {
+			return &fakeSource{name: cfg.(fakeConfig).Name}, nil
+		},
+	})
+
+	tests := []struct {
+		name         string
+		provider     string
+		decode       DecodeFunc
+		expectedName string
+		expectedErr  error
+	}{
+		{
+			name:     "registered provider",
+			provider: "fake-registry",
+			decode: func(v any) error {
+				v.(*fakeConfig).Name = "configured"
+				return nil
+			},
+			expectedName: "configured",
+		},
+		{
+			name:     "invalid config",
+			provider: "fake-registry",
+			decode: func(v any) error {
+				return nil
+			},
+			expectedErr: mockErr,
+		},
+		{
+			name:     "decode error",
+			provider: "fake-registry",
+			decode: func(v any) error {
+				return mockErr
+			},
+			expectedErr: mockErr,
+		},
+		{
+			name:        "unknown provider",
+			provider:    "unknown",
+			decode:      func(v any) error { return nil },
+			expectedErr: ErrUnknownProvider,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewSource(logger, tt.provider, tt.decode)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr, "error not the expected")
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedName, source.Name(), "source not the expected")
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	provider := Provider{
+		Decode: DecodeAs[fakeConfig](nil),
+		New: func(*slog.Logger, any) (Source, error) {
+			return &fakeSource{}, nil
+		},
+	}
+
+	Register("fake-twice", provider)
+	assert.Contains(t, Providers(), "fake-twice")
+	assert.Panics(t, func() { Register("fake-twice", provider) }, "duplicated provider should panic")
+	assert.Panics(t, func() { Register("fake-incomplete", Provider{}) }, "incomplete provider should panic")
+}
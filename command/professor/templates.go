@@ -0,0 +1,59 @@
+package professor
+
+const (
+	// DefaultTemplate is the name of the template used when none is requested.
+	DefaultTemplate = "default"
+	// BeginnerTemplate is the name of the template for explaining to newcomers.
+	BeginnerTemplate = "beginner"
+	// SecurityTemplate is the name of the template for reviewing the command security.
+	SecurityTemplate = "security"
+
+	defaultShell  = "sh"
+	defaultLocale = "en_US"
+)
+
+// commandContext describes the command metadata, shared by the built-in templates.
+const commandContext = `
+The user message is a command saved by the user{{ with .Command.Name }}, named "{{ . }}"{{ end }}.
+{{- with .Command.Description }}
+Its description is: {{ . }}
+{{- end }}
+The command can contain parameters of the form {{ "{{.name}}" }} where name is the name of the parameter, which are meant to be replaced.
+{{- with .Command.Params }}
+The parameters are:
+{{- range . }}
+- {{ .Name }}{{ with .Description }}: {{ . }}{{ end }}{{ with .DefaultValue }} (default: {{ . }}){{ end }}
+{{- end }}
+{{- end }}
+Don't mention how to replace the parameters.
+When formatting code, use {{ .Shell }} as the format.
+Answer in the language of the locale {{ .Locale }}.`
+
+var builtinTemplates = []struct {
+	name        string
+	description string
+	text        string
+}{
+	{
+		name:        DefaultTemplate,
+		description: "summary, breakdown, examples and cautions",
+		text: `Explain the given command and give your answer using markdown.
+The explanation should contain the following sections encoded as markdown headings: summary, breakdown, example of use and cautions.` + commandContext,
+	},
+	{
+		name:        BeginnerTemplate,
+		description: "step by step, for people new to the shell",
+		text: `Explain the given command to someone who is new to the command line and give your answer using markdown.
+Avoid jargon, and define any term that can't be avoided.
+The explanation should contain the following sections encoded as markdown headings: what it does, step by step and things to watch out for.
+In the step by step section, explain every program, flag and operator in the order they appear.` + commandContext,
+	},
+	{
+		name:        SecurityTemplate,
+		description: "risks, destructive operations and safer alternatives",
+		text: `Review the security of the given command and give your answer using markdown.
+The review should contain the following sections encoded as markdown headings: summary, risks, data exposure and safer alternatives.
+Point out destructive or irreversible operations, privilege escalation, credentials or secrets in the command, and network access.
+If there are no relevant risks, say so instead of inventing them.` + commandContext,
+	},
+}
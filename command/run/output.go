@@ -0,0 +1,69 @@
+package run
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// maxOutput is the number of bytes of output kept, the oldest are dropped.
+const maxOutput = 256 * 1024
+
+// Output collects the output of a run for displaying it, it's safe for concurrent use.
+type Output struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write appends the output, dropping the oldest beyond the limit.
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+	if extra := len(o.buf) - maxOutput; extra > 0 {
+		o.buf = o.buf[extra:]
+	}
+	return len(p), nil
+}
+
+// String returns the output as shown by a terminal, without the escape sequences.
+// The carriage returns and backspaces move back on the line, so the progress bars show their last state.
+func (o *Output) String() string {
+	o.mu.Lock()
+	raw := string(o.buf)
+	o.mu.Unlock()
+
+	lines := strings.Split(ansi.Strip(strings.ReplaceAll(raw, "\r\n", "\n")), "\n")
+	for i, line := range lines {
+		if strings.ContainsAny(line, "\r\b") {
+			lines[i] = overwrite(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// overwrite returns the line written by a terminal, moving the cursor back on the carriage returns and backspaces.
+func overwrite(line string) string {
+	var (
+		out []rune
+		col int
+	)
+	for _, r := range line {
+		switch r {
+		case '\r':
+			col = 0
+		case '\b':
+			col = max(col-1, 0)
+		default:
+			if col < len(out) {
+				out[col] = r
+			} else {
+				out = append(out, r)
+			}
+			col++
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,163 @@
+// Package run runs the commands inside clio, in a pseudo terminal under the user shell.
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/lian-rr/clio/command"
+)
+
+const (
+	defaultShell = "/bin/sh"
+	// defaultGracePeriod is how long a canceled command has to exit before being killed.
+	defaultGracePeriod = 3 * time.Second
+	// interrupt is the ctrl+c character, the terminal sends SIGINT to the command when it's written.
+	interrupt = 0x03
+)
+
+// Runner runs the command lines with the shell.
+type Runner struct {
+	shell       string
+	gracePeriod time.Duration
+}
+
+// OptFunc is used to apply optional settings to the Runner.
+type OptFunc func(*Runner)
+
+// WithShell sets the shell running the commands, the $SHELL of the user by default.
+func WithShell(shell string) OptFunc {
+	return func(r *Runner) {
+		r.shell = shell
+	}
+}
+
+// WithGracePeriod sets how long a canceled command has to exit before being killed.
+func WithGracePeriod(period time.Duration) OptFunc {
+	return func(r *Runner) {
+		r.gracePeriod = period
+	}
+}
+
+// New returns a new Runner.
+func New(opts ...OptFunc) Runner {
+	r := Runner{
+		shell:       os.Getenv("SHELL"),
+		gracePeriod: defaultGracePeriod,
+	}
+	if r.shell == "" {
+		r.shell = defaultShell
+	}
+
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+// Run runs the command in a pseudo terminal of the given size, with its env and working directory, writing its output to out.
+// What's read from in is typed in the terminal, e.g. the answers to the prompts; the caller closes it once Run returns.
+// Canceling the context interrupts the command as ctrl+c does, and kills it after the grace period.
+// The exit code of a command ended by a signal is 128 plus the signal, as in the shells.
+func (r Runner) Run(ctx context.Context, inv command.Invocation, cols, rows int, in io.Reader, out io.Writer) (command.RunResult, error) {
+	cmd := exec.Command(r.shell, "-c", inv.Command)
+	if len(inv.Env) > 0 {
+		cmd.Env = os.Environ()
+		for _, env := range inv.Env {
+			cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+		}
+	}
+	if inv.Dir != "" {
+		dir, err := expandHome(inv.Dir)
+		if err != nil {
+			return command.RunResult{}, err
+		}
+		cmd.Dir = dir
+	}
+
+	start := time.Now()
+	tty, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if err != nil {
+		return command.RunResult{}, fmt.Errorf("error starting command: %w", err)
+	}
+
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		// the read fails with EIO once the command exits.
+		_, _ = io.Copy(out, tty)
+	}()
+
+	if in != nil {
+		// the copy ends when in is closed, or on the first write after the terminal is closed.
+		go func() {
+			_, _ = io.Copy(tty, in)
+		}()
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-ctx.Done():
+		}
+
+		_, _ = tty.Write([]byte{interrupt})
+		select {
+		case <-exited:
+		case <-time.After(r.gracePeriod):
+			// the command runs in its own session, the whole group is killed.
+			_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}()
+
+	err = cmd.Wait()
+	result := command.RunResult{Duration: time.Since(start)}
+
+	// the background processes of the command can keep the terminal open.
+	select {
+	case <-copied:
+	case <-time.After(100 * time.Millisecond):
+	}
+	_ = tty.Close()
+	<-copied
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result.ExitCode = 128 + int(status.Signal())
+		}
+	default:
+		return result, fmt.Errorf("error running command: %w", err)
+	}
+
+	return result, nil
+}
+
+// expandHome replaces the ~ prefix of the path with the home directory, as the shells do.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting the home directory: %w", err)
+	}
+	return filepath.Join(home, path[1:]), nil
+}
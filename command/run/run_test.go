@@ -0,0 +1,172 @@
+package run
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestRunner_Run(t *testing.T) {
+	runner := New(WithShell("/bin/sh"), WithGracePeriod(time.Second))
+
+	tests := []struct {
+		name         string
+		line         string
+		expectedCode int
+		expectedOut  string
+	}{
+		{
+			name:        "output",
+			line:        "echo hello && echo world",
+			expectedOut: "hello\nworld\n",
+		},
+		{
+			name:         "exit code",
+			line:         "echo failing; exit 3",
+			expectedCode: 3,
+			expectedOut:  "failing\n",
+		},
+		{
+			name:        "terminal",
+			line:        "test -t 1 && echo tty",
+			expectedOut: "tty\n",
+		},
+		{
+			name:        "size",
+			line:        "stty size",
+			expectedOut: "24 80\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out Output
+			got, err := runner.Run(context.Background(), command.Invocation{Command: tt.line}, 80, 24, nil, &out)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, got.ExitCode, "exit code not the expected")
+			assert.Equal(t, tt.expectedOut, out.String(), "output not the expected")
+			assert.Positive(t, got.Duration)
+		})
+	}
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+
+		var out Output
+		got, err := runner.Run(ctx, command.Invocation{Command: "sleep 10"}, 80, 24, nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, 130, got.ExitCode, "interrupted as with ctrl+c")
+		assert.Less(t, got.Duration, 5*time.Second)
+	})
+
+	t.Run("cancel ignored", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(200*time.Millisecond, cancel)
+
+		var out Output
+		got, err := runner.Run(ctx, command.Invocation{Command: "trap '' INT; sleep 10"}, 80, 24, nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, 137, got.ExitCode, "killed after the grace period")
+		assert.Less(t, got.Duration, 5*time.Second)
+	})
+
+	t.Run("input", func(t *testing.T) {
+		in, typed := io.Pipe()
+		defer in.Close()
+		go func() {
+			_, _ = typed.Write([]byte("yes\r"))
+		}()
+
+		var out Output
+		got, err := runner.Run(context.Background(), command.Invocation{Command: `read -r answer; echo "got $answer"`}, 80, 24, in, &out)
+		require.NoError(t, err)
+		assert.Zero(t, got.ExitCode)
+		assert.Contains(t, out.String(), "got yes\n", "the input is typed in the terminal")
+	})
+
+	t.Run("env and working directory", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("HOME", dir)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "infra"), 0o700))
+
+		var out Output
+		got, err := runner.Run(context.Background(), command.Invocation{
+			Command: "echo $AWS_PROFILE $PWD",
+			Env:     []command.EnvVar{{Name: "AWS_PROFILE", Value: "ops"}},
+			Dir:     "~/infra",
+		}, 80, 24, nil, &out)
+		require.NoError(t, err)
+		assert.Zero(t, got.ExitCode)
+		assert.Equal(t, "ops "+filepath.Join(dir, "infra")+"\n", out.String())
+	})
+
+	t.Run("missing working directory", func(t *testing.T) {
+		var out Output
+		_, err := runner.Run(context.Background(), command.Invocation{Command: "pwd", Dir: "/missing/dir"}, 80, 24, nil, &out)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing shell", func(t *testing.T) {
+		var out Output
+		_, err := New(WithShell("/missing/shell")).Run(context.Background(), command.Invocation{Command: "echo hello"}, 80, 24, nil, &out)
+		assert.Error(t, err)
+	})
+}
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+	}{
+		{
+			name:     "lines",
+			writes:   []string{"hello\r\n", "wor", "ld\r\n"},
+			expected: "hello\nworld\n",
+		},
+		{
+			name:     "escape sequences",
+			writes:   []string{"\x1b[31mred\x1b[0m \x1b]0;title\x07text"},
+			expected: "red text",
+		},
+		{
+			name:     "progress",
+			writes:   []string{"10%\r", "50%\r", "100%\r\ndone"},
+			expected: "100%\ndone",
+		},
+		{
+			name:     "overwrite",
+			writes:   []string{"downloading\rok", "\bK"},
+			expected: "oKwnloading",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out Output
+			for _, w := range tt.writes {
+				n, err := out.Write([]byte(w))
+				require.NoError(t, err)
+				assert.Equal(t, len(w), n)
+			}
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		var out Output
+		_, _ = out.Write(make([]byte, maxOutput))
+		_, _ = out.Write([]byte("last"))
+		assert.Len(t, out.String(), maxOutput)
+		assert.Equal(t, "last", out.String()[maxOutput-4:])
+	})
+}
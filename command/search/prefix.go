@@ -0,0 +1,66 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// Prefix returns the commands where every token prefixes a word of the name, command or description,
+// best matches first. It's the full-text search of the stores without an index.
+func Prefix(cmds []command.Command, tokens []string) []command.Command {
+	if len(tokens) == 0 {
+		return []command.Command{}
+	}
+
+	type match struct {
+		cmd   command.Command
+		score int
+	}
+
+	matches := make([]match, 0)
+	for _, cmd := range cmds {
+		name, line, desc := Tokenize(cmd.Name), Tokenize(cmd.Command), Tokenize(cmd.Description)
+
+		score := 0
+		for _, token := range tokens {
+			s := nameWeight*prefixes(name, token) +
+				commandWeight*prefixes(line, token) +
+				descriptionWeight*prefixes(desc, token)
+			if s == 0 {
+				score = 0
+				break
+			}
+			score += s
+		}
+
+		if score > 0 {
+			matches = append(matches, match{cmd: cmd, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].cmd.Name < matches[j].cmd.Name
+	})
+
+	out := make([]command.Command, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.cmd)
+	}
+	return out
+}
+
+// prefixes returns the number of words starting with the token.
+func prefixes(words []string, token string) int {
+	count := 0
+	for _, w := range words {
+		if strings.HasPrefix(w, token) {
+			count++
+		}
+	}
+	return count
+}
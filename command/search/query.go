@@ -0,0 +1,323 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// ErrInvalidQuery used when the query can't be parsed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Field is the field of the commands a filter applies to.
+type Field string
+
+const (
+	// NameField filters by the words of the name.
+	NameField Field = "name"
+	// CommandField filters by the words of the command template.
+	CommandField Field = "cmd"
+	// TagField filters by a tag.
+	TagField Field = "tag"
+	// ParamField filters by the name of a parameter.
+	ParamField Field = "param"
+	// UsedField filters by the time of the usages, e.g. used:<7d.
+	UsedField Field = "used"
+)
+
+// fields are the fields known by the parser, in the order shown in the errors.
+var fields = []Field{NameField, CommandField, TagField, ParamField, UsedField}
+
+// units are the units of the periods of the used filters.
+var units = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// QueryError describes the part of the query that can't be parsed.
+type QueryError struct {
+	Token  string
+	Reason string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid %q: %s", e.Token, e.Reason)
+}
+
+// Is reports the error as an ErrInvalidQuery.
+func (e *QueryError) Is(target error) bool {
+	return target == ErrInvalidQuery
+}
+
+// Query is a parsed search, e.g. `deploy name:web -tag:prod param:namespace used:<7d`.
+type Query struct {
+	// Semantic ranks the commands by the meaning of the terms instead of their words,
+	// set by a leading ~, e.g. `~free up disk space`.
+	Semantic bool
+	// Terms are the free text, matched against every field of the commands.
+	Terms []string
+	// Filters restrict the commands matching the terms.
+	Filters []Filter
+	// Usage restricts the commands by the time of their usages.
+	Usage []UsageFilter
+}
+
+// Filter keeps the commands with the field matching the value, or the ones not matching it when negated.
+type Filter struct {
+	Field   Field
+	Value   string
+	Negated bool
+}
+
+// UsageFilter keeps the commands used in the period before now, or the ones not used in it.
+type UsageFilter struct {
+	Period time.Duration
+	// Within keeps the commands used in the period when true, e.g. used:<7d.
+	// When false, it keeps the ones not used in it, e.g. used:>7d.
+	Within bool
+}
+
+// Parse parses the search query. The words prefixed by a field, e.g. tag:prod, are filters, negated with a
+// leading -. The rest is free text. The quotes group words, and make the text with a colon free text.
+// A leading ~ makes the search semantic.
+func Parse(raw string) (Query, error) {
+	var q Query
+	if trimmed := strings.TrimLeftFunc(raw, unicode.IsSpace); strings.HasPrefix(trimmed, "~") {
+		q.Semantic = true
+		raw = strings.TrimPrefix(trimmed, "~")
+	}
+
+	tokens, err := split(raw)
+	if err != nil {
+		return Query{}, err
+	}
+
+	for _, t := range tokens {
+		if t.field == "" {
+			q.Terms = append(q.Terms, t.value)
+			continue
+		}
+
+		field := Field(t.field)
+		if !slices.Contains(fields, field) {
+			return Query{}, &QueryError{Token: t.raw, Reason: fmt.Sprintf("unknown field %q, use %s, or quote the text", t.field, fieldNames())}
+		}
+		if strings.TrimSpace(t.value) == "" {
+			return Query{}, &QueryError{Token: t.raw, Reason: "missing value"}
+		}
+
+		switch field {
+		case UsedField:
+			usage, err := parseUsage(t.value)
+			if err != nil {
+				return Query{}, &QueryError{Token: t.raw, Reason: err.Error()}
+			}
+			if t.negated {
+				usage.Within = !usage.Within
+			}
+			q.Usage = append(q.Usage, usage)
+		case NameField, CommandField:
+			if len(Tokenize(t.value)) == 0 {
+				return Query{}, &QueryError{Token: t.raw, Reason: "the value has no words"}
+			}
+			q.Filters = append(q.Filters, Filter{Field: field, Value: strings.ToLower(t.value), Negated: t.negated})
+		default:
+			q.Filters = append(q.Filters, Filter{Field: field, Value: strings.ToLower(t.value), Negated: t.negated})
+		}
+	}
+
+	return q, nil
+}
+
+// Text returns the free text of the query.
+func (q Query) Text() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// Filtered reports whether the query has filters.
+func (q Query) Filtered() bool {
+	return len(q.Filters) > 0 || len(q.Usage) > 0
+}
+
+// Empty reports whether the query has nothing to match.
+func (q Query) Empty() bool {
+	return !q.Filtered() && len(Tokenize(q.Text())) == 0
+}
+
+// Match reports whether the command passes the filters, given the times it was used. The terms aren't matched.
+func (q Query) Match(cmd command.Command, usages []time.Time, now time.Time) bool {
+	for _, f := range q.Filters {
+		if !f.Match(cmd) {
+			return false
+		}
+	}
+
+	for _, u := range q.Usage {
+		since := now.Add(-u.Period)
+		used := slices.ContainsFunc(usages, func(t time.Time) bool {
+			return !t.Before(since)
+		})
+		if used != u.Within {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Match reports whether the command passes the filter.
+func (f Filter) Match(cmd command.Command) bool {
+	var match bool
+	switch f.Field {
+	case NameField:
+		match = prefixesAll(cmd.Name, f.Value)
+	case CommandField:
+		match = prefixesAll(cmd.Command, f.Value)
+	case TagField:
+		match = slices.Contains(cmd.Tags, f.Value)
+	case ParamField:
+		match = slices.ContainsFunc(cmd.Params, func(p command.Parameter) bool {
+			return strings.EqualFold(p.Name, f.Value)
+		})
+	}
+
+	return match != f.Negated
+}
+
+// prefixesAll reports whether every word of the value prefixes a word of the text.
+func prefixesAll(text, value string) bool {
+	ws := words(text)
+	for _, token := range Tokenize(value) {
+		found := slices.ContainsFunc(ws, func(w word) bool {
+			return strings.HasPrefix(string(w.runes), token)
+		})
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUsage parses the value of a used filter, e.g. <7d or >2w.
+func parseUsage(value string) (UsageFilter, error) {
+	errFormat := errors.New("expected < or > and a period in m, h, d or w, e.g. used:<7d")
+	if len(value) < 3 {
+		return UsageFilter{}, errFormat
+	}
+
+	var usage UsageFilter
+	switch value[0] {
+	case '<':
+		usage.Within = true
+	case '>':
+		usage.Within = false
+	default:
+		return UsageFilter{}, errFormat
+	}
+
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return UsageFilter{}, errFormat
+	}
+
+	n, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || n <= 0 {
+		return UsageFilter{}, errFormat
+	}
+
+	usage.Period = time.Duration(n) * unit
+	return usage, nil
+}
+
+// token is a part of the query, a filter when it has a field.
+type token struct {
+	raw     string
+	field   string
+	value   string
+	negated bool
+}
+
+// split splits the query into tokens by the spaces, keeping the quoted text together.
+func split(raw string) ([]token, error) {
+	var (
+		tokens  []token
+		current strings.Builder
+		value   strings.Builder
+		quoted  bool
+		// field is set while reading the value of a filter.
+		field   string
+		negated bool
+		// plain is set when the token started with a quote, it can't be a filter.
+		plain bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{raw: current.String(), field: field, value: value.String(), negated: negated})
+		}
+		current.Reset()
+		value.Reset()
+		field, negated, plain = "", false, false
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			if current.Len() == 0 {
+				plain = true
+			}
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			flush()
+		case r == ':' && !quoted && !plain && field == "" && isFieldName(value.String()):
+			field = value.String()
+			if strings.HasPrefix(field, "-") {
+				field, negated = field[1:], true
+			}
+			field = strings.ToLower(field)
+			value.Reset()
+			current.WriteRune(r)
+		default:
+			value.WriteRune(r)
+			current.WriteRune(r)
+		}
+	}
+
+	if quoted {
+		return nil, &QueryError{Token: current.String(), Reason: "missing closing quote"}
+	}
+	flush()
+
+	return tokens, nil
+}
+
+// isFieldName reports whether the text can be the field of a filter, letters with an optional leading -.
+func isFieldName(text string) bool {
+	text = strings.TrimPrefix(text, "-")
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, string(f))
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
@@ -0,0 +1,160 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         string
+		expected    Query
+		expectedErr string
+	}{
+		{
+			name:     "free text",
+			raw:      "git  -n --soft",
+			expected: Query{Terms: []string{"git", "-n", "--soft"}},
+		},
+		{
+			name: "filters",
+			raw:  `deploy name:Web cmd:kubectl -tag:prod param:namespace used:<7d`,
+			expected: Query{
+				Terms: []string{"deploy"},
+				Filters: []Filter{
+					{Field: NameField, Value: "web"},
+					{Field: CommandField, Value: "kubectl"},
+					{Field: TagField, Value: "prod", Negated: true},
+					{Field: ParamField, Value: "namespace"},
+				},
+				Usage: []UsageFilter{{Period: 7 * 24 * time.Hour, Within: true}},
+			},
+		},
+		{
+			name: "quoted",
+			raw:  `name:"git push" "localhost:8080" cmd:a:b`,
+			expected: Query{
+				Terms: []string{"localhost:8080"},
+				Filters: []Filter{
+					{Field: NameField, Value: "git push"},
+					{Field: CommandField, Value: "a:b"},
+				},
+			},
+		},
+		{
+			name: "usage periods",
+			raw:  "used:>2w -used:<30m -used:>1h",
+			expected: Query{
+				Usage: []UsageFilter{
+					{Period: 2 * 7 * 24 * time.Hour},
+					{Period: 30 * time.Minute},
+					{Period: time.Hour, Within: true},
+				},
+			},
+		},
+		{
+			name: "semantic",
+			raw:  " ~free up disk space tag:ops",
+			expected: Query{
+				Semantic: true,
+				Terms:    []string{"free", "up", "disk", "space"},
+				Filters:  []Filter{{Field: TagField, Value: "ops"}},
+			},
+		},
+		{
+			name:     "tilde in the text",
+			raw:      "git reset HEAD~1",
+			expected: Query{Terms: []string{"git", "reset", "HEAD~1"}},
+		},
+		{
+			name:        "unknown field",
+			raw:         "localhost:8080",
+			expectedErr: `invalid "localhost:8080": unknown field "localhost", use name, cmd, tag, param or used, or quote the text`,
+		},
+		{
+			name:        "missing value",
+			raw:         "pods tag:",
+			expectedErr: `invalid "tag:": missing value`,
+		},
+		{
+			name:        "no words",
+			raw:         "name:--",
+			expectedErr: `invalid "name:--": the value has no words`,
+		},
+		{
+			name:        "invalid period",
+			raw:         "used:7d",
+			expectedErr: `invalid "used:7d": expected < or > and a period in m, h, d or w, e.g. used:<7d`,
+		},
+		{
+			name:        "invalid unit",
+			raw:         "used:<7y",
+			expectedErr: `invalid "used:<7y": expected < or > and a period in m, h, d or w, e.g. used:<7d`,
+		},
+		{
+			name:        "missing closing quote",
+			raw:         `name:"git push`,
+			expectedErr: `invalid "name:\"git push": missing closing quote`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if tt.expectedErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidQuery)
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestQuery_Match(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cmd := command.Command{
+		ID:      uuid.New(),
+		Name:    "Deploy web",
+		Command: "kubectl apply -n {{.namespace}} -f web.yaml",
+		Params:  []command.Parameter{{Name: "namespace"}},
+		Tags:    []string{"k8s", "staging"},
+	}
+	usages := []time.Time{now.Add(-3 * 24 * time.Hour)}
+
+	tests := []struct {
+		raw      string
+		expected bool
+	}{
+		{raw: "anything", expected: true},
+		{raw: "name:dep name:we", expected: true},
+		{raw: "name:apply", expected: false},
+		{raw: "cmd:kubectl cmd:yaml", expected: true},
+		{raw: "-cmd:helm", expected: true},
+		{raw: "tag:k8s -tag:prod", expected: true},
+		{raw: "tag:prod", expected: false},
+		{raw: "-tag:staging", expected: false},
+		{raw: "param:Namespace", expected: true},
+		{raw: "param:name", expected: false},
+		{raw: "used:<7d", expected: true},
+		{raw: "used:<2d", expected: false},
+		{raw: "used:>2d", expected: true},
+		{raw: "-used:<7d", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mustParse(t, tt.raw).Match(cmd, usages, now))
+		})
+	}
+}
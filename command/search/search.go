@@ -0,0 +1,463 @@
+// Package search ranks the commands matching a query, merging the full-text search of the store
+// with a typo-tolerant fuzzy matching and the usage frecency, or by the similarity of their embeddings.
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// weights of the matches in each field, following the ranking of the sql stores.
+const (
+	nameWeight        = 15
+	commandWeight     = 10
+	descriptionWeight = 5
+)
+
+// maxSimilar is the number of commands returned by a semantic search, the closest ones.
+const maxSimilar = 20
+
+// rrfK dampens the weight of the top positions in the reciprocal rank fusion.
+const rrfK = 60
+
+// weights of each ranking in the fusion.
+const (
+	indexedWeight  = 1.0
+	fuzzyWeight    = 1.0
+	contentWeight  = 0.5
+	frecencyWeight = 0.5
+)
+
+// marks of the matching words in the excerpts.
+const (
+	markStart = "«"
+	markEnd   = "»"
+)
+
+// Sources holds the rankings merged by Rank.
+type Sources struct {
+	// Indexed are the commands returned by the full-text search of the store, best first.
+	// The ones missing in Commands are dropped, e.g. the ones not passing the filters.
+	Indexed []command.Command
+	// Content are the commands whose parameters, explanation or history matched the full-text search
+	// of the store, best first. Like Indexed, the ones missing in Commands are dropped.
+	Content []command.ContentMatch
+	// Commands are the commands passing the filters of the query, matched fuzzily against its terms.
+	Commands []command.Command
+	// Usages are the times each command was used, for the frecency.
+	Usages map[uuid.UUID][]time.Time
+	// Now is the time the frecency is relative to.
+	Now time.Time
+}
+
+// Rank returns the commands matching the query, merging the full-text, content, fuzzy and frecency rankings
+// with reciprocal rank fusion, best first. A query with only filters ranks all the commands by frecency.
+// The results found only by their content say where it matched.
+func Rank(q Query, sources Sources) []command.SearchResult {
+	tokens := Tokenize(q.Text())
+	if len(tokens) == 0 && !q.Filtered() {
+		return []command.SearchResult{}
+	}
+
+	type candidate struct {
+		cmd   command.Command
+		score float64
+	}
+
+	candidates := make(map[uuid.UUID]*candidate)
+	// content holds the best content match of the commands not matching in their own fields.
+	content := make(map[uuid.UUID]command.ContentMatch)
+	fuse := func(ranking []command.Command, weight float64) {
+		for i, cmd := range ranking {
+			c, ok := candidates[cmd.ID]
+			if !ok {
+				c = &candidate{cmd: cmd}
+				candidates[cmd.ID] = c
+			}
+			c.score += weight / float64(rrfK+i+1)
+		}
+	}
+
+	if len(tokens) == 0 {
+		for _, cmd := range sources.Commands {
+			candidates[cmd.ID] = &candidate{cmd: cmd}
+		}
+	} else {
+		allowed := make(map[uuid.UUID]bool, len(sources.Commands))
+		for _, cmd := range sources.Commands {
+			allowed[cmd.ID] = true
+		}
+		indexed := slices.DeleteFunc(slices.Clone(sources.Indexed), func(cmd command.Command) bool {
+			return !allowed[cmd.ID]
+		})
+
+		fuzzy := Fuzzy(sources.Commands, tokens)
+		fuse(indexed, indexedWeight)
+		fuse(fuzzy, fuzzyWeight)
+
+		contentRanking := make([]command.Command, 0, len(sources.Content))
+		for _, match := range sources.Content {
+			if !allowed[match.Command.ID] || slices.ContainsFunc(contentRanking, func(cmd command.Command) bool {
+				return cmd.ID == match.Command.ID
+			}) {
+				continue
+			}
+			contentRanking = append(contentRanking, match.Command)
+			if _, ok := candidates[match.Command.ID]; !ok {
+				content[match.Command.ID] = match
+			}
+		}
+		fuse(contentRanking, contentWeight)
+	}
+
+	// the frecency only ranks the commands already matching the query.
+	matching := make([]command.Command, 0, len(candidates))
+	for _, c := range candidates {
+		matching = append(matching, c.cmd)
+	}
+	fuse(byFrecency(matching, sources.Usages, sources.Now), frecencyWeight)
+
+	// the words of the name filters are highlighted too.
+	highlighted := tokens
+	for _, f := range q.Filters {
+		if f.Field == NameField && !f.Negated {
+			highlighted = append(highlighted, Tokenize(f.Value)...)
+		}
+	}
+
+	results := make([]command.SearchResult, 0, len(candidates))
+	for _, c := range candidates {
+		results = append(results, command.SearchResult{
+			Command: c.cmd,
+			Score:   c.score,
+			Matches: Highlight(c.cmd.Name, highlighted),
+			Source:  content[c.cmd.ID].Source,
+			Snippet: content[c.cmd.ID].Snippet,
+		})
+	}
+
+	slices.SortFunc(results, func(a, b command.SearchResult) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return strings.Compare(a.Command.Name, b.Command.Name)
+	})
+
+	return results
+}
+
+// Similar returns the commands closest in meaning to the query vector, best first, with their cosine
+// similarity as the score. The commands without an embedding of the model, or with an opposite meaning, are dropped.
+func Similar(cmds []command.Command, embeddings map[uuid.UUID]command.Embedding, query []float32, model string) []command.SearchResult {
+	results := make([]command.SearchResult, 0)
+	for _, cmd := range cmds {
+		embedding, ok := embeddings[cmd.ID]
+		if !ok || embedding.Model != model {
+			continue
+		}
+
+		if score := Cosine(query, embedding.Vector); score > 0 {
+			results = append(results, command.SearchResult{Command: cmd, Score: score})
+		}
+	}
+
+	slices.SortFunc(results, func(a, b command.SearchResult) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return strings.Compare(a.Command.Name, b.Command.Name)
+	})
+
+	return results[:min(len(results), maxSimilar)]
+}
+
+// Cosine returns the cosine similarity of the vectors, from -1 for opposite ones to 1 for the same direction.
+// Zero if they have different lengths or one is zero.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Tokenize splits the query into lowercase words, dropping the symbols.
+func Tokenize(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// Fuzzy returns the commands where every token is close to a word prefix of the name or the command, best first.
+func Fuzzy(cmds []command.Command, tokens []string) []command.Command {
+	if len(tokens) == 0 {
+		return []command.Command{}
+	}
+
+	type match struct {
+		cmd   command.Command
+		score float64
+	}
+
+	matches := make([]match, 0)
+	for _, cmd := range cmds {
+		name, line := words(cmd.Name), words(cmd.Command)
+
+		score := 0.0
+		for _, token := range tokens {
+			t := []rune(token)
+			s := max(nameWeight*similarity(t, name), commandWeight*similarity(t, line))
+			if s == 0 {
+				score = 0
+				break
+			}
+			score += s
+		}
+
+		if score > 0 {
+			matches = append(matches, match{cmd: cmd, score: score})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int {
+		if a.score != b.score {
+			return cmp.Compare(b.score, a.score)
+		}
+		return strings.Compare(a.cmd.Name, b.cmd.Name)
+	})
+
+	out := make([]command.Command, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, m.cmd)
+	}
+	return out
+}
+
+// Highlight returns the indexes of the runes of the text matching the tokens, in order.
+func Highlight(text string, tokens []string) []int {
+	marked := make(map[int]bool)
+	for _, token := range tokens {
+		t := []rune(token)
+		for _, w := range words(text) {
+			n, _, ok := matchPrefix(t, w.runes)
+			if !ok {
+				continue
+			}
+			for i := range n {
+				marked[w.start+i] = true
+			}
+		}
+	}
+
+	matches := make([]int, 0, len(marked))
+	for i := range marked {
+		matches = append(matches, i)
+	}
+	slices.Sort(matches)
+	return matches
+}
+
+// Excerpt returns the text with the words starting with a token between « and », reporting whether
+// every token matched a word.
+func Excerpt(text string, tokens []string) (string, bool) {
+	if len(tokens) == 0 {
+		return "", false
+	}
+
+	runes := []rune(text)
+	marked := make([]bool, len(runes))
+	for _, token := range tokens {
+		t := []rune(token)
+
+		var found bool
+		for _, w := range words(text) {
+			if len(w.runes) < len(t) || !slices.Equal(w.runes[:len(t)], t) {
+				continue
+			}
+			found = true
+			for i := range len(t) {
+				marked[w.start+i] = true
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(markStart)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(markEnd)
+		}
+	}
+	return b.String(), true
+}
+
+// Frecency returns the score of the usages, the recent ones weighting more.
+func Frecency(usages []time.Time, now time.Time) float64 {
+	score := 0.0
+	for _, usage := range usages {
+		switch age := now.Sub(usage); {
+		case age < 4*24*time.Hour:
+			score += 100
+		case age < 14*24*time.Hour:
+			score += 70
+		case age < 31*24*time.Hour:
+			score += 50
+		case age < 90*24*time.Hour:
+			score += 30
+		default:
+			score += 10
+		}
+	}
+	return score
+}
+
+// byFrecency returns the used commands, the highest frecency first.
+func byFrecency(cmds []command.Command, usages map[uuid.UUID][]time.Time, now time.Time) []command.Command {
+	scores := make(map[uuid.UUID]float64, len(cmds))
+	used := make([]command.Command, 0, len(cmds))
+	for _, cmd := range cmds {
+		if score := Frecency(usages[cmd.ID], now); score > 0 {
+			scores[cmd.ID] = score
+			used = append(used, cmd)
+		}
+	}
+
+	slices.SortFunc(used, func(a, b command.Command) int {
+		if scores[a.ID] != scores[b.ID] {
+			return cmp.Compare(scores[b.ID], scores[a.ID])
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return used
+}
+
+// word is a word of a text, with the index of its first rune in the text.
+type word struct {
+	runes []rune
+	start int
+}
+
+// words splits the text into lowercase words.
+func words(text string) []word {
+	var (
+		out     []word
+		current []rune
+	)
+	for i, r := range []rune(text) {
+		if isWordRune(r) {
+			current = append(current, unicode.ToLower(r))
+			continue
+		}
+		if len(current) > 0 {
+			out = append(out, word{runes: current, start: i - len(current)})
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		out = append(out, word{runes: current, start: len([]rune(text)) - len(current)})
+	}
+	return out
+}
+
+// similarity returns how close the token is to the best matching word, 1 for a prefix, 0 if none matches.
+func similarity(token []rune, ws []word) float64 {
+	best := 0.0
+	for _, w := range ws {
+		if _, dist, ok := matchPrefix(token, w.runes); ok {
+			best = max(best, 1-float64(dist)/float64(len(token)+1))
+		}
+	}
+	return best
+}
+
+// matchPrefix returns the length of the word prefix closest to the token and their distance,
+// reporting whether it's within the typos allowed for the token.
+func matchPrefix(token, w []rune) (int, int, bool) {
+	limit := maxTypos(len(token))
+
+	length, best := 0, limit+1
+	for n := max(1, len(token)-limit); n <= min(len(w), len(token)+limit); n++ {
+		// on a tie, the prefix as long as the token is the closest.
+		d := distance(token, w[:n])
+		if d < best || d == best && abs(n-len(token)) < abs(length-len(token)) {
+			length, best = n, d
+		}
+	}
+
+	return length, best, best <= limit
+}
+
+// maxTypos returns the edits allowed for a token of the given length, the short ones must match exactly.
+func maxTypos(length int) int {
+	switch {
+	case length < 3:
+		return 0
+	case length < 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance returns the optimal string alignment distance between a and b: the number of insertions,
+// deletions, substitutions and transpositions of adjacent runes turning a into b.
+func distance(a, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return rows[len(a)][len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
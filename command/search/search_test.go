@@ -0,0 +1,337 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{query: "Git co", expected: []string{"git", "co"}},
+		{query: `kubectl -n "kube-system"`, expected: []string{"kubectl", "n", "kube", "system"}},
+		{query: "name:pods AND ñandú*", expected: []string{"name", "pods", "and", "ñandú"}},
+		{query: ` " - : `, expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got := Tokenize(tt.query)
+			if len(tt.expected) == 0 {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "git", b: "git", expected: 0},
+		{a: "gti", b: "git", expected: 1},
+		{a: "kubctl", b: "kubectl", expected: 1},
+		{a: "docekr", b: "docker", expected: 1},
+		{a: "ca", b: "abc", expected: 3},
+		{a: "", b: "abc", expected: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"-"+tt.b, func(t *testing.T) {
+			assert.Equal(t, tt.expected, distance([]rune(tt.a), []rune(tt.b)))
+		})
+	}
+}
+
+func TestFuzzy(t *testing.T) {
+	squash := command.Command{ID: uuid.New(), Name: "squash", Command: "git reset --soft HEAD~{{.commits}}"}
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods -n {{.namespace}}"}
+	logs := command.Command{ID: uuid.New(), Name: "logs", Command: "kubectl logs -f {{.pod}}"}
+	cmds := []command.Command{squash, pods, logs}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []uuid.UUID
+	}{
+		{name: "prefix", query: "squ", expected: []uuid.UUID{squash.ID}},
+		{name: "transposition", query: "sqausH", expected: []uuid.UUID{squash.ID}},
+		{name: "typo in the command", query: "kubctl", expected: []uuid.UUID{logs.ID, pods.ID}},
+		{name: "name first", query: "kubectl pods", expected: []uuid.UUID{pods.ID, logs.ID}},
+		{name: "short tokens are exact", query: "gi", expected: []uuid.UUID{squash.ID}},
+		{name: "too many typos", query: "sqxxxh", expected: []uuid.UUID{}},
+		{name: "every token", query: "squash kubectl", expected: []uuid.UUID{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ids(Fuzzy(cmds, Tokenize(tt.query))))
+		})
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	squash := command.Command{ID: uuid.New(), Name: "squash", Command: "git reset --soft HEAD~{{.commits}}", Description: "Squash the last commits"}
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods -n {{.namespace}}"}
+	logs := command.Command{ID: uuid.New(), Name: "logs", Command: "kubectl logs -f {{.pod}}", Description: "Follow the logs of the pod"}
+	cmds := []command.Command{squash, pods, logs}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []uuid.UUID
+	}{
+		{name: "prefix", query: "squ", expected: []uuid.UUID{squash.ID}},
+		{name: "case insensitive", query: "KUBE", expected: []uuid.UUID{logs.ID, pods.ID}},
+		{name: "name first", query: "pod", expected: []uuid.UUID{pods.ID, logs.ID}},
+		{name: "description", query: "follow", expected: []uuid.UUID{logs.ID}},
+		{name: "every token", query: "kubectl logs", expected: []uuid.UUID{logs.ID}},
+		{name: "no typos", query: "sqaush", expected: []uuid.UUID{}},
+		{name: "empty", query: "--", expected: []uuid.UUID{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ids(Prefix(cmds, Tokenize(tt.query))))
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected []int
+	}{
+		{name: "prefix", text: "Squash commits", query: "squ", expected: []int{0, 1, 2}},
+		{name: "many words", text: "Squash commits", query: "com sq", expected: []int{0, 1, 7, 8, 9}},
+		{name: "typo", text: "list pods", query: "psod", expected: []int{5, 6, 7}},
+		{name: "unicode", text: "ñandú tail", query: "ñan", expected: []int{0, 1, 2}},
+		{name: "no match", text: "list pods", query: "disk", expected: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Highlight(tt.text, Tokenize(tt.query)))
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected string
+		ok       bool
+	}{
+		{name: "prefix", text: "Namespace of the pods", query: "name", expected: "«Name»space of the pods", ok: true},
+		{name: "many words", text: "kubectl get pods -n web", query: "web pod", expected: "kubectl get «pod»s -n «web»", ok: true},
+		{name: "every occurrence", text: "pods of pods", query: "pods", expected: "«pods» of «pods»", ok: true},
+		{name: "unicode", text: "ñandú tail", query: "ñan", expected: "«ñan»dú tail", ok: true},
+		{name: "every token", text: "kubectl get pods", query: "pods web", ok: false},
+		{name: "no typos", text: "kubectl get pods", query: "psod", ok: false},
+		{name: "no tokens", text: "kubectl get pods", query: " - ", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Excerpt(tt.text, Tokenize(tt.query))
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	days := func(n int) time.Time {
+		return now.Add(-time.Duration(n) * 24 * time.Hour)
+	}
+
+	assert.Zero(t, Frecency(nil, now))
+	assert.Equal(t, 100.0, Frecency([]time.Time{days(1)}, now))
+	assert.Equal(t, 70.0, Frecency([]time.Time{days(60), days(120), days(365), days(100), days(200)}, now))
+	assert.Greater(t,
+		Frecency([]time.Time{days(1), days(2)}, now),
+		Frecency([]time.Time{days(100), days(100), days(100), days(100), days(100), days(100), days(100)}, now),
+		"the recent usages weight more",
+	)
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods"}
+	podsWide := command.Command{ID: uuid.New(), Name: "pods wide", Command: "kubectl get pods -o wide"}
+	deploys := command.Command{ID: uuid.New(), Name: "deploys", Command: "kubectl get deploy"}
+	cmds := []command.Command{pods, podsWide, deploys}
+
+	t.Run("empty query", func(t *testing.T) {
+		assert.Empty(t, Rank(mustParse(t, " - "), Sources{Commands: cmds, Now: now}))
+	})
+
+	t.Run("full-text and fuzzy", func(t *testing.T) {
+		got := Rank(mustParse(t, "pods"), Sources{
+			Indexed:  []command.Command{pods, podsWide},
+			Commands: cmds,
+			Now:      now,
+		})
+		require.Len(t, got, 2)
+		assert.Equal(t, []uuid.UUID{pods.ID, podsWide.ID}, resultIDs(got))
+		assert.Equal(t, []int{0, 1, 2, 3}, got[0].Matches)
+		assert.Greater(t, got[0].Score, got[1].Score)
+	})
+
+	t.Run("typo only matches fuzzily", func(t *testing.T) {
+		got := Rank(mustParse(t, "dpeloys"), Sources{Commands: cmds, Now: now})
+		assert.Equal(t, []uuid.UUID{deploys.ID}, resultIDs(got))
+	})
+
+	t.Run("frecency breaks ties", func(t *testing.T) {
+		got := Rank(mustParse(t, "kubectl"), Sources{
+			Commands: cmds,
+			Usages: map[uuid.UUID][]time.Time{
+				deploys.ID: {now.Add(-time.Hour), now.Add(-2 * time.Hour)},
+				pods.ID:    {now.Add(-200 * 24 * time.Hour)},
+			},
+			Now: now,
+		})
+		assert.Equal(t, []uuid.UUID{deploys.ID, pods.ID, podsWide.ID}, resultIDs(got))
+		assert.Empty(t, got[0].Matches, "the match isn't in the name")
+	})
+
+	t.Run("only filters", func(t *testing.T) {
+		got := Rank(mustParse(t, "name:pods"), Sources{
+			Indexed:  []command.Command{deploys},
+			Commands: []command.Command{pods, podsWide},
+			Usages:   map[uuid.UUID][]time.Time{podsWide.ID: {now}},
+			Now:      now,
+		})
+		assert.Equal(t, []uuid.UUID{podsWide.ID, pods.ID}, resultIDs(got))
+		assert.Equal(t, []int{0, 1, 2, 3}, got[0].Matches, "the name filters are highlighted")
+	})
+
+	t.Run("indexed commands not passing the filters", func(t *testing.T) {
+		got := Rank(mustParse(t, "kubectl tag:k8s"), Sources{
+			Indexed:  []command.Command{deploys, pods},
+			Commands: []command.Command{pods},
+			Now:      now,
+		})
+		assert.Equal(t, []uuid.UUID{pods.ID}, resultIDs(got))
+	})
+
+	t.Run("content", func(t *testing.T) {
+		got := Rank(mustParse(t, "namespace"), Sources{
+			Content: []command.ContentMatch{
+				{Command: podsWide, Source: command.ParameterMatch, Snippet: "«namespace» of the pods"},
+				{Command: pods, Source: command.HistoryMatch, Snippet: "kubectl get pods --«namespace» web"},
+				{Command: podsWide, Source: command.HistoryMatch, Snippet: "kubectl get pods -o wide --«namespace» web"},
+				{Command: deploys, Source: command.ExplanationMatch, Snippet: "lists the deployments of the «namespace»"},
+			},
+			Commands: []command.Command{pods, podsWide},
+			Now:      now,
+		})
+		require.Len(t, got, 2)
+		assert.Equal(t, []uuid.UUID{podsWide.ID, pods.ID}, resultIDs(got))
+		assert.Equal(t, command.ParameterMatch, got[0].Source, "the best match is kept")
+		assert.Equal(t, "«namespace» of the pods", got[0].Snippet)
+		assert.Equal(t, "matched in parameter", got[0].Reason())
+		assert.Equal(t, "matched in history", got[1].Reason())
+	})
+
+	t.Run("content of commands matching in their fields", func(t *testing.T) {
+		got := Rank(mustParse(t, "deploys"), Sources{
+			Content:  []command.ContentMatch{{Command: deploys, Source: command.ExplanationMatch, Snippet: "«deploys»"}},
+			Commands: cmds,
+			Now:      now,
+		})
+		require.Len(t, got, 1)
+		assert.Empty(t, got[0].Reason())
+		assert.Empty(t, got[0].Snippet)
+		assert.Greater(t, got[0].Score, Rank(mustParse(t, "deploys"), Sources{Commands: cmds, Now: now})[0].Score)
+	})
+
+	t.Run("frecency doesn't add commands", func(t *testing.T) {
+		got := Rank(mustParse(t, "deploys"), Sources{
+			Commands: cmds,
+			Usages:   map[uuid.UUID][]time.Time{pods.ID: {now}},
+			Now:      now,
+		})
+		assert.Equal(t, []uuid.UUID{deploys.ID}, resultIDs(got))
+	})
+}
+
+func TestCosine(t *testing.T) {
+	assert.InDelta(t, 1, Cosine([]float32{1, 2, 3}, []float32{2, 4, 6}), 1e-9)
+	assert.InDelta(t, 0, Cosine([]float32{1, 0}, []float32{0, 1}), 1e-9)
+	assert.InDelta(t, -1, Cosine([]float32{1, 1}, []float32{-1, -1}), 1e-9)
+	assert.Zero(t, Cosine([]float32{1, 2}, []float32{1, 2, 3}), "different lengths")
+	assert.Zero(t, Cosine([]float32{0, 0}, []float32{1, 2}), "zero vector")
+}
+
+func TestSimilar(t *testing.T) {
+	disk := command.Command{ID: uuid.New(), Name: "disk", Command: "du -sh *"}
+	clean := command.Command{ID: uuid.New(), Name: "clean docker", Command: "docker system prune"}
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods"}
+	logs := command.Command{ID: uuid.New(), Name: "logs", Command: "kubectl logs {{.pod}}"}
+	cmds := []command.Command{disk, clean, pods, logs}
+
+	embeddings := map[uuid.UUID]command.Embedding{
+		disk.ID:  {Model: "fake", Vector: []float32{1, 0.2, 0}},
+		clean.ID: {Model: "fake", Vector: []float32{0.8, 0.6, 0}},
+		pods.ID:  {Model: "fake", Vector: []float32{-1, 0, 0.1}},
+		logs.ID:  {Model: "other", Vector: []float32{1, 0.2, 0}},
+	}
+
+	got := Similar(cmds, embeddings, []float32{1, 0, 0}, "fake")
+	require.Len(t, got, 2, "the opposite meanings and other models are dropped")
+	assert.Equal(t, []uuid.UUID{disk.ID, clean.ID}, resultIDs(got))
+	assert.InDelta(t, 0.98, got[0].Score, 0.01)
+	assert.Greater(t, got[0].Score, got[1].Score)
+
+	many := make([]command.Command, 0, maxSimilar+5)
+	for range maxSimilar + 5 {
+		cmd := command.Command{ID: uuid.New(), Name: "disk"}
+		embeddings[cmd.ID] = command.Embedding{Model: "fake", Vector: []float32{1, 0, 0}}
+		many = append(many, cmd)
+	}
+	assert.Len(t, Similar(many, embeddings, []float32{1, 0, 0}, "fake"), maxSimilar)
+}
+
+func mustParse(t *testing.T, raw string) Query {
+	t.Helper()
+	q, err := Parse(raw)
+	require.NoError(t, err)
+	return q
+}
+
+func ids(cmds []command.Command) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(cmds))
+	for _, cmd := range cmds {
+		out = append(out, cmd.ID)
+	}
+	return out
+}
+
+func resultIDs(results []command.SearchResult) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(results))
+	for _, r := range results {
+		out = append(out, r.Command.ID)
+	}
+	return out
+}
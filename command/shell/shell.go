@@ -0,0 +1,247 @@
+package shell
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Segment is a simple command of a command line, e.g. each side of a pipe.
+type Segment struct {
+	Program string
+	Args    []string
+}
+
+// wrappers are programs that run the command passed as arguments.
+var wrappers = map[string]struct{}{
+	"sudo":    {},
+	"doas":    {},
+	"env":     {},
+	"time":    {},
+	"nohup":   {},
+	"command": {},
+	"exec":    {},
+	"nice":    {},
+}
+
+// Split splits the command line into its segments.
+// Env assignments and wrapper programs (e.g. sudo) are skipped when resolving the program.
+func Split(raw string) []Segment {
+	segments := make([]Segment, 0)
+	for _, words := range splitOperators(tokenize(raw)) {
+		// skip the env assignments and wrappers
+		idx := 0
+		for idx < len(words) {
+			word := words[idx]
+			if _, ok := wrappers[word]; ok || isAssignment(word) || (idx > 0 && isWrapperFlag(words, idx)) {
+				idx++
+				continue
+			}
+			break
+		}
+
+		if idx >= len(words) {
+			continue
+		}
+
+		segments = append(segments, Segment{
+			Program: words[idx],
+			Args:    words[idx+1:],
+		})
+	}
+
+	return segments
+}
+
+// Words returns the words of the command line, without the quotes and the operators.
+func Words(raw string) []string {
+	words := make([]string, 0)
+	for _, tk := range tokenize(raw) {
+		if !tk.operator {
+			words = append(words, tk.value)
+		}
+	}
+	return words
+}
+
+// Flags returns the flags in the args, e.g. -l or --all.
+func (s Segment) Flags() []string {
+	flags := make([]string, 0)
+	for _, arg := range s.Args {
+		if arg == "--" {
+			break
+		}
+		if IsFlag(arg) {
+			flags = append(flags, arg)
+		}
+	}
+
+	return flags
+}
+
+// IsFlag returns true if the word looks like a flag.
+func IsFlag(word string) bool {
+	return len(word) > 1 && word[0] == '-' && word != "--" && !unicode.IsSpace(rune(word[1]))
+}
+
+// isWrapperFlag returns true if the word is a flag of a wrapper program, e.g. sudo -u.
+func isWrapperFlag(words []string, idx int) bool {
+	if _, ok := wrappers[words[idx-1]]; ok && IsFlag(words[idx]) {
+		return true
+	}
+	return IsFlag(words[idx]) && idx > 1 && isWrapperFlag(words, idx-1)
+}
+
+// Quote returns the word quoted for the shell, as a single argument.
+// The words without special characters are returned as they are.
+func Quote(word string) string {
+	if word == "" {
+		return "''"
+	}
+
+	safe := true
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("@%+=:,./_-", r) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return word
+	}
+
+	// the single quotes can't be escaped inside single quotes, they are closed, escaped and opened again.
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// builtins are the commands and keywords of the shell, run without a program.
+var builtins = map[string]struct{}{
+	"cd": {}, "echo": {}, "printf": {}, "export": {}, "unset": {}, "set": {}, "source": {}, ".": {},
+	"alias": {}, "unalias": {}, "eval": {}, "exit": {}, "return": {}, "read": {}, "test": {}, "[": {},
+	"[[": {}, "true": {}, "false": {}, ":": {}, "pwd": {}, "type": {}, "ulimit": {}, "umask": {}, "wait": {},
+	"trap": {}, "shift": {}, "local": {}, "declare": {}, "readonly": {}, "let": {}, "history": {},
+	"if": {}, "then": {}, "else": {}, "elif": {}, "fi": {}, "for": {}, "while": {}, "until": {},
+	"do": {}, "done": {}, "case": {}, "esac": {}, "in": {}, "function": {}, "{": {}, "}": {}, "!": {},
+}
+
+// Builtin returns true if the program is a builtin or a keyword of the shell, e.g. cd or for.
+func Builtin(program string) bool {
+	_, ok := builtins[program]
+	return ok
+}
+
+// prefixKeywords are the keywords of the shell followed by a command, e.g. do.
+var prefixKeywords = map[string]struct{}{
+	"if": {}, "then": {}, "else": {}, "elif": {}, "do": {}, "while": {}, "until": {}, "!": {}, "{": {},
+}
+
+// Runs returns the program run by the segment, skipping the keywords preceding it, e.g. `do` in `do gzip $f`.
+func (s Segment) Runs() string {
+	words := append([]string{s.Program}, s.Args...)
+	for len(words) > 1 {
+		if _, ok := prefixKeywords[words[0]]; !ok {
+			break
+		}
+		words = words[1:]
+	}
+	return words[0]
+}
+
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	if !ok || name == "" {
+		return false
+	}
+
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// token is a word or an operator (|, ||, &&, ; or &) of the command line.
+type token struct {
+	value    string
+	operator bool
+}
+
+// tokenize splits the command line in words and operators, respecting the quotes.
+func tokenize(raw string) []token {
+	tokens := make([]token, 0)
+
+	var (
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	flush := func() {
+		if inWord {
+			tokens = append(tokens, token{value: word.String()})
+			word.Reset()
+			inWord = false
+		}
+	}
+
+	runes := []rune(raw)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				break
+			}
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			flush()
+		case r == '|' || r == '&' || r == ';':
+			flush()
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == r && r != ';' {
+				op += string(r)
+				i++
+			}
+			tokens = append(tokens, token{value: op, operator: true})
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// splitOperators groups the words between operators.
+func splitOperators(tokens []token) [][]string {
+	groups := make([][]string, 0)
+	words := make([]string, 0)
+	for _, tk := range tokens {
+		if tk.operator {
+			if len(words) > 0 {
+				groups = append(groups, words)
+			}
+			words = make([]string, 0)
+			continue
+		}
+		words = append(words, tk.value)
+	}
+
+	if len(words) > 0 {
+		groups = append(groups, words)
+	}
+
+	return groups
+}
@@ -0,0 +1,120 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected []Segment
+	}{
+		{
+			name:     "empty",
+			raw:      "  ",
+			expected: []Segment{},
+		},
+		{
+			name: "simple command",
+			raw:  "ls -la {{.dir}}",
+			expected: []Segment{
+				{Program: "ls", Args: []string{"-la", "{{.dir}}"}},
+			},
+		},
+		{
+			name: "pipes and operators",
+			raw:  "lsof -t -i:{{.port}} | xargs kill && echo done; git status",
+			expected: []Segment{
+				{Program: "lsof", Args: []string{"-t", "-i:{{.port}}"}},
+				{Program: "xargs", Args: []string{"kill"}},
+				{Program: "echo", Args: []string{"done"}},
+				{Program: "git", Args: []string{"status"}},
+			},
+		},
+		{
+			name: "quotes",
+			raw:  `grep -e "a | b" 'c && d' | wc -l`,
+			expected: []Segment{
+				{Program: "grep", Args: []string{"-e", "a | b", "c && d"}},
+				{Program: "wc", Args: []string{"-l"}},
+			},
+		},
+		{
+			name: "env assignments and wrappers",
+			raw:  "AWS_PROFILE=ops sudo -E env A=1 time pg_dump -Fc db",
+			expected: []Segment{
+				{Program: "pg_dump", Args: []string{"-Fc", "db"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Split(tt.raw), "segments not the expected")
+		})
+	}
+}
+
+func TestSegment_Flags(t *testing.T) {
+	seg := Segment{Program: "tar", Args: []string{"-czf", "out.tgz", "--exclude=.git", "-", "--", "-notflag"}}
+	assert.Equal(t, []string{"-czf", "--exclude=.git"}, seg.Flags(), "flags not the expected")
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     string
+		expected string
+	}{
+		{
+			name:     "empty",
+			expected: "''",
+		},
+		{
+			name:     "safe",
+			word:     "deploy@prod-1.internal:22",
+			expected: "deploy@prod-1.internal:22",
+		},
+		{
+			name:     "spaces and operators",
+			word:     "ls -la | wc -l",
+			expected: "'ls -la | wc -l'",
+		},
+		{
+			name:     "expansions",
+			word:     `echo "$HOME" $(id -u)`,
+			expected: `'echo "$HOME" $(id -u)'`,
+		},
+		{
+			name:     "single quotes",
+			word:     "echo 'done'",
+			expected: `'echo '\''done'\'''`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Quote(tt.word))
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	assert.Equal(t,
+		[]string{"A=1", "B=two words", "ls", "wc", "-l"},
+		Words(`A=1 B="two words" ls | wc -l`))
+}
+
+func TestSegment_Runs(t *testing.T) {
+	segments := Split("if test -f x; then rm x; fi; for f in *.log; do gzip $f; done")
+	runs := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		runs = append(runs, segment.Runs())
+	}
+	assert.Equal(t, []string{"test", "rm", "fi", "for", "gzip", "done"}, runs)
+	assert.True(t, Builtin("cd"))
+	assert.False(t, Builtin("git"))
+}
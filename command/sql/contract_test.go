@@ -0,0 +1,357 @@
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/search"
+)
+
+// testStoreContract checks the behavior every backend must have, against a real database.
+func testStoreContract(t *testing.T, store *Sql) {
+	ctx := context.Background()
+
+	newCmd := func(name, cmd, desc string, params ...string) command.Command {
+		c := command.Command{
+			ID:          uuid.New(),
+			Name:        name,
+			Description: desc,
+			Command:     cmd,
+		}
+		for _, p := range params {
+			c.Params = append(c.Params, command.Parameter{
+				ID:           uuid.New(),
+				Name:         p,
+				Description:  p + " description",
+				DefaultValue: p + " default",
+			})
+		}
+		return c
+	}
+
+	squash := newCmd("squash", "git reset --soft HEAD~{{.commits}} && git commit", "Squash the last commits", "commits")
+	pods := newCmd("pods", "kubectl get pods -n {{.namespace}} -l {{.label}}", "List the pods", "namespace", "label")
+	disk := newCmd("disk", "du -sh * | sort -h", "Disk usage of the current directory")
+	pods.Tags = []string{"k8s", "prod"}
+	disk.Tags = []string{"fs"}
+	pods.Target = "ssh:bastion"
+	pods.Env = []command.EnvVar{{Name: "KUBECONFIG", Value: "~/.kube/{{.namespace}}"}, {Name: "NO_COLOR", Value: "1"}}
+	pods.Dir = "~/k8s"
+	pods.Requires = []string{"jq", "kubectl"}
+
+	t.Run("save and get", func(t *testing.T) {
+		for _, cmd := range []command.Command{squash, pods, disk} {
+			require.NoError(t, store.Save(ctx, cmd))
+		}
+
+		got, err := store.GetCommandByID(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Equal(t, pods.ID, got.ID)
+		assert.Equal(t, pods.Command, got.Command)
+		assert.ElementsMatch(t, pods.Params, got.Params)
+		assert.Equal(t, pods.Tags, got.Tags)
+		assert.Equal(t, pods.Target, got.Target)
+		assert.Equal(t, pods.Env, got.Env)
+		assert.Equal(t, pods.Dir, got.Dir)
+		assert.Equal(t, pods.Requires, got.Requires)
+
+		got, err = store.GetCommandByID(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got.Params)
+		assert.Empty(t, got.Target)
+		assert.Empty(t, got.Env)
+		assert.Empty(t, got.Dir)
+		assert.Empty(t, got.Requires)
+
+		_, err = store.GetCommandByID(ctx, uuid.New())
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		got, err := store.ListCommands(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []uuid.UUID{squash.ID, pods.ID, disk.ID}, ids(got))
+
+		// the availability of the programs is checked with the listed commands.
+		for _, cmd := range got {
+			if cmd.ID == pods.ID {
+				assert.Equal(t, pods.Target, cmd.Target)
+				assert.Equal(t, pods.Requires, cmd.Requires)
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated := pods
+		updated.Command = "kubectl get pods -n {{.namespace}} -o wide"
+		updated.Params = []command.Parameter{pods.Params[0]}
+		updated.Params[0].DefaultValue = "kube-system"
+
+		require.NoError(t, store.Save(ctx, updated))
+		require.NoError(t, store.DeleteParameters(ctx, []uuid.UUID{pods.Params[1].ID}))
+
+		got, err := store.GetCommandByID(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated.Command, got.Command)
+		assert.Equal(t, updated.Params, got.Params)
+
+		// the search index follows the updates.
+		found, err := store.SearchCommand(ctx, "wide")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, ids(found))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		tests := []struct {
+			term     string
+			expected []uuid.UUID
+		}{
+			{term: "squash", expected: []uuid.UUID{squash.ID}},
+			{term: "kube", expected: []uuid.UUID{pods.ID}},
+			{term: "Disk", expected: []uuid.UUID{disk.ID}},
+			{term: "missing", expected: []uuid.UUID{}},
+			{term: "kube-system", expected: []uuid.UUID{}},
+			{term: `"squash`, expected: []uuid.UUID{squash.ID}},
+			{term: "get -n", expected: []uuid.UUID{pods.ID}},
+			{term: "name:pods", expected: []uuid.UUID{pods.ID}},
+			{term: "pods AND", expected: []uuid.UUID{}},
+			{term: ` " : `, expected: []uuid.UUID{}},
+		}
+
+		for _, tt := range tests {
+			got, err := store.SearchCommand(ctx, tt.term)
+			require.NoError(t, err, tt.term)
+			assert.ElementsMatch(t, tt.expected, ids(got), tt.term)
+		}
+	})
+
+	t.Run("notebook", func(t *testing.T) {
+		_, err := store.ReadExplanation(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		require.NoError(t, store.WriteTranscript(ctx, squash.ID, "transcript"))
+		_, err = store.ReadExplanation(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound, "an entry with only the transcript has no explanation")
+
+		expl := command.Explanation{
+			Content: "explanation",
+			Signature: command.ExplanationSignature{
+				CommandHash:   squash.Hash(),
+				Source:        "fake",
+				PromptVersion: "1",
+				Template:      "default",
+			},
+		}
+		require.NoError(t, store.WriteExplanation(ctx, squash.ID, expl))
+
+		got, err := store.ReadExplanation(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expl, got)
+
+		transcript, err := store.ReadTranscript(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "transcript", transcript, "writing the explanation keeps the transcript")
+
+		unindexed, err := store.UnindexedExplanations(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{squash.ID}, unindexed)
+
+		require.NoError(t, store.IndexExplanation(ctx, squash.ID, "merges the commits into a single one"))
+		matches, err := store.SearchContent(ctx, "single")
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.Equal(t, squash.ID, matches[0].Command.ID)
+		assert.Equal(t, command.ExplanationMatch, matches[0].Source)
+
+		require.NoError(t, store.WriteExplanation(ctx, squash.ID, expl))
+		matches, err = store.SearchContent(ctx, "single")
+		require.NoError(t, err)
+		assert.Empty(t, matches, "the indexed explanation is dropped once the explanation changes")
+
+		require.NoError(t, store.IndexExplanation(ctx, squash.ID, "merges the commits into a single one"))
+		require.NoError(t, store.DeleteExplanation(ctx, squash.ID))
+		matches, err = store.SearchContent(ctx, "single")
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+
+		_, err = store.ReadTranscript(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{Command: "du -sh * | sort -h"}))
+		require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{
+			Command: "du -sh * | sort -hr",
+			Target:  "docker:api",
+			Result:  &command.RunResult{ExitCode: 1, Duration: 1500 * time.Millisecond},
+		}))
+
+		got, err := store.GetHistory(ctx, disk.ID)
+		require.NoError(t, err)
+		require.Len(t, got.Usages, 2)
+		results := map[string]*command.RunResult{}
+		targets := map[string]string{}
+		for _, usage := range got.Usages {
+			results[usage.Command] = usage.Result
+			targets[usage.Command] = usage.Target
+		}
+		assert.Equal(t, map[string]*command.RunResult{
+			"du -sh * | sort -h":  nil,
+			"du -sh * | sort -hr": {ExitCode: 1, Duration: 1500 * time.Millisecond},
+		}, results, "only the runs have a result")
+		assert.Equal(t, map[string]string{
+			"du -sh * | sort -h":  "",
+			"du -sh * | sort -hr": "docker:api",
+		}, targets)
+		assert.False(t, got.Usages[0].Timestamp.IsZero())
+
+		usages, err := store.ListUsages(ctx)
+		require.NoError(t, err)
+		assert.Len(t, usages, 1)
+		assert.Len(t, usages[disk.ID], 2)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		tests := []struct {
+			query    string
+			expected []uuid.UUID
+		}{
+			{query: "name:pod", expected: []uuid.UUID{pods.ID}},
+			{query: "-name:pods", expected: []uuid.UUID{squash.ID, disk.ID}},
+			{query: "cmd:kubectl", expected: []uuid.UUID{pods.ID}},
+			{query: "cmd:git -tag:k8s", expected: []uuid.UUID{squash.ID}},
+			{query: "tag:k8s", expected: []uuid.UUID{pods.ID}},
+			{query: "-tag:prod", expected: []uuid.UUID{squash.ID, disk.ID}},
+			{query: "param:Namespace", expected: []uuid.UUID{pods.ID}},
+			{query: "used:<1d", expected: []uuid.UUID{disk.ID}},
+			{query: "used:>1d", expected: []uuid.UUID{squash.ID, pods.ID}},
+			{query: "tag:k8s used:<1d", expected: []uuid.UUID{}},
+		}
+
+		for _, tt := range tests {
+			q, err := search.Parse(tt.query)
+			require.NoError(t, err, tt.query)
+
+			got, err := store.FilterCommands(ctx, q)
+			require.NoError(t, err, tt.query)
+			assert.ElementsMatch(t, tt.expected, ids(got), tt.query)
+		}
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		got, err := store.ListRevisions(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+
+		older := squash
+		older.Command = "git reset --soft HEAD~{{.commits}}"
+		require.NoError(t, store.InsertRevision(ctx, older))
+		require.NoError(t, store.InsertRevision(ctx, squash))
+
+		got, err = store.ListRevisions(ctx, squash.ID)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, squash, got[0].Command, "the newest first")
+		assert.Equal(t, older, got[1].Command)
+		assert.Greater(t, got[0].ID, got[1].ID)
+		assert.False(t, got[0].CreatedAt.IsZero())
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		require.NoError(t, store.TrashCommand(ctx, disk.ID))
+		assert.ErrorIs(t, store.TrashCommand(ctx, disk.ID), ErrNotFound, "already in the trash")
+
+		_, err := store.GetCommandByID(ctx, disk.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		all, err := store.ListCommands(ctx)
+		require.NoError(t, err)
+		assert.NotContains(t, ids(all), disk.ID)
+
+		found, err := store.SearchCommand(ctx, "disk")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		trash, err := store.ListTrash(ctx)
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, disk.ID, trash[0].Command.ID)
+		assert.Equal(t, disk.Command, trash[0].Command.Command)
+		assert.False(t, trash[0].DeletedAt.IsZero())
+
+		require.NoError(t, store.RestoreCommand(ctx, disk.ID))
+		assert.ErrorIs(t, store.RestoreCommand(ctx, disk.ID), ErrNotFound, "not in the trash")
+
+		got, err := store.GetCommandByID(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Equal(t, disk.Name, got.Name)
+
+		history, err := store.GetHistory(ctx, disk.ID)
+		require.NoError(t, err)
+		assert.Len(t, history.Usages, 2, "the history is kept in the trash")
+
+		trash, err = store.ListTrash(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+	})
+
+	t.Run("embeddings", func(t *testing.T) {
+		embedding := command.Embedding{CommandID: squash.ID, Model: "fake", Hash: squash.EmbeddingHash(), Vector: []float32{0.5, -1, 0}}
+		require.NoError(t, store.WriteEmbedding(ctx, embedding))
+
+		embeddings, err := store.ListEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[uuid.UUID]command.Embedding{squash.ID: embedding}, embeddings)
+
+		// the new embedding replaces the previous one.
+		embedding.Vector = []float32{1, 2, 3}
+		require.NoError(t, store.WriteEmbedding(ctx, embedding))
+
+		embeddings, err = store.ListEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[uuid.UUID]command.Embedding{squash.ID: embedding}, embeddings)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.InsertUsage(ctx, squash.ID, command.Usage{Command: "git reset --soft HEAD~2 && git commit"}))
+		require.NoError(t, store.TrashCommand(ctx, squash.ID))
+		require.NoError(t, store.DeleteCommand(ctx, squash.ID))
+
+		_, err := store.GetCommandByID(ctx, squash.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		found, err := store.SearchCommand(ctx, "squash")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+
+		trash, err := store.ListTrash(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		history, err := store.GetHistory(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Empty(t, history.Usages, "the history is removed with the command")
+
+		revisions, err := store.ListRevisions(ctx, squash.ID)
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		embeddings, err := store.ListEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, embeddings, "the embedding is removed with the command")
+	})
+}
+
+func ids(cmds []command.Command) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(cmds))
+	for _, cmd := range cmds {
+		out = append(out, cmd.ID)
+	}
+	return out
+}
@@ -0,0 +1,376 @@
+package sql
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lian-rr/clio/command/sql/postgres"
+	"github.com/lian-rr/clio/command/sql/sqlite"
+)
+
+// Dialect holds the queries and the behavior specific to a database.
+type Dialect struct {
+	Name string
+	// Schema creates the tables, indexes and triggers, in order. It must be idempotent.
+	Schema []string
+	// Migrations are applied on top of the schema, in order.
+	Migrations []string
+
+	SchemaVersionQuery          string
+	SetSchemaVersionQuery       string
+	UpsertCommandQuery          string
+	UpsertParameterPartialQuery string
+	GetAllCommandsQuery         string
+	GetCommandbyIDQuery         string
+	GetParametersByCommandID    string
+	SearchCommandQuery          string
+	SubstringSearchCommandQuery string
+	DeleteCommandQuery          string
+	TrashCommandQuery           string
+	RestoreCommandQuery         string
+	GetTrashQuery               string
+	DeleteParametersQuery       string
+	UpsertExplanationQuery      string
+	GetExplanationByCommandID   string
+	DeleteExplanationQuery      string
+	UpsertTranscriptQuery       string
+	GetTranscriptByCommandID    string
+	InsertUsageQuery            string
+	GetHistoryForCommand        string
+	GetUsagesQuery              string
+	InsertRevisionQuery         string
+	GetRevisionsForCommand      string
+	GetKeyringQuery             string
+	UpsertKeyringQuery          string
+	GetEncryptedPartialQuery    string
+	UpdateEncryptedPartialQuery string
+	DeleteCommandTagsQuery      string
+	InsertTagsPartialQuery      string
+	GetTagsByCommandID          string
+	UpsertEmbeddingQuery        string
+	GetEmbeddingsQuery          string
+
+	// content search, see SearchContent. Not supported by the database without queries.
+	SearchContentQuery            string
+	InsertExplanationContentQuery string
+	DeleteExplanationContentQuery string
+	UnindexedExplanationsQuery    string
+
+	// filters of the search queries, see FilterCommands.
+	FilterCommandsPartialQuery string
+	MatchFilterPartialQuery    string
+	TagFilterPartialQuery      string
+	ParamFilterPartialQuery    string
+	UsedFilterPartialQuery     string
+
+	// maintenance, the checks without query aren't supported by the database.
+	IntegrityCheckQuery        string
+	GetUnindexedCommandsQuery  string
+	GetStaleSearchEntriesQuery string
+	CountOrphansPartialQuery   string
+	DeleteOrphansPartialQuery  string
+	// RebuildSearchQueries rebuild the search index from the commands.
+	RebuildSearchQueries []string
+
+	// CompactQueries drop the content left on disk by the deleted and updated rows.
+	CompactQueries []string
+	// DeleteCommandDataQueries remove what belongs to a command before removing it,
+	// the foreign keys aren't enforced by every database.
+	DeleteCommandDataQueries []string
+
+	// SearchTerm converts the term typed by the user into the search query argument.
+	SearchTerm func(term string) string
+	// ColumnSearchTerm converts the term into the search query argument matching only the column.
+	ColumnSearchTerm func(column, term string) string
+	// SubstringSearchTerm converts the term into the substring search query argument, reporting whether
+	// the term is matched as substrings too. Nil when the database has no substring index.
+	SubstringSearchTerm func(term string) (string, bool)
+	// Placeholder returns the placeholder of the nth (1-based) argument of a query. Nil uses ?.
+	Placeholder func(n int) string
+}
+
+// SqliteDialect is the dialect for SQLite, using FTS5 for the search.
+var SqliteDialect = Dialect{
+	Name: "sqlite",
+	Schema: []string{
+		sqlite.CommandTableQuery,
+		sqlite.ParametersTableQuery,
+		sqlite.SearchTableQuery,
+		sqlite.InsertCommandFtsTrigger,
+		sqlite.UpdateCommandFtsTrigger,
+		sqlite.DeleteCommandFtsTrigger,
+		sqlite.SubstringSearchTableQuery,
+		sqlite.InsertCommandTrigramTrigger,
+		sqlite.UpdateCommandTrigramTrigger,
+		sqlite.DeleteCommandTrigramTrigger,
+		sqlite.NotebookTableQuery,
+		sqlite.HistoryTableQuery,
+		sqlite.RevisionsTableQuery,
+		sqlite.KeyringTableQuery,
+		sqlite.TagsTableQuery,
+		sqlite.EmbeddingsTableQuery,
+		sqlite.ContentSearchTableQuery,
+		sqlite.InsertParameterContentTrigger,
+		sqlite.UpdateParameterContentTrigger,
+		sqlite.DeleteParameterContentTrigger,
+		sqlite.InsertUsageContentTrigger,
+		sqlite.UpdateUsageContentTrigger,
+		sqlite.DeleteUsageContentTrigger,
+		sqlite.UpdateExplanationContentTrigger,
+		sqlite.DeleteExplanationContentTrigger,
+	},
+	Migrations:                  sqlite.Migrations,
+	SchemaVersionQuery:          sqlite.SchemaVersionQuery,
+	SetSchemaVersionQuery:       sqlite.SetSchemaVersionQuery,
+	UpsertCommandQuery:          sqlite.UpsertCommandQuery,
+	UpsertParameterPartialQuery: sqlite.UpsertParameterPartialQuery,
+	GetAllCommandsQuery:         sqlite.GetAllCommandsQuery,
+	GetCommandbyIDQuery:         sqlite.GetCommandbyIDQuery,
+	GetParametersByCommandID:    sqlite.GetParametersByCommandID,
+	SearchCommandQuery:          sqlite.SearchCommandQuery,
+	SubstringSearchCommandQuery: sqlite.SubstringSearchCommandQuery,
+	DeleteCommandQuery:          sqlite.DeleteCommandQuery,
+	TrashCommandQuery:           sqlite.TrashCommandQuery,
+	RestoreCommandQuery:         sqlite.RestoreCommandQuery,
+	GetTrashQuery:               sqlite.GetTrashQuery,
+	DeleteParametersQuery:       sqlite.DeleteParametersPartialQuery,
+	UpsertExplanationQuery:      sqlite.UpsertExplanationQuery,
+	GetExplanationByCommandID:   sqlite.GetExplanationByCommandID,
+	DeleteExplanationQuery:      sqlite.DeleteExplanationQuery,
+	UpsertTranscriptQuery:       sqlite.UpsertTranscriptQuery,
+	GetTranscriptByCommandID:    sqlite.GetTranscriptByCommandID,
+	InsertUsageQuery:            sqlite.InsertUsageQuery,
+	GetHistoryForCommand:        sqlite.GetHistoryForCommand,
+	GetUsagesQuery:              sqlite.GetUsagesQuery,
+	InsertRevisionQuery:         sqlite.InsertRevisionQuery,
+	GetRevisionsForCommand:      sqlite.GetRevisionsForCommand,
+	GetKeyringQuery:             sqlite.GetKeyringQuery,
+	UpsertKeyringQuery:          sqlite.UpsertKeyringQuery,
+	GetEncryptedPartialQuery:    sqlite.GetEncryptedPartialQuery,
+	UpdateEncryptedPartialQuery: sqlite.UpdateEncryptedPartialQuery,
+	DeleteCommandTagsQuery:      sqlite.DeleteCommandTagsQuery,
+	InsertTagsPartialQuery:      sqlite.InsertTagsPartialQuery,
+	GetTagsByCommandID:          sqlite.GetTagsByCommandID,
+	UpsertEmbeddingQuery:        sqlite.UpsertEmbeddingQuery,
+	GetEmbeddingsQuery:          sqlite.GetEmbeddingsQuery,
+	FilterCommandsPartialQuery:  sqlite.FilterCommandsPartialQuery,
+	MatchFilterPartialQuery:     sqlite.MatchFilterPartialQuery,
+	TagFilterPartialQuery:       sqlite.TagFilterPartialQuery,
+	ParamFilterPartialQuery:     sqlite.ParamFilterPartialQuery,
+	UsedFilterPartialQuery:      sqlite.UsedFilterPartialQuery,
+	IntegrityCheckQuery:         sqlite.IntegrityCheckQuery,
+	GetUnindexedCommandsQuery:   sqlite.GetUnindexedCommandsQuery,
+	GetStaleSearchEntriesQuery:  sqlite.GetStaleSearchEntriesQuery,
+	CountOrphansPartialQuery:    sqlite.CountOrphansPartialQuery,
+	DeleteOrphansPartialQuery:   sqlite.DeleteOrphansPartialQuery,
+
+	SearchContentQuery:            sqlite.SearchContentQuery,
+	InsertExplanationContentQuery: sqlite.InsertExplanationContentQuery,
+	DeleteExplanationContentQuery: sqlite.DeleteExplanationContentQuery,
+	UnindexedExplanationsQuery:    sqlite.UnindexedExplanationsQuery,
+
+	CompactQueries: sqlite.CompactQueries,
+	RebuildSearchQueries: []string{
+		sqlite.ClearSearchIndexQuery,
+		sqlite.FillSearchIndexQuery,
+		sqlite.ClearSubstringSearchIndexQuery,
+		sqlite.FillSubstringSearchIndexQuery,
+		sqlite.ClearContentSearchIndexQuery,
+		sqlite.FillContentSearchIndexQuery,
+	},
+	DeleteCommandDataQueries: []string{
+		sqlite.DeleteCommandParametersQuery,
+		sqlite.DeleteExplanationQuery,
+		sqlite.DeleteCommandHistoryQuery,
+		sqlite.DeleteCommandRevisionsQuery,
+		sqlite.DeleteCommandTagsQuery,
+		sqlite.DeleteCommandEmbeddingQuery,
+	},
+	SearchTerm:          ftsQuery,
+	ColumnSearchTerm:    ftsColumnQuery,
+	SubstringSearchTerm: trigramQuery,
+}
+
+// PostgresDialect is the dialect for Postgres, using tsvector for the search.
+var PostgresDialect = Dialect{
+	Name: "postgres",
+	Schema: []string{
+		postgres.SchemaVersionTableQuery,
+		postgres.CommandTableQuery,
+		postgres.SearchIndexQuery,
+		postgres.ParametersTableQuery,
+		postgres.NotebookTableQuery,
+		postgres.HistoryTableQuery,
+		postgres.RevisionsTableQuery,
+		postgres.KeyringTableQuery,
+		postgres.TagsTableQuery,
+		postgres.EmbeddingsTableQuery,
+		postgres.ExplanationContentTableQuery,
+	},
+	Migrations:                  postgres.Migrations,
+	SchemaVersionQuery:          postgres.SchemaVersionQuery,
+	SetSchemaVersionQuery:       postgres.SetSchemaVersionQuery,
+	UpsertCommandQuery:          postgres.UpsertCommandQuery,
+	UpsertParameterPartialQuery: postgres.UpsertParameterPartialQuery,
+	GetAllCommandsQuery:         postgres.GetAllCommandsQuery,
+	GetCommandbyIDQuery:         postgres.GetCommandbyIDQuery,
+	GetParametersByCommandID:    postgres.GetParametersByCommandID,
+	SearchCommandQuery:          postgres.SearchCommandQuery,
+	DeleteCommandQuery:          postgres.DeleteCommandQuery,
+	TrashCommandQuery:           postgres.TrashCommandQuery,
+	RestoreCommandQuery:         postgres.RestoreCommandQuery,
+	GetTrashQuery:               postgres.GetTrashQuery,
+	DeleteParametersQuery:       postgres.DeleteParametersPartialQuery,
+	UpsertExplanationQuery:      postgres.UpsertExplanationQuery,
+	GetExplanationByCommandID:   postgres.GetExplanationByCommandID,
+	DeleteExplanationQuery:      postgres.DeleteExplanationQuery,
+	UpsertTranscriptQuery:       postgres.UpsertTranscriptQuery,
+	GetTranscriptByCommandID:    postgres.GetTranscriptByCommandID,
+	InsertUsageQuery:            postgres.InsertUsageQuery,
+	GetHistoryForCommand:        postgres.GetHistoryForCommand,
+	GetUsagesQuery:              postgres.GetUsagesQuery,
+	InsertRevisionQuery:         postgres.InsertRevisionQuery,
+	GetRevisionsForCommand:      postgres.GetRevisionsForCommand,
+	GetKeyringQuery:             postgres.GetKeyringQuery,
+	UpsertKeyringQuery:          postgres.UpsertKeyringQuery,
+	GetEncryptedPartialQuery:    postgres.GetEncryptedPartialQuery,
+	UpdateEncryptedPartialQuery: postgres.UpdateEncryptedPartialQuery,
+	DeleteCommandTagsQuery:      postgres.DeleteCommandTagsQuery,
+	InsertTagsPartialQuery:      postgres.InsertTagsPartialQuery,
+	GetTagsByCommandID:          postgres.GetTagsByCommandID,
+	UpsertEmbeddingQuery:        postgres.UpsertEmbeddingQuery,
+	GetEmbeddingsQuery:          postgres.GetEmbeddingsQuery,
+	FilterCommandsPartialQuery:  postgres.FilterCommandsPartialQuery,
+	MatchFilterPartialQuery:     postgres.MatchFilterPartialQuery,
+	TagFilterPartialQuery:       postgres.TagFilterPartialQuery,
+	ParamFilterPartialQuery:     postgres.ParamFilterPartialQuery,
+	UsedFilterPartialQuery:      postgres.UsedFilterPartialQuery,
+	CountOrphansPartialQuery:    postgres.CountOrphansPartialQuery,
+	DeleteOrphansPartialQuery:   postgres.DeleteOrphansPartialQuery,
+
+	SearchContentQuery:            postgres.SearchContentQuery,
+	InsertExplanationContentQuery: postgres.InsertExplanationContentQuery,
+	DeleteExplanationContentQuery: postgres.DeleteExplanationContentQuery,
+	UnindexedExplanationsQuery:    postgres.UnindexedExplanationsQuery,
+
+	CompactQueries: postgres.CompactQueries,
+	DeleteCommandDataQueries: []string{
+		postgres.DeleteCommandParametersQuery,
+		postgres.DeleteExplanationQuery,
+		postgres.DeleteCommandHistoryQuery,
+		postgres.DeleteCommandRevisionsQuery,
+		postgres.DeleteCommandTagsQuery,
+		postgres.DeleteCommandEmbeddingQuery,
+	},
+	SearchTerm:       tsQuery,
+	ColumnSearchTerm: tsColumnQuery,
+	Placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+}
+
+// placeholder returns the placeholder of the nth (1-based) argument of a query.
+func (d Dialect) placeholder(n int) string {
+	if d.Placeholder == nil {
+		return "?"
+	}
+	return d.Placeholder(n)
+}
+
+// placeholders returns the placeholders for count arguments starting from the nth one, e.g. (?, ?, ?).
+func (d Dialect) placeholders(n, count int) string {
+	args := make([]string, 0, count)
+	for i := n; i < n+count; i++ {
+		args = append(args, d.placeholder(i))
+	}
+
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+// tsQuery converts the term into a prefix tsquery matching all the words, e.g. "git co" => "git:* & co:*".
+func tsQuery(term string) string {
+	words := strings.FieldsFunc(term, func(r rune) bool {
+		return !isWordRune(r)
+	})
+
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// tsColumnQuery converts the term into a prefix tsquery matching all the words in the column,
+// using the weight the column has in the search index, e.g. "git co" => "git:*A & co:*A" for the name.
+func tsColumnQuery(column, term string) string {
+	weight := map[string]string{"name": "A", "command": "B", "description": "C"}[column]
+
+	words := strings.FieldsFunc(term, func(r rune) bool {
+		return !isWordRune(r)
+	})
+
+	for i, word := range words {
+		words[i] = word + ":*" + weight
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// ftsQuery converts the term into a FTS5 query matching the prefixes of all the words, e.g. "git co" => `"git"* "co"*`.
+// The words are quoted, so the symbols and keywords of the FTS5 syntax are matched as text.
+func ftsQuery(term string) string {
+	words := strings.FieldsFunc(term, func(r rune) bool {
+		return !isWordRune(r)
+	})
+
+	for i, word := range words {
+		words[i] = `"` + word + `"*`
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ftsColumnQuery converts the term into a FTS5 query matching the prefixes of all the words in the column,
+// e.g. "git co" => `name : ("git"* "co"*)`.
+func ftsColumnQuery(column, term string) string {
+	return column + " : (" + ftsQuery(term) + ")"
+}
+
+// maxShortTerm is the length of the longest piece of a term matched as a substring,
+// the longer ones are matched by the word prefixes.
+const maxShortTerm = 5
+
+// trigramQuery converts the term into a FTS5 query for the trigram index, matching every piece of the term
+// as a substring, e.g. "ctl --no-pag" => `"ctl" "--no-pag"`. Only the short pieces or the ones with symbols
+// are worth a substring search, and the trigrams need pieces of at least 3 runes.
+func trigramQuery(term string) (string, bool) {
+	pieces := strings.FieldsFunc(term, func(r rune) bool {
+		return r == '"' || unicode.IsSpace(r)
+	})
+	if len(pieces) == 0 {
+		return "", false
+	}
+
+	var substring bool
+	for i, piece := range pieces {
+		n := utf8.RuneCountInString(piece)
+		if n < 3 {
+			return "", false
+		}
+
+		symbols := strings.ContainsFunc(piece, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		substring = substring || symbols || n <= maxShortTerm
+		pieces[i] = `"` + piece + `"`
+	}
+
+	return strings.Join(pieces, " "), substring
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '-' || r == '.' ||
+		('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r > 127
+}
@@ -0,0 +1,123 @@
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect_placeholders(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		n        int
+		count    int
+		expected string
+	}{
+		{
+			name:     "sqlite",
+			dialect:  SqliteDialect,
+			n:        6,
+			count:    3,
+			expected: "(?, ?, ?)",
+		},
+		{
+			name:     "postgres",
+			dialect:  PostgresDialect,
+			n:        6,
+			count:    3,
+			expected: "($6, $7, $8)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dialect.placeholders(tt.n, tt.count))
+		})
+	}
+}
+
+func TestTsQuery(t *testing.T) {
+	tests := []struct {
+		term     string
+		expected string
+	}{
+		{term: "git", expected: "git:*"},
+		{term: "git  co", expected: "git:* & co:*"},
+		{term: "--no-pager", expected: "--no-pager:*"},
+		{term: "a:b & (c) | !d'", expected: "a:* & b:* & c:* & d:*"},
+		{term: "  ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tsQuery(tt.term))
+		})
+	}
+}
+
+func TestFtsQuery(t *testing.T) {
+	tests := []struct {
+		term     string
+		expected string
+	}{
+		{term: "git", expected: `"git"*`},
+		{term: "git  co", expected: `"git"* "co"*`},
+		{term: "kube-system", expected: `"kube-system"*`},
+		{term: `name:pods AND "x" NEAR(y)`, expected: `"name"* "pods"* "AND"* "x"* "NEAR"* "y"*`},
+		{term: ` " : `, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ftsQuery(tt.term))
+		})
+	}
+}
+
+func TestColumnSearchTerm(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		column   string
+		term     string
+		expected string
+	}{
+		{name: "sqlite name", dialect: SqliteDialect, column: "name", term: "git co", expected: `name : ("git"* "co"*)`},
+		{name: "sqlite command", dialect: SqliteDialect, column: "command", term: "kubectl", expected: `command : ("kubectl"*)`},
+		{name: "postgres name", dialect: PostgresDialect, column: "name", term: "git co", expected: "git:*A & co:*A"},
+		{name: "postgres command", dialect: PostgresDialect, column: "command", term: "kubectl", expected: "kubectl:*B"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.dialect.ColumnSearchTerm(tt.column, tt.term))
+		})
+	}
+}
+
+func TestTrigramQuery(t *testing.T) {
+	tests := []struct {
+		term      string
+		expected  string
+		substring bool
+	}{
+		{term: "ctl", expected: `"ctl"`, substring: true},
+		{term: "ctl --no-pag", expected: `"ctl" "--no-pag"`, substring: true},
+		{term: `"ñandú`, expected: `"ñandú"`, substring: true},
+		{term: "HEAD~", expected: `"HEAD~"`, substring: true},
+		{term: "kubectl", expected: `"kubectl"`, substring: false},
+		{term: "kubectl -n", substring: false},
+		{term: "  ", substring: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			got, substring := trigramQuery(tt.term)
+			assert.Equal(t, tt.substring, substring)
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ErrUnsupported used when the operation isn't supported by the database.
+var ErrUnsupported = errors.New("not supported by the store")
+
+// orphanTables are the tables with rows belonging to a command.
+var orphanTables = []string{"parameters", "notebook", "history", "revisions", "tags", "embeddings"}
+
+// CheckReport holds the problems found in the store.
+type CheckReport struct {
+	// Integrity holds the problems reported by the database.
+	Integrity []string
+	// UnindexedCommands is the number of commands missing or outdated in the search index.
+	UnindexedCommands int
+	// StaleSearchEntries is the number of search entries of commands that no longer exist.
+	StaleSearchEntries int
+	// Orphans is the number of rows without a command, by table.
+	Orphans map[string]int
+}
+
+// OK reports whether the store has no problems.
+func (r CheckReport) OK() bool {
+	if len(r.Integrity) > 0 || r.UnindexedCommands > 0 || r.StaleSearchEntries > 0 {
+		return false
+	}
+	for _, n := range r.Orphans {
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Check looks for corruption, a search index out of sync with the commands and orphaned rows.
+func (s *Sql) Check(ctx context.Context) (CheckReport, error) {
+	queries := s.queries()
+	report := CheckReport{
+		Orphans: make(map[string]int, len(orphanTables)),
+	}
+
+	if queries.IntegrityCheckQuery != "" {
+		problems, err := s.integrityCheck(ctx)
+		if err != nil {
+			return CheckReport{}, err
+		}
+		report.Integrity = problems
+	}
+
+	counts := []struct {
+		query string
+		dest  *int
+	}{
+		{query: queries.GetUnindexedCommandsQuery, dest: &report.UnindexedCommands},
+		{query: queries.GetStaleSearchEntriesQuery, dest: &report.StaleSearchEntries},
+	}
+	for _, count := range counts {
+		if count.query == "" {
+			continue
+		}
+		if err := s.db.QueryRowContext(ctx, count.query).Scan(count.dest); err != nil {
+			return CheckReport{}, fmt.Errorf("error checking the search index: %w", err)
+		}
+	}
+
+	for _, table := range orphanTables {
+		var n int
+		if err := s.db.QueryRowContext(ctx, fmt.Sprintf(queries.CountOrphansPartialQuery, table)).Scan(&n); err != nil {
+			return CheckReport{}, fmt.Errorf("error checking the orphaned %s: %w", table, err)
+		}
+		report.Orphans[table] = n
+	}
+
+	return report, nil
+}
+
+// Repair rebuilds the search index and removes the orphaned rows.
+func (s *Sql) Repair(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.logger.Warn("error rolling back repair", slog.Any("error", err))
+		}
+	}()
+
+	queries := s.queries()
+	for _, query := range queries.RebuildSearchQueries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error rebuilding the search index: %w", err)
+		}
+	}
+
+	for _, table := range orphanTables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(queries.DeleteOrphansPartialQuery, table)); err != nil {
+			return fmt.Errorf("error removing the orphaned %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commiting transaction: %w", err)
+	}
+	return nil
+}
+
+// Backup writes a snapshot of the store into a new database file, while the store is in use. Only for SQLite.
+func (s *Sql) Backup(ctx context.Context, path string) error {
+	if s.queries().Name != SqliteDialect.Name {
+		return ErrUnsupported
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("error backing up the store: %q already exists", path)
+	}
+
+	dest, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("error opening the backup: %w", err)
+	}
+	defer dest.Close()
+
+	if err := copyDatabase(ctx, dest, s.db); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("error backing up the store: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the content of the store with the one of the backup, after checking its integrity. Only for SQLite.
+func (s *Sql) Restore(ctx context.Context, path string) error {
+	if s.queries().Name != SqliteDialect.Name {
+		return ErrUnsupported
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error opening the backup: %w", err)
+	}
+
+	src, err := sql.Open("sqlite3", path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("error opening the backup: %w", err)
+	}
+	defer src.Close()
+
+	backup := &Sql{db: src, dialect: s.dialect, logger: s.logger}
+	problems, err := backup.integrityCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking the backup: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the backup is corrupted: %v", problems)
+	}
+
+	if err := copyDatabase(ctx, s.db, src); err != nil {
+		return fmt.Errorf("error restoring the store: %w", err)
+	}
+	return nil
+}
+
+// integrityCheck returns the problems reported by the database, empty when it's ok.
+func (s *Sql) integrityCheck(ctx context.Context) ([]string, error) {
+	if s.queries().IntegrityCheckQuery == "" {
+		return nil, ErrUnsupported
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.queries().IntegrityCheckQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error checking the integrity: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, fmt.Errorf("error checking the integrity: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+
+	return problems, rows.Err()
+}
+
+// copyDatabase copies the src database into the dest one with the SQLite online backup API.
+func copyDatabase(ctx context.Context, dest, src *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			destSqlite, ok := destDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrUnsupported
+			}
+			srcSqlite, ok := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok {
+				return ErrUnsupported
+			}
+
+			backup, err := destSqlite.Backup("main", srcSqlite, "main")
+			if err != nil {
+				return err
+			}
+
+			if _, err := backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
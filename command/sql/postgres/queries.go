@@ -0,0 +1,469 @@
+package postgres
+
+// table creation
+const (
+	SchemaVersionTableQuery = `
+	CREATE TABLE IF NOT EXISTS clio_schema (
+		id INTEGER PRIMARY KEY,
+		version INTEGER NOT NULL
+	)`
+
+	CommandTableQuery = `
+	CREATE TABLE IF NOT EXISTS commands (
+		id VARCHAR(36) PRIMARY KEY,
+		name VARCHAR(64) NOT NULL,
+		description TEXT,
+		command TEXT NOT NULL,
+		search TSVECTOR GENERATED ALWAYS AS (` + searchVector + `) STORED
+	)`
+
+	// the encrypted commands are indexed without their template, the tokens of the ciphertext
+	// would match random queries.
+	searchVector = `
+			setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('simple', CASE WHEN command LIKE 'enc:v1:%' THEN '' ELSE command END), 'B') ||
+			setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+		`
+
+	SearchIndexQuery = `
+	CREATE INDEX IF NOT EXISTS commands_search_idx
+	ON commands USING GIN (search)`
+
+	ParametersTableQuery = `
+	CREATE TABLE IF NOT EXISTS parameters (
+		id VARCHAR(36) PRIMARY KEY,
+		command VARCHAR(36),
+		name VARCHAR(64) NOT NULL,
+		description TEXT,
+		value TEXT,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	NotebookTableQuery = `
+	CREATE TABLE IF NOT EXISTS notebook (
+		command VARCHAR(36) PRIMARY KEY,
+		explanation TEXT,
+		transcript TEXT,
+		command_hash VARCHAR(64),
+		source VARCHAR(64),
+		prompt_version VARCHAR(32),
+		template VARCHAR(32),
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	HistoryTableQuery = `
+	CREATE TABLE IF NOT EXISTS history (
+		id BIGSERIAL PRIMARY KEY,
+		command VARCHAR(36),
+		usage TEXT,
+		created_by TIMESTAMP,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	RevisionsTableQuery = `
+	CREATE TABLE IF NOT EXISTS revisions (
+		id BIGSERIAL PRIMARY KEY,
+		command VARCHAR(36),
+		name VARCHAR(64) NOT NULL,
+		description TEXT,
+		template TEXT NOT NULL,
+		params TEXT,
+		created_at TIMESTAMP,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	KeyringTableQuery = `
+	CREATE TABLE IF NOT EXISTS keyring (
+		id INTEGER PRIMARY KEY,
+		salt VARCHAR(64) NOT NULL,
+		verifier TEXT NOT NULL
+	)`
+
+	TagsTableQuery = `
+	CREATE TABLE IF NOT EXISTS tags (
+		command VARCHAR(36),
+		tag VARCHAR(64) NOT NULL,
+
+		PRIMARY KEY (command, tag),
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	EmbeddingsTableQuery = `
+	CREATE TABLE IF NOT EXISTS embeddings (
+		command VARCHAR(36) PRIMARY KEY,
+		model VARCHAR(128) NOT NULL,
+		hash VARCHAR(64) NOT NULL,
+		vector TEXT NOT NULL,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	// ExplanationContentTableQuery holds the plaintext of the explanations for the content search.
+	ExplanationContentTableQuery = `
+	CREATE TABLE IF NOT EXISTS explanation_content (
+		command VARCHAR(36) PRIMARY KEY,
+		content TEXT NOT NULL,
+
+		CONSTRAINT fk_notebook
+			FOREIGN KEY (command)
+			REFERENCES notebook(command)
+			ON DELETE CASCADE
+	)`
+)
+
+// migrations
+const (
+	SchemaVersionQuery = `SELECT COALESCE(MAX(version), 0) FROM clio_schema`
+
+	SetSchemaVersionQuery = `
+	INSERT INTO clio_schema(id, version) VALUES (1, %d)
+	ON CONFLICT (id) DO UPDATE SET version = excluded.version`
+
+	AddCommandDeletedAtMigration = `ALTER TABLE commands ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`
+
+	AddHistoryRunResultMigration = `
+	ALTER TABLE history
+		ADD COLUMN IF NOT EXISTS exit_code INTEGER,
+		ADD COLUMN IF NOT EXISTS duration_ms BIGINT`
+	// AddCommandTargetMigration adds the default execution target of the commands.
+	AddCommandTargetMigration = `ALTER TABLE commands ADD COLUMN IF NOT EXISTS target VARCHAR(255)`
+	// AddHistoryTargetMigration adds the execution target of the usages.
+	AddHistoryTargetMigration = `ALTER TABLE history ADD COLUMN IF NOT EXISTS target VARCHAR(255)`
+	// AddCommandEnvMigration adds the env vars of the commands, encoded as JSON.
+	AddCommandEnvMigration = `ALTER TABLE commands ADD COLUMN IF NOT EXISTS env TEXT`
+	// AddCommandDirMigration adds the working directory of the commands.
+	AddCommandDirMigration = `ALTER TABLE commands ADD COLUMN IF NOT EXISTS dir VARCHAR(255)`
+	// AddCommandRequiresMigration adds the programs required by the commands, separated by commas.
+	AddCommandRequiresMigration = `ALTER TABLE commands ADD COLUMN IF NOT EXISTS requires TEXT`
+	// AddClearExplanationContentFunctionMigration adds the function dropping the indexed explanation once the
+	// explanation changes, e.g. when it's encrypted. The store indexes the new one.
+	AddClearExplanationContentFunctionMigration = `
+	CREATE OR REPLACE FUNCTION clear_explanation_content() RETURNS TRIGGER AS $$
+	BEGIN
+		DELETE FROM explanation_content WHERE command = NEW.command;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`
+	// AddClearExplanationContentTriggerMigration runs clear_explanation_content on every explanation update.
+	AddClearExplanationContentTriggerMigration = `
+	CREATE TRIGGER clear_explanation_content_trigger
+		AFTER UPDATE OF explanation ON notebook
+		FOR EACH ROW EXECUTE FUNCTION clear_explanation_content()`
+	// WidenCommandDirMigration makes room for the encrypted working directories.
+	WidenCommandDirMigration = `ALTER TABLE commands ALTER COLUMN dir TYPE TEXT`
+
+	// the search vector indexed the encrypted templates, it's generated again without them.
+	// Dropping the column drops its index too, created again by SearchIndexQuery.
+	DropCommandSearchMigration = `ALTER TABLE commands DROP COLUMN IF EXISTS search`
+
+	AddCommandSearchMigration = `
+	ALTER TABLE commands
+		ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (` + searchVector + `) STORED`
+	// WidenCommandTargetMigration makes room for the encrypted targets of the commands.
+	WidenCommandTargetMigration = `ALTER TABLE commands ALTER COLUMN target TYPE TEXT`
+	// WidenHistoryTargetMigration makes room for the encrypted targets of the usages.
+	WidenHistoryTargetMigration = `ALTER TABLE history ALTER COLUMN target TYPE TEXT`
+)
+
+// Migrations holds the schema changes applied on top of the tables, in order.
+// The number of applied migrations is stored as the schema version.
+var Migrations = []string{
+	AddCommandDeletedAtMigration,
+	AddHistoryRunResultMigration,
+	AddCommandTargetMigration,
+	AddHistoryTargetMigration,
+	AddCommandEnvMigration,
+	AddCommandDirMigration,
+	AddCommandRequiresMigration,
+	AddClearExplanationContentFunctionMigration,
+	AddClearExplanationContentTriggerMigration,
+	WidenCommandDirMigration,
+	DropCommandSearchMigration,
+	AddCommandSearchMigration,
+	SearchIndexQuery,
+	WidenCommandTargetMigration,
+	WidenHistoryTargetMigration,
+}
+
+// CompactQueries rewrite the tables without the content left by the deleted and updated rows.
+var CompactQueries = []string{
+	`VACUUM FULL commands, parameters, notebook, explanation_content, history, revisions`,
+}
+
+// queries
+const (
+	UpsertCommandQuery = `
+	INSERT INTO
+		commands(id, name, description, command, target, env, dir, requires)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (id)
+	DO
+		UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			command = excluded.command,
+			target = excluded.target,
+			env = excluded.env,
+			dir = excluded.dir,
+			requires = excluded.requires,
+			deleted_at = NULL`
+
+	UpsertParameterPartialQuery = `
+	INSERT INTO
+		parameters(id, command, name, description, value)
+	VALUES %s
+	ON CONFLICT (id)
+	DO
+		UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			value = excluded.value`
+
+	GetAllCommandsQuery = `
+	SELECT id, name, description, command, COALESCE(target, ''), COALESCE(requires, '')
+	FROM commands
+	WHERE deleted_at IS NULL`
+
+	GetCommandbyIDQuery = `
+	SELECT
+		id, name, description, command, COALESCE(target, ''), env, COALESCE(dir, ''), COALESCE(requires, '')
+	FROM commands
+	WHERE id = $1 AND deleted_at IS NULL`
+
+	GetParametersByCommandID = `
+	SELECT
+		id, name, description, value
+	FROM parameters
+	WHERE command = $1`
+
+	SearchCommandQuery = `
+	SELECT
+		c.id, c.name, c.description, c.command
+	FROM commands c, to_tsquery('simple', $1) query
+	WHERE c.search @@ query AND c.deleted_at IS NULL
+	ORDER BY ts_rank(c.search, query) DESC`
+
+	// the content is matched without an index, the explanations are matched by their plaintext
+	// written by the store, as the notebook keeps them compressed.
+	SearchContentQuery = `
+	SELECT
+		c.id, c.name, c.description, c.command, m.source,
+		ts_headline('simple', m.content, query, 'StartSel=«, StopSel=», MaxWords=12, MinWords=4')
+	FROM commands c
+	INNER JOIN (
+		SELECT command, 'parameter' AS source, name || ' ' || COALESCE(description, '') AS content
+		FROM parameters
+		UNION ALL
+		SELECT command, 'history' AS source, usage AS content
+		FROM history
+		WHERE usage NOT LIKE 'enc:v1:%'
+		UNION ALL
+		SELECT command, 'explanation' AS source, content
+		FROM explanation_content
+	) m
+		ON c.id = m.command,
+		to_tsquery('simple', $1) query
+	WHERE to_tsvector('simple', m.content) @@ query AND c.deleted_at IS NULL
+	ORDER BY ts_rank(to_tsvector('simple', m.content), query) DESC`
+
+	InsertExplanationContentQuery = `INSERT INTO explanation_content(command, content) VALUES ($1, $2)`
+
+	DeleteExplanationContentQuery = `DELETE FROM explanation_content WHERE command = $1`
+
+	UnindexedExplanationsQuery = `
+	SELECT command
+	FROM notebook
+	WHERE explanation IS NOT NULL
+		AND explanation NOT LIKE 'enc:v1:%'
+		AND command NOT IN (SELECT command FROM explanation_content)`
+
+	DeleteCommandQuery = `DELETE FROM commands WHERE id = $1`
+
+	DeleteCommandParametersQuery = `DELETE FROM parameters WHERE command = $1`
+
+	DeleteCommandHistoryQuery = `DELETE FROM history WHERE command = $1`
+
+	DeleteCommandRevisionsQuery = `DELETE FROM revisions WHERE command = $1`
+
+	DeleteCommandTagsQuery = `DELETE FROM tags WHERE command = $1`
+
+	InsertTagsPartialQuery = `INSERT INTO tags(command, tag) VALUES %s`
+
+	UpsertEmbeddingQuery = `
+	INSERT INTO
+		embeddings(command, model, hash, vector)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (command)
+	DO
+		UPDATE SET
+			model = excluded.model,
+			hash = excluded.hash,
+			vector = excluded.vector`
+
+	GetEmbeddingsQuery = `
+	SELECT
+		e.command, e.model, e.hash, e.vector
+	FROM embeddings e
+	INNER JOIN commands c
+		ON c.id = e.command
+	WHERE c.deleted_at IS NULL`
+
+	DeleteCommandEmbeddingQuery = `DELETE FROM embeddings WHERE command = $1`
+
+	GetTagsByCommandID = `
+	SELECT tag
+	FROM tags
+	WHERE command = $1
+	ORDER BY tag`
+
+	TrashCommandQuery = `
+	UPDATE commands
+	SET deleted_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND deleted_at IS NULL`
+
+	RestoreCommandQuery = `
+	UPDATE commands
+	SET deleted_at = NULL
+	WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	GetTrashQuery = `
+	SELECT id, name, description, command, deleted_at
+	FROM commands
+	WHERE deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC`
+
+	DeleteParametersPartialQuery = `DELETE FROM parameters WHERE id IN (%s)`
+
+	UpsertExplanationQuery = `
+	INSERT INTO
+		notebook(command, explanation, command_hash, source, prompt_version, template)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (command)
+	DO
+		UPDATE SET
+			explanation = excluded.explanation,
+			command_hash = excluded.command_hash,
+			source = excluded.source,
+			prompt_version = excluded.prompt_version,
+			template = excluded.template`
+
+	GetExplanationByCommandID = `
+	SELECT
+		command, explanation, command_hash, source, prompt_version, template
+	FROM notebook
+	WHERE command = $1`
+
+	DeleteExplanationQuery = `DELETE FROM notebook WHERE command = $1`
+
+	UpsertTranscriptQuery = `
+	INSERT INTO
+		notebook(command, transcript)
+	VALUES ($1, $2)
+	ON CONFLICT (command)
+	DO
+		UPDATE SET
+			transcript = excluded.transcript`
+
+	GetTranscriptByCommandID = `
+	SELECT
+		transcript
+	FROM notebook
+	WHERE command = $1`
+
+	InsertUsageQuery = `
+	INSERT INTO
+		history(command, usage, target, exit_code, duration_ms, created_by)
+	VALUES($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)`
+
+	GetHistoryForCommand = `
+	SELECT
+		usage, created_by, COALESCE(target, ''), exit_code, duration_ms
+	FROM history
+	WHERE command = $1`
+
+	GetUsagesQuery = `
+	SELECT
+		h.command, h.created_by
+	FROM history h
+	INNER JOIN commands c
+		ON c.id = h.command
+	WHERE c.deleted_at IS NULL`
+
+	InsertRevisionQuery = `
+	INSERT INTO
+		revisions(command, name, description, template, params, created_at)
+	VALUES($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)`
+
+	GetRevisionsForCommand = `
+	SELECT
+		id, name, description, template, params, created_at
+	FROM revisions
+	WHERE command = $1
+	ORDER BY id DESC`
+
+	GetKeyringQuery = `SELECT salt, verifier FROM keyring WHERE id = 1`
+
+	UpsertKeyringQuery = `
+	INSERT INTO
+		keyring(id, salt, verifier)
+	VALUES (1, $1, $2)
+	ON CONFLICT (id)
+	DO
+		UPDATE SET
+			salt = excluded.salt,
+			verifier = excluded.verifier`
+
+	GetEncryptedPartialQuery = `SELECT %[2]s, %[3]s FROM %[1]s WHERE %[3]s IS NOT NULL`
+
+	UpdateEncryptedPartialQuery = `UPDATE %[1]s SET %[3]s = $1 WHERE %[2]s = $2`
+)
+
+// filters, the conditions are added with the IN or NOT IN operator and the placeholder of the argument.
+const (
+	FilterCommandsPartialQuery = `
+	SELECT c.id, c.name, c.description, c.command
+	FROM commands c
+	WHERE c.deleted_at IS NULL%s`
+
+	MatchFilterPartialQuery = `c.id %[1]s (SELECT id FROM commands WHERE search @@ to_tsquery('simple', %[2]s))`
+
+	TagFilterPartialQuery = `c.id %[1]s (SELECT command FROM tags WHERE tag = %[2]s)`
+
+	ParamFilterPartialQuery = `c.id %[1]s (SELECT command FROM parameters WHERE LOWER(name) = %[2]s)`
+
+	UsedFilterPartialQuery = `c.id %[1]s (SELECT command FROM history WHERE created_by >= %[2]s)`
+)
+
+// maintenance
+const (
+	CountOrphansPartialQuery = `
+	SELECT COUNT(*)
+	FROM %s
+	WHERE command IS NULL OR command NOT IN (SELECT id FROM commands)`
+
+	DeleteOrphansPartialQuery = `
+	DELETE FROM %s
+	WHERE command IS NULL OR command NOT IN (SELECT id FROM commands)`
+)
@@ -3,28 +3,88 @@ package sql
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/lian-rr/clio/command"
-	"github.com/lian-rr/clio/command/sql/sqlite"
+	"github.com/lian-rr/clio/command/crypt"
+	"github.com/lian-rr/clio/command/search"
 )
 
-// ErrNotFound used when the searched element wasn't found.
-var ErrNotFound = errors.New("not found")
+var (
+	// ErrNotFound used when the searched element wasn't found.
+	ErrNotFound = command.ErrNotFound
+	// ErrEncrypted used when opening an encrypted store without the passphrase.
+	ErrEncrypted = errors.New("the store is encrypted, missing passphrase")
+	// ErrInvalidPassphrase used when the passphrase isn't the one the store was encrypted with.
+	ErrInvalidPassphrase = errors.New("invalid passphrase")
+)
+
+// timestampFormat is the format of the timestamps compared with the ones stored by the database.
+const timestampFormat = "2006-01-02 15:04:05"
+
+// keyringCheck is encrypted in the keyring for checking the passphrase.
+const keyringCheck = "clio"
+
+// encryptedColumns are the columns with sensitive values, encrypted when the store has a passphrase.
+// The key is the primary key of the table.
+var encryptedColumns = []struct {
+	table  string
+	key    string
+	column string
+}{
+	{table: "commands", key: "id", column: "command"},
+	{table: "commands", key: "id", column: "env"},
+	{table: "commands", key: "id", column: "dir"},
+	{table: "commands", key: "id", column: "target"},
+	{table: "parameters", key: "id", column: "value"},
+	{table: "notebook", key: "command", column: "explanation"},
+	{table: "notebook", key: "command", column: "transcript"},
+	{table: "history", key: "id", column: "usage"},
+	{table: "history", key: "id", column: "target"},
+	{table: "revisions", key: "id", column: "template"},
+	{table: "revisions", key: "id", column: "params"},
+	{table: "embeddings", key: "command", column: "vector"},
+}
+
+// revisionParam is a parameter stored in the revision of a command.
+type revisionParam struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Default     string    `json:"default"`
+}
+
+// envVar is an env var of a command, stored as JSON.
+type envVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
 
 // SqlOptFunc optional functions for Sql store.
 type SqlOptFunc func(store *Sql) error
 
 // Sql store
 type Sql struct {
-	db     *sql.DB
-	logger *slog.Logger
+	db      *sql.DB
+	dialect *Dialect
+	logger  *slog.Logger
+	// cipher encrypts the sensitive values, nil when the store isn't encrypted.
+	cipher *crypt.Cipher
+	// encrypted is set when the store has a keyring, it can't be used without the passphrase.
+	encrypted bool
 }
 
 // NewSql returns a new SQL store.
@@ -43,6 +103,11 @@ func NewSql(logger *slog.Logger, opts ...SqlOptFunc) (*Sql, error) {
 		return nil, errors.New("missing db connection")
 	}
 
+	if store.encrypted && store.cipher == nil {
+		_ = store.db.Close()
+		return nil, ErrEncrypted
+	}
+
 	return store, nil
 }
 
@@ -61,7 +126,17 @@ func (s *Sql) Save(ctx context.Context, cmd command.Command) error {
 		}
 	}()
 
-	_, err = tx.ExecContext(ctx, sqlite.UpsertCommandQuery, cmd.ID.String(), cmd.Name, cmd.Description, cmd.Command)
+	template, dir, execTarget := cmd.Command, cmd.Dir, cmd.Target
+	if err := s.seal(&template, &dir, &execTarget); err != nil {
+		return err
+	}
+
+	env, err := s.encodeEnv(cmd.Env)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.queries().UpsertCommandQuery, cmd.ID.String(), cmd.Name, cmd.Description, template, execTarget, env, dir, strings.Join(cmd.Requires, ","))
 	if err != nil {
 		return fmt.Errorf("error storing command: %w", err)
 	}
@@ -71,17 +146,42 @@ func (s *Sql) Save(ctx context.Context, cmd command.Command) error {
 		args := make([]any, 0, len(cmd.Params)*5) // cap: number of params * attrs to store
 
 		for _, param := range cmd.Params {
-			placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
-			args = append(args, param.ID.String(), cmd.ID.String(), param.Name, param.Description, param.DefaultValue)
+			value := param.DefaultValue
+			if err := s.seal(&value); err != nil {
+				return err
+			}
+
+			placeholders = append(placeholders, s.queries().placeholders(len(args)+1, 5))
+			args = append(args, param.ID.String(), cmd.ID.String(), param.Name, param.Description, value)
 		}
 
-		paramsQuery := fmt.Sprintf(sqlite.UpsertParameterPartialQuery, strings.Join(placeholders, ","))
+		paramsQuery := fmt.Sprintf(s.queries().UpsertParameterPartialQuery, strings.Join(placeholders, ","))
 		_, err = tx.ExecContext(ctx, paramsQuery, args...)
 		if err != nil {
 			return fmt.Errorf("error storing parameters: %w", err)
 		}
 	}
 
+	_, err = tx.ExecContext(ctx, s.queries().DeleteCommandTagsQuery, cmd.ID.String())
+	if err != nil {
+		return fmt.Errorf("error storing tags: %w", err)
+	}
+
+	if len(cmd.Tags) > 0 {
+		placeholders := make([]string, 0, len(cmd.Tags))
+		args := make([]any, 0, len(cmd.Tags)*2)
+		for _, tag := range cmd.Tags {
+			placeholders = append(placeholders, s.queries().placeholders(len(args)+1, 2))
+			args = append(args, cmd.ID.String(), tag)
+		}
+
+		tagsQuery := fmt.Sprintf(s.queries().InsertTagsPartialQuery, strings.Join(placeholders, ","))
+		_, err = tx.ExecContext(ctx, tagsQuery, args...)
+		if err != nil {
+			return fmt.Errorf("error storing tags: %w", err)
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error commiting transaction: %w", err)
 	}
@@ -92,7 +192,7 @@ func (s *Sql) Save(ctx context.Context, cmd command.Command) error {
 
 // ListCommands returns a list of all the commands
 func (s *Sql) ListCommands(ctx context.Context) ([]command.Command, error) {
-	rows, err := s.db.QueryContext(ctx, sqlite.GetAllCommandsQuery)
+	rows, err := s.db.QueryContext(ctx, s.queries().GetAllCommandsQuery)
 	if err != nil {
 		return nil, err
 	}
@@ -105,10 +205,17 @@ func (s *Sql) ListCommands(ctx context.Context) ([]command.Command, error) {
 
 	cmds := make([]command.Command, 0)
 	for rows.Next() {
-		var cmd command.Command
-		if err := rows.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command); err != nil {
+		var (
+			cmd      command.Command
+			requires string
+		)
+		if err := rows.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command, &cmd.Target, &requires); err != nil {
+			return nil, err
+		}
+		if err := s.open(&cmd.Command, &cmd.Target); err != nil {
 			return nil, err
 		}
+		cmd.Requires = command.ParseRequires(requires)
 
 		cmds = append(cmds, cmd)
 	}
@@ -118,16 +225,29 @@ func (s *Sql) ListCommands(ctx context.Context) ([]command.Command, error) {
 
 // GetCommandByID returns a command. If the command doesn't exists, returns an ErrNotFound error.
 func (s *Sql) GetCommandByID(ctx context.Context, id uuid.UUID) (command.Command, error) {
-	row := s.db.QueryRowContext(ctx, sqlite.GetCommandbyIDQuery, id.String())
-	var cmd command.Command
-	if err := row.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command); err != nil {
+	row := s.db.QueryRowContext(ctx, s.queries().GetCommandbyIDQuery, id.String())
+	var (
+		cmd      command.Command
+		env      sql.NullString
+		requires string
+	)
+	if err := row.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command, &cmd.Target, &env, &cmd.Dir, &requires); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return command.Command{}, ErrNotFound
 		}
 		return command.Command{}, err
 	}
+	if err := s.open(&cmd.Command, &cmd.Dir, &cmd.Target); err != nil {
+		return command.Command{}, err
+	}
+	decoded, err := s.decodeEnv(env)
+	if err != nil {
+		return command.Command{}, err
+	}
+	cmd.Env = decoded
+	cmd.Requires = command.ParseRequires(requires)
 
-	rows, err := s.db.QueryContext(ctx, sqlite.GetParametersByCommandID, id.String())
+	rows, err := s.db.QueryContext(ctx, s.queries().GetParametersByCommandID, id.String())
 	if err != nil {
 		return command.Command{}, err
 	}
@@ -143,17 +263,128 @@ func (s *Sql) GetCommandByID(ctx context.Context, id uuid.UUID) (command.Command
 		if err := rows.Scan(&param.ID, &param.Name, &param.Description, &param.DefaultValue); err != nil {
 			return command.Command{}, err
 		}
+		if err := s.open(&param.DefaultValue); err != nil {
+			return command.Command{}, err
+		}
 
 		params = append(params, param)
 	}
 
 	cmd.Params = params
+
+	tags, err := s.getTags(ctx, id)
+	if err != nil {
+		return command.Command{}, err
+	}
+	cmd.Tags = tags
+
 	return cmd, nil
 }
 
-// SearchCommand returns a list of the commands with the matching term.
+// encodeEnv returns the env vars encoded as JSON and encrypted, null if there's none.
+func (s *Sql) encodeEnv(env []command.EnvVar) (sql.NullString, error) {
+	if len(env) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	vars := make([]envVar, 0, len(env))
+	for _, v := range env {
+		vars = append(vars, envVar(v))
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("error encoding env: %w", err)
+	}
+
+	encoded := string(data)
+	if err := s.seal(&encoded); err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: encoded, Valid: true}, nil
+}
+
+// decodeEnv returns the env vars stored by encodeEnv.
+func (s *Sql) decodeEnv(raw sql.NullString) ([]command.EnvVar, error) {
+	if !raw.Valid || raw.String == "" {
+		return nil, nil
+	}
+	if err := s.open(&raw.String); err != nil {
+		return nil, err
+	}
+
+	var vars []envVar
+	if err := json.Unmarshal([]byte(raw.String), &vars); err != nil {
+		return nil, fmt.Errorf("error decoding env: %w", err)
+	}
+
+	env := make([]command.EnvVar, 0, len(vars))
+	for _, v := range vars {
+		env = append(env, command.EnvVar(v))
+	}
+	return env, nil
+}
+
+// getTags returns the tags of the command, sorted.
+func (s *Sql) getTags(ctx context.Context, id uuid.UUID) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries().GetTagsByCommandID, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// SearchCommand returns a list of the commands with the matching term, best first.
+// The short terms and the ones with symbols are matched as substrings too, after the word matches,
+// e.g. ctl in kubectl or pager in --no-pager.
 func (s *Sql) SearchCommand(ctx context.Context, term string) ([]command.Command, error) {
-	rows, err := s.db.QueryContext(ctx, sqlite.SearchCommandQuery, fmt.Sprintf("%s*", term))
+	cmds := make([]command.Command, 0)
+	if query := s.queries().SearchTerm(term); query != "" {
+		found, err := s.search(ctx, s.queries().SearchCommandQuery, query)
+		if err != nil {
+			return nil, err
+		}
+		cmds = found
+	}
+
+	if s.queries().SubstringSearchTerm == nil {
+		return cmds, nil
+	}
+	query, ok := s.queries().SubstringSearchTerm(term)
+	if !ok {
+		return cmds, nil
+	}
+
+	found, err := s.search(ctx, s.queries().SubstringSearchCommandQuery, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range found {
+		if !slices.ContainsFunc(cmds, func(c command.Command) bool { return c.ID == cmd.ID }) {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return cmds, nil
+}
+
+// search returns the commands matching the argument of the search query.
+func (s *Sql) search(ctx context.Context, searchQuery, query string) ([]command.Command, error) {
+	rows, err := s.db.QueryContext(ctx, searchQuery, query)
 	if err != nil {
 		return nil, err
 	}
@@ -169,6 +400,9 @@ func (s *Sql) SearchCommand(ctx context.Context, term string) ([]command.Command
 		if err := rows.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command); err != nil {
 			return nil, err
 		}
+		if err := s.open(&cmd.Command); err != nil {
+			return nil, err
+		}
 
 		cmds = append(cmds, cmd)
 	}
@@ -176,31 +410,242 @@ func (s *Sql) SearchCommand(ctx context.Context, term string) ([]command.Command
 	return cmds, nil
 }
 
-// DeleteCommand removes a command and it's params.
+// SearchContent returns the commands whose parameters, explanation or history match the term, best first,
+// with an excerpt of the best match of each. The encrypted content isn't searched.
+func (s *Sql) SearchContent(ctx context.Context, term string) ([]command.ContentMatch, error) {
+	matches := make([]command.ContentMatch, 0)
+	query := s.queries().SearchTerm(term)
+	if s.queries().SearchContentQuery == "" || query == "" {
+		return matches, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.queries().SearchContentQuery, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	for rows.Next() {
+		var match command.ContentMatch
+		if err := rows.Scan(
+			&match.Command.ID,
+			&match.Command.Name,
+			&match.Command.Description,
+			&match.Command.Command,
+			&match.Source,
+			&match.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(matches, func(m command.ContentMatch) bool { return m.Command.ID == match.Command.ID }) {
+			continue
+		}
+		if err := s.open(&match.Command.Command); err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// FilterCommands returns the commands passing the filters of the query, the terms aren't matched.
+func (s *Sql) FilterCommands(ctx context.Context, q search.Query) ([]command.Command, error) {
+	var (
+		conditions []string
+		args       []any
+		// inGo are the filters over the encrypted templates, matched after decrypting them.
+		inGo []search.Filter
+	)
+
+	condition := func(partial string, negated bool, arg any) {
+		op := "IN"
+		if negated {
+			op = "NOT IN"
+		}
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(partial, op, s.queries().placeholder(len(args))))
+	}
+
+	for _, f := range q.Filters {
+		switch f.Field {
+		case search.NameField:
+			condition(s.queries().MatchFilterPartialQuery, f.Negated, s.queries().ColumnSearchTerm("name", f.Value))
+		case search.CommandField:
+			if s.cipher != nil {
+				inGo = append(inGo, f)
+				continue
+			}
+			condition(s.queries().MatchFilterPartialQuery, f.Negated, s.queries().ColumnSearchTerm("command", f.Value))
+		case search.TagField:
+			condition(s.queries().TagFilterPartialQuery, f.Negated, f.Value)
+		case search.ParamField:
+			condition(s.queries().ParamFilterPartialQuery, f.Negated, f.Value)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, u := range q.Usage {
+		condition(s.queries().UsedFilterPartialQuery, !u.Within, now.Add(-u.Period).Format(timestampFormat))
+	}
+
+	var where string
+	if len(conditions) > 0 {
+		where = "\n\tAND " + strings.Join(conditions, "\n\tAND ")
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(s.queries().FilterCommandsPartialQuery, where), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	cmds := make([]command.Command, 0)
+	for rows.Next() {
+		var cmd command.Command
+		if err := rows.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command); err != nil {
+			return nil, err
+		}
+		if err := s.open(&cmd.Command); err != nil {
+			return nil, err
+		}
+
+		passes := true
+		for _, f := range inGo {
+			passes = passes && f.Match(cmd)
+		}
+		if passes {
+			cmds = append(cmds, cmd)
+		}
+	}
+
+	return cmds, rows.Err()
+}
+
+// DeleteCommand removes permanently a command, its params, notebook, history and revisions.
 func (s *Sql) DeleteCommand(ctx context.Context, id uuid.UUID) error {
-	_, err := s.db.ExecContext(ctx, sqlite.DeleteCommandQuery, id.String())
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.logger.Warn("error rolling back delete command", slog.Any("error", err))
+		}
+	}()
+
+	queries := s.queries()
+	for _, query := range append(slices.Clip(queries.DeleteCommandDataQueries), queries.DeleteCommandQuery) {
+		if _, err := tx.ExecContext(ctx, query, id.String()); err != nil {
+			return fmt.Errorf("error removing command with ID %q: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commiting transaction: %w", err)
+	}
+	return nil
+}
+
+// TrashCommand moves a command to the trash. If the command doesn't exists, returns an ErrNotFound error.
+func (s *Sql) TrashCommand(ctx context.Context, id uuid.UUID) error {
+	return s.setDeleted(ctx, s.queries().TrashCommandQuery, id)
+}
+
+// RestoreCommand takes a command out of the trash. If the command isn't in the trash, returns an ErrNotFound error.
+func (s *Sql) RestoreCommand(ctx context.Context, id uuid.UUID) error {
+	return s.setDeleted(ctx, s.queries().RestoreCommandQuery, id)
+}
+
+// ListTrash returns the commands in the trash, the last deleted first.
+func (s *Sql) ListTrash(ctx context.Context) ([]command.DeletedCommand, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries().GetTrashQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	cmds := make([]command.DeletedCommand, 0)
+	for rows.Next() {
+		var deleted command.DeletedCommand
+		cmd := &deleted.Command
+		if err := rows.Scan(&cmd.ID, &cmd.Name, &cmd.Description, &cmd.Command, &deleted.DeletedAt); err != nil {
+			return nil, err
+		}
+		if err := s.open(&cmd.Command); err != nil {
+			return nil, err
+		}
+
+		cmds = append(cmds, deleted)
+	}
+
+	return cmds, rows.Err()
+}
+
+func (s *Sql) setDeleted(ctx context.Context, query string, id uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, query, id.String())
+	if err != nil {
+		return fmt.Errorf("error updating command with ID %q: %w", id, err)
+	}
+
+	n, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("error removing command with ID %q: %w", id, err)
+		return fmt.Errorf("error updating command with ID %q: %w", id, err)
+	}
+	if n == 0 {
+		return ErrNotFound
 	}
 	return nil
 }
 
 // DeleteParameters removes the parameters with the provided ids.
 func (s *Sql) DeleteParameters(ctx context.Context, ids []uuid.UUID) error {
-	strIDs := make([]string, 0, len(ids))
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(ids))
 	for _, id := range ids {
-		strIDs = append(strIDs, id.String())
+		args = append(args, id.String())
 	}
 
-	if _, err := s.db.ExecContext(ctx, sqlite.DeleteParametersQuery, strings.Join(strIDs, ",")); err != nil {
+	query := fmt.Sprintf(s.queries().DeleteParametersQuery, s.queries().placeholders(1, len(ids)))
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
 		return fmt.Errorf("error removing parameters: %v", err)
 	}
 	return nil
 }
 
 // WriteExplanation writes the explanation for a command.
-func (s *Sql) WriteExplanation(ctx context.Context, cmdID uuid.UUID, explanation string) error {
-	_, err := s.db.ExecContext(ctx, sqlite.UpsertExplanationQuery, cmdID.String(), explanation)
+func (s *Sql) WriteExplanation(ctx context.Context, cmdID uuid.UUID, explanation command.Explanation) error {
+	content := explanation.Content
+	if err := s.seal(&content); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, s.queries().UpsertExplanationQuery,
+		cmdID.String(),
+		content,
+		explanation.Signature.CommandHash,
+		explanation.Signature.Source,
+		explanation.Signature.PromptVersion,
+		explanation.Signature.Template,
+	)
 	if err != nil {
 		return fmt.Errorf("error writing explanation: %v", err)
 	}
@@ -208,34 +653,144 @@ func (s *Sql) WriteExplanation(ctx context.Context, cmdID uuid.UUID, explanation
 }
 
 // ReadExplanation reads the explanation of a command.
-func (s *Sql) ReadExplanation(ctx context.Context, cmdID uuid.UUID) (string, error) {
-	row := s.db.QueryRowContext(ctx, sqlite.GetExplanationByCommandID, cmdID.String())
+func (s *Sql) ReadExplanation(ctx context.Context, cmdID uuid.UUID) (command.Explanation, error) {
+	row := s.db.QueryRowContext(ctx, s.queries().GetExplanationByCommandID, cmdID.String())
 	var (
-		commandID   string
-		explanation string
+		commandID     string
+		explanation   sql.NullString
+		commandHash   sql.NullString
+		source        sql.NullString
+		promptVersion sql.NullString
+		template      sql.NullString
 	)
-	if err := row.Scan(&commandID, &explanation); err != nil {
+	if err := row.Scan(&commandID, &explanation, &commandHash, &source, &promptVersion, &template); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", ErrNotFound
+			return command.Explanation{}, ErrNotFound
 		}
-		return "", err
+		return command.Explanation{}, err
+	}
+
+	// the entry can exist only with the transcript.
+	if !explanation.Valid {
+		return command.Explanation{}, ErrNotFound
+	}
+	if err := s.open(&explanation.String); err != nil {
+		return command.Explanation{}, err
 	}
 
-	return explanation, nil
+	return command.Explanation{
+		Content: explanation.String,
+		Signature: command.ExplanationSignature{
+			CommandHash:   commandHash.String,
+			Source:        source.String,
+			PromptVersion: promptVersion.String,
+			Template:      template.String,
+		},
+	}, nil
 }
 
 // DeleteExplanation removes the explanation of a command.
 func (s *Sql) DeleteExplanation(ctx context.Context, cmdID uuid.UUID) error {
-	_, err := s.db.ExecContext(ctx, sqlite.DeleteExplanationQuery, cmdID.String())
+	_, err := s.db.ExecContext(ctx, s.queries().DeleteExplanationQuery, cmdID.String())
 	if err != nil {
 		return fmt.Errorf("error delete explanation: %v", err)
 	}
 	return nil
 }
 
-// InsertUsage insert the usage of a command.
-func (s *Sql) InsertUsage(ctx context.Context, cmdID uuid.UUID, usage string) error {
-	_, err := s.db.ExecContext(ctx, sqlite.InsertUsageQuery, cmdID.String(), usage)
+// IndexExplanation adds the plaintext of the explanation of a command to the content search,
+// replacing the previous one. The explanations of an encrypted store aren't indexed.
+func (s *Sql) IndexExplanation(ctx context.Context, cmdID uuid.UUID, content string) error {
+	if s.queries().InsertExplanationContentQuery == "" || s.cipher != nil {
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.queries().DeleteExplanationContentQuery, cmdID.String()); err != nil {
+		return fmt.Errorf("error removing indexed explanation: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, s.queries().InsertExplanationContentQuery, cmdID.String(), content); err != nil {
+		return fmt.Errorf("error indexing explanation: %w", err)
+	}
+	return nil
+}
+
+// UnindexedExplanations returns the ids of the commands whose explanation is missing in the content search,
+// e.g. the ones written before it existed.
+func (s *Sql) UnindexedExplanations(ctx context.Context) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0)
+	if s.queries().UnindexedExplanationsQuery == "" || s.cipher != nil {
+		return ids, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.queries().UnindexedExplanationsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error listing unindexed explanations: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// WriteTranscript writes the follow-up conversation for a command.
+func (s *Sql) WriteTranscript(ctx context.Context, cmdID uuid.UUID, transcript string) error {
+	if err := s.seal(&transcript); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, s.queries().UpsertTranscriptQuery, cmdID.String(), transcript)
+	if err != nil {
+		return fmt.Errorf("error writing transcript: %v", err)
+	}
+	return nil
+}
+
+// ReadTranscript reads the follow-up conversation of a command.
+func (s *Sql) ReadTranscript(ctx context.Context, cmdID uuid.UUID) (string, error) {
+	row := s.db.QueryRowContext(ctx, s.queries().GetTranscriptByCommandID, cmdID.String())
+	var transcript sql.NullString
+	if err := row.Scan(&transcript); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	if !transcript.Valid {
+		return "", ErrNotFound
+	}
+	if err := s.open(&transcript.String); err != nil {
+		return "", err
+	}
+
+	return transcript.String, nil
+}
+
+// InsertUsage insert the usage of a command, with the target and the result when it was run inside clio.
+func (s *Sql) InsertUsage(ctx context.Context, cmdID uuid.UUID, usage command.Usage) error {
+	text, execTarget := usage.Command, usage.Target
+	if err := s.seal(&text, &execTarget); err != nil {
+		return err
+	}
+
+	var exitCode, duration sql.NullInt64
+	if usage.Result != nil {
+		exitCode = sql.NullInt64{Int64: int64(usage.Result.ExitCode), Valid: true}
+		duration = sql.NullInt64{Int64: usage.Result.Duration.Milliseconds(), Valid: true}
+	}
+
+	_, err := s.db.ExecContext(ctx, s.queries().InsertUsageQuery, cmdID.String(), text, execTarget, exitCode, duration)
 	if err != nil {
 		return fmt.Errorf("error writing usage: %v", err)
 	}
@@ -244,31 +799,392 @@ func (s *Sql) InsertUsage(ctx context.Context, cmdID uuid.UUID, usage string) er
 
 // GetHistory returns the history of usages of a given command.
 func (s *Sql) GetHistory(ctx context.Context, cmdID uuid.UUID) (command.History, error) {
-	rows, err := s.db.QueryContext(ctx, sqlite.GetHistoryForCommand, cmdID.String())
+	rows, err := s.db.QueryContext(ctx, s.queries().GetHistoryForCommand, cmdID.String())
 	if err != nil {
 		return command.History{}, err
 	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
 
 	usages := make([]command.Usage, 0)
 	for rows.Next() {
-		var usage command.Usage
-		if err := rows.Scan(&usage.Command, &usage.Timestamp); err != nil {
+		var (
+			usage              command.Usage
+			exitCode, duration sql.NullInt64
+		)
+		if err := rows.Scan(&usage.Command, &usage.Timestamp, &usage.Target, &exitCode, &duration); err != nil {
+			return command.History{}, err
+		}
+		if err := s.open(&usage.Command, &usage.Target); err != nil {
 			return command.History{}, err
 		}
+		if exitCode.Valid {
+			usage.Result = &command.RunResult{
+				ExitCode: int(exitCode.Int64),
+				Duration: time.Duration(duration.Int64) * time.Millisecond,
+			}
+		}
 
 		usages = append(usages, usage)
 	}
+	if err := rows.Err(); err != nil {
+		return command.History{}, err
+	}
 
 	return command.History{
 		Usages: usages,
 	}, nil
 }
 
+// ListUsages returns the times each command was used, by command.
+func (s *Sql) ListUsages(ctx context.Context) (map[uuid.UUID][]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries().GetUsagesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	usages := make(map[uuid.UUID][]time.Time)
+	for rows.Next() {
+		var (
+			id        uuid.UUID
+			timestamp time.Time
+		)
+		if err := rows.Scan(&id, &timestamp); err != nil {
+			return nil, err
+		}
+
+		usages[id] = append(usages[id], timestamp)
+	}
+
+	return usages, rows.Err()
+}
+
+// InsertRevision stores the version of a command, before it's changed.
+func (s *Sql) InsertRevision(ctx context.Context, cmd command.Command) error {
+	params := make([]revisionParam, 0, len(cmd.Params))
+	for _, param := range cmd.Params {
+		params = append(params, revisionParam{
+			ID:          param.ID,
+			Name:        param.Name,
+			Description: param.Description,
+			Default:     param.DefaultValue,
+		})
+	}
+
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("error encoding revision parameters: %w", err)
+	}
+
+	template, encodedParams := cmd.Command, string(rawParams)
+	if err := s.seal(&template, &encodedParams); err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, s.queries().InsertRevisionQuery,
+		cmd.ID.String(), cmd.Name, cmd.Description, template, encodedParams)
+	if err != nil {
+		return fmt.Errorf("error writing revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions returns the prior versions of a command, the newest first.
+func (s *Sql) ListRevisions(ctx context.Context, cmdID uuid.UUID) ([]command.Revision, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries().GetRevisionsForCommand, cmdID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	revisions := make([]command.Revision, 0)
+	for rows.Next() {
+		var (
+			rev       command.Revision
+			rawParams sql.NullString
+		)
+		if err := rows.Scan(&rev.ID, &rev.Command.Name, &rev.Command.Description, &rev.Command.Command, &rawParams, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := s.open(&rev.Command.Command, &rawParams.String); err != nil {
+			return nil, err
+		}
+
+		var params []revisionParam
+		if rawParams.Valid {
+			if err := json.Unmarshal([]byte(rawParams.String), &params); err != nil {
+				return nil, fmt.Errorf("error decoding revision parameters: %w", err)
+			}
+		}
+
+		rev.Command.ID = cmdID
+		rev.Command.Params = make([]command.Parameter, 0, len(params))
+		for _, param := range params {
+			rev.Command.Params = append(rev.Command.Params, command.Parameter{
+				ID:           param.ID,
+				Name:         param.Name,
+				Description:  param.Description,
+				DefaultValue: param.Default,
+			})
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// WriteEmbedding stores the embedding of a command, replacing the previous one.
+func (s *Sql) WriteEmbedding(ctx context.Context, embedding command.Embedding) error {
+	vector := encodeVector(embedding.Vector)
+	if err := s.seal(&vector); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, s.queries().UpsertEmbeddingQuery,
+		embedding.CommandID.String(),
+		embedding.Model,
+		embedding.Hash,
+		vector,
+	)
+	if err != nil {
+		return fmt.Errorf("error writing embedding: %w", err)
+	}
+	return nil
+}
+
+// ListEmbeddings returns the embeddings of the commands not in the trash, by command id.
+func (s *Sql) ListEmbeddings(ctx context.Context) (map[uuid.UUID]command.Embedding, error) {
+	rows, err := s.db.QueryContext(ctx, s.queries().GetEmbeddingsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error listing embeddings: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			s.logger.Warn("error closing rows", slog.Any("error", err))
+		}
+	}()
+
+	embeddings := make(map[uuid.UUID]command.Embedding)
+	for rows.Next() {
+		var (
+			embedding command.Embedding
+			vector    string
+		)
+		if err := rows.Scan(&embedding.CommandID, &embedding.Model, &embedding.Hash, &vector); err != nil {
+			return nil, err
+		}
+		if err := s.open(&vector); err != nil {
+			return nil, err
+		}
+
+		embedding.Vector, err = decodeVector(vector)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[embedding.CommandID] = embedding
+	}
+
+	return embeddings, rows.Err()
+}
+
+// RotateKey encrypts the sensitive values with a new key derived from the passphrase.
+// On a store not encrypted yet, it encrypts the values stored so far.
+func (s *Sql) RotateKey(ctx context.Context, passphrase string) error {
+	if s.encrypted && s.cipher == nil {
+		return ErrEncrypted
+	}
+
+	salt, err := crypt.NewSalt()
+	if err != nil {
+		return err
+	}
+
+	next, err := crypt.New(passphrase, salt)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := next.Encrypt(keyringCheck)
+	if err != nil {
+		return fmt.Errorf("error encrypting the keyring: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			s.logger.Warn("error rolling back key rotation", slog.Any("error", err))
+		}
+	}()
+
+	for _, col := range encryptedColumns {
+		if err := s.reencrypt(ctx, tx, col.table, col.key, col.column, next); err != nil {
+			return err
+		}
+	}
+
+	encodedSalt := base64.StdEncoding.EncodeToString(salt)
+	if _, err := tx.ExecContext(ctx, s.queries().UpsertKeyringQuery, encodedSalt, verifier); err != nil {
+		return fmt.Errorf("error storing the keyring: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commiting transaction: %w", err)
+	}
+
+	s.cipher = next
+	s.encrypted = true
+
+	// the values encrypted with the previous key, or not encrypted, can remain in the free pages.
+	for _, query := range s.queries().CompactQueries {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			s.logger.Warn("error compacting the store after the key rotation", slog.String("query", query), slog.Any("error", err))
+		}
+	}
+
+	s.logger.Debug("store key rotated successfully")
+	return nil
+}
+
+// reencrypt decrypts the values of the column with the current key and encrypts them with the next one.
+func (s *Sql) reencrypt(ctx context.Context, tx *sql.Tx, table, key, column string, next *crypt.Cipher) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(s.queries().GetEncryptedPartialQuery, table, key, column))
+	if err != nil {
+		return fmt.Errorf("error reading %s.%s: %w", table, column, err)
+	}
+
+	type entry struct{ key, value string }
+	entries := make([]entry, 0)
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.key, &e.value); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("error reading %s.%s: %w", table, column, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Close(); err != nil {
+		return fmt.Errorf("error reading %s.%s: %w", table, column, err)
+	}
+
+	update := fmt.Sprintf(s.queries().UpdateEncryptedPartialQuery, table, key, column)
+	for _, e := range entries {
+		value, err := s.cipher.Decrypt(e.value)
+		if err != nil {
+			return fmt.Errorf("error decrypting %s.%s: %w", table, column, err)
+		}
+
+		value, err = next.Encrypt(value)
+		if err != nil {
+			return fmt.Errorf("error encrypting %s.%s: %w", table, column, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, update, value, e.key); err != nil {
+			return fmt.Errorf("error updating %s.%s: %w", table, column, err)
+		}
+	}
+
+	return nil
+}
+
+// seal encrypts the values in place, when the store is encrypted.
+func (s *Sql) seal(values ...*string) error {
+	for _, value := range values {
+		encrypted, err := s.cipher.Encrypt(*value)
+		if err != nil {
+			return fmt.Errorf("error encrypting value: %w", err)
+		}
+		*value = encrypted
+	}
+	return nil
+}
+
+// open decrypts the values in place, when the store is encrypted.
+func (s *Sql) open(values ...*string) error {
+	for _, value := range values {
+		decrypted, err := s.cipher.Decrypt(*value)
+		if err != nil {
+			return fmt.Errorf("error decrypting value: %w", err)
+		}
+		*value = decrypted
+	}
+	return nil
+}
+
 // Close closes the db driver.
 func (s *Sql) Close() error {
 	return s.db.Close()
 }
 
+// queries returns the dialect of the store, SQLite by default.
+func (s *Sql) queries() Dialect {
+	if s.dialect == nil {
+		return SqliteDialect
+	}
+	return *s.dialect
+}
+
+// migrate creates the schema and applies the pending migrations of the dialect.
+func migrate(ctx context.Context, db *sql.DB, dialect Dialect, logger *slog.Logger) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+
+	defer func() {
+		err := tx.Rollback()
+		if !errors.Is(err, sql.ErrTxDone) {
+			logger.Warn("error rolling back init db config", slog.Any("error", err))
+		}
+	}()
+
+	for _, query := range dialect.Schema {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error executing the query `%s`: %w", query, err)
+		}
+		logger.Debug("query executed successfully", slog.String("query", query))
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx, dialect.SchemaVersionQuery).Scan(&version); err != nil {
+		return fmt.Errorf("error getting the schema version: %w", err)
+	}
+
+	for _, query := range dialect.Migrations[min(version, len(dialect.Migrations)):] {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("error executing the migration `%s`: %w", query, err)
+		}
+		logger.Debug("migration executed successfully", slog.String("query", query))
+	}
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(dialect.SetSchemaVersionQuery, len(dialect.Migrations))); err != nil {
+		return fmt.Errorf("error setting the schema version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error commiting transaction: %w", err)
+	}
+
+	return nil
+}
+
 // WithSqliteDriver returns a sqlOptFunc that sets the config necessary for a SQLite store.
 func WithSqliteDriver(ctx context.Context, path string) SqlOptFunc {
 	return func(store *Sql) error {
@@ -281,43 +1197,131 @@ func WithSqliteDriver(ctx context.Context, path string) SqlOptFunc {
 			return fmt.Errorf("error opening sqlite db: %v", err)
 		}
 
-		tx, err := db.BeginTx(ctx, nil)
+		if err := migrate(ctx, db, SqliteDialect, store.logger); err != nil {
+			return err
+		}
+
+		encrypted, err := hasKeyring(ctx, db, SqliteDialect)
 		if err != nil {
-			return fmt.Errorf("error starting transaction: %w", err)
+			return err
 		}
 
-		defer func() {
-			err := tx.Rollback()
-			if !errors.Is(err, sql.ErrTxDone) {
-				store.logger.Warn("error rolling back init sqlite db config", slog.Any("error", err))
-			}
-		}()
-
-		queries := []string{
-			sqlite.CommandTableQuery,
-			sqlite.ParametersTableQuery,
-			sqlite.SearchTableQuery,
-			sqlite.InsertCommandFtsTrigger,
-			sqlite.UpdateCommandFtsTrigger,
-			sqlite.DeleteCommandFtsTrigger,
-			sqlite.NotebookTableQuery,
-			sqlite.HistoryTableQuery,
-		}
-
-		for _, query := range queries {
-			if _, err := tx.ExecContext(ctx, query); err != nil {
-				return fmt.Errorf("error executing the query `%s`: %w", query, err)
-			}
-			store.logger.Debug("query executed successfully", slog.String("query", query))
+		store.logger.Debug("sqlite store initiatied successfully")
+
+		store.db = db
+		store.dialect = &SqliteDialect
+		store.encrypted = encrypted
+		return nil
+	}
+}
+
+// WithPostgresDriver returns a sqlOptFunc that sets the config necessary for a Postgres store.
+// The dsn is a connection string or URL, e.g. postgres://user@host/clio.
+func WithPostgresDriver(ctx context.Context, dsn string) SqlOptFunc {
+	return func(store *Sql) error {
+		if store.db != nil {
+			return errors.New("sql store already set")
 		}
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("error commiting transaction: %w", err)
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return fmt.Errorf("error opening postgres db: %v", err)
 		}
 
-		store.logger.Debug("sqlite store initiatied successfully")
+		if err := db.PingContext(ctx); err != nil {
+			_ = db.Close()
+			return fmt.Errorf("error connecting to postgres db: %w", err)
+		}
+
+		if err := migrate(ctx, db, PostgresDialect, store.logger); err != nil {
+			_ = db.Close()
+			return err
+		}
+
+		encrypted, err := hasKeyring(ctx, db, PostgresDialect)
+		if err != nil {
+			_ = db.Close()
+			return err
+		}
+
+		store.logger.Debug("postgres store initiatied successfully")
 
 		store.db = db
+		store.dialect = &PostgresDialect
+		store.encrypted = encrypted
 		return nil
 	}
 }
+
+// WithEncryption returns a SqlOptFunc that encrypts the sensitive values with a key derived from the passphrase:
+// the command templates, parameter values, notebook, history and revisions. It must follow the driver.
+// On a store not encrypted yet, it encrypts the values stored so far.
+func WithEncryption(ctx context.Context, passphrase string) SqlOptFunc {
+	return func(store *Sql) error {
+		if store.db == nil {
+			return errors.New("missing db connection, the encryption must follow the driver")
+		}
+
+		if !store.encrypted {
+			return store.RotateKey(ctx, passphrase)
+		}
+
+		var encodedSalt, verifier string
+		if err := store.db.QueryRowContext(ctx, store.queries().GetKeyringQuery).Scan(&encodedSalt, &verifier); err != nil {
+			return fmt.Errorf("error reading the keyring: %w", err)
+		}
+
+		salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+		if err != nil {
+			return fmt.Errorf("error decoding the keyring salt: %w", err)
+		}
+
+		cipher, err := crypt.New(passphrase, salt)
+		if err != nil {
+			return err
+		}
+
+		if check, err := cipher.Decrypt(verifier); err != nil || check != keyringCheck {
+			return ErrInvalidPassphrase
+		}
+
+		store.cipher = cipher
+		return nil
+	}
+}
+
+// hasKeyring reports whether the store is encrypted.
+func hasKeyring(ctx context.Context, db *sql.DB, dialect Dialect) (bool, error) {
+	var salt, verifier string
+	err := db.QueryRowContext(ctx, dialect.GetKeyringQuery).Scan(&salt, &verifier)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("error reading the keyring: %w", err)
+	}
+	return true, nil
+}
+
+// encodeVector returns the vector as base64, the float32s in little endian.
+func encodeVector(vector []float32) string {
+	raw := make([]byte, 0, 4*len(vector))
+	for _, v := range vector {
+		raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// decodeVector returns the vector encoded by encodeVector.
+func decodeVector(encoded string) ([]float32, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw)%4 != 0 {
+		return nil, fmt.Errorf("invalid embedding vector: %v", err)
+	}
+
+	vector := make([]float32, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		vector = append(vector, math.Float32frombits(binary.LittleEndian.Uint32(raw[i:])))
+	}
+	return vector, nil
+}
@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -18,6 +19,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/crypt"
 	"github.com/lian-rr/clio/command/sql/sqlite"
 )
 
@@ -104,7 +106,13 @@ func TestSql_Save(t *testing.T) {
 				DefaultValue: "bye",
 			},
 		},
+		Tags:     []string{"k8s", "prod"},
+		Target:   "ssh:prod-1",
+		Env:      []command.EnvVar{{Name: "AWS_PROFILE", Value: "ops"}},
+		Dir:      "~/infra",
+		Requires: []string{"kubectl", "terraform"},
 	}
+	env := `[{"name":"AWS_PROFILE","value":"ops"}]`
 	mockErr := errors.New("mock error")
 
 	tests := []struct {
@@ -128,7 +136,7 @@ func TestSql_Save(t *testing.T) {
 				mock.ExpectBegin()
 
 				mock.ExpectExec(sqlite.UpsertCommandQuery).
-					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
 					WillReturnError(mockErr)
 
 				mock.ExpectRollback()
@@ -142,7 +150,7 @@ func TestSql_Save(t *testing.T) {
 				mock.ExpectBegin()
 
 				mock.ExpectExec(sqlite.UpsertCommandQuery).
-					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
 					WillReturnError(mockErr)
 
 				mock.ExpectRollback().WillReturnError(mockErr)
@@ -161,7 +169,7 @@ func TestSql_Save(t *testing.T) {
 				mock.ExpectBegin()
 
 				mock.ExpectExec(sqlite.UpsertCommandQuery).
-					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				paramsValue := make([]driver.Value, 0, len(cmd.Params)*5)
@@ -183,7 +191,7 @@ func TestSql_Save(t *testing.T) {
 				mock.ExpectBegin()
 
 				mock.ExpectExec(sqlite.UpsertCommandQuery).
-					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				paramsValue := make([]driver.Value, 0, len(cmd.Params)*5)
@@ -195,10 +203,46 @@ func TestSql_Save(t *testing.T) {
 					WithArgs(paramsValue...).
 					WillReturnResult(sqlmock.NewResult(2, 2))
 
+				mock.ExpectExec(sqlite.DeleteCommandTagsQuery).
+					WithArgs(cmd.ID.String()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(fmt.Sprintf(sqlite.InsertTagsPartialQuery, "(?, ?),(?, ?)")).
+					WithArgs(cmd.ID.String(), "k8s", cmd.ID.String(), "prod").
+					WillReturnResult(sqlmock.NewResult(2, 2))
+
 				mock.ExpectCommit().WillReturnError(mockErr)
 			},
 			expectedErrMsg: "error commiting transaction",
 		},
+		{
+			name: "error inserting tags",
+			cmd:  cmd,
+			setMockCallsFunc: func(cmd command.Command, mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+
+				mock.ExpectExec(sqlite.UpsertCommandQuery).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+
+				paramsValue := make([]driver.Value, 0, len(cmd.Params)*5)
+				for _, p := range cmd.Params {
+					paramsValue = append(paramsValue, p.ID.String(), cmd.ID.String(), p.Name, p.Description, p.DefaultValue)
+				}
+
+				mock.ExpectExec(fmt.Sprintf(sqlite.UpsertParameterPartialQuery, "(?, ?, ?, ?, ?),(?, ?, ?, ?, ?)")).
+					WithArgs(paramsValue...).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+
+				mock.ExpectExec(sqlite.DeleteCommandTagsQuery).
+					WithArgs(cmd.ID.String()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(fmt.Sprintf(sqlite.InsertTagsPartialQuery, "(?, ?),(?, ?)")).
+					WithArgs(cmd.ID.String(), "k8s", cmd.ID.String(), "prod").
+					WillReturnError(mockErr)
+				mock.ExpectRollback()
+			},
+			expectedErrMsg: "error storing tags",
+		},
 		{
 			name: "error committing",
 			cmd:  cmd,
@@ -206,7 +250,7 @@ func TestSql_Save(t *testing.T) {
 				mock.ExpectBegin()
 
 				mock.ExpectExec(sqlite.UpsertCommandQuery).
-					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command).
+					WithArgs(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl,terraform").
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
 				paramsValue := make([]driver.Value, 0, len(cmd.Params)*5)
@@ -218,6 +262,13 @@ func TestSql_Save(t *testing.T) {
 					WithArgs(paramsValue...).
 					WillReturnResult(sqlmock.NewResult(2, 2))
 
+				mock.ExpectExec(sqlite.DeleteCommandTagsQuery).
+					WithArgs(cmd.ID.String()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(fmt.Sprintf(sqlite.InsertTagsPartialQuery, "(?, ?),(?, ?)")).
+					WithArgs(cmd.ID.String(), "k8s", cmd.ID.String(), "prod").
+					WillReturnResult(sqlmock.NewResult(2, 2))
+
 				mock.ExpectCommit()
 			},
 			validateLogs: func(buf bytes.Buffer) {
@@ -290,6 +341,8 @@ func TestSql_ListCommands(t *testing.T) {
 			Name:        "kill process running on port",
 			Description: "kills the process running on the provided port",
 			Command:     "lsof -t -i:{{port}} | xargs kill",
+			Target:      "ssh:prod-1",
+			Requires:    []string{"lsof"},
 		},
 		{
 			ID:          id3,
@@ -316,9 +369,9 @@ func TestSql_ListCommands(t *testing.T) {
 			name:        "command found",
 			expectedOut: cmds,
 			setMockCalls: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command"})
+				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command", "target", "requires"})
 				for _, cmd := range cmds {
-					rows.AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command)
+					rows.AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, strings.Join(cmd.Requires, ","))
 				}
 
 				mock.ExpectQuery(sqlite.GetAllCommandsQuery).
@@ -354,6 +407,92 @@ func TestSql_ListCommands(t *testing.T) {
 	}
 }
 
+func TestSql_GetHistory(t *testing.T) {
+	mockErr := errors.New("mock err")
+	id := uuid.New()
+	now := time.Now()
+
+	cipher, err := crypt.New("passphrase", []byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		cipher           *crypt.Cipher
+		expectedErrorMsg string
+		setMockCalls     func(mock sqlmock.Sqlmock)
+		expectedOut      command.History
+	}{
+		{
+			name:             "unexpected error getting history",
+			expectedErrorMsg: mockErr.Error(),
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlite.GetHistoryForCommand).WithArgs(id.String()).WillReturnError(mockErr)
+			},
+		},
+		{
+			name: "history found",
+			expectedOut: command.History{Usages: []command.Usage{
+				{Command: "du -sh", Timestamp: now},
+				{Command: "du -sh /", Timestamp: now, Target: "ssh:prod-1", Result: &command.RunResult{ExitCode: 1, Duration: time.Second}},
+			}},
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"usage", "created_by", "target", "exit_code", "duration_ms"}).
+					AddRow("du -sh", now, "", nil, nil).
+					AddRow("du -sh /", now, "ssh:prod-1", 1, 1000)
+				mock.ExpectQuery(sqlite.GetHistoryForCommand).WithArgs(id.String()).WillReturnRows(rows).RowsWillBeClosed()
+			},
+		},
+		{
+			name:             "undecryptable usage",
+			cipher:           cipher,
+			expectedErrorMsg: "error decrypting value",
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"usage", "created_by", "target", "exit_code", "duration_ms"}).
+					AddRow("enc:v1:invalid", now, "", nil, nil).
+					AddRow("du -sh", now, "", nil, nil)
+				mock.ExpectQuery(sqlite.GetHistoryForCommand).WithArgs(id.String()).WillReturnRows(rows).RowsWillBeClosed()
+			},
+		},
+		{
+			name:             "unexpected error reading history",
+			expectedErrorMsg: mockErr.Error(),
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"usage", "created_by", "target", "exit_code", "duration_ms"}).
+					AddRow("du -sh", now, "", nil, nil).
+					RowError(0, mockErr)
+				mock.ExpectQuery(sqlite.GetHistoryForCommand).WithArgs(id.String()).WillReturnRows(rows).RowsWillBeClosed()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+
+			if tt.setMockCalls != nil {
+				tt.setMockCalls(mock)
+			}
+
+			store := Sql{
+				db:     db,
+				cipher: tt.cipher,
+			}
+
+			got, err := store.GetHistory(context.Background(), id)
+
+			assert.NoError(t, mock.ExpectationsWereMet(), "expectations not met")
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg, "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedOut, got, "history not the expected")
+		})
+	}
+}
+
 func TestSql_GetCommandByID(t *testing.T) {
 	mockErr := errors.New("mock err")
 
@@ -383,7 +522,13 @@ func TestSql_GetCommandByID(t *testing.T) {
 				DefaultValue: "bye",
 			},
 		},
+		Tags:     []string{"k8s"},
+		Target:   "ssh:prod-1",
+		Env:      []command.EnvVar{{Name: "AWS_PROFILE", Value: "ops"}},
+		Dir:      "~/infra",
+		Requires: []string{"kubectl"},
 	}
+	env := `[{"name":"AWS_PROFILE","value":"ops"}]`
 
 	tests := []struct {
 		name             string
@@ -409,8 +554,8 @@ func TestSql_GetCommandByID(t *testing.T) {
 			name:             "error getting params",
 			expectedErrorMsg: mockErr.Error(),
 			setMockCalls: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command"}).
-					AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command)
+				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command", "target", "env", "dir", "requires"}).
+					AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl")
 
 				mock.ExpectQuery(sqlite.GetCommandbyIDQuery).
 					WithArgs(id.String()).
@@ -425,8 +570,8 @@ func TestSql_GetCommandByID(t *testing.T) {
 			name:        "command found",
 			expectedOut: cmd,
 			setMockCalls: func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command"}).
-					AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command)
+				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command", "target", "env", "dir", "requires"}).
+					AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command, cmd.Target, env, cmd.Dir, "kubectl")
 
 				mock.ExpectQuery(sqlite.GetCommandbyIDQuery).
 					WithArgs(id.String()).
@@ -440,6 +585,10 @@ func TestSql_GetCommandByID(t *testing.T) {
 				mock.ExpectQuery(sqlite.GetParametersByCommandID).
 					WithArgs(id.String()).
 					WillReturnRows(rows)
+
+				mock.ExpectQuery(sqlite.GetTagsByCommandID).
+					WithArgs(id.String()).
+					WillReturnRows(sqlmock.NewRows([]string{"tag"}).AddRow("k8s"))
 			},
 		},
 	}
@@ -528,9 +677,47 @@ func TestSql_SearchCommand(t *testing.T) {
 				}
 
 				mock.ExpectQuery(sqlite.SearchCommandQuery).
+					WithArgs(`"whatever"*`).
+					WillReturnRows(rows)
+			},
+		},
+		{
+			name:        "term without words",
+			expectedOut: []command.Command{},
+			searchTerm:  ` " : `,
+		},
+		{
+			name:        "substrings",
+			expectedOut: []command.Command{cmds[1], cmds[0]},
+			searchTerm:  "kill",
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlite.SearchCommandQuery).
+					WithArgs(`"kill"*`).
+					WillReturnRows(sqlmock.NewRows([]string{"uuid", "name", "description", "command"}).
+						AddRow(cmds[1].ID, cmds[1].Name, cmds[1].Description, cmds[1].Command))
+
+				rows := sqlmock.NewRows([]string{"uuid", "name", "description", "command"})
+				for _, cmd := range cmds[:2] {
+					rows.AddRow(cmd.ID, cmd.Name, cmd.Description, cmd.Command)
+				}
+				mock.ExpectQuery(sqlite.SubstringSearchCommandQuery).
+					WithArgs(`"kill"`).
 					WillReturnRows(rows)
 			},
 		},
+		{
+			name:             "error searching substrings",
+			searchTerm:       "~num",
+			expectedErrorMsg: mockErr.Error(),
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlite.SearchCommandQuery).
+					WithArgs(`"num"*`).
+					WillReturnRows(sqlmock.NewRows([]string{"uuid", "name", "description", "command"}))
+				mock.ExpectQuery(sqlite.SubstringSearchCommandQuery).
+					WithArgs(`"~num"`).
+					WillReturnError(mockErr)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -559,3 +746,76 @@ func TestSql_SearchCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestSql_SearchContent(t *testing.T) {
+	mockErr := errors.New("mock err")
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Description: "list the pods", Command: "kubectl get pods -n {{.ns}}"}
+	disk := command.Command{ID: uuid.New(), Name: "disk", Command: "du -sh {{.path}}"}
+
+	columns := []string{"uuid", "name", "description", "command", "source", "snippet"}
+
+	tests := []struct {
+		name             string
+		expectedErrorMsg string
+		searchTerm       string
+		setMockCalls     func(mock sqlmock.Sqlmock)
+		expectedOut      []command.ContentMatch
+	}{
+		{
+			name:             "unexpected error searching",
+			searchTerm:       "namespace",
+			expectedErrorMsg: mockErr.Error(),
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlite.SearchContentQuery).WillReturnError(mockErr)
+			},
+		},
+		{
+			name:       "content found",
+			searchTerm: "namespace",
+			expectedOut: []command.ContentMatch{
+				{Command: pods, Source: command.ExplanationMatch, Snippet: "pods of the «namespace»"},
+				{Command: disk, Source: command.HistoryMatch, Snippet: "du -sh /«namespace»s"},
+			},
+			setMockCalls: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(sqlite.SearchContentQuery).
+					WithArgs(`"namespace"*`).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(pods.ID, pods.Name, pods.Description, pods.Command, "explanation", "pods of the «namespace»").
+						AddRow(disk.ID, disk.Name, disk.Description, disk.Command, "history", "du -sh /«namespace»s").
+						AddRow(pods.ID, pods.Name, pods.Description, pods.Command, "parameter", "ns «Namespace»"))
+			},
+		},
+		{
+			name:        "term without words",
+			expectedOut: []command.ContentMatch{},
+			searchTerm:  ` " : `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+
+			if tt.setMockCalls != nil {
+				tt.setMockCalls(mock)
+			}
+
+			store := Sql{
+				db: db,
+			}
+
+			got, err := store.SearchContent(context.Background(), tt.searchTerm)
+
+			assert.NoError(t, mock.ExpectationsWereMet(), "expectations not met")
+			if tt.expectedErrorMsg != "" {
+				assert.ErrorContains(t, err, tt.expectedErrorMsg, "error not the expected")
+				return
+			}
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedOut, got, "matches not the expected")
+		})
+	}
+}
@@ -24,11 +24,27 @@ const (
 			ON DELETE CASCADE
 	)`
 
+	// the encrypted commands are indexed without their template, see SubstringSearchTableQuery.
 	SearchTableQuery = `
 	CREATE VIRTUAL TABLE IF NOT EXISTS commands_fts
 	USING fts5(id UNINDEXED, name, command, description);
 	`
 
+	// the trigram index matches substrings, e.g. ctl in kubectl. The encrypted commands aren't indexed,
+	// the substrings of their ciphertext would match random queries.
+	SubstringSearchTableQuery = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS commands_trigram
+	USING fts5(id UNINDEXED, name, command, description, tokenize='trigram');
+	`
+
+	// the content index holds the parameters, explanations and usages of the commands. The explanations
+	// are stored compressed, so their plaintext is indexed by the store instead of a trigger.
+	// The ref is the id of the indexed row, the command id for the explanations.
+	ContentSearchTableQuery = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS content_fts
+	USING fts5(command UNINDEXED, source UNINDEXED, ref UNINDEXED, content);
+	`
+
 	NotebookTableQuery = `
 	CREATE TABLE IF NOT EXISTS notebook (
 		command VARCHAR(16) PRIMARY KEY,
@@ -53,8 +69,156 @@ const (
 			ON DELETE CASCADE
 	)
 	`
+
+	RevisionsTableQuery = `
+	CREATE TABLE IF NOT EXISTS revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		command VARCHAR(16),
+		name VARCHAR(64) NOT NULL,
+		description VARCHAR(255),
+		template VARCHAR(255) NOT NULL,
+		params TEXT,
+		created_at TIMESTAMP,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	KeyringTableQuery = `
+	CREATE TABLE IF NOT EXISTS keyring (
+		id INTEGER PRIMARY KEY,
+		salt VARCHAR(64) NOT NULL,
+		verifier TEXT NOT NULL
+	)`
+
+	TagsTableQuery = `
+	CREATE TABLE IF NOT EXISTS tags (
+		command VARCHAR(16),
+		tag VARCHAR(64) NOT NULL,
+
+		PRIMARY KEY (command, tag),
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
+
+	EmbeddingsTableQuery = `
+	CREATE TABLE IF NOT EXISTS embeddings (
+		command VARCHAR(16) PRIMARY KEY,
+		model VARCHAR(128) NOT NULL,
+		hash VARCHAR(64) NOT NULL,
+		vector TEXT NOT NULL,
+
+		CONSTRAINT fk_command
+			FOREIGN KEY (command)
+			REFERENCES commands(id)
+			ON DELETE CASCADE
+	)`
 )
 
+// migrations
+const (
+	SchemaVersionQuery = `PRAGMA user_version`
+
+	SetSchemaVersionQuery = `PRAGMA user_version = %d`
+
+	AddNotebookTranscriptMigration = `ALTER TABLE notebook ADD COLUMN transcript TEXT`
+
+	AddNotebookCommandHashMigration = `ALTER TABLE notebook ADD COLUMN command_hash VARCHAR(64)`
+
+	AddNotebookSourceMigration = `ALTER TABLE notebook ADD COLUMN source VARCHAR(64)`
+
+	AddNotebookPromptVersionMigration = `ALTER TABLE notebook ADD COLUMN prompt_version VARCHAR(32)`
+
+	AddNotebookTemplateMigration = `ALTER TABLE notebook ADD COLUMN template VARCHAR(32)`
+
+	AddCommandDeletedAtMigration = `ALTER TABLE commands ADD COLUMN deleted_at TIMESTAMP`
+
+	// the delete trigger removed the command instead of its search entry.
+	DropDeleteCommandFtsTriggerMigration = `DROP TRIGGER IF EXISTS delete_command_fts_trigger`
+
+	ClearStaleSearchEntriesMigration = `DELETE FROM commands_fts WHERE id NOT IN (SELECT id FROM commands)`
+
+	// the trigram index is created empty by the schema, the commands stored before are added once.
+	FillSubstringSearchIndexMigration = FillSubstringSearchIndexQuery
+
+	// the content index is created empty by the schema, the parameters and usages stored before are added once.
+	// The explanations are added by the store, see UnindexedExplanationsQuery.
+	FillContentSearchIndexMigration = FillContentSearchIndexQuery
+
+	AddHistoryExitCodeMigration = `ALTER TABLE history ADD COLUMN exit_code INTEGER`
+
+	AddHistoryDurationMigration = `ALTER TABLE history ADD COLUMN duration_ms INTEGER`
+	// AddCommandTargetMigration adds the default execution target of the commands.
+	AddCommandTargetMigration = `ALTER TABLE commands ADD COLUMN target VARCHAR(255)`
+	// AddHistoryTargetMigration adds the execution target of the usages.
+	AddHistoryTargetMigration = `ALTER TABLE history ADD COLUMN target VARCHAR(255)`
+	// AddCommandEnvMigration adds the env vars of the commands, encoded as JSON.
+	AddCommandEnvMigration = `ALTER TABLE commands ADD COLUMN env TEXT`
+	// AddCommandDirMigration adds the working directory of the commands.
+	AddCommandDirMigration = `ALTER TABLE commands ADD COLUMN dir VARCHAR(255)`
+	// AddCommandRequiresMigration adds the programs required by the commands, separated by commas.
+	AddCommandRequiresMigration = `ALTER TABLE commands ADD COLUMN requires TEXT`
+
+	// the insert and update triggers indexed the encrypted templates, they are created again without them.
+	DropInsertCommandFtsTriggerMigration = `DROP TRIGGER IF EXISTS insert_command_fts_trigger`
+
+	DropUpdateCommandFtsTriggerMigration = `DROP TRIGGER IF EXISTS update_command_fts_trigger`
+
+	ClearEncryptedSearchEntriesMigration = `UPDATE commands_fts SET command = '' WHERE command LIKE 'enc:v1:%'`
+
+	// the trigram triggers indexed the encrypted templates as NULL, they index '' like the ones of commands_fts.
+	DropInsertCommandTrigramTriggerMigration = `DROP TRIGGER IF EXISTS insert_command_trigram_trigger`
+
+	DropUpdateCommandTrigramTriggerMigration = `DROP TRIGGER IF EXISTS update_command_trigram_trigger`
+
+	ClearEncryptedSubstringSearchEntriesMigration = `UPDATE commands_trigram SET command = '' WHERE command IS NULL`
+)
+
+// Migrations holds the schema changes applied on top of the tables, in order.
+// The number of applied migrations is stored as the schema version.
+var Migrations = []string{
+	AddNotebookTranscriptMigration,
+	AddNotebookCommandHashMigration,
+	AddNotebookSourceMigration,
+	AddNotebookPromptVersionMigration,
+	AddNotebookTemplateMigration,
+	AddCommandDeletedAtMigration,
+	DropDeleteCommandFtsTriggerMigration,
+	DeleteCommandFtsTrigger,
+	ClearStaleSearchEntriesMigration,
+	FillSubstringSearchIndexMigration,
+	FillContentSearchIndexMigration,
+	AddHistoryExitCodeMigration,
+	AddHistoryDurationMigration,
+	AddCommandTargetMigration,
+	AddHistoryTargetMigration,
+	AddCommandEnvMigration,
+	AddCommandDirMigration,
+	AddCommandRequiresMigration,
+	DropInsertCommandFtsTriggerMigration,
+	InsertCommandFtsTrigger,
+	DropUpdateCommandFtsTriggerMigration,
+	UpdateCommandFtsTrigger,
+	ClearEncryptedSearchEntriesMigration,
+	DropInsertCommandTrigramTriggerMigration,
+	InsertCommandTrigramTrigger,
+	DropUpdateCommandTrigramTriggerMigration,
+	UpdateCommandTrigramTrigger,
+	ClearEncryptedSubstringSearchEntriesMigration,
+}
+
+// CompactQueries rewrite the database without the content left by the deleted and updated rows.
+var CompactQueries = []string{
+	`INSERT INTO commands_fts(commands_fts) VALUES('optimize')`,
+	`INSERT INTO commands_trigram(commands_trigram) VALUES('optimize')`,
+	`INSERT INTO content_fts(content_fts) VALUES('optimize')`,
+	`VACUUM`,
+}
+
 // triggers
 const (
 	InsertCommandFtsTrigger = `
@@ -62,7 +226,12 @@ const (
 		AFTER INSERT ON commands
 	BEGIN
 		INSERT INTO commands_fts (id, name, command, description)
-		VALUES (NEW.id, NEW.name, NEW.command, NEW.description);
+		VALUES (
+			NEW.id,
+			NEW.name,
+			CASE WHEN NEW.command LIKE 'enc:v1:%' THEN '' ELSE NEW.command END,
+			NEW.description
+		);
 	END`
 
 	UpdateCommandFtsTrigger = `
@@ -72,7 +241,7 @@ const (
 		UPDATE commands_fts
 		SET
 			name = NEW.name,
-			command = NEW.command,
+			command = CASE WHEN NEW.command LIKE 'enc:v1:%' THEN '' ELSE NEW.command END,
 			description = NEW.description
 		WHERE id = NEW.id;
 	END`
@@ -81,23 +250,134 @@ const (
 	CREATE TRIGGER IF NOT EXISTS delete_command_fts_trigger
 		AFTER DELETE ON commands
 	BEGIN
-		DELETE from commands
+		DELETE FROM commands_fts
+		WHERE id = OLD.id;
+	END`
+
+	InsertCommandTrigramTrigger = `
+	CREATE TRIGGER IF NOT EXISTS insert_command_trigram_trigger
+		AFTER INSERT ON commands
+	BEGIN
+		INSERT INTO commands_trigram (id, name, command, description)
+		VALUES (
+			NEW.id,
+			NEW.name,
+			CASE WHEN NEW.command LIKE 'enc:v1:%' THEN '' ELSE NEW.command END,
+			NEW.description
+		);
+	END`
+
+	UpdateCommandTrigramTrigger = `
+	CREATE TRIGGER IF NOT EXISTS update_command_trigram_trigger
+		AFTER UPDATE ON commands
+	BEGIN
+		UPDATE commands_trigram
+		SET
+			name = NEW.name,
+			command = CASE WHEN NEW.command LIKE 'enc:v1:%' THEN '' ELSE NEW.command END,
+			description = NEW.description
+		WHERE id = NEW.id;
+	END`
+
+	DeleteCommandTrigramTrigger = `
+	CREATE TRIGGER IF NOT EXISTS delete_command_trigram_trigger
+		AFTER DELETE ON commands
+	BEGIN
+		DELETE FROM commands_trigram
 		WHERE id = OLD.id;
 	END`
+
+	InsertParameterContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS insert_parameter_content_trigger
+		AFTER INSERT ON parameters
+	BEGIN
+		INSERT INTO content_fts (command, source, ref, content)
+		VALUES (NEW.command, 'parameter', NEW.id, NEW.name || ' ' || COALESCE(NEW.description, ''));
+	END`
+
+	UpdateParameterContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS update_parameter_content_trigger
+		AFTER UPDATE OF name, description ON parameters
+	BEGIN
+		UPDATE content_fts
+		SET content = NEW.name || ' ' || COALESCE(NEW.description, '')
+		WHERE source = 'parameter' AND ref = NEW.id;
+	END`
+
+	DeleteParameterContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS delete_parameter_content_trigger
+		AFTER DELETE ON parameters
+	BEGIN
+		DELETE FROM content_fts
+		WHERE source = 'parameter' AND ref = OLD.id;
+	END`
+
+	// the encrypted usages aren't indexed, their plaintext would be readable in the index.
+	InsertUsageContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS insert_usage_content_trigger
+		AFTER INSERT ON history
+		WHEN NEW.usage NOT LIKE 'enc:v1:%'
+	BEGIN
+		INSERT INTO content_fts (command, source, ref, content)
+		VALUES (NEW.command, 'history', NEW.id, NEW.usage);
+	END`
+
+	// the usages are only updated when they're encrypted, or decrypted by a key rotation.
+	UpdateUsageContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS update_usage_content_trigger
+		AFTER UPDATE OF usage ON history
+	BEGIN
+		DELETE FROM content_fts
+		WHERE source = 'history' AND ref = NEW.id;
+
+		INSERT INTO content_fts (command, source, ref, content)
+		SELECT NEW.command, 'history', NEW.id, NEW.usage
+		WHERE NEW.usage NOT LIKE 'enc:v1:%';
+	END`
+
+	DeleteUsageContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS delete_usage_content_trigger
+		AFTER DELETE ON history
+	BEGIN
+		DELETE FROM content_fts
+		WHERE source = 'history' AND ref = OLD.id;
+	END`
+
+	// the explanation indexed is outdated once the explanation changes, the store indexes the new one.
+	UpdateExplanationContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS update_explanation_content_trigger
+		AFTER UPDATE OF explanation ON notebook
+	BEGIN
+		DELETE FROM content_fts
+		WHERE source = 'explanation' AND ref = NEW.command;
+	END`
+
+	DeleteExplanationContentTrigger = `
+	CREATE TRIGGER IF NOT EXISTS delete_explanation_content_trigger
+		AFTER DELETE ON notebook
+	BEGIN
+		DELETE FROM content_fts
+		WHERE source = 'explanation' AND ref = OLD.command;
+	END`
 )
 
 // queries
 const (
 	UpsertCommandQuery = `
 	INSERT INTO 
-		commands(id, name, description, command) 
-	VALUES (?, ?, ?, ?)
+		commands(id, name, description, command, target, env, dir, requires) 
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT (id) 
 	DO
 		UPDATE SET 
 			name = excluded.name,
 			description = excluded.description,
-			command = excluded.command
+			command = excluded.command,
+			target = excluded.target,
+			env = excluded.env,
+			dir = excluded.dir,
+			requires = excluded.requires,
+			deleted_at = NULL
 		WHERE excluded.id = commands.id`
 
 	UpsertParameterPartialQuery = `
@@ -113,14 +393,15 @@ const (
 		WHERE excluded.id = parameters.id`
 
 	GetAllCommandsQuery = `
-	SELECT id, name, description, command 
-	FROM commands`
+	SELECT id, name, description, command, COALESCE(target, ''), COALESCE(requires, '')
+	FROM commands
+	WHERE deleted_at IS NULL`
 
 	GetCommandbyIDQuery = `
 	SELECT 
-		id, name, description, command 
+		id, name, description, command, COALESCE(target, ''), env, COALESCE(dir, ''), COALESCE(requires, '') 
 	FROM commands
-	WHERE id = ?`
+	WHERE id = ? AND deleted_at IS NULL`
 
 	GetParametersByCommandID = `
 	SELECT 
@@ -134,39 +415,265 @@ const (
 	FROM commands c
 	INNER JOIN commands_fts fts 
 		ON c.id = fts.id
-	WHERE commands_fts MATCH ?
+	WHERE commands_fts MATCH ? AND c.deleted_at IS NULL
 	ORDER BY bm25(commands_fts, 0, 15, 10, 5)`
 
+	SubstringSearchCommandQuery = `
+	SELECT
+		c.id, c.name, c.description, c.command
+	FROM commands c
+	INNER JOIN commands_trigram tri
+		ON c.id = tri.id
+	WHERE commands_trigram MATCH ? AND c.deleted_at IS NULL
+	ORDER BY bm25(commands_trigram, 0, 15, 10, 5)`
+
+	SearchContentQuery = `
+	SELECT
+		c.id, c.name, c.description, c.command, f.source,
+		snippet(content_fts, 3, '«', '»', '…', 12)
+	FROM commands c
+	INNER JOIN content_fts f
+		ON c.id = f.command
+	WHERE content_fts MATCH ? AND c.deleted_at IS NULL
+	ORDER BY bm25(content_fts)`
+
+	InsertExplanationContentQuery = `
+	INSERT INTO
+		content_fts(command, source, ref, content)
+	VALUES (?1, 'explanation', ?1, ?2)`
+
+	DeleteExplanationContentQuery = `DELETE FROM content_fts WHERE source = 'explanation' AND ref = ?`
+
+	UnindexedExplanationsQuery = `
+	SELECT command
+	FROM notebook
+	WHERE explanation IS NOT NULL
+		AND explanation NOT LIKE 'enc:v1:%'
+		AND command NOT IN (SELECT ref FROM content_fts WHERE source = 'explanation')`
+
 	DeleteCommandQuery = `DELETE FROM commands WHERE id = ?`
 
-	DeleteParametersQuery = `DELETE FROM parameters WHERE id IN (?)`
+	DeleteCommandParametersQuery = `DELETE FROM parameters WHERE command = ?`
+
+	DeleteCommandHistoryQuery = `DELETE FROM history WHERE command = ?`
+
+	DeleteCommandRevisionsQuery = `DELETE FROM revisions WHERE command = ?`
+
+	DeleteCommandTagsQuery = `DELETE FROM tags WHERE command = ?`
+
+	InsertTagsPartialQuery = `INSERT INTO tags(command, tag) VALUES %s`
+
+	UpsertEmbeddingQuery = `
+	INSERT INTO
+		embeddings(command, model, hash, vector)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (command)
+	DO
+		UPDATE SET
+			model = excluded.model,
+			hash = excluded.hash,
+			vector = excluded.vector`
+
+	GetEmbeddingsQuery = `
+	SELECT
+		e.command, e.model, e.hash, e.vector
+	FROM embeddings e
+	INNER JOIN commands c
+		ON c.id = e.command
+	WHERE c.deleted_at IS NULL`
+
+	DeleteCommandEmbeddingQuery = `DELETE FROM embeddings WHERE command = ?`
+
+	GetTagsByCommandID = `
+	SELECT tag
+	FROM tags
+	WHERE command = ?
+	ORDER BY tag`
+
+	TrashCommandQuery = `
+	UPDATE commands
+	SET deleted_at = CURRENT_TIMESTAMP
+	WHERE id = ? AND deleted_at IS NULL`
+
+	RestoreCommandQuery = `
+	UPDATE commands
+	SET deleted_at = NULL
+	WHERE id = ? AND deleted_at IS NOT NULL`
+
+	GetTrashQuery = `
+	SELECT id, name, description, command, deleted_at
+	FROM commands
+	WHERE deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC`
+
+	DeleteParametersPartialQuery = `DELETE FROM parameters WHERE id IN (%s)`
 
 	UpsertExplanationQuery = `
 	INSERT INTO 
-		notebook(command, explanation) 
-	VALUES (?, ?)
+		notebook(command, explanation, command_hash, source, prompt_version, template) 
+	VALUES (?, ?, ?, ?, ?, ?)
 	ON CONFLICT (command) 
 	DO
 		UPDATE SET 
-			explanation = excluded.explanation
+			explanation = excluded.explanation,
+			command_hash = excluded.command_hash,
+			source = excluded.source,
+			prompt_version = excluded.prompt_version,
+			template = excluded.template
 		WHERE excluded.command = notebook.command`
 
 	GetExplanationByCommandID = `
 	SELECT 
-		command, explanation
+		command, explanation, command_hash, source, prompt_version, template
 	FROM notebook
 	WHERE command = ?`
 
 	DeleteExplanationQuery = `DELETE FROM notebook WHERE command = ?`
 
+	UpsertTranscriptQuery = `
+	INSERT INTO 
+		notebook(command, transcript) 
+	VALUES (?, ?)
+	ON CONFLICT (command) 
+	DO
+		UPDATE SET 
+			transcript = excluded.transcript
+		WHERE excluded.command = notebook.command`
+
+	GetTranscriptByCommandID = `
+	SELECT 
+		transcript
+	FROM notebook
+	WHERE command = ?`
+
 	InsertUsageQuery = `
 	INSERT INTO
-		history(command, usage, created_by)
-	VALUES(?, ?, CURRENT_TIMESTAMP)`
+		history(command, usage, target, exit_code, duration_ms, created_by)
+	VALUES(?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 
 	GetHistoryForCommand = `
 	SELECT
-		usage, created_by
+		usage, created_by, COALESCE(target, ''), exit_code, duration_ms
 	FROM history
 	WHERE command = ?`
+
+	GetUsagesQuery = `
+	SELECT
+		h.command, h.created_by
+	FROM history h
+	INNER JOIN commands c
+		ON c.id = h.command
+	WHERE c.deleted_at IS NULL`
+
+	InsertRevisionQuery = `
+	INSERT INTO
+		revisions(command, name, description, template, params, created_at)
+	VALUES(?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	GetRevisionsForCommand = `
+	SELECT
+		id, name, description, template, params, created_at
+	FROM revisions
+	WHERE command = ?
+	ORDER BY id DESC`
+
+	GetKeyringQuery = `SELECT salt, verifier FROM keyring WHERE id = 1`
+
+	UpsertKeyringQuery = `
+	INSERT INTO
+		keyring(id, salt, verifier)
+	VALUES (1, ?, ?)
+	ON CONFLICT (id)
+	DO
+		UPDATE SET
+			salt = excluded.salt,
+			verifier = excluded.verifier`
+
+	GetEncryptedPartialQuery = `SELECT %[2]s, %[3]s FROM %[1]s WHERE %[3]s IS NOT NULL`
+
+	UpdateEncryptedPartialQuery = `UPDATE %[1]s SET %[3]s = ? WHERE %[2]s = ?`
+)
+
+// filters, the conditions are added with the IN or NOT IN operator and the placeholder of the argument.
+const (
+	FilterCommandsPartialQuery = `
+	SELECT c.id, c.name, c.description, c.command
+	FROM commands c
+	WHERE c.deleted_at IS NULL%s`
+
+	MatchFilterPartialQuery = `c.id %[1]s (SELECT id FROM commands_fts WHERE commands_fts MATCH %[2]s)`
+
+	TagFilterPartialQuery = `c.id %[1]s (SELECT command FROM tags WHERE tag = %[2]s)`
+
+	ParamFilterPartialQuery = `c.id %[1]s (SELECT command FROM parameters WHERE LOWER(name) = %[2]s)`
+
+	UsedFilterPartialQuery = `c.id %[1]s (SELECT command FROM history WHERE created_by >= %[2]s)`
+)
+
+// maintenance
+const (
+	IntegrityCheckQuery = `PRAGMA integrity_check`
+
+	GetUnindexedCommandsQuery = `
+	SELECT COUNT(*)
+	FROM commands c
+	LEFT JOIN commands_fts fts
+		ON c.id = fts.id
+	LEFT JOIN commands_trigram tri
+		ON c.id = tri.id
+	WHERE fts.id IS NULL
+		OR fts.name IS NOT c.name
+		OR fts.command IS NOT (CASE WHEN c.command LIKE 'enc:v1:%' THEN '' ELSE c.command END)
+		OR fts.description IS NOT c.description
+		OR tri.id IS NULL
+		OR tri.name IS NOT c.name
+		OR tri.command IS NOT (CASE WHEN c.command LIKE 'enc:v1:%' THEN '' ELSE c.command END)
+		OR tri.description IS NOT c.description`
+
+	GetStaleSearchEntriesQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM commands_fts WHERE id NOT IN (SELECT id FROM commands)) +
+		(SELECT COUNT(*) FROM commands_trigram WHERE id NOT IN (SELECT id FROM commands)) +
+		(SELECT COUNT(*) FROM content_fts WHERE command NOT IN (SELECT id FROM commands))`
+
+	ClearSearchIndexQuery = `DELETE FROM commands_fts`
+
+	FillSearchIndexQuery = `
+	INSERT INTO
+		commands_fts(id, name, command, description)
+	SELECT id, name, CASE WHEN command LIKE 'enc:v1:%' THEN '' ELSE command END, description
+	FROM commands`
+
+	ClearSubstringSearchIndexQuery = `DELETE FROM commands_trigram`
+
+	FillSubstringSearchIndexQuery = `
+	INSERT INTO
+		commands_trigram(id, name, command, description)
+	SELECT id, name, CASE WHEN command LIKE 'enc:v1:%' THEN '' ELSE command END, description
+	FROM commands`
+
+	// the explanations are indexed by the store, only the stale ones are removed.
+	ClearContentSearchIndexQuery = `
+	DELETE FROM content_fts
+	WHERE source != 'explanation'
+		OR ref NOT IN (SELECT command FROM notebook WHERE explanation NOT LIKE 'enc:v1:%')`
+
+	FillContentSearchIndexQuery = `
+	INSERT INTO
+		content_fts(command, source, ref, content)
+	SELECT command, 'parameter', id, name || ' ' || COALESCE(description, '')
+	FROM parameters
+	UNION ALL
+	SELECT command, 'history', id, usage
+	FROM history
+	WHERE usage NOT LIKE 'enc:v1:%'`
+
+	CountOrphansPartialQuery = `
+	SELECT COUNT(*)
+	FROM %s
+	WHERE command IS NULL OR command NOT IN (SELECT id FROM commands)`
+
+	DeleteOrphansPartialQuery = `
+	DELETE FROM %s
+	WHERE command IS NULL OR command NOT IN (SELECT id FROM commands)`
 )
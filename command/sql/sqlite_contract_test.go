@@ -0,0 +1,478 @@
+//go:build fts5
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/search"
+	"github.com/lian-rr/clio/command/sql/sqlite"
+)
+
+func TestSqliteStoreContract(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := NewSql(logger, WithSqliteDriver(context.Background(), t.TempDir()))
+	require.NoError(t, err)
+	defer store.Close()
+
+	testStoreContract(t, store)
+}
+
+func TestSqliteStore_SubstringSearch(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods"}
+	logs := command.Command{ID: uuid.New(), Name: "service logs", Command: "journalctl -u {{.unit}}"}
+	gitLog := command.Command{ID: uuid.New(), Name: "git log", Command: "git --no-pager log --oneline"}
+
+	store, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+	require.NoError(t, err)
+	for _, cmd := range []command.Command{pods, logs, gitLog} {
+		require.NoError(t, store.Save(ctx, cmd))
+	}
+
+	tests := []struct {
+		term     string
+		expected []uuid.UUID
+	}{
+		{term: "ctl", expected: []uuid.UUID{pods.ID, logs.ID}},
+		{term: "no-pag", expected: []uuid.UUID{gitLog.ID}},
+		// the pieces under 3 runes have no trigrams, ctl is searched by words only.
+		{term: "ctl -u", expected: []uuid.UUID{}},
+		{term: "--oneline", expected: []uuid.UUID{gitLog.ID}},
+		{term: "ervic", expected: []uuid.UUID{logs.ID}},
+		{term: "ernetes", expected: []uuid.UUID{}},
+	}
+
+	assertFound := func(t *testing.T, store *Sql) {
+		t.Helper()
+		for _, tt := range tests {
+			got, err := store.SearchCommand(ctx, tt.term)
+			require.NoError(t, err, tt.term)
+			assert.ElementsMatch(t, tt.expected, ids(got), tt.term)
+		}
+	}
+
+	t.Run("search", func(t *testing.T) {
+		assertFound(t, store)
+
+		got, err := store.SearchCommand(ctx, "log")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{gitLog.ID, logs.ID}, ids(got), "the word matches first")
+	})
+
+	t.Run("migration", func(t *testing.T) {
+		// a store created before the trigram index, and the content index after it.
+		version := slices.Index(SqliteDialect.Migrations, sqlite.FillSubstringSearchIndexMigration)
+		for _, query := range []string{
+			`DROP TRIGGER insert_command_trigram_trigger`,
+			`DROP TRIGGER update_command_trigram_trigger`,
+			`DROP TRIGGER delete_command_trigram_trigger`,
+			`DROP TABLE commands_trigram`,
+			`DELETE FROM content_fts`,
+			`ALTER TABLE history DROP COLUMN exit_code`,
+			`ALTER TABLE history DROP COLUMN duration_ms`,
+			`ALTER TABLE commands DROP COLUMN target`,
+			`ALTER TABLE history DROP COLUMN target`,
+			`ALTER TABLE commands DROP COLUMN env`,
+			`ALTER TABLE commands DROP COLUMN dir`,
+			`ALTER TABLE commands DROP COLUMN requires`,
+			fmt.Sprintf(`PRAGMA user_version = %d`, version),
+		} {
+			_, err := store.db.ExecContext(ctx, query)
+			require.NoError(t, err, query)
+		}
+		require.NoError(t, store.Close())
+
+		store, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+		require.NoError(t, err)
+		defer store.Close()
+
+		assertFound(t, store)
+	})
+}
+
+func TestSqliteStore_ContentSearch(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	pods := command.Command{
+		ID:      uuid.New(),
+		Name:    "pods",
+		Command: "kubectl get pods -n {{.ns}}",
+		Params:  []command.Parameter{{ID: uuid.New(), Name: "ns", Description: "Namespace of the pods"}},
+	}
+	disk := command.Command{ID: uuid.New(), Name: "disk", Command: "du -sh {{.path}}"}
+
+	store, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, pods))
+	require.NoError(t, store.Save(ctx, disk))
+	require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{Command: "du -sh /var/lib/docker"}))
+	require.NoError(t, store.WriteExplanation(ctx, pods.ID, command.Explanation{Content: "compressed"}))
+	require.NoError(t, store.IndexExplanation(ctx, pods.ID, "Lists the pods scheduled in a cluster."))
+
+	sources := func(t *testing.T, store *Sql, term string) map[uuid.UUID]command.MatchSource {
+		t.Helper()
+		matches, err := store.SearchContent(ctx, term)
+		require.NoError(t, err, term)
+
+		out := make(map[uuid.UUID]command.MatchSource, len(matches))
+		for _, match := range matches {
+			out[match.Command.ID] = match.Source
+		}
+		return out
+	}
+
+	t.Run("search", func(t *testing.T) {
+		matches, err := store.SearchContent(ctx, "namesp")
+		require.NoError(t, err)
+		require.Len(t, matches, 1)
+		assert.Equal(t, pods.ID, matches[0].Command.ID)
+		assert.Equal(t, pods.Command, matches[0].Command.Command)
+		assert.Equal(t, command.ParameterMatch, matches[0].Source)
+		assert.Equal(t, "ns «Namespace» of the pods", matches[0].Snippet)
+
+		assert.Equal(t, map[uuid.UUID]command.MatchSource{pods.ID: command.ExplanationMatch}, sources(t, store, "cluster"))
+		assert.Equal(t, map[uuid.UUID]command.MatchSource{disk.ID: command.HistoryMatch}, sources(t, store, "docker"))
+		assert.Len(t, sources(t, store, "pods"), 1, "a command is found once")
+		assert.Empty(t, sources(t, store, "kubernetes"))
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		updated := pods
+		updated.Params = []command.Parameter{{ID: pods.Params[0].ID, Name: "ns", Description: "Kubernetes namespace"}}
+		require.NoError(t, store.Save(ctx, updated))
+		assert.Len(t, sources(t, store, "kubernetes"), 1, "the updated parameter is indexed")
+
+		require.NoError(t, store.WriteExplanation(ctx, pods.ID, command.Explanation{Content: "regenerated"}))
+		assert.Empty(t, sources(t, store, "cluster"), "the outdated explanation is removed")
+		require.NoError(t, store.IndexExplanation(ctx, pods.ID, "Lists the pods scheduled in a cluster."))
+		require.NoError(t, store.IndexExplanation(ctx, pods.ID, "Lists the pods scheduled in a cluster."))
+		matches, err := store.SearchContent(ctx, "cluster")
+		require.NoError(t, err)
+		assert.Len(t, matches, 1, "the explanation is indexed once")
+
+		require.NoError(t, store.TrashCommand(ctx, disk.ID))
+		assert.Empty(t, sources(t, store, "docker"), "the trash isn't searched")
+		require.NoError(t, store.RestoreCommand(ctx, disk.ID))
+	})
+
+	t.Run("migration", func(t *testing.T) {
+		// a store created before the content index.
+		version := slices.Index(SqliteDialect.Migrations, sqlite.FillContentSearchIndexMigration)
+		for _, query := range []string{
+			`DELETE FROM content_fts`,
+			`ALTER TABLE history DROP COLUMN exit_code`,
+			`ALTER TABLE history DROP COLUMN duration_ms`,
+			`ALTER TABLE commands DROP COLUMN target`,
+			`ALTER TABLE history DROP COLUMN target`,
+			`ALTER TABLE commands DROP COLUMN env`,
+			`ALTER TABLE commands DROP COLUMN dir`,
+			`ALTER TABLE commands DROP COLUMN requires`,
+			fmt.Sprintf(`PRAGMA user_version = %d`, version),
+		} {
+			_, err := store.db.ExecContext(ctx, query)
+			require.NoError(t, err, query)
+		}
+		require.NoError(t, store.Close())
+
+		store, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+		require.NoError(t, err)
+		defer store.Close()
+
+		assert.Len(t, sources(t, store, "kubernetes"), 1)
+		assert.Len(t, sources(t, store, "docker"), 1)
+
+		unindexed, err := store.UnindexedExplanations(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, unindexed, "the explanations are indexed by the manager")
+	})
+}
+
+func TestSqliteStore_Encryption(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	secrets := []string{"hunter2", "db.internal.example", "the admin password", "follow-up answer", "vault.internal.example", "vault-token-42", "/srv/vault-admin", "prod-db.internal", "vault-pod"}
+
+	cmd := command.Command{
+		ID:          uuid.New(),
+		Name:        "psql",
+		Description: "Connect to the db",
+		Command:     "PGPASSWORD={{.password}} psql -h db.internal.example",
+		Params: []command.Parameter{
+			{ID: uuid.New(), Name: "password", DefaultValue: "hunter2"},
+		},
+	}
+	explanation := command.Explanation{Content: "Connects with the admin password."}
+	embedding := command.Embedding{CommandID: cmd.ID, Model: "fake", Hash: "hash", Vector: []float32{0.25, -4}}
+
+	// the values stored before enabling the encryption.
+	store, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+	require.NoError(t, err)
+	require.NoError(t, store.Save(ctx, cmd))
+	require.NoError(t, store.WriteExplanation(ctx, cmd.ID, explanation))
+	require.NoError(t, store.IndexExplanation(ctx, cmd.ID, explanation.Content))
+	require.NoError(t, store.InsertUsage(ctx, cmd.ID, command.Usage{
+		Command: "PGPASSWORD=hunter2 psql -h db.internal.example",
+		Target:  "ssh:prod-db.internal",
+	}))
+	require.NoError(t, store.Close())
+
+	store, err = NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "first"))
+	require.NoError(t, err)
+	require.NoError(t, store.WriteTranscript(ctx, cmd.ID, "follow-up answer"))
+	require.NoError(t, store.InsertRevision(ctx, cmd))
+	require.NoError(t, store.WriteEmbedding(ctx, embedding))
+
+	updated := cmd
+	updated.Command = "psql -h vault.internal.example"
+	updated.Env = []command.EnvVar{{Name: "VAULT_TOKEN", Value: "vault-token-42"}}
+	updated.Dir = "/srv/vault-admin"
+	updated.Target = "kubectl:vault/vault-pod"
+	require.NoError(t, store.Save(ctx, updated))
+	require.NoError(t, store.Close())
+
+	assertNoPlaintext(t, dir, secrets)
+
+	t.Run("missing passphrase", func(t *testing.T) {
+		_, err := NewSql(logger, WithSqliteDriver(ctx, dir))
+		assert.ErrorIs(t, err, ErrEncrypted)
+	})
+
+	t.Run("invalid passphrase", func(t *testing.T) {
+		_, err := NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "wrong"))
+		assert.ErrorIs(t, err, ErrInvalidPassphrase)
+	})
+
+	assertReadable := func(t *testing.T, store *Sql) {
+		t.Helper()
+
+		got, err := store.GetCommandByID(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, updated, got)
+
+		gotExplanation, err := store.ReadExplanation(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, explanation, gotExplanation)
+
+		transcript, err := store.ReadTranscript(ctx, cmd.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "follow-up answer", transcript)
+
+		history, err := store.GetHistory(ctx, cmd.ID)
+		require.NoError(t, err)
+		require.Len(t, history.Usages, 1)
+		assert.Equal(t, "PGPASSWORD=hunter2 psql -h db.internal.example", history.Usages[0].Command)
+		assert.Equal(t, "ssh:prod-db.internal", history.Usages[0].Target)
+
+		revisions, err := store.ListRevisions(ctx, cmd.ID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, cmd, revisions[0].Command)
+
+		embeddings, err := store.ListEmbeddings(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[uuid.UUID]command.Embedding{cmd.ID: embedding}, embeddings)
+
+		// the names and descriptions aren't encrypted, they are still searchable.
+		found, err := store.SearchCommand(ctx, "psql")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{cmd.ID}, ids(found))
+
+		// the templates aren't in the search index, the command filters are matched after decrypting them.
+		for _, term := range []string{"enc", "v1", "vault", "command:enc"} {
+			found, err := store.SearchCommand(ctx, term)
+			require.NoError(t, err)
+			assert.Empty(t, found, "the ciphertext isn't indexed, %q matched", term)
+		}
+		q, err := search.Parse("cmd:vault")
+		require.NoError(t, err)
+		filtered, err := store.FilterCommands(ctx, q)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{cmd.ID}, ids(filtered))
+
+		// the content index only keeps what isn't encrypted.
+		content, err := store.SearchContent(ctx, "admin")
+		require.NoError(t, err)
+		assert.Empty(t, content, "the explanation isn't indexed")
+		content, err = store.SearchContent(ctx, "hunter2")
+		require.NoError(t, err)
+		assert.Empty(t, content, "the usages aren't indexed")
+	}
+
+	t.Run("read", func(t *testing.T) {
+		store, err := NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "first"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		assertReadable(t, store)
+	})
+
+	t.Run("rotate key", func(t *testing.T) {
+		store, err := NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "first"))
+		require.NoError(t, err)
+		require.NoError(t, store.RotateKey(ctx, "second"))
+		assertReadable(t, store)
+		require.NoError(t, store.Close())
+
+		_, err = NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "first"))
+		assert.ErrorIs(t, err, ErrInvalidPassphrase)
+
+		store, err = NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "second"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		assertReadable(t, store)
+		assertNoPlaintext(t, dir, secrets)
+	})
+
+	t.Run("migration", func(t *testing.T) {
+		// a store whose encrypted templates were indexed by the former triggers, the ciphertext
+		// in the full-text index and NULL in the trigram one.
+		store, err := NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "second"))
+		require.NoError(t, err)
+		version := slices.Index(SqliteDialect.Migrations, sqlite.DropInsertCommandFtsTriggerMigration)
+		for _, query := range []string{
+			`UPDATE commands_fts SET command = (SELECT command FROM commands WHERE commands.id = commands_fts.id)`,
+			`UPDATE commands_trigram SET command = NULL`,
+			fmt.Sprintf(`PRAGMA user_version = %d`, version),
+		} {
+			_, err := store.db.ExecContext(ctx, query)
+			require.NoError(t, err, query)
+		}
+		found, err := store.SearchCommand(ctx, "enc")
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{cmd.ID}, ids(found))
+		require.NoError(t, store.Close())
+
+		store, err = NewSql(logger, WithSqliteDriver(ctx, dir), WithEncryption(ctx, "second"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		found, err = store.SearchCommand(ctx, "enc")
+		require.NoError(t, err)
+		assert.Empty(t, found)
+		report, err := store.Check(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, report.UnindexedCommands, "both indexes keep the encrypted templates as ''")
+		assertReadable(t, store)
+	})
+}
+
+// assertNoPlaintext checks the secrets aren't in any file of the dir.
+func assertNoPlaintext(t *testing.T, dir string, secrets []string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+
+		for _, secret := range secrets {
+			assert.NotContains(t, string(data), secret, "plaintext in %s", entry.Name())
+		}
+	}
+}
+
+func TestSqliteStore_Maintenance(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := NewSql(logger, WithSqliteDriver(ctx, t.TempDir()))
+	require.NoError(t, err)
+	defer store.Close()
+
+	pods := command.Command{ID: uuid.New(), Name: "pods", Command: "kubectl get pods -n {{.ns}}", Params: []command.Parameter{{ID: uuid.New(), Name: "ns"}}}
+	disk := command.Command{ID: uuid.New(), Name: "disk", Command: "du -sh *"}
+	require.NoError(t, store.Save(ctx, pods))
+	require.NoError(t, store.Save(ctx, disk))
+	require.NoError(t, store.InsertUsage(ctx, disk.ID, command.Usage{Command: "du -sh *"}))
+
+	t.Run("check", func(t *testing.T) {
+		require.NoError(t, store.DeleteCommand(ctx, disk.ID))
+
+		report, err := store.Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), "the deleted command leaves nothing behind: %+v", report)
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		// the problems left by the old delete trigger and the foreign keys not being enforced,
+		// the orphaned usage is in the content index too.
+		_, err := store.db.ExecContext(ctx, `DELETE FROM commands_fts WHERE id = ?`, pods.ID.String())
+		require.NoError(t, err)
+		_, err = store.db.ExecContext(ctx, `INSERT INTO commands_fts (id, name, command, description) VALUES (?, 'gone', 'gone', '')`, uuid.NewString())
+		require.NoError(t, err)
+		_, err = store.db.ExecContext(ctx, `UPDATE commands_trigram SET name = 'outdated' WHERE id = ?`, pods.ID.String())
+		require.NoError(t, err)
+		_, err = store.db.ExecContext(ctx, `INSERT INTO commands_trigram (id, name, command, description) VALUES (?, 'gone', 'gone', '')`, uuid.NewString())
+		require.NoError(t, err)
+		_, err = store.db.ExecContext(ctx, `INSERT INTO history (command, usage, created_by) VALUES (?, 'gone', CURRENT_TIMESTAMP)`, uuid.NewString())
+		require.NoError(t, err)
+
+		report, err := store.Check(ctx)
+		require.NoError(t, err)
+		assert.False(t, report.OK())
+		assert.Empty(t, report.Integrity)
+		assert.Equal(t, 1, report.UnindexedCommands)
+		assert.Equal(t, 3, report.StaleSearchEntries)
+		assert.Equal(t, map[string]int{"parameters": 0, "notebook": 0, "history": 1, "revisions": 0, "tags": 0, "embeddings": 0}, report.Orphans)
+
+		require.NoError(t, store.Repair(ctx))
+
+		report, err = store.Check(ctx)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), "%+v", report)
+
+		found, err := store.SearchCommand(ctx, "pods")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, ids(found))
+
+		found, err = store.SearchCommand(ctx, "ctl")
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{pods.ID}, ids(found), "the trigram index is rebuilt too")
+	})
+
+	t.Run("backup and restore", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "backup.db")
+		require.NoError(t, store.Backup(ctx, path))
+		assert.Error(t, store.Backup(ctx, path), "the backups aren't overwritten")
+
+		require.NoError(t, store.DeleteCommand(ctx, pods.ID))
+		require.NoError(t, store.Restore(ctx, path))
+
+		got, err := store.GetCommandByID(ctx, pods.ID)
+		require.NoError(t, err)
+		assert.Equal(t, pods, got)
+
+		corrupted := filepath.Join(t.TempDir(), "corrupted.db")
+		require.NoError(t, os.WriteFile(corrupted, []byte("not a database"), 0o600))
+		assert.Error(t, store.Restore(ctx, corrupted))
+		assert.Error(t, store.Restore(ctx, filepath.Join(t.TempDir(), "missing.db")))
+
+		_, err = store.GetCommandByID(ctx, pods.ID)
+		assert.NoError(t, err, "a failed restore keeps the store")
+	})
+}
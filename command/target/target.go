@@ -0,0 +1,116 @@
+package target
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lian-rr/clio/command/shell"
+)
+
+// ErrInvalidTarget returned when the execution target can't be parsed.
+var ErrInvalidTarget = errors.New("invalid execution target")
+
+// Kind is where the command runs.
+type Kind string
+
+const (
+	// Local runs the command in the current shell.
+	Local Kind = ""
+	// SSH runs the command in a host over SSH.
+	SSH Kind = "ssh"
+	// Docker runs the command in a container.
+	Docker Kind = "docker"
+	// Kubectl runs the command in a Kubernetes pod.
+	Kubectl Kind = "kubectl"
+)
+
+// Target is where a command runs, e.g. ssh:host, docker:container or kubectl:namespace/pod.
+// The zero value is the local shell.
+type Target struct {
+	Kind Kind
+	// Name is the host, container or pod.
+	Name string
+	// Namespace of the pod, the current one of kubectl if empty.
+	Namespace string
+}
+
+// Parse returns the target in the text, e.g. ssh:host. Empty and "local" are the local shell.
+func Parse(raw string) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "local" {
+		return Target{}, nil
+	}
+
+	kind, name, ok := strings.Cut(raw, ":")
+	if !ok {
+		return Target{}, fmt.Errorf("%w %q: expected kind:name, e.g. ssh:host", ErrInvalidTarget, raw)
+	}
+
+	t := Target{Kind: Kind(kind), Name: name}
+	switch t.Kind {
+	case SSH, Docker:
+	case Kubectl:
+		if ns, pod, ok := strings.Cut(name, "/"); ok {
+			t.Namespace = ns
+			t.Name = pod
+			if err := validateName(raw, ns); err != nil {
+				return Target{}, err
+			}
+		}
+	default:
+		return Target{}, fmt.Errorf("%w %q: unknown kind %q, supported [ssh, docker, kubectl]", ErrInvalidTarget, raw, kind)
+	}
+
+	if err := validateName(raw, t.Name); err != nil {
+		return Target{}, err
+	}
+	return t, nil
+}
+
+// validateName checks the name can't be taken as an option of the wrapping program.
+func validateName(raw, name string) error {
+	if name == "" {
+		return fmt.Errorf("%w %q: missing name", ErrInvalidTarget, raw)
+	}
+	if strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("%w %q: invalid name %q", ErrInvalidTarget, raw, name)
+	}
+	return nil
+}
+
+// Local returns true if the target is the local shell.
+func (t Target) Local() bool {
+	return t.Kind == Local
+}
+
+// String returns the target as parsed, empty for the local shell.
+func (t Target) String() string {
+	switch {
+	case t.Local():
+		return ""
+	case t.Namespace != "":
+		return fmt.Sprintf("%s:%s/%s", t.Kind, t.Namespace, t.Name)
+	default:
+		return fmt.Sprintf("%s:%s", t.Kind, t.Name)
+	}
+}
+
+// Wrap returns the command line running the line in the target.
+// The line is quoted, so it's run as is by the shell of the target.
+func (t Target) Wrap(line string) string {
+	switch t.Kind {
+	case SSH:
+		return fmt.Sprintf("ssh %s -- %s", shell.Quote(t.Name), shell.Quote(line))
+	case Docker:
+		return fmt.Sprintf("docker exec -it %s sh -c %s", shell.Quote(t.Name), shell.Quote(line))
+	case Kubectl:
+		var ns string
+		if t.Namespace != "" {
+			ns = "-n " + shell.Quote(t.Namespace) + " "
+		}
+		return fmt.Sprintf("kubectl exec -it %s%s -- sh -c %s", ns, shell.Quote(t.Name), shell.Quote(line))
+	default:
+		return line
+	}
+}
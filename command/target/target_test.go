@@ -0,0 +1,149 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected Target
+		err      bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "local",
+			raw:  "local",
+		},
+		{
+			name:     "ssh",
+			raw:      " ssh:deploy@prod-1 ",
+			expected: Target{Kind: SSH, Name: "deploy@prod-1"},
+		},
+		{
+			name:     "docker",
+			raw:      "docker:api",
+			expected: Target{Kind: Docker, Name: "api"},
+		},
+		{
+			name:     "kubectl with namespace",
+			raw:      "kubectl:payments/api-7d9f",
+			expected: Target{Kind: Kubectl, Namespace: "payments", Name: "api-7d9f"},
+		},
+		{
+			name:     "kubectl without namespace",
+			raw:      "kubectl:api-7d9f",
+			expected: Target{Kind: Kubectl, Name: "api-7d9f"},
+		},
+		{
+			name: "missing kind",
+			raw:  "prod-1",
+			err:  true,
+		},
+		{
+			name: "unknown kind",
+			raw:  "podman:api",
+			err:  true,
+		},
+		{
+			name: "missing name",
+			raw:  "ssh:",
+			err:  true,
+		},
+		{
+			name: "missing namespace",
+			raw:  "kubectl:/api",
+			err:  true,
+		},
+		{
+			name: "option as name",
+			raw:  "ssh:-oProxyCommand=sh",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if tt.err {
+				assert.ErrorIs(t, err, ErrInvalidTarget)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestTarget_String(t *testing.T) {
+	for _, raw := range []string{"", "ssh:prod-1", "docker:api", "kubectl:payments/api-7d9f", "kubectl:api-7d9f"} {
+		t.Run(raw, func(t *testing.T) {
+			target, err := Parse(raw)
+			require.NoError(t, err)
+			assert.Equal(t, raw, target.String())
+		})
+	}
+}
+
+func TestTarget_Wrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   Target
+		line     string
+		expected string
+	}{
+		{
+			name:     "local",
+			line:     "df -h | grep /data",
+			expected: "df -h | grep /data",
+		},
+		{
+			name:     "ssh",
+			target:   Target{Kind: SSH, Name: "deploy@prod-1"},
+			line:     "df -h | grep /data",
+			expected: "ssh deploy@prod-1 -- 'df -h | grep /data'",
+		},
+		{
+			name:     "ssh with quotes",
+			target:   Target{Kind: SSH, Name: "prod-1"},
+			line:     "grep 'timeout' /var/log/app.log",
+			expected: `ssh prod-1 -- 'grep '\''timeout'\'' /var/log/app.log'`,
+		},
+		{
+			name:     "docker",
+			target:   Target{Kind: Docker, Name: "api"},
+			line:     `echo "$HOME" && ls`,
+			expected: `docker exec -it api sh -c 'echo "$HOME" && ls'`,
+		},
+		{
+			name:     "kubectl with namespace",
+			target:   Target{Kind: Kubectl, Namespace: "payments", Name: "api-7d9f"},
+			line:     "cat /etc/hosts",
+			expected: "kubectl exec -it -n payments api-7d9f -- sh -c 'cat /etc/hosts'",
+		},
+		{
+			name:     "kubectl without namespace",
+			target:   Target{Kind: Kubectl, Name: "api-7d9f"},
+			line:     "env",
+			expected: "kubectl exec -it api-7d9f -- sh -c env",
+		},
+		{
+			name:     "name with special characters",
+			target:   Target{Kind: Docker, Name: "$(reboot)"},
+			line:     "ls",
+			expected: "docker exec -it '$(reboot)' sh -c ls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.target.Wrap(tt.line))
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
 
 	"github.com/BurntSushi/toml"
@@ -14,9 +15,18 @@ var ErrNoConfigFound = errors.New("config file or not found")
 
 // App holds the application configuration
 type App struct {
-	PathOverride string `toml:"pathOverride"`
-	Debug        bool   `toml:"debug"`
-	Professor    ProfessorConfig
+	PathOverride  string `toml:"pathOverride"`
+	Debug         bool   `toml:"debug"`
+	Professor     ProfessorConfig
+	Store         StoreConfig          `toml:"store"`
+	Encryption    EncryptionConfig     `toml:"encryption"`
+	Sync          SyncConfig           `toml:"sync"`
+	Trash         TrashConfig          `toml:"trash"`
+	Output        OutputConfig         `toml:"output"`
+	Execution     ExecutionConfig      `toml:"execution"`
+	Server        ServerConfig         `toml:"server"`
+	Remote        RemoteConfig         `toml:"remote"`
+	Subscriptions []SubscriptionConfig `toml:"subscriptions"`
 }
 
 // New returns a new app's config.
@@ -57,11 +67,27 @@ func NewDefault() (App, error) {
 }
 
 func loadConfig(path string) (App, error) {
+	data, err := os.ReadFile(fmt.Sprintf("%s/clio.toml", path))
+	if err != nil {
+		return App{}, fmt.Errorf("%w path=%q: %v", ErrNoConfigFound, path, err)
+	}
+
 	var cfg App
-	_, err := toml.DecodeFile(fmt.Sprintf("%s/clio.toml", path), &cfg)
+	if _, err := toml.Decode(string(data), &cfg); err != nil {
+		return App{}, fmt.Errorf("%w path=%q: %v", ErrNoConfigFound, path, err)
+	}
+
+	// the professor source sections are decoded later by their providers.
+	var sections struct {
+		Professor map[string]toml.Primitive `toml:"professor"`
+	}
+	meta, err := toml.Decode(string(data), &sections)
 	if err != nil {
 		return App{}, fmt.Errorf("%w path=%q: %v", ErrNoConfigFound, path, err)
 	}
+
+	cfg.Professor.sections = sections.Professor
+	cfg.Professor.meta = meta
 	return cfg, nil
 }
 
@@ -82,9 +108,52 @@ func (a App) validate() error {
 		errs = errors.Join(errs, err)
 	}
 
+	if err := a.Store.validate(); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if err := a.Encryption.validate(a.Store); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if err := a.Output.validate(); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if err := a.Remote.validate(); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if err := validateSubscriptions(a.Subscriptions); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
 	return errs
 }
 
+// LogValue logs the config with the secrets hidden, e.g. the tokens, the DSN and the keys of the professor sources.
+// slog only resolves the LogValuer of the logged value, so the one of every section is resolved here.
+func (a App) LogValue() slog.Value {
+	subscriptions := make([]string, 0, len(a.Subscriptions))
+	for _, sub := range a.Subscriptions {
+		subscriptions = append(subscriptions, sub.Name)
+	}
+
+	return slog.GroupValue(
+		slog.Bool("debug", a.Debug),
+		slog.Any("professor", a.Professor),
+		slog.Any("store", a.Store),
+		slog.Bool("encryption", a.Encryption.Enabled),
+		slog.Any("sync", a.Sync),
+		slog.Any("trash", a.Trash),
+		slog.Any("output", a.Output),
+		slog.Any("execution", a.Execution),
+		slog.Any("server", a.Server),
+		slog.Any("remote", a.Remote),
+		slog.Any("subscriptions", subscriptions),
+	)
+}
+
 func (a App) GetPath() string {
 	path := a.PathOverride
 	if path == "" {
@@ -0,0 +1,18 @@
+package config
+
+import "errors"
+
+// EncryptionConfig is the config for encrypting the sensitive values of the sql store.
+type EncryptionConfig struct {
+	Enabled bool `toml:"enabled"`
+	// KeyCommand is a shell command printing the passphrase, e.g. "pass show clio".
+	// Without it, the passphrase is read from the CLIO_PASSPHRASE env var or prompted.
+	KeyCommand string `toml:"keyCommand"`
+}
+
+func (e EncryptionConfig) validate(store StoreConfig) error {
+	if e.Enabled && store.GetDriver() == FileStoreDriver {
+		return errors.New("the encryption isn't supported by the file store")
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package config
+
+// ExecutionConfig is the config for where the commands run.
+type ExecutionConfig struct {
+	// Targets are offered for running every command, besides the local shell and the default target of the command.
+	// e.g. ssh:host, docker:container or kubectl:namespace/pod.
+	Targets []string `toml:"targets"`
+}
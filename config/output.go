@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OutputTarget type for where the composed commands go.
+type OutputTarget string
+
+const (
+	// TTYOutput injects the command in the terminal input, as if it was typed.
+	TTYOutput OutputTarget = "tty"
+	// ClipboardOutput copies the command to the system clipboard.
+	ClipboardOutput OutputTarget = "clipboard"
+	// OSC52Output copies the command to the clipboard of the terminal, it works over SSH.
+	OSC52Output OutputTarget = "osc52"
+	// TmuxOutput sends the command to a tmux pane.
+	TmuxOutput OutputTarget = "tmux"
+	// StdoutOutput prints the command, e.g. for `$(clio)`.
+	StdoutOutput OutputTarget = "stdout"
+	// FileOutput appends the command to a file.
+	FileOutput OutputTarget = "file"
+)
+
+// OutputConfig is the config for the output of the composed commands.
+type OutputConfig struct {
+	// Target is where the commands go by default. Without it, the commands are printed when the
+	// stdout isn't a terminal, copied with OSC 52 in the SSH sessions and injected in the terminal otherwise.
+	Target OutputTarget `toml:"target"`
+	// TmuxPane is the pane receiving the commands with the tmux target. Defaults to the current pane.
+	TmuxPane string `toml:"tmuxPane"`
+	// File is where the commands are appended with the file target.
+	File string `toml:"file"`
+}
+
+func (o OutputConfig) validate() error {
+	switch o.Target {
+	case "", TTYOutput, ClipboardOutput, OSC52Output, TmuxOutput, StdoutOutput:
+		return nil
+	case FileOutput:
+		if o.File == "" {
+			return errors.New("missing output file")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid output target %q", o.Target)
+	}
+}
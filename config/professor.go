@@ -2,29 +2,151 @@ package config
 
 import (
 	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/BurntSushi/toml"
 )
 
-// ProfessorSourceType type for the professor source implementation.
+// ProfessorSourceType is the name of a professor source.
+// The source is created by the provider with the same name, unless its section sets another one.
 type ProfessorSourceType string
 
+// legacySourceType is the source that held the custom prompt before the templates.
+const legacySourceType ProfessorSourceType = "openai"
+
 const (
-	// OpenAISourceType used for setting OpenAI as the professor source.
-	OpenAISourceType ProfessorSourceType = "openai"
+	defaultProfessorTimeout = 60 * time.Second
+	defaultRetryAttempts    = 3
+	defaultRetryBackoff     = 500 * time.Millisecond
+	defaultRetryMaxBackoff  = 8 * time.Second
 )
 
 // ProfessorConfig is the config for the Professor feature.
 type ProfessorConfig struct {
 	Enabled bool                `toml:"enabled"`
 	Type    ProfessorSourceType `toml:"type"`
-	OpenAI  OpenAISourceConfig  `toml:"openai"`
+	// Fallback sources used, in order, when the previous ones fail.
+	Fallback []ProfessorSourceType `toml:"fallback"`
+	// Timeout for each request to a source.
+	Timeout time.Duration `toml:"timeout"`
+	// Template is the name of the prompt template used by default.
+	Template string `toml:"template"`
+	// Templates are custom prompt templates, added to the built-in ones.
+	Templates []TemplateConfig `toml:"templates"`
+	Retry     RetryConfig      `toml:"retry"`
+
+	// sections holds the config of every source, e.g. [professor.openai], decoded by their providers.
+	sections map[string]toml.Primitive
+	meta     toml.MetaData
+}
+
+// LogValue leaves the sections of the sources out, they have the keys.
+func (p ProfessorConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("enabled", p.Enabled),
+		slog.Any("sources", p.Sources()),
+		slog.Duration("timeout", p.Timeout),
+		slog.String("template", p.Template),
+	)
+}
+
+// TemplateConfig holds a custom prompt template.
+type TemplateConfig struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	// Text is a text/template receiving the command, the user shell and locale.
+	Text string `toml:"text"`
+}
+
+// RetryConfig holds the configuration for retrying the requests that failed with transient errors.
+type RetryConfig struct {
+	// Attempts is the max number of tries for a request. Set to 1 for disabling the retries.
+	Attempts   int           `toml:"attempts"`
+	Backoff    time.Duration `toml:"backoff"`
+	MaxBackoff time.Duration `toml:"maxBackoff"`
+}
+
+// Sources returns the source types in the order they should be used.
+func (p ProfessorConfig) Sources() []ProfessorSourceType {
+	sources := make([]ProfessorSourceType, 0, len(p.Fallback)+1)
+	sources = append(sources, p.Type)
+	for _, fallback := range p.Fallback {
+		if fallback != p.Type {
+			sources = append(sources, fallback)
+		}
+	}
+	return sources
+}
+
+// Provider returns the name of the provider used by the source.
+// Set with the `provider` key of the source section, defaults to the source name.
+func (p ProfessorConfig) Provider(source ProfessorSourceType) string {
+	var section struct {
+		Provider string `toml:"provider"`
+	}
+
+	if err := p.DecodeSource(source, &section); err != nil || section.Provider == "" {
+		return string(source)
+	}
+	return section.Provider
+}
+
+// DecodeSource decodes the section of the source into v.
+// It's a no-op if the source has no section.
+func (p ProfessorConfig) DecodeSource(source ProfessorSourceType, v any) error {
+	section, ok := p.sections[string(source)]
+	if !ok {
+		return nil
+	}
+
+	return p.meta.PrimitiveDecode(section, v)
 }
 
-// OpenAISourceConfig holds the configuration for setting the OpenAI professor source.
-type OpenAISourceConfig struct {
-	ApiKey       string `toml:"key"`
-	CustomPrompt string `toml:"customPrompt"`
-	Url          string `toml:"url"`
-	Model        string `toml:"model"`
+// CustomPrompt returns the custom prompt of the openai section.
+//
+// Deprecated: replaced by the templates.
+func (p ProfessorConfig) CustomPrompt() string {
+	var section struct {
+		CustomPrompt string `toml:"customPrompt"`
+	}
+
+	if err := p.DecodeSource(legacySourceType, &section); err != nil {
+		return ""
+	}
+	return section.CustomPrompt
+}
+
+// GetTimeout returns the timeout for each request to a source.
+func (p ProfessorConfig) GetTimeout() time.Duration {
+	if p.Timeout == 0 {
+		return defaultProfessorTimeout
+	}
+	return p.Timeout
+}
+
+// GetAttempts returns the max number of tries for a request.
+func (r RetryConfig) GetAttempts() int {
+	if r.Attempts == 0 {
+		return defaultRetryAttempts
+	}
+	return r.Attempts
+}
+
+// GetBackoff returns the wait before the first retry.
+func (r RetryConfig) GetBackoff() time.Duration {
+	if r.Backoff == 0 {
+		return defaultRetryBackoff
+	}
+	return r.Backoff
+}
+
+// GetMaxBackoff returns the max wait between retries.
+func (r RetryConfig) GetMaxBackoff() time.Duration {
+	if r.MaxBackoff == 0 {
+		return defaultRetryMaxBackoff
+	}
+	return r.MaxBackoff
 }
 
 func (p ProfessorConfig) validate() error {
@@ -32,17 +154,31 @@ func (p ProfessorConfig) validate() error {
 		return nil
 	}
 
-	switch p.Type {
-	case OpenAISourceType:
-		return p.OpenAI.validate()
-	default:
-		return errors.New("invalid professor type")
+	var errs error
+	if p.Timeout < 0 {
+		errs = errors.Join(errs, errors.New("invalid professor timeout"))
+	}
+
+	if err := p.Retry.validate(); err != nil {
+		errs = errors.Join(errs, err)
 	}
+
+	for _, tmpl := range p.Templates {
+		if tmpl.Name == "" || tmpl.Text == "" {
+			errs = errors.Join(errs, errors.New("invalid professor template: name and text are required"))
+		}
+	}
+
+	if p.Type == "" {
+		errs = errors.Join(errs, errors.New("missing professor type"))
+	}
+
+	return errs
 }
 
-func (c OpenAISourceConfig) validate() error {
-	if c.ApiKey == "" {
-		return errors.New("missing openai api key")
+func (r RetryConfig) validate() error {
+	if r.Attempts < 0 || r.Backoff < 0 || r.MaxBackoff < 0 {
+		return errors.New("invalid professor retry config")
 	}
 
 	return nil
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+)
+
+const defaultListenAddr = "127.0.0.1:7070"
+
+// ServerConfig is the config for serving the library with `clio serve`.
+type ServerConfig struct {
+	// Listen is the address to listen on. Defaults to 127.0.0.1:7070.
+	Listen string `toml:"listen"`
+	// Token authenticates the clients. Required by serve.
+	Token string `toml:"token"`
+}
+
+// GetListen returns the address to listen on.
+func (s ServerConfig) GetListen() string {
+	if s.Listen == "" {
+		return defaultListenAddr
+	}
+	return s.Listen
+}
+
+// LogValue hides the token.
+func (s ServerConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("listen", s.GetListen()),
+		slog.String("token", redact(s.Token)),
+	)
+}
+
+// RemoteConfig is the config for using the library served by a remote clio server.
+type RemoteConfig struct {
+	// URL of the server, e.g. http://127.0.0.1:7070. When set, the local store isn't used.
+	URL   string `toml:"url"`
+	Token string `toml:"token"`
+}
+
+// Enabled returns if a remote server is configured.
+func (r RemoteConfig) Enabled() bool {
+	return r.URL != ""
+}
+
+func (r RemoteConfig) validate() error {
+	if r.Enabled() && r.Token == "" {
+		return errors.New("missing remote token")
+	}
+	return nil
+}
+
+// LogValue hides the token.
+func (r RemoteConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("url", r.URL),
+		slog.String("token", redact(r.Token)),
+	)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "<redacted>"
+}
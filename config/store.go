@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// StoreDriver type for the store implementation.
+type StoreDriver string
+
+const (
+	// SqliteStoreDriver used for storing the library in a local SQLite file.
+	SqliteStoreDriver StoreDriver = "sqlite"
+	// PostgresStoreDriver used for storing the library in a Postgres database, e.g. shared by a team.
+	PostgresStoreDriver StoreDriver = "postgres"
+	// FileStoreDriver used for storing the library as plain TOML files, e.g. in a dotfiles repository.
+	FileStoreDriver StoreDriver = "file"
+)
+
+// StoreConfig is the config for the command store.
+type StoreConfig struct {
+	Driver StoreDriver `toml:"driver"`
+	// DSN is the connection string of the database. Required by postgres.
+	DSN string `toml:"dsn"`
+	// Path is the directory of the file store. Defaults to the library dir inside the config path.
+	Path string `toml:"path"`
+}
+
+// GetDriver returns the store driver, sqlite by default.
+func (s StoreConfig) GetDriver() StoreDriver {
+	if s.Driver == "" {
+		return SqliteStoreDriver
+	}
+	return s.Driver
+}
+
+func (s StoreConfig) validate() error {
+	switch s.GetDriver() {
+	case SqliteStoreDriver, FileStoreDriver:
+		return nil
+	case PostgresStoreDriver:
+		if s.DSN == "" {
+			return errors.New("missing postgres dsn")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid store driver %q", s.Driver)
+	}
+}
+
+// LogValue hides the DSN, it can contain credentials.
+func (s StoreConfig) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("driver", string(s.GetDriver())),
+		slog.String("dsn", redact(s.DSN)),
+		slog.String("path", s.Path),
+	)
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// subscriptionName is also used as the name of the cached file.
+var subscriptionName = regexp.MustCompile(`^[\w-]+$`)
+
+// SubscriptionConfig is a remote command bundle shown as read-only in the library.
+type SubscriptionConfig struct {
+	// Name is shown as the source of the commands.
+	Name string `toml:"name"`
+	// URL or Path of the bundle, one of them is required.
+	URL  string `toml:"url"`
+	Path string `toml:"path"`
+	// SHA256 is the expected hex checksum of the bundle. Optional.
+	SHA256 string `toml:"sha256"`
+	// PublicKey is the base64 ed25519 key signing the bundle. Optional.
+	// The signature is read from the bundle location with the .sig extension.
+	PublicKey string `toml:"publicKey"`
+}
+
+// Location returns the url or the path of the bundle.
+func (s SubscriptionConfig) Location() string {
+	if s.URL != "" {
+		return s.URL
+	}
+	return s.Path
+}
+
+func validateSubscriptions(subs []SubscriptionConfig) error {
+	var errs error
+	names := make(map[string]struct{}, len(subs))
+	for _, sub := range subs {
+		if sub.Name == "" {
+			errs = errors.Join(errs, errors.New("missing subscription name"))
+			continue
+		}
+
+		if !subscriptionName.MatchString(sub.Name) {
+			errs = errors.Join(errs, fmt.Errorf("invalid subscription name %q: use letters, digits, - and _", sub.Name))
+		}
+
+		if _, ok := names[sub.Name]; ok {
+			errs = errors.Join(errs, fmt.Errorf("duplicated subscription %q", sub.Name))
+		}
+		names[sub.Name] = struct{}{}
+
+		if (sub.URL == "") == (sub.Path == "") {
+			errs = errors.Join(errs, fmt.Errorf("subscription %q needs either an url or a path", sub.Name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"log/slog"
+	"net/url"
+)
+
+// SyncConfig is the config for syncing the library with a git repository.
+type SyncConfig struct {
+	// Remote is the url of the git repository. Without it, the changes are only committed locally.
+	Remote string `toml:"remote"`
+	// Branch is the branch to sync. Defaults to main.
+	Branch string `toml:"branch"`
+	// Path is the directory of the local clone. Defaults to the sync dir inside the config path.
+	Path string `toml:"path"`
+}
+
+// LogValue hides the password of the remote, if it has one.
+func (s SyncConfig) LogValue() slog.Value {
+	remote := s.Remote
+	if u, err := url.Parse(remote); err == nil {
+		remote = u.Redacted()
+	}
+
+	return slog.GroupValue(
+		slog.String("remote", remote),
+		slog.String("branch", s.Branch),
+		slog.String("path", s.Path),
+	)
+}
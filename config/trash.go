@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// TrashConfig is the config for the deleted commands.
+type TrashConfig struct {
+	// Retention is how long the deleted commands are kept before being purged, e.g. "168h".
+	// Defaults to 30 days. A negative retention keeps them until purged by hand.
+	Retention time.Duration `toml:"retention"`
+}
+
+// GetRetention returns how long the deleted commands are kept.
+func (t TrashConfig) GetRetention() time.Duration {
+	if t.Retention == 0 {
+		return defaultTrashRetention
+	}
+	return t.Retention
+}
@@ -3,21 +3,58 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
+	"golang.org/x/term"
+
+	"github.com/lian-rr/clio/api"
+	"github.com/lian-rr/clio/command/catalog"
+	"github.com/lian-rr/clio/command/file"
+	"github.com/lian-rr/clio/command/gitsync"
 	"github.com/lian-rr/clio/command/manager"
 	"github.com/lian-rr/clio/command/professor"
-	"github.com/lian-rr/clio/command/professor/openai"
+	_ "github.com/lian-rr/clio/command/professor/anthropic"
+	_ "github.com/lian-rr/clio/command/professor/manpage"
+	_ "github.com/lian-rr/clio/command/professor/openai"
 	"github.com/lian-rr/clio/command/sql"
+	"github.com/lian-rr/clio/command/target"
 	"github.com/lian-rr/clio/config"
+	"github.com/lian-rr/clio/out"
 	"github.com/lian-rr/clio/tui"
+	"github.com/lian-rr/clio/tui/view"
 )
 
-const configPathEnv = "CLIO_CONFIG_PATH"
+const (
+	configPathEnv        = "CLIO_CONFIG_PATH"
+	customPromptTemplate = "custom"
+	libraryDir           = "library"
+	syncDir              = "sync"
+	syncCommand          = "sync"
+	serveCommand         = "serve"
+	apiTokenEnv          = "CLIO_API_TOKEN"
+	rotateKeyCommand     = "rotate-key"
+	dbCommand            = "db"
+	backupsDir           = "backups"
+	backupPrefix         = "store-"
+	backupTimeFormat     = "20060102T150405Z"
+	defaultBackupKeep    = 7
+	preRestoreBackup     = "pre-restore.db"
+	passphraseEnv        = "CLIO_PASSPHRASE"
+	newPassphraseEnv     = "CLIO_NEW_PASSPHRASE"
+	subscriptionsDir     = "subscriptions"
+	subscriptionsTimeout = 5 * time.Second
+)
 
 func main() {
 	// exit once
@@ -57,46 +94,527 @@ func run() error {
 
 	logger.Info("config setup done", slog.Any("config", cfg), slog.String("path", cfg.GetPath()))
 
-	sqlStore, err := sql.NewSql(logger, sql.WithSqliteDriver(ctx, cfg.GetPath()))
+	args := os.Args[1:]
+
+	var profe *professor.Professor
+	prf, ok, err := newProfessor(cfg.Professor, logger)
+	if err != nil {
+		return fmt.Errorf("error loading the professor: %w", err)
+	}
+	if ok {
+		profe = &prf
+	}
+
+	if profe != nil {
+		logger.Info("professor loaded successfully", slog.String("professor type", string(cfg.Professor.Type)))
+	}
+
+	var controller view.Controller
+	if cfg.Remote.Enabled() && len(args) == 0 {
+		client, err := api.NewClient(cfg.Remote.URL, secret(cfg.Remote.Token))
+		if err != nil {
+			return fmt.Errorf("error connecting to the remote library: %w", err)
+		}
+		controller = client
+	} else {
+		if len(args) > 0 && args[0] == rotateKeyCommand {
+			return runRotateKey(ctx, cfg, logger)
+		}
+
+		if len(args) > 0 && args[0] == dbCommand {
+			return runDB(ctx, cfg, logger, args[1:])
+		}
+
+		var opts []manager.OptFunc
+		if profe != nil && profe.EmbeddingModel() != "" {
+			opts = append(opts, manager.WithEmbedder(profe))
+		}
+
+		manager, closeStore, err := newManager(ctx, cfg, logger, opts...)
+		if err != nil {
+			slog.Error("error starting command manager", slog.Any("error", err))
+			return err
+		}
+		defer func() {
+			if err := closeStore(); err != nil {
+				logger.Warn("error closing store", slog.Any("error", err))
+				return
+			}
+			logger.Debug("Store closed successfully")
+		}()
+
+		if retention := cfg.Trash.GetRetention(); retention > 0 {
+			purged, err := manager.PurgeTrash(ctx, retention)
+			if err != nil {
+				logger.Warn("error purging the trash", slog.Any("error", err))
+			} else if purged > 0 {
+				logger.Info("trash purged", slog.Int("commands", purged))
+			}
+		}
+
+		if len(args) > 0 && args[0] == syncCommand {
+			// the subscribed commands aren't synced.
+			return runSync(ctx, cfg, &manager, logger)
+		}
+
+		controller = &manager
+		if len(cfg.Subscriptions) > 0 {
+			controller, err = withSubscriptions(ctx, cfg, &manager, logger)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(args) > 0 {
+			switch args[0] {
+			case serveCommand:
+				return runServe(ctx, cfg, controller, logger, args[1:])
+			default:
+				return fmt.Errorf("unknown command %q", args[0])
+			}
+		}
+	}
+
+	execTargets := make([]target.Target, 0, len(cfg.Execution.Targets))
+	for _, raw := range cfg.Execution.Targets {
+		t, err := target.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("error loading the execution targets: %w", err)
+		}
+		execTargets = append(execTargets, t)
+	}
+
+	ui, err := tui.New(ctx, controller, logger, profe, newOutputTargets(cfg.Output), execTargets)
 	if err != nil {
-		slog.Error("error initializing the local store", slog.Any("error", err))
 		return err
 	}
-	defer func() {
-		if err := sqlStore.Close(); err != nil {
-			logger.Warn("error closing store", slog.Any("error", err))
-			return
+
+	if err := ui.Start(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newOutputTargets returns the targets the composed commands can go to, the default one first.
+// The tmux and file targets are only offered when they can be used.
+func newOutputTargets(cfg config.OutputConfig) []out.Target {
+	targets := map[config.OutputTarget]out.Target{
+		config.TTYOutput:       out.NewTTY(),
+		config.ClipboardOutput: out.NewClipboard(),
+		config.OSC52Output:     out.NewOSC52(os.Stderr),
+		config.TmuxOutput:      out.NewTmux(cfg.TmuxPane),
+		config.StdoutOutput:    out.NewStdout(os.Stdout),
+		config.FileOutput:      out.NewFile(cfg.File),
+	}
+
+	def := cfg.Target
+	switch {
+	case def != "":
+	case !term.IsTerminal(int(os.Stdout.Fd())):
+		def = config.StdoutOutput
+	case os.Getenv("SSH_CONNECTION") != "" || os.Getenv("SSH_TTY") != "":
+		// the input of the terminal can't be injected over SSH.
+		def = config.OSC52Output
+	default:
+		def = config.TTYOutput
+	}
+
+	selectable := []out.Target{targets[def]}
+	for _, name := range []config.OutputTarget{
+		config.TTYOutput,
+		config.ClipboardOutput,
+		config.OSC52Output,
+		config.TmuxOutput,
+		config.StdoutOutput,
+		config.FileOutput,
+	} {
+		switch {
+		case name == def:
+		case name == config.TmuxOutput && cfg.TmuxPane == "" && os.Getenv("TMUX") == "":
+		case name == config.FileOutput && cfg.File == "":
+		default:
+			selectable = append(selectable, targets[name])
 		}
-		logger.Debug("Store closed successfully")
-	}()
+	}
+	return selectable
+}
+
+// newManager returns the command manager over the configured store and the func closing the store.
+func newManager(ctx context.Context, cfg config.App, logger *slog.Logger, opts ...manager.OptFunc) (manager.Manager, func() error, error) {
+	if cfg.Store.GetDriver() == config.FileStoreDriver {
+		path := cfg.Store.Path
+		if path == "" {
+			path = filepath.Join(cfg.GetPath(), libraryDir)
+		}
+
+		fileStore, err := file.New(logger, path)
+		if err != nil {
+			return manager.Manager{}, nil, fmt.Errorf("error initializing the file store: %w", err)
+		}
+
+		mng, err := manager.NewManager(fileStore, fileStore, opts...)
+		return mng, fileStore.Close, err
+	}
+
+	sqlStore, err := newSqlStore(ctx, cfg, logger)
+	if err != nil {
+		return manager.Manager{}, nil, err
+	}
+
+	mng, err := manager.NewManager(sqlStore, sqlStore, opts...)
+	if err != nil {
+		_ = sqlStore.Close()
+		return manager.Manager{}, nil, err
+	}
+
+	// the explanations written before the content search existed are added to it once.
+	if _, err := mng.IndexExplanations(ctx); err != nil {
+		logger.Warn("error indexing the explanations", slog.Any("error", err))
+	}
+	return mng, sqlStore.Close, nil
+}
+
+// newSqlStore returns the sql store, decrypted with the passphrase when the encryption is enabled.
+func newSqlStore(ctx context.Context, cfg config.App, logger *slog.Logger) (*sql.Sql, error) {
+	opts := []sql.SqlOptFunc{sql.WithSqliteDriver(ctx, cfg.GetPath())}
+	if cfg.Store.GetDriver() == config.PostgresStoreDriver {
+		opts[0] = sql.WithPostgresDriver(ctx, cfg.Store.DSN)
+	}
+
+	if cfg.Encryption.Enabled {
+		pass, err := passphrase(ctx, cfg.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sql.WithEncryption(ctx, pass))
+	}
 
-	manager, err := manager.NewManager(sqlStore, sqlStore)
+	store, err := sql.NewSql(logger, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing the store: %w", err)
+	}
+	return store, nil
+}
+
+// runRotateKey encrypts the store with a new passphrase.
+func runRotateKey(ctx context.Context, cfg config.App, logger *slog.Logger) error {
+	if !cfg.Encryption.Enabled {
+		return errors.New("the encryption isn't enabled, enable it in the [encryption] config")
+	}
+
+	store, err := newSqlStore(ctx, cfg, logger)
 	if err != nil {
-		slog.Error("error starting command manager", slog.Any("error", err))
 		return err
 	}
+	defer store.Close()
 
-	var profe *professor.Professor
-	if prf, ok := newProfessor(cfg.Professor, logger); ok {
-		profe = &prf
+	pass := os.Getenv(newPassphraseEnv)
+	if pass == "" {
+		if pass, err = prompt("New passphrase: "); err != nil {
+			return err
+		}
+
+		confirmation, err := prompt("Repeat the new passphrase: ")
+		if err != nil {
+			return err
+		}
+		if pass != confirmation {
+			return errors.New("the passphrases don't match")
+		}
 	}
 
-	if profe != nil {
-		logger.Info("professor loaded successfully", slog.String("professor type", string(cfg.Professor.Type)))
+	if err := store.RotateKey(ctx, pass); err != nil {
+		return fmt.Errorf("error rotating the key: %w", err)
+	}
+
+	fmt.Println("Key rotated. Update the passphrase source if it isn't prompted.")
+	return nil
+}
+
+// runDB runs the maintenance subcommands of the sql store: backup, restore and check.
+func runDB(ctx context.Context, cfg config.App, logger *slog.Logger, args []string) error {
+	if cfg.Store.GetDriver() == config.FileStoreDriver {
+		return errors.New("the db commands aren't supported by the file store")
+	}
+
+	if len(args) == 0 {
+		return errors.New("missing db subcommand, one of [backup, restore, check]")
 	}
 
-	ui, err := tui.New(ctx, &manager, logger, profe)
+	flags := flag.NewFlagSet(dbCommand+" "+args[0], flag.ContinueOnError)
+	dir := flags.String("dir", filepath.Join(cfg.GetPath(), backupsDir), "directory of the backups")
+	keep := flags.Int("keep", defaultBackupKeep, "number of backups kept by backup, the oldest are removed")
+	repair := flags.Bool("repair", false, "rebuild the search index and remove the orphaned rows found by check")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	store, err := newSqlStore(ctx, cfg, logger)
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
-	if err := ui.Start(); err != nil {
+	switch args[0] {
+	case "backup":
+		if err := os.MkdirAll(*dir, 0o700); err != nil {
+			return fmt.Errorf("error creating the backups dir: %w", err)
+		}
+
+		path := filepath.Join(*dir, backupPrefix+time.Now().UTC().Format(backupTimeFormat)+".db")
+		if err := store.Backup(ctx, path); err != nil {
+			return err
+		}
+		fmt.Printf("Backup written to %s\n", path)
+
+		removed, err := rotateBackups(*dir, *keep)
+		if err != nil {
+			return err
+		}
+		for _, path := range removed {
+			fmt.Printf("Old backup %s removed\n", path)
+		}
+		return nil
+	case "restore":
+		path := flags.Arg(0)
+		if path == "" {
+			backups, err := listBackups(*dir)
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				return fmt.Errorf("no backups found in %s", *dir)
+			}
+			path = backups[0]
+		}
+
+		// the current content is kept until the next restore, for undoing it.
+		preRestore := filepath.Join(*dir, preRestoreBackup)
+		if err := os.MkdirAll(*dir, 0o700); err != nil {
+			return fmt.Errorf("error creating the backups dir: %w", err)
+		}
+		if err := os.Remove(preRestore); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error removing the previous pre-restore backup: %w", err)
+		}
+		if err := store.Backup(ctx, preRestore); err != nil {
+			return err
+		}
+
+		if err := store.Restore(ctx, path); err != nil {
+			return err
+		}
+		fmt.Printf("Store restored from %s, the previous content is in %s\n", path, preRestore)
+		return nil
+	case "check":
+		report, err := store.Check(ctx)
+		if err != nil {
+			return err
+		}
+		printCheckReport(report)
+
+		if report.OK() {
+			return nil
+		}
+		if !*repair {
+			return errors.New("problems found in the store, run `clio db check --repair` for repairing them")
+		}
+
+		if err := store.Repair(ctx); err != nil {
+			return err
+		}
+
+		report, err = store.Check(ctx)
+		if err != nil {
+			return err
+		}
+		if !report.OK() {
+			printCheckReport(report)
+			return errors.New("problems remain after the repair, restore a backup")
+		}
+		fmt.Println("Store repaired.")
+		return nil
+	default:
+		return fmt.Errorf("unknown db subcommand %q, one of [backup, restore, check]", args[0])
+	}
+}
+
+// listBackups returns the backups in the dir, the newest first.
+func listBackups(dir string) ([]string, error) {
+	backups, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the timestamp in the name sorts them.
+	slices.Sort(backups)
+	slices.Reverse(backups)
+	return backups, nil
+}
+
+// rotateBackups removes the oldest backups, keeping the given number of them. Returns the removed ones.
+func rotateBackups(dir string, keep int) ([]string, error) {
+	backups, err := listBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, path := range backups[min(max(keep, 1), len(backups)):] {
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("error removing old backup: %w", err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+func printCheckReport(report sql.CheckReport) {
+	if report.OK() {
+		fmt.Println("No problems found.")
+		return
+	}
+
+	for _, problem := range report.Integrity {
+		fmt.Printf("Integrity: %s\n", problem)
+	}
+	if report.UnindexedCommands > 0 {
+		fmt.Printf("Search index: %d commands missing or outdated\n", report.UnindexedCommands)
+	}
+	if report.StaleSearchEntries > 0 {
+		fmt.Printf("Search index: %d entries of removed commands\n", report.StaleSearchEntries)
+	}
+	for _, table := range slices.Sorted(maps.Keys(report.Orphans)) {
+		if n := report.Orphans[table]; n > 0 {
+			fmt.Printf("Orphans: %d %s rows without command\n", n, table)
+		}
+	}
+}
+
+// passphrase returns the passphrase of the store from the env var, the key command or the terminal, in that order.
+func passphrase(ctx context.Context, cfg config.EncryptionConfig) (string, error) {
+	if pass := os.Getenv(passphraseEnv); pass != "" {
+		return pass, nil
+	}
+
+	if cfg.KeyCommand != "" {
+		out, err := exec.CommandContext(ctx, "sh", "-c", cfg.KeyCommand).Output()
+		if err != nil {
+			return "", fmt.Errorf("error running the key command: %w", err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	return prompt("Passphrase: ")
+}
+
+// prompt reads a secret from the terminal, without echoing it.
+func prompt(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("missing passphrase, set the %s env var or the key command in the [encryption] config", passphraseEnv)
+	}
+
+	fmt.Fprint(os.Stderr, label)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("error reading the passphrase: %w", err)
+	}
+	return string(secret), nil
+}
+
+// runSync syncs the library with the configured git repository and prints the summary.
+func runSync(ctx context.Context, cfg config.App, mng *manager.Manager, logger *slog.Logger) error {
+	path := cfg.Sync.Path
+	if path == "" {
+		path = filepath.Join(cfg.GetPath(), syncDir)
+	}
+
+	message := "Sync library"
+	if host, err := os.Hostname(); err == nil {
+		message = fmt.Sprintf("Sync library from %s", host)
+	}
+
+	syncer, err := gitsync.New(mng, logger, path,
+		gitsync.WithRemote(cfg.Sync.Remote),
+		gitsync.WithBranch(cfg.Sync.Branch),
+		gitsync.WithMessage(message),
+	)
+	if err != nil {
 		return err
 	}
 
+	report, err := syncer.Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("error syncing the library: %w", err)
+	}
+
+	fmt.Printf("Library synced: %d exported, %d imported, %d deleted.\n", report.Exported, report.Imported, report.Deleted)
+	for _, conflict := range report.Conflicts {
+		fmt.Printf("Conflict on %q (%s): kept the local version.\n", conflict.Name, conflict.ID)
+	}
+
 	return nil
 }
 
+// withSubscriptions adds the commands of the subscriptions to the library, as read-only.
+func withSubscriptions(ctx context.Context, cfg config.App, mng *manager.Manager, logger *slog.Logger) (*catalog.Library, error) {
+	library, err := catalog.NewLibrary(mng, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]catalog.Subscription, 0, len(cfg.Subscriptions))
+	for _, sub := range cfg.Subscriptions {
+		subs = append(subs, catalog.Subscription{
+			Name:      sub.Name,
+			Location:  sub.Location(),
+			SHA256:    sub.SHA256,
+			PublicKey: sub.PublicKey,
+		})
+	}
+
+	// the cached copies are used when the bundles can't be fetched in time.
+	fetchCtx, cancel := context.WithTimeout(ctx, subscriptionsTimeout)
+	defer cancel()
+
+	fetcher := catalog.NewFetcher(logger, filepath.Join(cfg.GetPath(), subscriptionsDir))
+	library.Load(fetchCtx, fetcher, subs)
+
+	return library, nil
+}
+
+// runServe serves the library over HTTP until the context is done.
+func runServe(ctx context.Context, cfg config.App, library view.Controller, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet(serveCommand, flag.ContinueOnError)
+	listen := flags.String("listen", cfg.Server.GetListen(), "address to listen on, e.g. 127.0.0.1:7070")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	token := secret(cfg.Server.Token)
+	if token == "" {
+		return fmt.Errorf("missing api token, set it in the [server] config or the %s env var", apiTokenEnv)
+	}
+
+	server, err := api.NewServer(library, logger, token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Serving the library on http://%s\n", *listen)
+	return server.ListenAndServe(ctx, *listen)
+}
+
+// secret returns the configured token, or the one in the env var if not set.
+func secret(token string) string {
+	if token != "" {
+		return token
+	}
+	return os.Getenv(apiTokenEnv)
+}
+
 func initLogger(debug bool) (logger *slog.Logger, close func() error, err error) {
 	logLevel := slog.LevelInfo
 	if debug {
@@ -116,27 +634,56 @@ func initLogger(debug bool) (logger *slog.Logger, close func() error, err error)
 	return logger, file.Close, nil
 }
 
-func newProfessor(cfg config.ProfessorConfig, logger *slog.Logger) (professor.Professor, bool) {
+func newProfessor(cfg config.ProfessorConfig, logger *slog.Logger) (professor.Professor, bool, error) {
 	if !cfg.Enabled {
-		return professor.Professor{}, false
+		return professor.Professor{}, false, nil
 	}
 
-	var source professor.Source
-	switch cfg.Type {
-	default:
-		opts := make([]openai.OptFunc, 0)
-		if cfg.OpenAI.Url != "" {
-			opts = append(opts, openai.WithBaseUrl(cfg.OpenAI.Url))
+	retry := professor.RetryPolicy{
+		Attempts:   cfg.Retry.GetAttempts(),
+		Backoff:    cfg.Retry.GetBackoff(),
+		MaxBackoff: cfg.Retry.GetMaxBackoff(),
+	}
+
+	sources := make([]professor.Source, 0)
+	for _, name := range cfg.Sources() {
+		decode := func(v any) error {
+			return cfg.DecodeSource(name, v)
+		}
+
+		source, err := professor.NewSource(logger, cfg.Provider(name), decode)
+		if err != nil {
+			return professor.Professor{}, false, fmt.Errorf("error loading source %q: %w", name, err)
+		}
+
+		source = professor.WithTimeout(source, cfg.GetTimeout())
+		sources = append(sources, professor.WithRetry(source, retry, logger))
+	}
+
+	opts := make([]professor.OptFunc, 0, len(cfg.Templates)+2)
+	for _, t := range cfg.Templates {
+		tmpl, err := professor.NewTemplate(t.Name, t.Description, t.Text)
+		if err != nil {
+			return professor.Professor{}, false, err
 		}
-		if cfg.OpenAI.Model != "" {
-			opts = append(opts, openai.WithModel(cfg.OpenAI.Model))
+		opts = append(opts, professor.WithTemplate(tmpl))
+	}
+
+	// the custom prompt of the openai source predates the templates.
+	if prompt := cfg.CustomPrompt(); prompt != "" {
+		tmpl, err := professor.NewTemplate(customPromptTemplate, "custom prompt of the openai config", prompt)
+		if err != nil {
+			return professor.Professor{}, false, err
 		}
-		if cfg.OpenAI.CustomPrompt != "" {
-			opts = append(opts, openai.WithCustomContext(cfg.OpenAI.ApiKey))
+		opts = append(opts, professor.WithTemplate(tmpl))
+		if cfg.Template == "" {
+			opts = append(opts, professor.WithDefaultTemplate(customPromptTemplate))
 		}
+	}
 
-		source = openai.New(logger, cfg.OpenAI.ApiKey, opts...)
+	if cfg.Template != "" {
+		opts = append(opts, professor.WithDefaultTemplate(cfg.Template))
 	}
 
-	return professor.New(source, logger), true
+	return professor.New(professor.Chain(logger, sources...), logger, opts...), true, nil
 }
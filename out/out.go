@@ -3,16 +3,40 @@ package out
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"syscall"
 	"unsafe"
 
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
 	"golang.org/x/sys/unix"
 )
 
+// Target receives the command composed in clio.
+type Target interface {
+	// Name identifies the target, e.g. in the execute panel.
+	Name() string
+	Produce(text string) error
+}
+
+// TTY injects the command in the input of the terminal, as if it was typed, and clears the screen.
+// It fails in the SSH sessions and in the kernels without the legacy TIOCSTI.
+type TTY struct{}
+
+// NewTTY returns a new TTY target.
+func NewTTY() TTY {
+	return TTY{}
+}
+
+// Name returns the name of the target.
+func (TTY) Name() string {
+	return "tty"
+}
+
 // Produce injects the text in the stdin buffer.
-func Produce(text string) error {
+func (TTY) Produce(text string) error {
 	fd, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
 	if err != nil {
 		return fmt.Errorf("error oppening tty: %v", err)
@@ -27,14 +51,148 @@ func Produce(text string) error {
 		}
 	}
 
-	return nil
-}
-
-// Clear clears the buffer
-func Clear() error {
+	// clears the screen
 	c := exec.Command("clear")
 	c.Stdout = os.Stdout
 	c.Run()
 
 	return nil
 }
+
+// Clipboard copies the command to the system clipboard.
+type Clipboard struct{}
+
+// NewClipboard returns a new Clipboard target.
+func NewClipboard() Clipboard {
+	return Clipboard{}
+}
+
+// Name returns the name of the target.
+func (Clipboard) Name() string {
+	return "clipboard"
+}
+
+// Produce copies the text to the clipboard.
+func (Clipboard) Produce(text string) error {
+	if err := clipboard.WriteAll(text); err != nil {
+		return fmt.Errorf("error copying to the clipboard: %w", err)
+	}
+	return nil
+}
+
+// OSC52 copies the command to the clipboard of the terminal with the OSC 52 escape sequence.
+// It works in the SSH sessions, the terminal of the local machine sets the clipboard.
+type OSC52 struct {
+	w    io.Writer
+	tmux bool
+}
+
+// NewOSC52 returns a new OSC52 target writing the sequence to the terminal.
+// Inside tmux, the sequence is wrapped for passing through it.
+func NewOSC52(w io.Writer) OSC52 {
+	return OSC52{
+		w:    w,
+		tmux: os.Getenv("TMUX") != "",
+	}
+}
+
+// Name returns the name of the target.
+func (OSC52) Name() string {
+	return "osc52"
+}
+
+// Produce writes the sequence setting the clipboard to the text.
+func (o OSC52) Produce(text string) error {
+	seq := osc52.New(text)
+	if o.tmux {
+		seq = seq.Tmux()
+	}
+
+	if _, err := seq.WriteTo(o.w); err != nil {
+		return fmt.Errorf("error writing the osc52 sequence: %w", err)
+	}
+	return nil
+}
+
+// Tmux sends the command to a tmux pane, as if it was typed.
+type Tmux struct {
+	pane string
+}
+
+// NewTmux returns a new Tmux target sending the commands to the pane, the current one if empty.
+func NewTmux(pane string) Tmux {
+	return Tmux{pane: pane}
+}
+
+// Name returns the name of the target.
+func (Tmux) Name() string {
+	return "tmux"
+}
+
+// Produce sends the text to the pane.
+func (t Tmux) Produce(text string) error {
+	if out, err := exec.Command("tmux", t.args(text)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("error sending keys to tmux: %w: %s", err, out)
+	}
+	return nil
+}
+
+// args returns the arguments of tmux for typing the text in the pane.
+func (t Tmux) args(text string) []string {
+	args := []string{"send-keys"}
+	if t.pane != "" {
+		args = append(args, "-t", t.pane)
+	}
+	// the text is sent literally, not as key names.
+	return append(args, "-l", "--", text)
+}
+
+// Stdout prints the command, e.g. for `$(clio)`.
+type Stdout struct {
+	w io.Writer
+}
+
+// NewStdout returns a new Stdout target.
+func NewStdout(w io.Writer) Stdout {
+	return Stdout{w: w}
+}
+
+// Name returns the name of the target.
+func (Stdout) Name() string {
+	return "stdout"
+}
+
+// Produce prints the text.
+func (s Stdout) Produce(text string) error {
+	_, err := fmt.Fprintln(s.w, text)
+	return err
+}
+
+// File appends the command to a file.
+type File struct {
+	path string
+}
+
+// NewFile returns a new File target appending the commands to the file in the path.
+func NewFile(path string) File {
+	return File{path: path}
+}
+
+// Name returns the name of the target.
+func (File) Name() string {
+	return "file"
+}
+
+// Produce appends the text to the file, as a line.
+func (f File) Produce(text string) error {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening the output file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintln(file, text); err != nil {
+		return fmt.Errorf("error writing the output file: %w", err)
+	}
+	return nil
+}
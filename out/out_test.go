@@ -0,0 +1,87 @@
+package out
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSC52_Produce(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmux     bool
+		expected string
+	}{
+		{
+			name:     "terminal",
+			expected: "\x1b]52;c;a3ViZWN0bCBnZXQgcG9kcw==\x07",
+		},
+		{
+			name:     "tmux",
+			tmux:     true,
+			expected: "\x1bPtmux;\x1b\x1b]52;c;a3ViZWN0bCBnZXQgcG9kcw==\x07\x1b\\",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			target := OSC52{w: &buf, tmux: tt.tmux}
+
+			require.NoError(t, target.Produce("kubectl get pods"))
+			assert.Equal(t, tt.expected, buf.String())
+		})
+	}
+}
+
+func TestTmux_args(t *testing.T) {
+	tests := []struct {
+		name     string
+		pane     string
+		expected []string
+	}{
+		{
+			name:     "current pane",
+			expected: []string{"send-keys", "-l", "--", "-n kube-system"},
+		},
+		{
+			name:     "target pane",
+			pane:     "work:1.0",
+			expected: []string{"send-keys", "-t", "work:1.0", "-l", "--", "-n kube-system"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NewTmux(tt.pane).args("-n kube-system"))
+		})
+	}
+}
+
+func TestStdout_Produce(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewStdout(&buf).Produce("du -sh *"))
+	assert.Equal(t, "du -sh *\n", buf.String())
+}
+
+func TestFile_Produce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.sh")
+	target := NewFile(path)
+
+	require.NoError(t, target.Produce("du -sh *"))
+	require.NoError(t, target.Produce("kubectl get pods"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "du -sh *\nkubectl get pods\n", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	assert.Error(t, NewFile(filepath.Join(t.TempDir(), "missing", "commands.sh")).Produce("du -sh *"))
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
 
-	"github.com/lian-rr/clio/command/manager"
 	"github.com/lian-rr/clio/command/professor"
+	"github.com/lian-rr/clio/command/target"
 	"github.com/lian-rr/clio/out"
 	"github.com/lian-rr/clio/tui/view"
 )
@@ -20,25 +22,33 @@ type Tui struct {
 	logger  *slog.Logger
 }
 
-// New returns a new TUI container.
-func New(ctx context.Context, manager *manager.Manager, logger *slog.Logger, professor *professor.Professor) (Tui, error) {
-	opts := make([]view.OptFunc, 0)
+// New returns a new TUI container. The composed commands go to the first target, unless another one is chosen.
+func New(ctx context.Context, controller view.Controller, logger *slog.Logger, professor *professor.Professor, targets []out.Target, execTargets []target.Target) (Tui, error) {
+	opts := []view.OptFunc{
+		view.WithOutputTargets(targets),
+		view.WithExecutionTargets(execTargets),
+	}
 	if professor != nil {
 		opts = append(opts, view.WithProfessor(professor))
 	}
 
-	model, err := view.New(ctx, manager, logger, opts...)
+	programOpts := []tea.ProgramOption{
+		tea.WithContext(ctx),
+		tea.WithAltScreen(),
+	}
+	// e.g. in `$(clio)`, the stdout is only for the command.
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		programOpts = append(programOpts, tea.WithOutput(os.Stderr))
+	}
+
+	model, err := view.New(ctx, controller, logger, opts...)
 	if err != nil {
 		return Tui{}, fmt.Errorf("error starting the main model: %w", err)
 	}
 
 	return Tui{
-		program: tea.NewProgram(
-			model,
-			tea.WithContext(ctx),
-			tea.WithAltScreen(),
-		),
-		logger: logger,
+		program: tea.NewProgram(model, programOpts...),
+		logger:  logger,
 	}, nil
 }
 
@@ -53,10 +63,11 @@ func (t *Tui) Start() error {
 	if !ok {
 		return errors.New("error getting last model")
 	}
-	if mm.Output != "" {
-		t.logger.Debug("program output", slog.String("command", mm.Output))
-		out.Produce(mm.Output)
-		out.Clear()
+	if mm.Output != "" && mm.Target != nil {
+		t.logger.Debug("program output", slog.String("command", mm.Output), slog.String("target", mm.Target.Name()))
+		if err := mm.Target.Produce(mm.Output); err != nil {
+			return fmt.Errorf("error sending the command to %s: %w", mm.Target.Name(), err)
+		}
 	}
 
 	return nil
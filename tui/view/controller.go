@@ -5,24 +5,45 @@ import (
 	"log/slog"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
 	"github.com/lian-rr/clio/tui/view/msgs"
 )
 
-type controller interface {
+// Controller handles the commands shown in the view, e.g. the manager or a remote clio server.
+type Controller interface {
 	GetAll(context.Context) ([]command.Command, error)
 	GetOne(context.Context, string) (command.Command, error)
-	Search(context.Context, string) ([]command.Command, error)
+	Search(context.Context, string) ([]command.SearchResult, error)
 	Add(context.Context, command.Command) (command.Command, error)
 	DeleteCommand(context.Context, string) error
 	UpdateCommand(context.Context, command.Command) (command.Command, error)
-	WriteExplanation(context.Context, uuid.UUID, string) error
-	ReadExplanation(context.Context, uuid.UUID) (string, error)
+	WriteExplanation(context.Context, uuid.UUID, command.Explanation) error
+	ReadExplanation(context.Context, uuid.UUID) (command.Explanation, error)
 	DeleteExplanation(context.Context, uuid.UUID) error
-	InsertUsage(context.Context, uuid.UUID, string) error
+	WriteTranscript(context.Context, uuid.UUID, []command.Message) error
+	ReadTranscript(context.Context, uuid.UUID) ([]command.Message, error)
+	InsertUsage(context.Context, uuid.UUID, command.Usage) error
 	GetHistory(context.Context, uuid.UUID) (command.History, error)
+	Revisions(context.Context, uuid.UUID) ([]command.Revision, error)
+	RestoreRevision(context.Context, uuid.UUID, int64) (command.Command, error)
+	Trash(context.Context) ([]command.DeletedCommand, error)
+	RestoreCommand(context.Context, uuid.UUID) (command.Command, error)
+	PurgeCommand(context.Context, uuid.UUID) error
+}
+
+const (
+	// semanticSearchTimeout bounds the semantic queries, embedded by the professor source.
+	semanticSearchTimeout = 10 * time.Second
+	// indexEmbeddingsTimeout bounds each run computing the missing embeddings.
+	indexEmbeddingsTimeout = 5 * time.Minute
+)
+
+// embeddingIndex is implemented by the controllers keeping the embeddings of the commands.
+type embeddingIndex interface {
+	IndexEmbeddings(context.Context) (int, error)
 }
 
 func (m *Main) fechCommands() ([]command.Command, error) {
@@ -32,13 +53,28 @@ func (m *Main) fechCommands() ([]command.Command, error) {
 	return m.commandController.GetAll(ctx)
 }
 
-func (m *Main) searchCommands(term string) ([]command.Command, error) {
+func (m *Main) searchCommands(term string) ([]command.SearchResult, error) {
 	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*300)
 	defer cancel()
 
 	return m.commandController.Search(ctx, term)
 }
 
+// searchSemantic returns the cmd searching the commands by meaning, out of the update loop.
+func (m *Main) searchSemantic(terms string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(m.ctx, semanticSearchTimeout)
+		defer cancel()
+
+		results, err := m.commandController.Search(ctx, terms)
+		return searchResultsMsg{
+			Terms:   terms,
+			Results: results,
+			Err:     err,
+		}
+	}
+}
+
 func (m *Main) fechFullCommand(id string) (command.Command, error) {
 	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*200)
 	defer cancel()
@@ -55,9 +91,11 @@ func (m *Main) saveCommand(cmd command.Command) error {
 		return err
 	}
 
+	m.explorerPanel.SetMissing(cmd.ID, m.checker.Missing(cmd))
 	idx := m.explorerPanel.AddCommand(cmd)
 	m.explorerPanel.Select(idx)
 	m.detailPanel.SetCommand(cmd)
+	m.requestEmbeddings()
 
 	return nil
 }
@@ -71,8 +109,26 @@ func (m *Main) editCommand(cmd command.Command) error {
 		return err
 	}
 
+	m.explorerPanel.SetMissing(newCmd.ID, m.checker.Missing(newCmd))
 	m.explorerPanel.RefreshCommand(newCmd)
 	m.detailPanel.SetCommand(newCmd)
+	m.requestEmbeddings()
+	return nil
+}
+
+func (m *Main) restoreRevision(commandID uuid.UUID, revisionID int64) error {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*200)
+	defer cancel()
+
+	cmd, err := m.commandController.RestoreRevision(ctx, commandID, revisionID)
+	if err != nil {
+		return err
+	}
+
+	m.explorerPanel.SetMissing(cmd.ID, m.checker.Missing(cmd))
+	m.explorerPanel.RefreshCommand(cmd)
+	m.detailPanel.SetCommand(cmd)
+	m.requestEmbeddings()
 	return nil
 }
 
@@ -98,7 +154,37 @@ func (m *Main) removeCommand(cmd command.Command) error {
 	return nil
 }
 
-func (m *Main) saveUsage(commandID uuid.UUID, usage string) error {
+// restoreCommand takes the command out of the trash and selects it in the explorer.
+func (m *Main) restoreCommand(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*200)
+	defer cancel()
+
+	cmd, err := m.commandController.RestoreCommand(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if m.lastDeleted != nil && m.lastDeleted.ID == id {
+		m.lastDeleted = nil
+		m.detailPanel.SetToast("")
+	}
+
+	m.explorerPanel.SetMissing(cmd.ID, m.checker.Missing(cmd))
+	idx := m.explorerPanel.AddCommand(cmd)
+	m.explorerPanel.Select(idx)
+	m.detailPanel.SetCommand(cmd)
+	m.requestEmbeddings()
+	return nil
+}
+
+func (m *Main) purgeCommand(id uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*200)
+	defer cancel()
+
+	return m.commandController.PurgeCommand(ctx, id)
+}
+
+func (m *Main) saveUsage(commandID uuid.UUID, usage command.Usage) error {
 	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*200)
 	defer cancel()
 
@@ -128,3 +214,84 @@ func (m *Main) getHistory(commandID uuid.UUID) {
 		msgs.HandleSetHistoryMsg(history),
 	)
 }
+
+func (m *Main) getRevisions(commandID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*500)
+	defer cancel()
+
+	revisions, err := m.commandController.Revisions(ctx, commandID)
+	if err != nil {
+		m.logger.Error("error getting command revisions",
+			slog.Any("commandID", commandID),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	msgs.PublishAsyncMsg(
+		m.activityChan,
+		msgs.HandleSetRevisionsMsg(revisions),
+	)
+}
+
+// checkPrograms checks the programs of the commands are installed, the commands missing some are published.
+func (m *Main) checkPrograms() {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*500)
+	defer cancel()
+
+	cmds, err := m.commandController.GetAll(ctx)
+	if err != nil {
+		m.logger.Error("error getting the commands for checking their programs", slog.Any("error", err))
+		return
+	}
+
+	msgs.PublishAsyncMsg(
+		m.activityChan,
+		msgs.HandleProgramsCheckedMsg(m.checker.MissingAll(cmds)),
+	)
+}
+
+// indexEmbeddings computes the missing embeddings of the commands each time they are requested, until the view
+// is done. The requests made while computing them are merged into the next run.
+func (m *Main) indexEmbeddings(index embeddingIndex) {
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-m.reindex:
+		}
+
+		ctx, cancel := context.WithTimeout(m.ctx, indexEmbeddingsTimeout)
+		n, err := index.IndexEmbeddings(ctx)
+		cancel()
+		if err != nil {
+			m.logger.Warn("error computing the embeddings", slog.Any("error", err))
+			continue
+		}
+		m.logger.Debug("embeddings computed", slog.Int("commands", n))
+	}
+}
+
+// requestEmbeddings requests computing the missing embeddings, e.g. the one of a saved command.
+func (m *Main) requestEmbeddings() {
+	select {
+	case m.reindex <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Main) getTrash() {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*500)
+	defer cancel()
+
+	trash, err := m.commandController.Trash(ctx)
+	if err != nil {
+		m.logger.Error("error getting the trash", slog.Any("error", err))
+		return
+	}
+
+	msgs.PublishAsyncMsg(
+		m.activityChan,
+		msgs.HandleSetTrashMsg(trash),
+	)
+}
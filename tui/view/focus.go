@@ -15,6 +15,9 @@ const (
 	executeFocus
 	explainFocus
 	historyFocus
+	revisionsFocus
+	trashFocus
+	runFocus
 )
 
 type updateFocusMsg struct {
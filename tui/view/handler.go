@@ -3,8 +3,10 @@ package view
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"reflect"
+	"slices"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -13,12 +15,39 @@ import (
 
 	"github.com/lian-rr/clio/command"
 	"github.com/lian-rr/clio/command/manager"
+	"github.com/lian-rr/clio/command/search"
+	"github.com/lian-rr/clio/command/target"
 	"github.com/lian-rr/clio/tui/components/dialog"
 	"github.com/lian-rr/clio/tui/view/msgs"
 	"github.com/lian-rr/clio/tui/view/panel"
 )
 
-const minCharCount = 3
+const (
+	minCharCount = 3
+	// undoTimeout is how long a deleted command can be restored with undo.
+	undoTimeout = 5 * time.Second
+	// semanticSearchDelay is how long the typing pauses before a semantic query is sent to the embedder.
+	semanticSearchDelay = 400 * time.Millisecond
+)
+
+var errProfessorNotAvailable = errors.New("explanations not available: enable the professor in the config")
+
+// undoExpiredMsg is sent when the undo of the deleted command is no longer offered.
+type undoExpiredMsg struct {
+	CommandID uuid.UUID
+}
+
+// semanticSearchMsg is sent when the typing of a semantic query pauses.
+type semanticSearchMsg struct {
+	Terms string
+}
+
+// searchResultsMsg is sent when a search running outside the update loop is done.
+type searchResultsMsg struct {
+	Terms   string
+	Results []command.SearchResult
+	Err     error
+}
 
 func (m *Main) handleInput(msg tea.Msg) tea.Cmd {
 	// TODO: this is getting anoying, review this later, consider approach where the handlers are registered and then with a map[focus]handler chosen.
@@ -34,6 +63,12 @@ func (m *Main) handleInput(msg tea.Msg) tea.Cmd {
 			return m.handleExplainInput(msg)
 		case historyFocus:
 			return m.handleHistoryInput(msg)
+		case revisionsFocus:
+			return m.handleRevisionsInput(msg)
+		case trashFocus:
+			return m.handleTrashInput(msg)
+		case runFocus:
+			return m.handleRunInput(msg)
 		default:
 			return m.handleNavigationInput(msg)
 		}
@@ -102,6 +137,11 @@ func (m *Main) handleNavigationInput(msg tea.Msg) tea.Cmd {
 				break
 			}
 
+			if item.Command.ReadOnly() {
+				m.logger.Warn("read-only command can't be edited", slog.String("source", item.Command.Source))
+				break
+			}
+
 			return changeFocus(editFocus, func(m *Main) {
 				err := m.editPanel.SetCommand(panel.EditCommandMode, item.Command)
 				if err != nil {
@@ -114,23 +154,29 @@ func (m *Main) handleNavigationInput(msg tea.Msg) tea.Cmd {
 				break
 			}
 
+			cmd := copyCommand(*item.Command)
 			return changeFocus(editFocus, func(m *Main) {
-				err := m.editPanel.SetCommand(panel.NewCommandMode, item.Command)
+				err := m.editPanel.SetCommand(panel.NewCommandMode, &cmd)
 				if err != nil {
 					m.logger.Error("error setting edit view content", slog.Any("error", err))
 				}
 			})
 		case key.Matches(msg, m.keys.Explain):
-			if m.professor == nil {
-				m.logger.Warn("professor not available")
-				break
-			}
-
 			item, ok := m.explorerPanel.SelectedCommand()
 			if !ok {
 				break
 			}
 
+			if m.professor == nil {
+				m.logger.Warn("professor not available")
+				return changeFocus(explainFocus, func(m *Main) {
+					if err := m.explainPanel.SetCommand(*item.Command); err != nil {
+						m.logger.Error("error setting explain view content", slog.Any("error", err))
+					}
+					m.explainPanel.SetError(item.Command.ID, errProfessorNotAvailable)
+				})
+			}
+
 			msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRequestExplanationMsg(*item.Command))
 
 			return changeFocus(explainFocus, func(m *Main) {
@@ -153,7 +199,41 @@ func (m *Main) handleNavigationInput(msg tea.Msg) tea.Cmd {
 					m.logger.Error("error setting history view content", slog.Any("error", err))
 				}
 			})
+		case key.Matches(msg, m.keys.Revisions):
+			item, ok := m.explorerPanel.SelectedCommand()
+			if !ok {
+				break
+			}
+
+			if item.Command.ReadOnly() {
+				m.logger.Warn("read-only command has no revisions", slog.String("source", item.Command.Source))
+				break
+			}
+
+			msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRequestRevisionsMsg(item.Command.ID))
+
+			return changeFocus(revisionsFocus, func(m *Main) {
+				m.revisionPanel.SetCommand(*item.Command)
+			})
+		case key.Matches(msg, m.keys.Trash):
+			msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRequestTrashMsg())
+
+			return changeFocus(trashFocus, func(m *Main) {
+				m.trashPanel.Reset()
+			})
+		case key.Matches(msg, m.keys.Undo):
+			if m.lastDeleted == nil {
+				break
+			}
+
+			if err := m.restoreCommand(m.lastDeleted.ID); err != nil {
+				m.logger.Error("error undoing command deletion", slog.Any("command", *m.lastDeleted), slog.Any("error", err))
+			}
 		case key.Matches(msg, m.keys.Delete):
+			if item, ok := m.explorerPanel.SelectedCommand(); ok && item.Command.ReadOnly() {
+				m.logger.Warn("read-only command can't be deleted", slog.String("source", item.Command.Source))
+				break
+			}
 			return m.detailPanel.ToggleConfirmation()
 		default:
 			m.explorerPanel, cmd = m.explorerPanel.Update(msg)
@@ -170,6 +250,10 @@ func (m *Main) handleNavigationInput(msg tea.Msg) tea.Cmd {
 				}
 
 				item.Command.Params = c.Params
+				item.Command.Target = c.Target
+				item.Command.Env = c.Env
+				item.Command.Dir = c.Dir
+				item.Command.Requires = c.Requires
 				item.Loaded = true
 
 				m.logger.Debug("command details fetched successfully", slog.Any("command", c))
@@ -187,9 +271,17 @@ func (m *Main) handleNavigationInput(msg tea.Msg) tea.Cmd {
 			break
 		}
 
-		if err := m.removeCommand(*item.Command); err != nil {
-			m.logger.Error("error removing command", slog.Any("command", *item.Command), slog.Any("error", err))
+		deleted := *item.Command
+		if err := m.removeCommand(deleted); err != nil {
+			m.logger.Error("error removing command", slog.Any("command", deleted), slog.Any("error", err))
+			break
 		}
+
+		m.lastDeleted = &deleted
+		m.detailPanel.SetToast(fmt.Sprintf("%q moved to the trash, press %s to undo.", deleted.Name, m.keys.Undo.Help().Key))
+		return tea.Tick(undoTimeout, func(time.Time) tea.Msg {
+			return undoExpiredMsg{CommandID: deleted.ID}
+		})
 	case dialog.DiscardMsg:
 		_ = m.detailPanel.ToggleConfirmation()
 	}
@@ -281,20 +373,17 @@ func (m *Main) handleSearchInput(msg tea.Msg) tea.Cmd {
 			terms := m.searchPanel.Content()
 			if len(terms) >= minCharCount {
 				m.searching = true
-				cmds, err := m.searchCommands(terms)
-				if err != nil {
-					m.logger.Error("error searching for commands",
-						slog.String("terms", terms),
-						slog.Any("error", err),
-					)
-					break
-				}
-				m.setContent(cmds)
-				if len(cmds) > 0 {
-					m.explorerPanel.Select(0)
+				// the semantic queries are embedded remotely, so they are sent once the typing pauses.
+				if q, err := search.Parse(terms); err == nil && q.Semantic {
+					return tea.Batch(cmd, tea.Tick(semanticSearchDelay, func(time.Time) tea.Msg {
+						return semanticSearchMsg{Terms: terms}
+					}))
 				}
+				results, err := m.searchCommands(terms)
+				m.showResults(terms, results, err)
 			} else if m.searching && len(terms) == 0 {
 				m.searching = false
+				m.searchPanel.SetError(nil)
 				getAll()
 			}
 		}
@@ -310,7 +399,34 @@ func (m *Main) handleExplainInput(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
-		case key.Matches(msg, m.keys.Back):
+		case key.Matches(msg, m.keys.Regenerate) && m.professor != nil && m.explainPanel.CanRegenerate():
+			item, ok := m.explorerPanel.SelectedCommand()
+			if !ok {
+				break
+			}
+
+			template := m.explainPanel.Template()
+			if err := m.explainPanel.SetCommand(*item.Command); err != nil {
+				m.logger.Error("error setting explain view content", slog.Any("error", err))
+			}
+			m.explainPanel.SetTemplate(template)
+			msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRegenerateExplanationMsg(*item.Command, template))
+			return m.explainPanel.Init()
+		case key.Matches(msg, m.keys.Template) && m.professor != nil && m.explainPanel.CanRegenerate():
+			item, ok := m.explorerPanel.SelectedCommand()
+			if !ok {
+				break
+			}
+
+			template := nextTemplate(m.professor.Templates(), m.explainPanel.Template())
+			if err := m.explainPanel.SetCommand(*item.Command); err != nil {
+				m.logger.Error("error setting explain view content", slog.Any("error", err))
+			}
+			m.explainPanel.SetTemplate(template)
+			msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRegenerateExplanationMsg(*item.Command, template))
+			return m.explainPanel.Init()
+		// while asking, back only leaves the input
+		case key.Matches(msg, m.keys.Back) && !m.explainPanel.InputFocused():
 			return changeFocus(navigationFocus, func(m *Main) {
 				item, ok := m.explorerPanel.SelectedCommand()
 				if !ok {
@@ -355,25 +471,132 @@ func (m *Main) handleHistoryInput(msg tea.Msg) tea.Cmd {
 	return cmd
 }
 
+func (m *Main) handleRunInput(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Back) && !m.runPanel.Running():
+			return changeFocus(navigationFocus, func(m *Main) {
+				item, ok := m.explorerPanel.SelectedCommand()
+				if !ok {
+					return
+				}
+				if err := m.detailPanel.SetCommand(*item.Command); err != nil {
+					m.logger.Error("error setting detail view content", slog.Any("error", err))
+				}
+			})
+		default:
+			m.runPanel, cmd = m.runPanel.Update(msg)
+		}
+	default:
+		// pass control for any other event
+		m.runPanel, cmd = m.runPanel.Update(msg)
+	}
+	return cmd
+}
+
+func (m *Main) handleRevisionsInput(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Back):
+			return changeFocus(navigationFocus, func(m *Main) {
+				item, ok := m.explorerPanel.SelectedCommand()
+				if !ok {
+					return
+				}
+				if err := m.detailPanel.SetCommand(*item.Command); err != nil {
+					m.logger.Error("error setting detail view content", slog.Any("error", err))
+				}
+			})
+		default:
+			m.revisionPanel, cmd = m.revisionPanel.Update(msg)
+		}
+	default:
+		// pass control for any other event
+		m.revisionPanel, cmd = m.revisionPanel.Update(msg)
+	}
+	return cmd
+}
+
+func (m *Main) handleTrashInput(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Back) && !m.confirmation:
+			return changeFocus(navigationFocus, nil)
+		default:
+			m.trashPanel, cmd = m.trashPanel.Update(msg)
+		}
+	default:
+		// pass control for any other event
+		m.trashPanel, cmd = m.trashPanel.Update(msg)
+	}
+	return cmd
+}
+
 func (m *Main) handleAsyncActivities(msg tea.Msg) tea.Cmd {
 	switch msg := msg.(type) {
 	case msgs.RequestExplanationMsg:
-		go m.fetchExplanation(msg.Command)
+		go m.fetchExplanation(msg.Command, "")
 	case msgs.SetExplanationMsg:
 		if msg.Cache {
 			go m.cacheExplanation(msg.CommandID, msg.Explanation)
 		}
-		m.explainPanel.SetExplanation(msg.Explanation)
+		m.explainPanel.SetExplanation(msg.Explanation, msg.Stale)
 	case msgs.CacheExplanationMsg:
 		go m.cacheExplanation(msg.CommandID, msg.Explanation)
+	case msgs.SetTranscriptMsg:
+		if msg.Cache {
+			go m.cacheTranscript(msg.CommandID, msg.Transcript)
+		}
+		if err := m.explainPanel.SetTranscript(msg.CommandID, msg.Transcript); err != nil {
+			m.logger.Error("error setting transcript", slog.Any("error", err))
+		}
+	case msgs.ProfessorErrorMsg:
+		m.explainPanel.SetError(msg.CommandID, msg.Err)
 	case msgs.EvictCachedExplanationMsg:
-		go m.deleteExplanation(msg.CommandID)
+		go func() {
+			m.deleteExplanation(msg.CommandID)
+			if msg.Regenerate != nil {
+				m.fetchExplanation(*msg.Regenerate, msg.Template)
+			}
+		}()
 	case msgs.SaveUsageMsg:
-		go m.saveUsage(msg.CommandID, msg.Usage)
+		go m.saveUsage(msg.CommandID, command.Usage{Command: msg.Usage})
+	case msgs.RunFinishedMsg:
+		m.runPanel.Finish(msg.CommandID, msg.Result, msg.Err)
+		if msg.Err != nil {
+			m.logger.Error("error running command", slog.String("command", msg.Command), slog.Any("error", msg.Err))
+			break
+		}
+		go func() {
+			usage := command.Usage{Command: msg.Command, Target: msg.ExecutionTarget.String(), Result: &msg.Result}
+			if err := m.saveUsage(msg.CommandID, usage); err != nil {
+				m.logger.Error("error storing command run",
+					slog.Any("commandID", msg.CommandID),
+					slog.String("usage", msg.Command),
+					slog.Any("error", err),
+				)
+			}
+		}()
 	case msgs.RequestHistoryMsg:
 		go m.getHistory(msg.CommandID)
 	case msgs.SetHistoryMsg:
 		m.historyPanel.SetHistoryContent(msg.History)
+	case msgs.RequestRevisionsMsg:
+		go m.getRevisions(msg.CommandID)
+	case msgs.SetRevisionsMsg:
+		m.revisionPanel.SetRevisions(msg.Revisions)
+	case msgs.RequestTrashMsg:
+		go m.getTrash()
+	case msgs.SetTrashMsg:
+		m.trashPanel.SetTrash(msg.Trash)
+	case msgs.ProgramsCheckedMsg:
+		m.explorerPanel.SetAllMissing(msg.Missing)
 	default:
 		m.logger.Warn("unknown async msg captured",
 			slog.Any("msg", msg),
@@ -385,13 +608,14 @@ func (m *Main) handleAsyncActivities(msg tea.Msg) tea.Cmd {
 	return msgs.AsyncHandler(m.activityChan)
 }
 
-func (m *Main) fetchExplanation(cmd command.Command) {
-	ctx, cancel := context.WithTimeout(m.ctx, time.Second*60)
-	defer cancel()
-
-	var cache bool
-	explanation, err := m.commandController.ReadExplanation(ctx, cmd.ID)
-	if err != nil {
+// fetchExplanation gets the explanation of the command from the cache or the professor.
+// The template is used only when the explanation is not cached; empty means the default one.
+func (m *Main) fetchExplanation(cmd command.Command, template string) {
+	var cache, stale bool
+	explanation, err := m.readExplanation(cmd.ID)
+	if err == nil {
+		stale = explanation.Signature != m.professor.Signature(cmd, explanation.Signature.Template)
+	} else {
 		if !errors.Is(err, manager.ErrElementNotFound) {
 			m.logger.Error("error getting command explanation from cache",
 				slog.Any("command", "cmd"),
@@ -399,13 +623,18 @@ func (m *Main) fetchExplanation(cmd command.Command) {
 			)
 		}
 
+		// the professor handles its own timeouts
 		m.logger.Debug("getting explanation from professor")
-		explanation, err = m.professor.Explain(ctx, cmd)
+		explanation, err = m.professor.Explain(m.ctx, cmd, template)
 		if err != nil {
 			m.logger.Error("error getting command explanation from professor",
 				slog.Any("command", cmd),
 				slog.Any("error", err),
 			)
+			msgs.PublishAsyncMsg(
+				m.activityChan,
+				msgs.HandleProfessorErrorMsg(cmd.ID, err),
+			)
 			return
 		}
 
@@ -414,11 +643,94 @@ func (m *Main) fetchExplanation(cmd command.Command) {
 
 	msgs.PublishAsyncMsg(
 		m.activityChan,
-		msgs.HandleSetExplanationMsg(cmd.ID, explanation, cache),
+		msgs.HandleSetExplanationMsg(cmd.ID, explanation, cache, stale),
 	)
+
+	// a new explanation doesn't have follow-ups yet.
+	if cache {
+		return
+	}
+
+	transcript, err := m.readTranscript(cmd.ID)
+	if err != nil {
+		if !errors.Is(err, manager.ErrElementNotFound) {
+			m.logger.Error("error getting command transcript from cache",
+				slog.Any("command", cmd),
+				slog.Any("error", err),
+			)
+		}
+		return
+	}
+
+	msgs.PublishAsyncMsg(
+		m.activityChan,
+		msgs.HandleSetTranscriptMsg(cmd.ID, transcript, false),
+	)
+}
+
+func (m *Main) fetchFollowUp(msg msgs.RequestFollowUpMsg) {
+	// the professor handles its own timeouts
+	m.logger.Debug("asking follow-up question to the professor")
+	answer, err := m.professor.FollowUp(m.ctx, msg.Command, msg.Explanation, msg.Transcript, msg.Question)
+	if err != nil {
+		m.logger.Error("error getting follow-up answer from professor",
+			slog.Any("command", msg.Command),
+			slog.Any("error", err),
+		)
+		msgs.PublishAsyncMsg(
+			m.activityChan,
+			msgs.HandleProfessorErrorMsg(msg.Command.ID, err),
+		)
+		return
+	}
+
+	transcript := append(slices.Clone(msg.Transcript),
+		command.Message{Role: command.UserRole, Content: msg.Question},
+		command.Message{Role: command.AssistantRole, Content: answer},
+	)
+
+	msgs.PublishAsyncMsg(
+		m.activityChan,
+		msgs.HandleSetTranscriptMsg(msg.Command.ID, transcript, true),
+	)
+}
+
+// copyCommand returns a new local command with the content of the command, e.g. for forking a read-only one.
+func copyCommand(cmd command.Command) command.Command {
+	cmd.ID = uuid.Nil
+	cmd.Source = ""
+	cmd.Params = slices.Clone(cmd.Params)
+	for i := range cmd.Params {
+		cmd.Params[i].ID = uuid.New()
+	}
+	return cmd
+}
+
+// nextTemplate returns the template after the current one, wrapping around.
+func nextTemplate(templates []string, current string) string {
+	if len(templates) == 0 {
+		return current
+	}
+
+	i := slices.Index(templates, current)
+	return templates[(i+1)%len(templates)]
+}
+
+func (m *Main) readExplanation(commandID uuid.UUID) (command.Explanation, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*400)
+	defer cancel()
+
+	return m.commandController.ReadExplanation(ctx, commandID)
+}
+
+func (m *Main) readTranscript(commandID uuid.UUID) ([]command.Message, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*400)
+	defer cancel()
+
+	return m.commandController.ReadTranscript(ctx, commandID)
 }
 
-func (m *Main) cacheExplanation(commandID uuid.UUID, explanation string) {
+func (m *Main) cacheExplanation(commandID uuid.UUID, explanation command.Explanation) {
 	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*400)
 	defer cancel()
 
@@ -429,6 +741,17 @@ func (m *Main) cacheExplanation(commandID uuid.UUID, explanation string) {
 	}
 }
 
+func (m *Main) cacheTranscript(commandID uuid.UUID, transcript []command.Message) {
+	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*400)
+	defer cancel()
+
+	m.logger.Debug("attempting to cache transcript")
+	err := m.commandController.WriteTranscript(ctx, commandID, transcript)
+	if err != nil {
+		m.logger.Error("error writing transcript in cache", slog.Any("error", err))
+	}
+}
+
 func (m *Main) deleteExplanation(commandID uuid.UUID) {
 	ctx, cancel := context.WithTimeout(m.ctx, time.Millisecond*400)
 	defer cancel()
@@ -439,3 +762,27 @@ func (m *Main) deleteExplanation(commandID uuid.UUID) {
 		m.logger.Error("error deleting explanation from cache", slog.Any("error", err))
 	}
 }
+
+// startRun runs the command in the run panel, the result is published when it exits.
+func (m *Main) startRun(commandID uuid.UUID, inv command.Invocation, execTarget target.Target) {
+	// the remote targets get the env and the working directory in the line run by their shell.
+	line := inv.ShellLine()
+	wrapped := execTarget.Wrap(line)
+	if !execTarget.Local() {
+		inv = command.Invocation{Command: wrapped}
+	}
+	in, out := m.runPanel.Start(commandID, wrapped)
+	cols, rows := m.runPanel.TerminalSize()
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.cancelRun = cancel
+	go func() {
+		defer cancel()
+		result, err := m.runner.Run(ctx, inv, cols, rows, in, out)
+		// the keys typed after the command exited aren't left waiting for a reader.
+		if err := in.Close(); err != nil {
+			m.logger.Warn("error closing the input of the run", slog.Any("error", err))
+		}
+		msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRunFinishedMsg(commandID, line, execTarget, result, err))
+	}()
+}
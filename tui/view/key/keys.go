@@ -12,15 +12,27 @@ type Map struct {
 	ForceQuit        key.Binding
 	Compose          key.Binding
 	Go               key.Binding
+	Run              key.Binding
+	Output           key.Binding
+	ExecutionTarget  key.Binding
+	Cancel           key.Binding
 	Back             key.Binding
 	New              key.Binding
 	Edit             key.Binding
 	Explain          key.Binding
 	History          key.Binding
+	Revisions        key.Binding
+	Restore          key.Binding
+	Trash            key.Binding
+	Undo             key.Binding
+	Purge            key.Binding
 	Copy             key.Binding
 	NextParamKey     key.Binding
 	PreviousParamKey key.Binding
 	Delete           key.Binding
+	Ask              key.Binding
+	Regenerate       key.Binding
+	Template         key.Binding
 }
 
 func (km Map) ShortHelp() []key.Binding {
@@ -35,6 +47,8 @@ func (km Map) ShortHelp() []key.Binding {
 		km.Explain,
 		km.Delete,
 		km.History,
+		km.Revisions,
+		km.Trash,
 	}
 }
 
@@ -62,6 +76,18 @@ var DefaultMap = Map{
 	Go: key.NewBinding(
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "go")),
+	Run: key.NewBinding(
+		key.WithKeys("ctrl+r"),
+		key.WithHelp("ctrl+r", "run")),
+	Output: key.NewBinding(
+		key.WithKeys("ctrl+o"),
+		key.WithHelp("ctrl+o", "switch output")),
+	ExecutionTarget: key.NewBinding(
+		key.WithKeys("ctrl+t"),
+		key.WithHelp("ctrl+t", "switch target")),
+	Cancel: key.NewBinding(
+		key.WithKeys("ctrl+c"),
+		key.WithHelp("ctrl+c", "cancel")),
 	Back: key.NewBinding(
 		key.WithKeys("esc"),
 		key.WithHelp("esc", "back")),
@@ -77,6 +103,21 @@ var DefaultMap = Map{
 	History: key.NewBinding(
 		key.WithKeys("y"),
 		key.WithHelp("y", "history")),
+	Revisions: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("v", "revisions")),
+	Restore: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "restore")),
+	Trash: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "trash")),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("u", "undo")),
+	Purge: key.NewBinding(
+		key.WithKeys("backspace"),
+		key.WithHelp("backspace", "purge")),
 	Copy: key.NewBinding(
 		key.WithKeys("c"),
 		key.WithHelp("c", "copy")),
@@ -91,4 +132,16 @@ var DefaultMap = Map{
 		key.WithKeys("shift+tab"),
 		key.WithHelp("shift+tab", "prev"),
 	),
+	Ask: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("tab", "ask/scroll"),
+	),
+	Regenerate: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "regenerate"),
+	),
+	Template: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "switch template"),
+	),
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/target"
 )
 
 // NewCommandMsg is the event triggered for creating a new command.
@@ -39,14 +40,35 @@ func HandleUpdateCommandMsg(cmd command.Command) tea.Cmd {
 type ExecuteCommandMsg struct {
 	CommandID uuid.UUID
 	Command   string
+	// ExecutionTarget is where the command runs, it's wrapped for running there.
+	ExecutionTarget target.Target
+	// Target is the name of the output target, empty for the default one.
+	Target string
 }
 
 // HandleExecuteMsg returns a new ExecuteCommandMsg
-func HandleExecuteMsg(commandID uuid.UUID, cmd string) tea.Cmd {
+func HandleExecuteMsg(commandID uuid.UUID, cmd string, execTarget target.Target, output string) tea.Cmd {
 	return func() tea.Msg {
 		return ExecuteCommandMsg{
-			CommandID: commandID,
-			Command:   cmd,
+			CommandID:       commandID,
+			Command:         cmd,
+			ExecutionTarget: execTarget,
+			Target:          output,
+		}
+	}
+}
+
+// ProgramsCheckedMsg is the event triggered when the programs of the commands are checked.
+type ProgramsCheckedMsg struct {
+	// Missing are the programs missing in the PATH, by command ID.
+	Missing map[uuid.UUID][]string
+}
+
+// HandleProgramsCheckedMsg returns a new ProgramsCheckedMsg.
+func HandleProgramsCheckedMsg(missing map[uuid.UUID][]string) tea.Cmd {
+	return func() tea.Msg {
+		return ProgramsCheckedMsg{
+			Missing: missing,
 		}
 	}
 }
@@ -24,17 +24,20 @@ func HandleRequestExplanationMsg(cmd command.Command) tea.Cmd {
 // SetExplanationMsg is the event triggered for setting the command explanation
 type SetExplanationMsg struct {
 	CommandID   uuid.UUID
-	Explanation string
+	Explanation command.Explanation
 	Cache       bool
+	// Stale indicates if the explanation was generated from outdated inputs.
+	Stale bool
 }
 
 // HandleNewCommandMsg returns a new SetExplanationMsg.
-func HandleSetExplanationMsg(commandID uuid.UUID, explanation string, cache bool) tea.Cmd {
+func HandleSetExplanationMsg(commandID uuid.UUID, explanation command.Explanation, cache, stale bool) tea.Cmd {
 	return func() tea.Msg {
 		return SetExplanationMsg{
 			CommandID:   commandID,
 			Explanation: explanation,
 			Cache:       cache,
+			Stale:       stale,
 		}
 	}
 }
@@ -42,11 +45,11 @@ func HandleSetExplanationMsg(commandID uuid.UUID, explanation string, cache bool
 // CacheExplanationMsg is the event triggered for caching the command explanation
 type CacheExplanationMsg struct {
 	CommandID   uuid.UUID
-	Explanation string
+	Explanation command.Explanation
 }
 
 // HandleNewCommandMsg returns a new CacheExplanationMsg.
-func HandleCacheExplanationMsg(commandID uuid.UUID, explanation string) tea.Cmd {
+func HandleCacheExplanationMsg(commandID uuid.UUID, explanation command.Explanation) tea.Cmd {
 	return func() tea.Msg {
 		return CacheExplanationMsg{
 			CommandID:   commandID,
@@ -58,6 +61,10 @@ func HandleCacheExplanationMsg(commandID uuid.UUID, explanation string) tea.Cmd
 // EvictCachedExplanationMsg is the event triggered for deleting the cached command explanation
 type EvictCachedExplanationMsg struct {
 	CommandID uuid.UUID
+	// Regenerate if set, the explanation of the command is requested again after the eviction.
+	Regenerate *command.Command
+	// Template is the name of the prompt template used for regenerating the explanation.
+	Template string
 }
 
 // HandleEvictCachedExplanationMsg returns a new EvictCachedExplanationMsg.
@@ -68,3 +75,69 @@ func HandleEvictCachedExplanationMsg(commandID uuid.UUID) tea.Cmd {
 		}
 	}
 }
+
+// HandleRegenerateExplanationMsg returns a new EvictCachedExplanationMsg that requests the explanation again
+// using the given prompt template.
+func HandleRegenerateExplanationMsg(cmd command.Command, template string) tea.Cmd {
+	return func() tea.Msg {
+		return EvictCachedExplanationMsg{
+			CommandID:  cmd.ID,
+			Regenerate: &cmd,
+			Template:   template,
+		}
+	}
+}
+
+// RequestFollowUpMsg is the event triggered when a follow-up question about the command is asked.
+type RequestFollowUpMsg struct {
+	Command     command.Command
+	Explanation command.Explanation
+	Transcript  []command.Message
+	Question    string
+}
+
+// HandleRequestFollowUpMsg returns a new RequestFollowUpMsg.
+func HandleRequestFollowUpMsg(cmd command.Command, explanation command.Explanation, transcript []command.Message, question string) tea.Cmd {
+	return func() tea.Msg {
+		return RequestFollowUpMsg{
+			Command:     cmd,
+			Explanation: explanation,
+			Transcript:  transcript,
+			Question:    question,
+		}
+	}
+}
+
+// SetTranscriptMsg is the event triggered for setting the follow-up conversation of the command.
+type SetTranscriptMsg struct {
+	CommandID  uuid.UUID
+	Transcript []command.Message
+	Cache      bool
+}
+
+// HandleSetTranscriptMsg returns a new SetTranscriptMsg.
+func HandleSetTranscriptMsg(commandID uuid.UUID, transcript []command.Message, cache bool) tea.Cmd {
+	return func() tea.Msg {
+		return SetTranscriptMsg{
+			CommandID:  commandID,
+			Transcript: transcript,
+			Cache:      cache,
+		}
+	}
+}
+
+// ProfessorErrorMsg is the event triggered when the professor failed to answer.
+type ProfessorErrorMsg struct {
+	CommandID uuid.UUID
+	Err       error
+}
+
+// HandleProfessorErrorMsg returns a new ProfessorErrorMsg.
+func HandleProfessorErrorMsg(commandID uuid.UUID, err error) tea.Cmd {
+	return func() tea.Msg {
+		return ProfessorErrorMsg{
+			CommandID: commandID,
+			Err:       err,
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package msgs
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// RequestRevisionsMsg is the event triggered when the command revisions are requested.
+type RequestRevisionsMsg struct {
+	CommandID uuid.UUID
+}
+
+// HandleRequestRevisionsMsg returns a new RequestRevisionsMsg.
+func HandleRequestRevisionsMsg(commandID uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		return RequestRevisionsMsg{
+			CommandID: commandID,
+		}
+	}
+}
+
+// SetRevisionsMsg returns the revisions of the command.
+type SetRevisionsMsg struct {
+	Revisions []command.Revision
+}
+
+// HandleSetRevisionsMsg returns a new SetRevisionsMsg.
+func HandleSetRevisionsMsg(revisions []command.Revision) tea.Cmd {
+	return func() tea.Msg {
+		return SetRevisionsMsg{
+			Revisions: revisions,
+		}
+	}
+}
+
+// RestoreRevisionMsg is the event triggered for restoring a revision of the command.
+type RestoreRevisionMsg struct {
+	CommandID  uuid.UUID
+	RevisionID int64
+}
+
+// HandleRestoreRevisionMsg returns a new RestoreRevisionMsg.
+func HandleRestoreRevisionMsg(commandID uuid.UUID, revisionID int64) tea.Cmd {
+	return func() tea.Msg {
+		return RestoreRevisionMsg{
+			CommandID:  commandID,
+			RevisionID: revisionID,
+		}
+	}
+}
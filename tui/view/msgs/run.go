@@ -0,0 +1,51 @@
+package msgs
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/target"
+)
+
+// RunCommandMsg is the event triggered for running a command inside the app.
+type RunCommandMsg struct {
+	CommandID uuid.UUID
+	// Invocation is the compiled command with its env and working directory.
+	Invocation command.Invocation
+	// ExecutionTarget is where the command runs.
+	ExecutionTarget target.Target
+}
+
+// HandleRunCommandMsg returns a new RunCommandMsg.
+func HandleRunCommandMsg(commandID uuid.UUID, inv command.Invocation, execTarget target.Target) tea.Cmd {
+	return func() tea.Msg {
+		return RunCommandMsg{
+			CommandID:       commandID,
+			Invocation:      inv,
+			ExecutionTarget: execTarget,
+		}
+	}
+}
+
+// RunFinishedMsg is the event triggered when the command run inside the app exits.
+type RunFinishedMsg struct {
+	CommandID       uuid.UUID
+	Command         string
+	ExecutionTarget target.Target
+	Result          command.RunResult
+	Err             error
+}
+
+// HandleRunFinishedMsg returns a new RunFinishedMsg.
+func HandleRunFinishedMsg(commandID uuid.UUID, cmd string, execTarget target.Target, result command.RunResult, err error) tea.Cmd {
+	return func() tea.Msg {
+		return RunFinishedMsg{
+			CommandID:       commandID,
+			Command:         cmd,
+			ExecutionTarget: execTarget,
+			Result:          result,
+			Err:             err,
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package msgs
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+)
+
+// RequestTrashMsg is the event triggered when the deleted commands are requested.
+type RequestTrashMsg struct{}
+
+// HandleRequestTrashMsg returns a new RequestTrashMsg.
+func HandleRequestTrashMsg() tea.Cmd {
+	return func() tea.Msg {
+		return RequestTrashMsg{}
+	}
+}
+
+// SetTrashMsg returns the deleted commands.
+type SetTrashMsg struct {
+	Trash []command.DeletedCommand
+}
+
+// HandleSetTrashMsg returns a new SetTrashMsg.
+func HandleSetTrashMsg(trash []command.DeletedCommand) tea.Cmd {
+	return func() tea.Msg {
+		return SetTrashMsg{
+			Trash: trash,
+		}
+	}
+}
+
+// RestoreCommandMsg is the event triggered for taking a command out of the trash.
+type RestoreCommandMsg struct {
+	CommandID uuid.UUID
+}
+
+// HandleRestoreCommandMsg returns a new RestoreCommandMsg.
+func HandleRestoreCommandMsg(commandID uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		return RestoreCommandMsg{
+			CommandID: commandID,
+		}
+	}
+}
+
+// PurgeCommandMsg is the event triggered for removing permanently a command.
+type PurgeCommandMsg struct {
+	CommandID uuid.UUID
+}
+
+// HandlePurgeCommandMsg returns a new PurgeCommandMsg.
+func HandlePurgeCommandMsg(commandID uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		return PurgeCommandMsg{
+			CommandID: commandID,
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package view
 
+import (
+	"github.com/lian-rr/clio/command/target"
+	"github.com/lian-rr/clio/out"
+)
+
 type OptFunc func(main *Main)
 
 func WithProfessor(professor professor) OptFunc {
@@ -7,3 +12,17 @@ func WithProfessor(professor professor) OptFunc {
 		main.professor = professor
 	}
 }
+
+// WithOutputTargets sets the targets the composed commands can go to, the first one by default.
+func WithOutputTargets(targets []out.Target) OptFunc {
+	return func(main *Main) {
+		main.targets = targets
+	}
+}
+
+// WithExecutionTargets sets the targets the commands can run in, besides the local shell and their default one.
+func WithExecutionTargets(targets []target.Target) OptFunc {
+	return func(main *Main) {
+		main.executionTargets = targets
+	}
+}
@@ -3,6 +3,7 @@ package panel
 import (
 	"bytes"
 	"log/slog"
+	"strings"
 
 	"github.com/alecthomas/chroma/v2/quick"
 	tea "github.com/charmbracelet/bubbletea"
@@ -31,6 +32,7 @@ type Details struct {
 	width   int
 	height  int
 	confirm bool
+	toast   string
 
 	// styles
 	titleStyle   lipgloss.Style
@@ -89,11 +91,31 @@ func (p *Details) SetCommand(cmd command.Command) error {
 
 	p.paramsTable.Data(table.NewStringData(rows...))
 
-	p.infoTable.Data(table.NewStringData([][]string{
+	info := [][]string{
 		{style.Label.Render("Name"), style.Header.Render(cmd.Name)},
 		{style.Label.Render("Description"), style.Header.Render(cmd.Description)},
 		{style.Label.Render("Command"), style.Header.Render(b.String())},
-	}...))
+	}
+	if len(cmd.Tags) > 0 {
+		info = append(info, []string{style.Label.Render("Tags"), style.Header.Render(strings.Join(cmd.Tags, ", "))})
+	}
+	if cmd.Target != "" {
+		info = append(info, []string{style.Label.Render("Target"), style.Header.Render(cmd.Target)})
+	}
+	if len(cmd.Env) > 0 {
+		info = append(info, []string{style.Label.Render("Env"), style.Header.Render(command.FormatEnv(cmd.Env))})
+	}
+	if cmd.Dir != "" {
+		info = append(info, []string{style.Label.Render("Dir"), style.Header.Render(cmd.Dir)})
+	}
+	if len(cmd.Requires) > 0 {
+		info = append(info, []string{style.Label.Render("Requires"), style.Header.Render(strings.Join(cmd.Requires, ", "))})
+	}
+	if cmd.ReadOnly() {
+		info = append(info, []string{style.Label.Render("Source"), style.Header.Render(cmd.Source + " (read-only, copy it for editing)")})
+	}
+
+	p.infoTable.Data(table.NewStringData(info...))
 
 	return nil
 }
@@ -106,6 +128,8 @@ func (p *Details) View() string {
 	var confirmation string
 	if p.confirm {
 		confirmation = p.confirmation.View()
+	} else if p.toast != "" {
+		confirmation = style.Info.Render(p.toast)
 	}
 
 	sty := lipgloss.NewStyle()
@@ -134,6 +158,11 @@ func (p *Details) SetSize(width, height int) {
 	p.paramsTable.Width(w)
 }
 
+// SetToast sets a short notice shown below the command, empty hides it.
+func (p *Details) SetToast(toast string) {
+	p.toast = toast
+}
+
 // ToggleConfirmation toggles the confirmation mode
 func (p *Details) ToggleConfirmation() tea.Cmd {
 	var cmd tea.Cmd
@@ -3,6 +3,7 @@ package panel
 import (
 	"log/slog"
 	"slices"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -11,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss/table"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/target"
 	"github.com/lian-rr/clio/tui/components/dialog"
 	ckey "github.com/lian-rr/clio/tui/view/key"
 	"github.com/lian-rr/clio/tui/view/msgs"
@@ -22,6 +24,11 @@ const (
 	nameInputPos = iota
 	descInputPos
 	cmdInputPos
+	tagsInputPos
+	targetInputPos
+	envInputPos
+	dirInputPos
+	requiresInputPos
 )
 
 // EditMode represents the way the panel is going to be used.
@@ -35,8 +42,8 @@ const (
 	EditCommandMode
 )
 
-// number of fixed inputs (name, description, command)
-const fixedInputs = 3
+// number of fixed inputs (name, description, command, tags, target, env, dir, requires)
+const fixedInputs = 8
 
 // Edit handles the panel for editing or creating a command.
 type Edit struct {
@@ -56,6 +63,8 @@ type Edit struct {
 	height        int
 	selectedInput int
 	confirm       bool
+	// err is the problem with the command found when it's done, shown under the inputs.
+	err error
 
 	// styles
 	titleStyle   lipgloss.Style
@@ -71,6 +80,16 @@ func NewEdit(keys ckey.Map, logger *slog.Logger) Edit {
 	descInput.Placeholder = "and some description"
 	cmdInput := textinput.New()
 	cmdInput.Placeholder = "here goes the important part"
+	tagsInput := textinput.New()
+	tagsInput.Placeholder = "optional, e.g. k8s, prod"
+	targetInput := textinput.New()
+	targetInput.Placeholder = "optional, e.g. ssh:host, docker:container, kubectl:namespace/pod"
+	envInput := textinput.New()
+	envInput.Placeholder = "optional, e.g. AWS_PROFILE={{.profile}}"
+	dirInput := textinput.New()
+	dirInput.Placeholder = "optional, e.g. ~/infra"
+	requiresInput := textinput.New()
+	requiresInput.Placeholder = "optional, programs besides the ones of the command, e.g. jq, pg_dump"
 
 	infoTable := table.New().
 		Border(lipgloss.HiddenBorder()).
@@ -93,7 +112,7 @@ func NewEdit(keys ckey.Map, logger *slog.Logger) Edit {
 		infoTable:     infoTable,
 		confirmation:  dialog.New("Are you sure you want to edit the command?"),
 		paramsTable:   params,
-		inputs:        []*textinput.Model{&nameInput, &descInput, &cmdInput},
+		inputs:        []*textinput.Model{&nameInput, &descInput, &cmdInput, &tagsInput, &targetInput, &envInput, &dirInput, &requiresInput},
 		paramsContent: make(map[string][2]*textinput.Model),
 		logger:        logger,
 		titleStyle:    style.Title,
@@ -141,9 +160,10 @@ func (p *Edit) Update(msg tea.Msg) (Edit, tea.Cmd) {
 			var input textinput.Model
 			input, cmd = p.inputs[p.selectedInput].Update(msg)
 			p.inputs[p.selectedInput] = &input
+			p.err = nil
 
 			// command didn't changed
-			if p.selectedInput > cmdInputPos {
+			if p.selectedInput >= fixedInputs {
 				p.updateParams()
 			} else {
 				if err := p.updateCommand(); err != nil {
@@ -152,6 +172,7 @@ func (p *Edit) Update(msg tea.Msg) (Edit, tea.Cmd) {
 			}
 		}
 	case dialog.AcceptMsg:
+		p.confirm = false
 		return *p, p.done()
 	case dialog.DiscardMsg:
 		p.confirm = false
@@ -172,14 +193,19 @@ func (p *Edit) View() string {
 		{style.Label.Render("Name"), p.inputStyle.Render(p.inputs[nameInputPos].View())},
 		{style.Label.Render("Description"), p.inputStyle.Render(p.inputs[descInputPos].View())},
 		{style.Label.Render("Command"), p.inputStyle.Render(p.inputs[cmdInputPos].View())},
+		{style.Label.Render("Tags"), p.inputStyle.Render(p.inputs[tagsInputPos].View())},
+		{style.Label.Render("Target"), p.inputStyle.Render(p.inputs[targetInputPos].View())},
+		{style.Label.Render("Env"), p.inputStyle.Render(p.inputs[envInputPos].View())},
+		{style.Label.Render("Dir"), p.inputStyle.Render(p.inputs[dirInputPos].View())},
+		{style.Label.Render("Requires"), p.inputStyle.Render(p.inputs[requiresInputPos].View())},
 	}...))
 
 	rows := make([][]string, 0, len(p.cmd.Params))
 	for i, param := range p.cmd.Params {
 		rows = append(rows, []string{
 			param.Name,
-			p.inputs[i*2+3].View(),
-			p.inputs[i*2+4].View(),
+			p.inputs[i*2+fixedInputs].View(),
+			p.inputs[i*2+fixedInputs+1].View(),
 		})
 	}
 
@@ -197,6 +223,11 @@ func (p *Edit) View() string {
 		title = "New Command"
 	}
 
+	var problem string
+	if p.err != nil {
+		problem = style.Warning.Render(p.err.Error())
+	}
+
 	sty := lipgloss.NewStyle()
 	return style.Border.Render(p.contentStyle.
 		Width(w).
@@ -206,6 +237,7 @@ func (p *Edit) View() string {
 				lipgloss.Center,
 				p.titleStyle.Render(title),
 				p.infoTable.Render(),
+				problem,
 				confirmation,
 				sty.MarginLeft(1).Render(style.Label.Render("Parameters")),
 				sty.MarginLeft(2).Render(p.paramsTable.Render()),
@@ -223,6 +255,7 @@ func (p *Edit) SetCommand(mode EditMode, cmd *command.Command) error {
 	p.paramsContent = make(map[string][2]*textinput.Model)
 
 	p.mode = mode
+	p.err = nil
 	if cmd == nil {
 		p.cmd = command.Command{}
 	} else {
@@ -230,6 +263,11 @@ func (p *Edit) SetCommand(mode EditMode, cmd *command.Command) error {
 		p.inputs[nameInputPos].SetValue(cmd.Name)
 		p.inputs[descInputPos].SetValue(cmd.Description)
 		p.inputs[cmdInputPos].SetValue(cmd.Command)
+		p.inputs[tagsInputPos].SetValue(strings.Join(cmd.Tags, ", "))
+		p.inputs[targetInputPos].SetValue(cmd.Target)
+		p.inputs[envInputPos].SetValue(command.FormatEnv(cmd.Env))
+		p.inputs[dirInputPos].SetValue(cmd.Dir)
+		p.inputs[requiresInputPos].SetValue(strings.Join(cmd.Requires, ", "))
 		p.refreshParamsInputs()
 	}
 
@@ -251,17 +289,30 @@ func (p *Edit) SetSize(width, height int) {
 func (p *Edit) updateCommand() error {
 	p.cmd.Name = p.inputs[nameInputPos].Value()
 	p.cmd.Description = p.inputs[descInputPos].Value()
+	p.cmd.Tags = command.ParseTags(p.inputs[tagsInputPos].Value())
+	p.cmd.Target = p.inputs[targetInputPos].Value()
+	p.cmd.Requires = command.ParseRequires(p.inputs[requiresInputPos].Value())
 
 	cmd := p.inputs[cmdInputPos].Value()
-	if len(cmd) != len(p.cmd.Command) {
-		p.cmd.Command = p.inputs[cmdInputPos].Value()
+	dir := strings.TrimSpace(p.inputs[dirInputPos].Value())
+	env, envErr := command.ParseEnv(p.inputs[envInputPos].Value())
+	if envErr != nil {
+		// keep the last valid env while it's being typed
+		env = p.cmd.Env
+	}
+
+	// the params come from the env and the working directory too
+	if cmd != p.cmd.Command || dir != p.cmd.Dir || !slices.Equal(env, p.cmd.Env) {
+		p.cmd.Command = cmd
+		p.cmd.Env = env
+		p.cmd.Dir = dir
 		if err := p.cmd.Build(); err != nil {
 			return err
 		}
 		p.refreshParamsInputs()
 	}
 
-	return nil
+	return envErr
 }
 
 func (p *Edit) updateParams() {
@@ -303,6 +354,7 @@ func (p *Edit) refreshParamsInputs() {
 func (p *Edit) Reset() {
 	p.selectedInput = nameInputPos
 	p.confirm = false
+	p.err = nil
 	p.confirmation.Reset()
 }
 
@@ -319,11 +371,24 @@ func (p *Execute) FullHelp() [][]key.Binding {
 	return [][]key.Binding{}
 }
 
+// done returns the cmd saving the command, or shows the problem with it in the panel.
 func (p *Edit) done() tea.Cmd {
 	if err := p.cmd.Build(); err != nil {
 		p.logger.Warn("error building param", slog.Any("error", err))
+		p.err = err
+		return nil
+	}
+	if _, err := target.Parse(p.cmd.Target); err != nil {
+		p.logger.Warn("invalid target", slog.Any("error", err))
+		p.err = err
+		return nil
+	}
+	if _, err := command.ParseEnv(p.inputs[envInputPos].Value()); err != nil {
+		p.logger.Warn("invalid env", slog.Any("error", err))
+		p.err = err
 		return nil
 	}
+	p.err = nil
 
 	p.logger.Debug("Done editing/creating command", slog.Any("command", p.cmd))
 	switch p.mode {
@@ -0,0 +1,65 @@
+package panel
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	ckey "github.com/lian-rr/clio/tui/view/key"
+	"github.com/lian-rr/clio/tui/view/msgs"
+)
+
+func TestEdit_Done(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	tests := []struct {
+		name     string
+		cmd      command.Command
+		env      string
+		expected string
+	}{
+		{
+			name:     "invalid target",
+			cmd:      command.Command{Name: "pods", Command: "kubectl get pods", Target: "ftp:host"},
+			expected: `invalid execution target "ftp:host"`,
+		},
+		{
+			name:     "invalid env",
+			cmd:      command.Command{Name: "pods", Command: "kubectl get pods"},
+			env:      "KUBECONFIG",
+			expected: `invalid env var "KUBECONFIG"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewEdit(ckey.DefaultMap, logger)
+			p.SetSize(200, 60)
+			require.NoError(t, p.SetCommand(NewCommandMode, &tt.cmd))
+			p.inputs[envInputPos].SetValue(tt.env)
+
+			p, cmd := p.Update(enter)
+			assert.Nil(t, cmd)
+			require.Error(t, p.err)
+			assert.Contains(t, p.err.Error(), tt.expected)
+			assert.Contains(t, p.View(), tt.expected)
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		p := NewEdit(ckey.DefaultMap, logger)
+		p.SetSize(200, 60)
+		require.NoError(t, p.SetCommand(NewCommandMode, &command.Command{Name: "pods", Command: "kubectl get pods", Target: "ssh:host"}))
+
+		p, cmd := p.Update(enter)
+		require.NotNil(t, cmd)
+		assert.NoError(t, p.err)
+		assert.IsType(t, msgs.NewCommandMsg{}, cmd())
+	})
+}
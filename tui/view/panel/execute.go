@@ -3,6 +3,8 @@ package panel
 import (
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -12,6 +14,8 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/prereq"
+	"github.com/lian-rr/clio/command/target"
 	ckey "github.com/lian-rr/clio/tui/view/key"
 	"github.com/lian-rr/clio/tui/view/msgs"
 	"github.com/lian-rr/clio/tui/view/style"
@@ -29,8 +33,18 @@ type Execute struct {
 
 	orderedParams []string
 	selectedInput int
-	width         int
-	height        int
+	// targets are the names of the output targets, the selected one receives the command.
+	targets        []string
+	selectedTarget int
+	// knownTargets are the configured execution targets, offered for every command.
+	knownTargets []target.Target
+	// execTargets are where the command can run: its default target, the local shell and the known ones.
+	execTargets        []target.Target
+	selectedExecTarget int
+	// checker finds the programs of the command missing in the PATH, warned in the info.
+	checker *prereq.Checker
+	width   int
+	height  int
 
 	contentStyle lipgloss.Style
 	titleStyle   lipgloss.Style
@@ -89,12 +103,29 @@ func (p *Execute) Update(msg tea.Msg) (Execute, tea.Cmd) {
 				p.paramInputs[p.orderedParams[p.selectedInput]].Focus()
 			}
 		case key.Matches(msg, p.keyMap.Go):
-			id, out, err := p.produceCommand()
+			id, inv, err := p.produceCommand()
 			if err != nil {
 				p.logger.Warn("producing incomplete command", slog.Any("error", err))
 				break
 			}
-			return *p, msgs.HandleExecuteMsg(id, out)
+			return *p, msgs.HandleExecuteMsg(id, inv.ShellLine(), p.execTargets[p.selectedExecTarget], p.target())
+		case key.Matches(msg, p.keyMap.Output):
+			if len(p.targets) > 1 {
+				p.selectedTarget = (p.selectedTarget + 1) % len(p.targets)
+				p.setInfo()
+			}
+		case key.Matches(msg, p.keyMap.ExecutionTarget):
+			if len(p.execTargets) > 1 {
+				p.selectedExecTarget = (p.selectedExecTarget + 1) % len(p.execTargets)
+				p.setInfo()
+			}
+		case key.Matches(msg, p.keyMap.Run):
+			id, inv, err := p.produceCommand()
+			if err != nil {
+				p.logger.Warn("running incomplete command", slog.Any("error", err))
+				break
+			}
+			return *p, msgs.HandleRunCommandMsg(id, inv, p.execTargets[p.selectedExecTarget])
 		default:
 			if len(p.paramInputs) > 0 {
 				var input textinput.Model
@@ -169,11 +200,9 @@ func (p *Execute) SetCommand(cmd command.Command) error {
 		})
 
 	p.command = &cmd
-
-	p.infoTable.Data(table.NewStringData([][]string{
-		{style.Label.Render("Name"), cmd.Name},
-		{style.Label.Render("Description"), cmd.Description},
-	}...))
+	p.selectedTarget = 0
+	p.setExecutionTargets(cmd)
+	p.setInfo()
 
 	rows := make([][]string, 0, len(cmd.Params))
 	orderedParams := make([]string, 0, len(cmd.Params))
@@ -206,6 +235,91 @@ func (p *Execute) SetCommand(cmd command.Command) error {
 	return nil
 }
 
+// SetTargets sets the names of the output targets, the first one is selected by default.
+func (p *Execute) SetTargets(targets []string) {
+	p.targets = targets
+	p.selectedTarget = 0
+}
+
+// SetExecutionTargets sets the configured execution targets.
+func (p *Execute) SetExecutionTargets(targets []target.Target) {
+	p.knownTargets = targets
+}
+
+// SetChecker sets the checker of the programs needed by the commands.
+func (p *Execute) SetChecker(checker *prereq.Checker) {
+	p.checker = checker
+}
+
+// setExecutionTargets sets where the command can run, its default target selected.
+func (p *Execute) setExecutionTargets(cmd command.Command) {
+	targets := make([]target.Target, 0, len(p.knownTargets)+2)
+	def, err := target.Parse(cmd.Target)
+	if err != nil {
+		p.logger.Warn("invalid default target, running locally", slog.String("target", cmd.Target), slog.Any("error", err))
+	}
+	targets = append(targets, def)
+
+	for _, t := range append([]target.Target{{}}, p.knownTargets...) {
+		if !slices.Contains(targets, t) {
+			targets = append(targets, t)
+		}
+	}
+
+	p.execTargets = targets
+	p.selectedExecTarget = 0
+}
+
+// target returns the name of the selected output target.
+func (p *Execute) target() string {
+	if len(p.targets) == 0 {
+		return ""
+	}
+	return p.targets[p.selectedTarget]
+}
+
+// setInfo sets the info of the command and the selected execution and output targets.
+func (p *Execute) setInfo() {
+	execTarget := "local"
+	if t := p.execTargets[p.selectedExecTarget]; !t.Local() {
+		execTarget = t.String()
+	}
+
+	rows := [][]string{
+		{style.Label.Render("Name"), p.command.Name},
+		{style.Label.Render("Description"), p.command.Description},
+		{style.Label.Render("Target"), execTarget},
+	}
+	if len(p.command.Env) > 0 {
+		rows = append(rows, []string{style.Label.Render("Env"), command.FormatEnv(p.command.Env)})
+	}
+	if p.command.Dir != "" {
+		rows = append(rows, []string{style.Label.Render("Dir"), p.command.Dir})
+	}
+	if target := p.target(); target != "" {
+		rows = append(rows, []string{style.Label.Render("Output"), target})
+	}
+	if missing := p.missing(); len(missing) > 0 {
+		rows = append(rows, []string{
+			style.Label.Render("Missing"),
+			style.Warning.Render(strings.Join(missing, ", ") + " not found in the PATH"),
+		})
+	}
+
+	p.infoTable.Data(table.NewStringData(rows...))
+}
+
+// missing returns the programs missing for running the command on the selected execution target.
+func (p *Execute) missing() []string {
+	if p.checker == nil {
+		return nil
+	}
+
+	cmd := *p.command
+	cmd.Target = p.execTargets[p.selectedExecTarget].String()
+	return p.checker.Missing(cmd)
+}
+
 // SetSize sets the panel size.
 func (p *Execute) SetSize(width, height int) {
 	p.width = width
@@ -220,6 +334,9 @@ func (p *Execute) ShortHelp() []key.Binding {
 		p.keyMap.NextParamKey,
 		p.keyMap.PreviousParamKey,
 		p.keyMap.Go,
+		p.keyMap.ExecutionTarget,
+		p.keyMap.Output,
+		p.keyMap.Run,
 	}
 }
 
@@ -227,12 +344,12 @@ func (p *Edit) FullHelp() [][]key.Binding {
 	return [][]key.Binding{}
 }
 
-func (p *Execute) produceCommand() (commandID uuid.UUID, out string, err error) {
+func (p *Execute) produceCommand() (commandID uuid.UUID, inv command.Invocation, err error) {
 	arguments := make([]command.Argument, 0, len(p.command.Params))
 	for param, input := range p.paramInputs {
 		val := input.Value()
 		if len(val) == 0 {
-			return uuid.Nil, "", fmt.Errorf("value empty for param %q", param)
+			return uuid.Nil, command.Invocation{}, fmt.Errorf("value empty for param %q", param)
 		}
 		arguments = append(arguments, command.Argument{
 			Name:  param,
@@ -240,10 +357,10 @@ func (p *Execute) produceCommand() (commandID uuid.UUID, out string, err error)
 		})
 	}
 
-	outCommand, err := p.command.Compile(arguments)
+	inv, err = p.command.Invocation(arguments)
 	if err != nil {
-		return uuid.Nil, "", fmt.Errorf("error compiling command: %p", err)
+		return uuid.Nil, command.Invocation{}, fmt.Errorf("error compiling command: %w", err)
 	}
 
-	return p.command.ID, outCommand, nil
+	return p.command.ID, inv, nil
 }
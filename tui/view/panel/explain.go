@@ -3,15 +3,18 @@ package panel
 import (
 	"bytes"
 	"log/slog"
+	"strings"
 
 	"github.com/alecthomas/chroma/v2/quick"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
 	ckey "github.com/lian-rr/clio/tui/view/key"
@@ -27,10 +30,19 @@ type Explain struct {
 	comand  string
 	content viewport.Model
 	spinner spinner.Model
+	input   textinput.Model
+
+	cmd         command.Command
+	explanation command.Explanation
+	template    string
+	transcript  []command.Message
 
 	width   int
 	height  int
 	loading bool
+	asking  bool
+	stale   bool
+	err     error
 
 	// styles
 	titleStyle lipgloss.Style
@@ -46,17 +58,22 @@ func NewExplain(keys ckey.Map, logger *slog.Logger) Explain {
 	s.Spinner = spinner.Points
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
 
+	input := textinput.New()
+	input.Placeholder = "ask a follow-up question"
+	input.Prompt = "> "
+
 	return Explain{
 		logger:     logger,
 		keyMap:     keys,
 		content:    vp,
 		spinner:    s,
+		input:      input,
 		titleStyle: style.Title,
 	}
 }
 
 func (p *Explain) Init() tea.Cmd {
-	return p.spinner.Tick
+	return tea.Batch(p.spinner.Tick, textinput.Blink)
 }
 
 func (p *Explain) SetCommand(cmd command.Command) error {
@@ -65,41 +82,118 @@ func (p *Explain) SetCommand(cmd command.Command) error {
 		return err
 	}
 
+	p.cmd = cmd
 	p.comand = b.String()
+	p.explanation = command.Explanation{}
+	p.template = ""
+	p.transcript = nil
 	p.content.SetContent("")
+	p.input.Reset()
+	p.input.Blur()
 	p.loading = true
+	p.asking = false
+	p.stale = false
+	p.err = nil
 	p.spinner.Tick()
 
 	return nil
 }
 
-func (p *Explain) SetExplanation(explanation string) error {
-	renderer, err := glamour.NewTermRenderer(
-		glamour.WithWordWrap(p.width),
-		glamour.WithStandardStyle(styles.DarkStyle),
-	)
-	if err != nil {
-		return err
+// SetExplanation sets the explanation of the command.
+// Stale explanations are marked as outdated.
+func (p *Explain) SetExplanation(explanation command.Explanation, stale bool) error {
+	p.explanation = explanation
+	p.template = explanation.Signature.Template
+	p.stale = stale
+	p.loading = false
+	return p.render()
+}
+
+// SetError shows the error of the last request to the professor.
+func (p *Explain) SetError(commandID uuid.UUID, err error) {
+	if commandID != p.cmd.ID {
+		return
 	}
 
-	str, err := renderer.Render(explanation)
-	if err != nil {
+	p.err = err
+	p.asking = false
+}
+
+// SetTemplate sets the name of the prompt template requested for the explanation.
+func (p *Explain) SetTemplate(template string) {
+	p.template = template
+}
+
+// Template returns the name of the prompt template of the explanation.
+func (p *Explain) Template() string {
+	return p.template
+}
+
+// CanRegenerate returns true if the explanation can be requested again.
+func (p *Explain) CanRegenerate() bool {
+	return (!p.loading || p.err != nil) && !p.asking && !p.input.Focused()
+}
+
+// SetTranscript sets the follow-up conversation of the command.
+func (p *Explain) SetTranscript(commandID uuid.UUID, transcript []command.Message) error {
+	if commandID != p.cmd.ID {
+		return nil
+	}
+
+	p.transcript = transcript
+	p.asking = false
+	p.err = nil
+	if err := p.render(); err != nil {
 		return err
 	}
 
-	p.content.SetContent(str)
-	p.loading = false
+	p.content.GotoBottom()
 	return nil
 }
 
+// InputFocused returns true if the follow-up input is focused.
+func (p *Explain) InputFocused() bool {
+	return p.input.Focused()
+}
+
 func (p *Explain) View() string {
 	sty := lipgloss.NewStyle()
 	cont := "Loading " + p.spinner.View()
-	if !p.loading {
+	switch {
+	case p.loading && p.err != nil:
+		cont = style.Warning.Render(p.err.Error())
+	case !p.loading:
+		question := p.input.View()
+		switch {
+		case p.asking:
+			question = "Thinking " + p.spinner.View()
+		case p.err != nil:
+			question = lipgloss.JoinVertical(lipgloss.Left,
+				style.Warning.Render(p.err.Error()),
+				question,
+			)
+		}
+
 		cont = lipgloss.JoinVertical(lipgloss.Center,
 			sty.PaddingRight(2).
 				PaddingLeft(2).
 				Render(p.content.View()),
+			sty.PaddingLeft(2).
+				Width(p.content.Width).
+				Render(question),
+		)
+	}
+
+	title := "Explanation"
+	if p.template != "" {
+		title += " (" + p.template + ")"
+	}
+
+	label := style.Label.Render(title)
+	if p.stale && !p.loading {
+		label = lipgloss.JoinHorizontal(lipgloss.Center,
+			label,
+			style.Warning.Render("outdated, press "+p.keyMap.Regenerate.Help().Key+" to regenerate"),
 		)
 	}
 
@@ -109,7 +203,7 @@ func (p *Explain) View() string {
 			p.titleStyle.Render("Explain"),
 			style.Label.Render(p.comand),
 			sty.PaddingTop(1).
-				Render(style.Label.Render("Explanation")),
+				Render(label),
 			cont,
 		))
 }
@@ -118,12 +212,45 @@ func (p *Explain) Update(msg tea.Msg) (Explain, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		p.content, cmd = p.content.Update(msg)
+		if p.loading {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, p.keyMap.Ask):
+			if p.input.Focused() {
+				p.input.Blur()
+				break
+			}
+			cmd = p.input.Focus()
+		case p.input.Focused() && key.Matches(msg, p.keyMap.Back):
+			p.input.Blur()
+		case p.input.Focused() && key.Matches(msg, p.keyMap.Go):
+			question := strings.TrimSpace(p.input.Value())
+			if question == "" || p.asking {
+				break
+			}
+
+			p.asking = true
+			p.err = nil
+			p.input.Reset()
+			return *p, tea.Batch(
+				p.spinner.Tick,
+				msgs.HandleRequestFollowUpMsg(p.cmd, p.explanation, p.transcript, question),
+			)
+		case p.input.Focused():
+			p.input, cmd = p.input.Update(msg)
+		default:
+			p.content, cmd = p.content.Update(msg)
+		}
 	case spinner.TickMsg:
 		p.spinner, cmd = p.spinner.Update(msg)
 	// TODO: Pretty sure this is not necessary.
 	case msgs.SetExplanationMsg:
-		p.SetExplanation(msg.Explanation)
+		p.SetExplanation(msg.Explanation, msg.Stale)
+	default:
+		// handling blinking mostly
+		p.input, cmd = p.input.Update(msg)
 	}
 	return *p, cmd
 }
@@ -133,14 +260,26 @@ func (p *Explain) SetSize(width, height int) {
 	p.width = width
 	p.height = height
 
-	w, h := util.RelativeDimensions(width, height, .9, .77)
+	w, h := util.RelativeDimensions(width, height, .9, .7)
 	p.content.Width = w
 	p.content.Height = h
+	p.input.Width = w - 4
 }
 
 func (p *Explain) ShortHelp() []key.Binding {
+	if p.input.Focused() {
+		return []key.Binding{
+			p.keyMap.Back,
+			p.keyMap.Ask,
+			p.keyMap.Go,
+		}
+	}
+
 	keys := []key.Binding{
 		p.keyMap.Back,
+		p.keyMap.Ask,
+		p.keyMap.Regenerate,
+		p.keyMap.Template,
 		p.content.KeyMap.Down,
 		p.content.KeyMap.Up,
 		p.content.KeyMap.PageDown,
@@ -154,3 +293,35 @@ func (p *Explain) ShortHelp() []key.Binding {
 func (p *Explain) FullHelp() [][]key.Binding {
 	return [][]key.Binding{}
 }
+
+// render renders the explanation and the follow-up conversation as markdown.
+func (p *Explain) render() error {
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithWordWrap(p.width),
+		glamour.WithStandardStyle(styles.DarkStyle),
+	)
+	if err != nil {
+		return err
+	}
+
+	var doc strings.Builder
+	doc.WriteString(p.explanation.Content)
+	for _, msg := range p.transcript {
+		switch msg.Role {
+		case command.UserRole:
+			doc.WriteString("\n\n---\n\n> **You:** ")
+			doc.WriteString(msg.Content)
+		default:
+			doc.WriteString("\n\n")
+			doc.WriteString(msg.Content)
+		}
+	}
+
+	str, err := renderer.Render(doc.String())
+	if err != nil {
+		return err
+	}
+
+	p.content.SetContent(str)
+	return nil
+}
@@ -1,9 +1,16 @@
 package panel
 
 import (
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
 	ckey "github.com/lian-rr/clio/tui/view/key"
@@ -13,11 +20,14 @@ import (
 type Explorer struct {
 	keyMap ckey.Map
 	list   list.Model
+	// missing are the programs missing in the PATH, by command ID. Shared with the delegate.
+	missing map[uuid.UUID][]string
 }
 
 // NewExplorer returns a new ExplorerView.
 func NewExplorer(keys ckey.Map) Explorer {
-	view := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+	missing := make(map[uuid.UUID][]string)
+	view := list.New(nil, explorerDelegate{DefaultDelegate: list.NewDefaultDelegate(), missing: missing}, 0, 0)
 	view.DisableQuitKeybindings()
 	view.SetShowTitle(false)
 	view.SetFilteringEnabled(false)
@@ -25,8 +35,9 @@ func NewExplorer(keys ckey.Map) Explorer {
 	view.SetShowStatusBar(false)
 
 	return Explorer{
-		list:   view,
-		keyMap: keys,
+		list:    view,
+		keyMap:  keys,
+		missing: missing,
 	}
 }
 
@@ -63,15 +74,44 @@ func (p *Explorer) SetCommands(cmds []command.Command) {
 	p.list.SetItems(toListItem(cmds))
 }
 
+// SetResults sets the commands found by a search as the content of the list, highlighting the matches.
+// The results matching in their content show where instead of the description.
+func (p *Explorer) SetResults(results []command.SearchResult) {
+	items := make([]list.Item, 0, len(results))
+	for _, result := range results {
+		item := newExplorerItem(result.Command)
+		item.matches = result.Matches
+		if reason := result.Reason(); reason != "" {
+			item.desc = fmt.Sprintf("%s: %s", reason, strings.Join(strings.Fields(result.Snippet), " "))
+		}
+		items = append(items, item)
+	}
+	p.list.SetItems(items)
+}
+
+// SetAllMissing sets the programs missing in the PATH by command ID, the commands missing some are greyed out.
+func (p *Explorer) SetAllMissing(missing map[uuid.UUID][]string) {
+	clear(p.missing)
+	for id, programs := range missing {
+		p.SetMissing(id, programs)
+	}
+}
+
+// SetMissing sets the programs of the command missing in the PATH, none if it can run.
+func (p *Explorer) SetMissing(id uuid.UUID, programs []string) {
+	if len(programs) == 0 {
+		delete(p.missing, id)
+		return
+	}
+	p.missing[id] = programs
+}
+
 // AddCommand adds a new item to the List
 func (p *Explorer) AddCommand(cmd command.Command) int {
 	idx := len(p.list.Items())
-	p.list.InsertItem(idx, &ExplorerItem{
-		title:   cmd.Name,
-		desc:    cmd.Description,
-		Command: &cmd,
-		Loaded:  true,
-	})
+	item := newExplorerItem(cmd)
+	item.Loaded = true
+	p.list.InsertItem(idx, item)
 
 	return idx
 }
@@ -95,12 +135,9 @@ func (p *Explorer) Select(idx int) {
 // RefreshCommand refresh the item command of the selected Item.
 func (p *Explorer) RefreshCommand(cmd command.Command) {
 	idx := p.list.Index()
-	p.list.SetItem(idx, &ExplorerItem{
-		title:   cmd.Name,
-		desc:    cmd.Description,
-		Command: &cmd,
-		Loaded:  true,
-	})
+	item := newExplorerItem(cmd)
+	item.Loaded = true
+	p.list.SetItem(idx, item)
 }
 
 func (p *Explorer) ShortHelp() []key.Binding {
@@ -114,6 +151,8 @@ func (p *Explorer) ShortHelp() []key.Binding {
 		p.keyMap.DiscardSearch,
 		p.keyMap.Explain,
 		p.keyMap.History,
+		p.keyMap.Revisions,
+		p.keyMap.Trash,
 	}
 }
 
@@ -125,6 +164,8 @@ func (p *Explorer) FullHelp() [][]key.Binding {
 type ExplorerItem struct {
 	title string
 	desc  string
+	// matches are the indexes of the runes of the title matching the search.
+	matches []int
 
 	// Command is a pointer to the represented command.
 	Command *command.Command
@@ -149,12 +190,79 @@ func (i ExplorerItem) FilterValue() string {
 func toListItem(cmds []command.Command) []list.Item {
 	items := make([]list.Item, 0, len(cmds))
 	for _, cmd := range cmds {
-		items = append(items, &ExplorerItem{
-			title:   cmd.Name,
-			desc:    cmd.Description,
-			Command: &cmd,
-		})
+		items = append(items, newExplorerItem(cmd))
 	}
 
 	return items
 }
+
+// newExplorerItem returns the item of the command, showing the source of the read-only ones.
+func newExplorerItem(cmd command.Command) *ExplorerItem {
+	desc := cmd.Description
+	if cmd.ReadOnly() {
+		desc = fmt.Sprintf("[%s] %s", cmd.Source, desc)
+	}
+
+	return &ExplorerItem{
+		title:   cmd.Name,
+		desc:    desc,
+		Command: &cmd,
+	}
+}
+
+// explorerDelegate renders the items like the default delegate, highlighting the runes matching the search.
+// The filtering of the list is disabled, so the matches come from the search results instead.
+// The commands missing programs are greyed out, with the missing ones as description.
+type explorerDelegate struct {
+	list.DefaultDelegate
+	missing map[uuid.UUID][]string
+}
+
+func (d explorerDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	i, ok := item.(*ExplorerItem)
+	if !ok || m.Width() <= 0 {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+	missing := d.missing[i.Command.ID]
+	if len(i.matches) == 0 && len(missing) == 0 {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+
+	s := &d.Styles
+	titleStyle, descStyle := s.NormalTitle, s.NormalDesc
+	if index == m.Index() {
+		titleStyle, descStyle = s.SelectedTitle, s.SelectedDesc
+	}
+
+	description := i.Description()
+	if len(missing) > 0 {
+		titleStyle = titleStyle.Foreground(s.DimmedTitle.GetForeground())
+		descStyle = descStyle.Foreground(s.DimmedDesc.GetForeground())
+		description = "missing " + strings.Join(missing, ", ")
+	}
+
+	// prevent text from exceeding list width
+	textwidth := m.Width() - s.NormalTitle.GetPaddingLeft() - s.NormalTitle.GetPaddingRight()
+	title := ansi.Truncate(i.Title(), textwidth, "…")
+
+	unmatched := titleStyle.Inline(true)
+	title = titleStyle.Render(lipgloss.StyleRunes(title, i.matches, unmatched.Inherit(s.FilterMatch), unmatched))
+
+	if !d.ShowDescription {
+		fmt.Fprintf(w, "%s", title) //nolint: errcheck
+		return
+	}
+
+	var lines []string
+	for n, line := range strings.Split(description, "\n") {
+		if n >= d.Height()-1 {
+			break
+		}
+		lines = append(lines, ansi.Truncate(line, textwidth, "…"))
+	}
+	desc := descStyle.Render(strings.Join(lines, "\n"))
+
+	fmt.Fprintf(w, "%s\n%s", title, desc) //nolint: errcheck
+}
@@ -1,6 +1,7 @@
 package panel
 
 import (
+	"fmt"
 	"log/slog"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/target"
 	ckey "github.com/lian-rr/clio/tui/view/key"
 	"github.com/lian-rr/clio/tui/view/msgs"
 	"github.com/lian-rr/clio/tui/view/style"
@@ -28,6 +30,7 @@ type History struct {
 
 	loading   bool
 	commandID uuid.UUID
+	usages    []command.Usage
 
 	height       int
 	width        int
@@ -55,6 +58,8 @@ func NewHistory(keys ckey.Map, logger *slog.Logger) History {
 	columns := []btable.Column{
 		{Title: "Usage", Width: 32},
 		{Title: "Timestamp", Width: 19},
+		{Title: "Target", Width: 12},
+		{Title: "Result", Width: 12},
 	}
 
 	t := btable.New(
@@ -112,8 +117,15 @@ func (p *History) Update(msg tea.Msg) (History, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, p.keyMap.Go):
-			if p.historyTable.Focused() {
-				return *p, msgs.HandleExecuteMsg(p.commandID, p.historyTable.SelectedRow()[0])
+			if p.historyTable.Focused() && len(p.usages) > 0 {
+				usage := p.usages[p.historyTable.Cursor()]
+				// the usage runs again where it ran.
+				execTarget, err := target.Parse(usage.Target)
+				if err != nil {
+					p.logger.Warn("invalid usage target", slog.String("target", usage.Target), slog.Any("error", err))
+					break
+				}
+				return *p, msgs.HandleExecuteMsg(p.commandID, usage.Command, execTarget, "")
 			}
 		default:
 			p.historyTable, cmd = p.historyTable.Update(msg)
@@ -144,12 +156,20 @@ func (p *History) SetCommand(cmd command.Command) error {
 
 func (p *History) SetHistoryContent(history command.History) {
 	p.loading = false
+	p.usages = history.Usages
 
 	rows := make([]btable.Row, 0, len(history.Usages))
 	for _, usage := range history.Usages {
+		var result string
+		if usage.Result != nil {
+			result = fmt.Sprintf("exit %d · %s", usage.Result.ExitCode, usage.Result.Duration.Round(time.Millisecond))
+		}
+
 		rows = append(rows, btable.Row{
 			usage.Command,
 			usage.Timestamp.Format(time.RFC822),
+			usage.Target,
+			result,
 		})
 	}
 
@@ -163,10 +183,13 @@ func (p *History) SetSize(width, height int) {
 	p.width = width
 
 	p.titleStyle.Width(width)
-	w, _ := util.RelativeDimensions(width, height, .6, .77)
+	w, _ := util.RelativeDimensions(width, height, .4, .77)
 	p.historyTable.Columns()[0].Width = w
 	w, _ = util.RelativeDimensions(width, height, .2, .77)
 	p.historyTable.Columns()[1].Width = w
+	w, _ = util.RelativeDimensions(width, height, .1, .77)
+	p.historyTable.Columns()[2].Width = w
+	p.historyTable.Columns()[3].Width = w
 }
 
 func (p *History) ShortHelp() []key.Binding {
@@ -0,0 +1,284 @@
+package panel
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	btable "github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+
+	"github.com/lian-rr/clio/command"
+	ckey "github.com/lian-rr/clio/tui/view/key"
+	"github.com/lian-rr/clio/tui/view/msgs"
+	"github.com/lian-rr/clio/tui/view/style"
+)
+
+// Revisions handles the panel for listing the prior versions of a command.
+type Revisions struct {
+	logger        *slog.Logger
+	keyMap        ckey.Map
+	diffTable     *table.Table
+	spinner       spinner.Model
+	revisionTable btable.Model
+
+	loading   bool
+	command   command.Command
+	revisions []command.Revision
+
+	height       int
+	width        int
+	contentStyle lipgloss.Style
+	titleStyle   lipgloss.Style
+}
+
+// NewRevisions returns a new Revisions panel.
+func NewRevisions(keys ckey.Map, logger *slog.Logger) Revisions {
+	diffTable := table.New().
+		Border(lipgloss.HiddenBorder()).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			style := lipgloss.NewStyle()
+
+			if col != 0 {
+				style = style.MarginLeft(1)
+			}
+
+			return style
+		})
+
+	s := spinner.New()
+	s.Spinner = spinner.Points
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	columns := []btable.Column{
+		{Title: "#", Width: 4},
+		{Title: "Name", Width: 32},
+		{Title: "Timestamp", Width: 19},
+	}
+
+	t := btable.New(
+		btable.WithColumns(columns),
+		btable.WithHeight(7),
+	)
+	t.SetStyles(getTableStyles())
+
+	return Revisions{
+		logger:        logger,
+		keyMap:        keys,
+		diffTable:     diffTable,
+		revisionTable: t,
+		spinner:       s,
+		titleStyle:    style.Title,
+		contentStyle: lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Padding(2, 8),
+	}
+}
+
+func (p *Revisions) Init() tea.Cmd {
+	return p.spinner.Tick
+}
+
+func (p Revisions) View() string {
+	sty := lipgloss.NewStyle()
+	cont := "Loading " + p.spinner.View()
+	if !p.loading {
+		cont = p.revisionTable.View()
+		if len(p.revisions) == 0 {
+			cont = style.Warning.Render("The command has no revisions yet.")
+		}
+	}
+
+	w := p.width - p.contentStyle.GetHorizontalBorderSize()
+	h := p.height - p.contentStyle.GetVerticalFrameSize()
+
+	return style.Border.Render(
+		p.contentStyle.
+			Width(w).
+			Height(h).
+			Render(
+				lipgloss.JoinVertical(
+					lipgloss.Center,
+					p.titleStyle.Render("Revisions"),
+					cont,
+					sty.PaddingTop(1).
+						Render(style.Label.Render("Changes since the revision")),
+					p.diffTable.Render(),
+				),
+			))
+}
+
+func (p *Revisions) Update(msg tea.Msg) (Revisions, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, p.keyMap.Restore):
+			if rev, ok := p.selected(); ok {
+				return *p, msgs.HandleRestoreRevisionMsg(p.command.ID, rev.ID)
+			}
+		default:
+			p.revisionTable, cmd = p.revisionTable.Update(msg)
+			p.setDiff()
+		}
+	case spinner.TickMsg:
+		p.spinner, cmd = p.spinner.Update(msg)
+	}
+	return *p, cmd
+}
+
+// SetCommand sets the current version of the command, the revisions are compared against it.
+func (p *Revisions) SetCommand(cmd command.Command) {
+	p.command = cmd
+	p.revisions = nil
+	p.revisionTable.SetRows(nil)
+	p.diffTable.Data(table.NewStringData())
+	p.loading = true
+}
+
+// SetRevisions sets the revisions of the command, the newest first.
+func (p *Revisions) SetRevisions(revisions []command.Revision) {
+	p.loading = false
+	p.revisions = revisions
+
+	rows := make([]btable.Row, 0, len(revisions))
+	for _, rev := range revisions {
+		rows = append(rows, btable.Row{
+			strconv.FormatInt(rev.ID, 10),
+			rev.Command.Name,
+			rev.CreatedAt.Local().Format(time.RFC822),
+		})
+	}
+
+	p.revisionTable.SetRows(rows)
+	p.revisionTable.Focus()
+	p.revisionTable.SetCursor(0)
+	p.setDiff()
+}
+
+func (p *Revisions) SetSize(width, height int) {
+	p.height = height
+	p.width = width
+
+	p.titleStyle.Width(width)
+	p.revisionTable.Columns()[1].Width = int(float32(width) * .5)
+	p.revisionTable.Columns()[2].Width = int(float32(width) * .2)
+}
+
+func (p *Revisions) ShortHelp() []key.Binding {
+	return []key.Binding{
+		p.keyMap.Back,
+		p.revisionTable.KeyMap.LineUp,
+		p.revisionTable.KeyMap.LineDown,
+		p.keyMap.Restore,
+	}
+}
+
+func (p *Revisions) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (p *Revisions) selected() (command.Revision, bool) {
+	idx := p.revisionTable.Cursor()
+	if idx < 0 || idx >= len(p.revisions) {
+		return command.Revision{}, false
+	}
+	return p.revisions[idx], true
+}
+
+// setDiff shows the changes from the selected revision to the current version.
+func (p *Revisions) setDiff() {
+	rev, ok := p.selected()
+	if !ok {
+		p.diffTable.Data(table.NewStringData())
+		return
+	}
+
+	old, curr := rev.Command, p.command
+	rows := [][]string{
+		{style.Label.Render("Name"), diffWords(old.Name, curr.Name)},
+		{style.Label.Render("Description"), diffWords(old.Description, curr.Description)},
+		{style.Label.Render("Command"), diffWords(old.Command, curr.Command)},
+	}
+
+	params := make([]string, 0, len(old.Params))
+	for _, param := range old.Params {
+		params = append(params, param.Name+"="+param.DefaultValue)
+	}
+	currParams := make([]string, 0, len(curr.Params))
+	for _, param := range curr.Params {
+		currParams = append(currParams, param.Name+"="+param.DefaultValue)
+	}
+	rows = append(rows, []string{style.Label.Render("Parameters"), diffWords(strings.Join(params, " "), strings.Join(currParams, " "))})
+
+	p.diffTable.Data(table.NewStringData(rows...))
+}
+
+// diffWords returns the new text with the removed words in red and the added ones in green.
+func diffWords(old, curr string) string {
+	a, b := splitWords(old), splitWords(curr)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			sb.WriteString(a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString(style.Removed.Render(a[i]))
+			i++
+		default:
+			sb.WriteString(style.Added.Render(b[j]))
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		sb.WriteString(style.Removed.Render(a[i]))
+	}
+	for ; j < len(b); j++ {
+		sb.WriteString(style.Added.Render(b[j]))
+	}
+
+	return sb.String()
+}
+
+// splitWords splits the text in words and the spaces between them, e.g. "ls -la" => ["ls", " ", "-la"].
+func splitWords(text string) []string {
+	words := make([]string, 0)
+	start, space := 0, false
+	for i, r := range text {
+		if i > 0 && unicode.IsSpace(r) != space {
+			words = append(words, text[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+
+	return words
+}
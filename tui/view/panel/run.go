@@ -0,0 +1,295 @@
+package panel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/alecthomas/chroma/v2/quick"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/run"
+	ckey "github.com/lian-rr/clio/tui/view/key"
+	"github.com/lian-rr/clio/tui/view/style"
+	"github.com/lian-rr/clio/tui/view/util"
+)
+
+// typedKeys is how many typed keys wait for the command to read them, the next ones are dropped.
+const typedKeys = 64
+
+// runningKeyMap pages the output while the command runs, the other keys are typed in the command.
+var runningKeyMap = viewport.KeyMap{
+	PageDown: key.NewBinding(
+		key.WithKeys("pgdown"),
+		key.WithHelp("pgdn", "page down")),
+	PageUp: key.NewBinding(
+		key.WithKeys("pgup"),
+		key.WithHelp("pgup", "page up")),
+}
+
+// Run handles the panel showing the output of a command run inside the app.
+type Run struct {
+	logger  *slog.Logger
+	keyMap  ckey.Map
+	content viewport.Model
+	spinner spinner.Model
+
+	commandID uuid.UUID
+	command   string
+	output    *run.Output
+	started   time.Time
+	running   bool
+	result    command.RunResult
+	err       error
+	// input queues the keys typed in the terminal of the command while it runs, they are written by typeInput
+	// so a command not reading them doesn't block the app.
+	input chan []byte
+
+	width  int
+	height int
+
+	// styles
+	titleStyle lipgloss.Style
+}
+
+// NewRun returns a new Run panel.
+func NewRun(keys ckey.Map, logger *slog.Logger) Run {
+	vp := viewport.New(0, 0)
+	vp.Style = lipgloss.NewStyle().
+		BorderStyle(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("62"))
+
+	s := spinner.New()
+	s.Spinner = spinner.Points
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	return Run{
+		logger:     logger,
+		keyMap:     keys,
+		content:    vp,
+		spinner:    s,
+		output:     &run.Output{},
+		titleStyle: style.Title,
+	}
+}
+
+// Init starts the spinner, its ticks refresh the output while the command runs.
+func (p *Run) Init() tea.Cmd {
+	return p.spinner.Tick
+}
+
+// Start sets the command being run, returning the reader of its input and the writer of its output.
+// The caller closes the input once the command exits, typing in it after that fails.
+func (p *Run) Start(commandID uuid.UUID, cmd string) (io.ReadCloser, io.Writer) {
+	var b bytes.Buffer
+	if err := quick.Highlight(&b, cmd, chromaLang, chromaFormatter, chromaStyle); err != nil {
+		p.logger.Warn("error highlighting command", slog.Any("error", err))
+		b.Reset()
+		b.WriteString(cmd)
+	}
+
+	p.closeInput()
+	in, w := io.Pipe()
+	p.commandID = commandID
+	p.command = b.String()
+	p.output = &run.Output{}
+	p.input = make(chan []byte, typedKeys)
+	go typeInput(p.input, w, p.logger)
+	p.content.KeyMap = runningKeyMap
+	p.started = time.Now()
+	p.running = true
+	p.result = command.RunResult{}
+	p.err = nil
+	p.content.SetContent("")
+
+	return in, p.output
+}
+
+// Finish sets the result of the run.
+func (p *Run) Finish(commandID uuid.UUID, result command.RunResult, err error) {
+	if commandID != p.commandID {
+		return
+	}
+
+	p.running = false
+	p.result = result
+	p.err = err
+	p.content.KeyMap = viewport.DefaultKeyMap()
+	p.closeInput()
+	p.refresh()
+}
+
+func (p *Run) closeInput() {
+	if p.input == nil {
+		return
+	}
+	close(p.input)
+	p.input = nil
+}
+
+// typeInput writes the typed keys in the input of the command until they are closed.
+func typeInput(keys <-chan []byte, w *io.PipeWriter, logger *slog.Logger) {
+	defer w.Close()
+	for b := range keys {
+		// the write fails once the command exited, before the run finishes.
+		if _, err := w.Write(b); err != nil {
+			logger.Debug("error typing in the command", slog.Any("error", err))
+		}
+	}
+}
+
+// Running returns true while the command runs.
+func (p *Run) Running() bool {
+	return p.running
+}
+
+// TerminalSize returns the columns and rows of the output, the size of the terminal of the command.
+func (p *Run) TerminalSize() (int, int) {
+	return max(p.content.Width-p.content.Style.GetHorizontalFrameSize(), 1),
+		max(p.content.Height-p.content.Style.GetVerticalFrameSize(), 1)
+}
+
+func (p *Run) View() string {
+	sty := lipgloss.NewStyle()
+
+	var status string
+	switch {
+	case p.running:
+		status = fmt.Sprintf("Running %s %s  %s", time.Since(p.started).Round(time.Second), p.spinner.View(),
+			style.Label.Render("the keys are typed in the command"))
+	case p.err != nil:
+		status = style.Warning.Render(p.err.Error())
+	case p.result.ExitCode != 0:
+		status = style.Warning.Render(fmt.Sprintf("exit %d in %s", p.result.ExitCode, p.result.Duration.Round(time.Millisecond)))
+	default:
+		status = style.Added.Render(fmt.Sprintf("exit 0 in %s", p.result.Duration.Round(time.Millisecond)))
+	}
+
+	return style.Border.Render(
+		lipgloss.JoinVertical(
+			lipgloss.Center,
+			p.titleStyle.Render("Run"),
+			style.Label.Render(p.command),
+			sty.PaddingTop(1).
+				PaddingRight(2).
+				PaddingLeft(2).
+				Render(p.content.View()),
+			sty.PaddingLeft(2).
+				Width(p.content.Width).
+				Render(status),
+		))
+}
+
+func (p *Run) Update(msg tea.Msg) (Run, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// while running, the keys go to the command except the ones paging the output.
+		if p.running && !key.Matches(msg, p.content.KeyMap.PageDown, p.content.KeyMap.PageUp) {
+			p.typeKey(msg)
+			break
+		}
+		p.content, cmd = p.content.Update(msg)
+	case spinner.TickMsg:
+		if !p.running {
+			break
+		}
+		p.refresh()
+		p.spinner, cmd = p.spinner.Update(msg)
+	}
+	return *p, cmd
+}
+
+// typeKey types the key in the terminal of the command, e.g. answering a prompt.
+// It's dropped when the command isn't reading the ones typed before.
+func (p *Run) typeKey(msg tea.KeyMsg) {
+	if p.input == nil {
+		return
+	}
+	select {
+	case p.input <- keyBytes(msg):
+	default:
+		p.logger.Debug("dropping the key typed in the command, it isn't reading its input")
+	}
+}
+
+// keySequences are the sequences sent by a terminal for the keys without a character.
+var keySequences = map[tea.KeyType]string{
+	tea.KeySpace:    " ",
+	tea.KeyUp:       "\x1b[A",
+	tea.KeyDown:     "\x1b[B",
+	tea.KeyRight:    "\x1b[C",
+	tea.KeyLeft:     "\x1b[D",
+	tea.KeyHome:     "\x1b[H",
+	tea.KeyEnd:      "\x1b[F",
+	tea.KeyDelete:   "\x1b[3~",
+	tea.KeyShiftTab: "\x1b[Z",
+}
+
+// keyBytes returns the bytes a terminal sends for the key.
+func keyBytes(msg tea.KeyMsg) []byte {
+	var b []byte
+	switch seq, ok := keySequences[msg.Type]; {
+	case msg.Type == tea.KeyRunes:
+		b = []byte(string(msg.Runes))
+	case ok:
+		b = []byte(seq)
+	case msg.Type >= 0:
+		// the control characters, e.g. enter or ctrl+d.
+		b = []byte{byte(msg.Type)}
+	}
+
+	if msg.Alt && len(b) > 0 {
+		b = append([]byte{0x1b}, b...)
+	}
+	return b
+}
+
+// refresh shows the output written so far, following it unless it was scrolled up.
+func (p *Run) refresh() {
+	follow := p.content.AtBottom()
+	p.content.SetContent(p.output.String())
+	if follow {
+		p.content.GotoBottom()
+	}
+}
+
+func (p *Run) SetSize(width, height int) {
+	p.titleStyle.Width(width)
+	p.width = width
+	p.height = height
+
+	w, h := util.RelativeDimensions(width, height, .9, .75)
+	p.content.Width = w
+	p.content.Height = h
+}
+
+func (p *Run) ShortHelp() []key.Binding {
+	if p.running {
+		return []key.Binding{
+			p.keyMap.Cancel,
+			p.content.KeyMap.PageDown,
+			p.content.KeyMap.PageUp,
+		}
+	}
+
+	return []key.Binding{
+		p.keyMap.Back,
+		p.content.KeyMap.Down,
+		p.content.KeyMap.Up,
+		p.content.KeyMap.PageDown,
+		p.content.KeyMap.PageUp,
+	}
+}
+
+func (p *Run) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
@@ -0,0 +1,50 @@
+package panel
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lian-rr/clio/command"
+	ckey "github.com/lian-rr/clio/tui/view/key"
+)
+
+func TestRun_TypeKey(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	id := uuid.New()
+
+	p := NewRun(ckey.DefaultMap, logger)
+	in, _ := p.Start(id, "read answer")
+
+	t.Run("doesn't block while the command isn't reading", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			for range typedKeys * 2 {
+				p, _ = p.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("typing blocked the update")
+		}
+	})
+
+	t.Run("the command reads the keys once it exits", func(t *testing.T) {
+		p.Finish(id, command.RunResult{}, nil)
+
+		typed, err := io.ReadAll(in)
+		require.NoError(t, err)
+		assert.NotEmpty(t, typed)
+		assert.Equal(t, strings.Repeat("y", len(typed)), string(typed))
+	})
+}
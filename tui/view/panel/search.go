@@ -7,6 +7,7 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
 	ckey "github.com/lian-rr/clio/tui/view/key"
 	"github.com/lian-rr/clio/tui/view/style"
@@ -17,6 +18,9 @@ type Search struct {
 	title  string
 	input  textinput.Model
 	keyMap ckey.Map
+	width  int
+	// err is the problem with the query, shown under the input.
+	err error
 }
 
 func NewSearch(keys ckey.Map, logger *slog.Logger) Search {
@@ -50,12 +54,18 @@ func (p *Search) Update(msg tea.Msg) (Search, tea.Cmd) {
 }
 
 func (p *Search) View() string {
-	return style.Border.BorderBottom(true).Render(
-		lipgloss.JoinHorizontal(lipgloss.Left,
-			p.title+" ",
-			p.input.View(),
-		),
+	content := lipgloss.JoinHorizontal(lipgloss.Left,
+		p.title+" ",
+		p.input.View(),
 	)
+	if p.err != nil {
+		content = lipgloss.JoinVertical(lipgloss.Left,
+			content,
+			style.Warning.Render(ansi.Truncate(p.err.Error(), max(p.width-4, 0), "…")),
+		)
+	}
+
+	return style.Border.BorderBottom(true).Render(content)
 }
 
 func (p *Search) ShortHelp() []key.Binding {
@@ -79,6 +89,12 @@ func (p *Search) Unfocus() {
 
 func (p *Search) Reset() {
 	p.input.Reset()
+	p.err = nil
+}
+
+// SetError shows the problem with the query under the input, nil clears it.
+func (p *Search) SetError(err error) {
+	p.err = err
 }
 
 func (p *Search) Content() string {
@@ -86,5 +102,6 @@ func (p *Search) Content() string {
 }
 
 func (p *Search) SetWidth(width int) {
+	p.width = width
 	p.input.Width = width - (len(p.title) + 4)
 }
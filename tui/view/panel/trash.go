@@ -0,0 +1,208 @@
+package panel
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	btable "github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/tui/components/dialog"
+	ckey "github.com/lian-rr/clio/tui/view/key"
+	"github.com/lian-rr/clio/tui/view/msgs"
+	"github.com/lian-rr/clio/tui/view/style"
+)
+
+// Trash handles the panel for listing the deleted commands.
+type Trash struct {
+	logger       *slog.Logger
+	keyMap       ckey.Map
+	spinner      spinner.Model
+	trashTable   btable.Model
+	confirmation dialog.Dialog
+
+	loading bool
+	confirm bool
+	trash   []command.DeletedCommand
+
+	height       int
+	width        int
+	contentStyle lipgloss.Style
+	titleStyle   lipgloss.Style
+}
+
+// NewTrash returns a new Trash panel.
+func NewTrash(keys ckey.Map, logger *slog.Logger) Trash {
+	s := spinner.New()
+	s.Spinner = spinner.Points
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+
+	columns := []btable.Column{
+		{Title: "Name", Width: 32},
+		{Title: "Deleted", Width: 19},
+	}
+
+	t := btable.New(
+		btable.WithColumns(columns),
+		btable.WithHeight(10),
+	)
+	t.SetStyles(getTableStyles())
+
+	return Trash{
+		logger:       logger,
+		keyMap:       keys,
+		trashTable:   t,
+		spinner:      s,
+		confirmation: dialog.New("Are you sure you want to delete the command permanently?"),
+		titleStyle:   style.Title,
+		contentStyle: lipgloss.NewStyle().
+			Align(lipgloss.Center).
+			Padding(2, 8),
+	}
+}
+
+func (p *Trash) Init() tea.Cmd {
+	return p.spinner.Tick
+}
+
+func (p Trash) View() string {
+	cont := "Loading " + p.spinner.View()
+	if !p.loading {
+		cont = p.trashTable.View()
+		if len(p.trash) == 0 {
+			cont = style.Warning.Render("The trash is empty.")
+		}
+	}
+
+	var confirmation string
+	if p.confirm {
+		confirmation = p.confirmation.View()
+	}
+
+	w := p.width - p.contentStyle.GetHorizontalBorderSize()
+	h := p.height - p.contentStyle.GetVerticalFrameSize()
+
+	return style.Border.Render(
+		p.contentStyle.
+			Width(w).
+			Height(h).
+			Render(
+				lipgloss.JoinVertical(
+					lipgloss.Center,
+					p.titleStyle.Render("Trash"),
+					cont,
+					confirmation,
+				),
+			))
+}
+
+func (p *Trash) Update(msg tea.Msg) (Trash, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if p.confirm {
+			p.confirmation, cmd = p.confirmation.Update(msg)
+			break
+		}
+
+		switch {
+		case key.Matches(msg, p.keyMap.Restore):
+			if deleted, ok := p.selected(); ok {
+				return *p, msgs.HandleRestoreCommandMsg(deleted.Command.ID)
+			}
+		case key.Matches(msg, p.keyMap.Purge):
+			if _, ok := p.selected(); ok {
+				return *p, p.ToggleConfirmation()
+			}
+		default:
+			p.trashTable, cmd = p.trashTable.Update(msg)
+		}
+	// purge confirmed
+	case dialog.AcceptMsg:
+		_ = p.ToggleConfirmation()
+		if deleted, ok := p.selected(); ok {
+			return *p, msgs.HandlePurgeCommandMsg(deleted.Command.ID)
+		}
+	case dialog.DiscardMsg:
+		_ = p.ToggleConfirmation()
+	case spinner.TickMsg:
+		p.spinner, cmd = p.spinner.Update(msg)
+	}
+	return *p, cmd
+}
+
+// Reset clears the panel while the deleted commands are loading.
+func (p *Trash) Reset() {
+	p.trash = nil
+	p.confirm = false
+	p.trashTable.SetRows(nil)
+	p.loading = true
+}
+
+// SetTrash sets the deleted commands, the last deleted first.
+func (p *Trash) SetTrash(trash []command.DeletedCommand) {
+	p.loading = false
+	p.trash = trash
+
+	rows := make([]btable.Row, 0, len(trash))
+	for _, deleted := range trash {
+		rows = append(rows, btable.Row{
+			deleted.Command.Name,
+			deleted.DeletedAt.Local().Format(time.RFC822),
+		})
+	}
+
+	p.trashTable.SetRows(rows)
+	p.trashTable.Focus()
+	p.trashTable.SetCursor(0)
+}
+
+// ToggleConfirmation toggles the confirmation for purging the selected command.
+func (p *Trash) ToggleConfirmation() tea.Cmd {
+	var cmd tea.Cmd
+	if !p.confirm {
+		cmd = p.confirmation.Init()
+	}
+	p.confirmation = p.confirmation.Reset()
+	p.confirm = !p.confirm
+
+	return cmd
+}
+
+func (p *Trash) SetSize(width, height int) {
+	p.height = height
+	p.width = width
+
+	p.titleStyle.Width(width)
+	p.trashTable.Columns()[0].Width = int(float32(width) * .5)
+	p.trashTable.Columns()[1].Width = int(float32(width) * .2)
+}
+
+func (p *Trash) ShortHelp() []key.Binding {
+	if p.confirm {
+		return p.confirmation.ShortHelp()
+	}
+	return []key.Binding{
+		p.keyMap.Back,
+		p.trashTable.KeyMap.LineUp,
+		p.trashTable.KeyMap.LineDown,
+		p.keyMap.Restore,
+		p.keyMap.Purge,
+	}
+}
+
+func (p *Trash) FullHelp() [][]key.Binding {
+	return [][]key.Binding{}
+}
+
+func (p *Trash) selected() (command.DeletedCommand, bool) {
+	idx := p.trashTable.Cursor()
+	if idx < 0 || idx >= len(p.trash) {
+		return command.DeletedCommand{}, false
+	}
+	return p.trash[idx], true
+}
@@ -49,5 +49,17 @@ var (
 		Italic(true).
 		Foreground(lipgloss.Color("#FFF7DB"))
 
+	Warning = lipgloss.NewStyle().
+		Italic(true).
+		Padding(0, 1).
+		Foreground(lipgloss.Color("#F25D94"))
+
+	Added = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#04B575"))
+
+	Removed = lipgloss.NewStyle().
+		Strikethrough(true).
+		Foreground(lipgloss.Color("#F25D94"))
+
 	Subtle = lipgloss.AdaptiveColor{Light: "#D9DCCF", Dark: "#383838"}
 )
@@ -2,6 +2,8 @@ package view
 
 import (
 	"context"
+	"errors"
+	"io"
 	"log/slog"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -10,6 +12,11 @@ import (
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/lian-rr/clio/command"
+	"github.com/lian-rr/clio/command/prereq"
+	"github.com/lian-rr/clio/command/run"
+	"github.com/lian-rr/clio/command/search"
+	"github.com/lian-rr/clio/command/target"
+	"github.com/lian-rr/clio/out"
 	ckey "github.com/lian-rr/clio/tui/view/key"
 	"github.com/lian-rr/clio/tui/view/msgs"
 	"github.com/lian-rr/clio/tui/view/panel"
@@ -22,9 +29,15 @@ const title = "CLIo"
 // Main is the main view for the TUI.
 type Main struct {
 	ctx               context.Context
-	commandController controller
+	commandController Controller
 	professor         professor
+	runner            runner
+	checker           *prereq.Checker
+	targets           []out.Target
+	executionTargets  []target.Target
 	activityChan      chan msgs.AsyncMsg
+	// reindex requests computing the missing embeddings of the commands.
+	reindex chan struct{}
 
 	keys   ckey.Map
 	logger *slog.Logger
@@ -37,31 +50,50 @@ type Main struct {
 	editPanel     panel.Edit
 	explainPanel  panel.Explain
 	historyPanel  panel.History
+	revisionPanel panel.Revisions
+	trashPanel    panel.Trash
+	runPanel      panel.Run
 	help          help.Model
 
 	focus        focus
 	searching    bool
 	confirmation bool
+	// lastDeleted is the command that can be restored with undo.
+	lastDeleted *command.Command
+	// cancelRun interrupts the command running in the run panel.
+	cancelRun context.CancelFunc
 
 	// styles
 	titleStyle lipgloss.Style
 
 	// Output is the view output
 	Output string
+	// Target is where the output goes.
+	Target out.Target
 }
 
 type professor interface {
-	Explain(ctx context.Context, cmd command.Command) (string, error)
+	Explain(ctx context.Context, cmd command.Command, template string) (command.Explanation, error)
+	Signature(cmd command.Command, template string) command.ExplanationSignature
+	FollowUp(ctx context.Context, cmd command.Command, explanation command.Explanation, transcript []command.Message, question string) (string, error)
+	Templates() []string
+}
+
+type runner interface {
+	Run(ctx context.Context, inv command.Invocation, cols, rows int, in io.Reader, out io.Writer) (command.RunResult, error)
 }
 
 // New returns a new main view.
-func New(ctx context.Context, controller controller, logger *slog.Logger, opts ...OptFunc) (*Main, error) {
+func New(ctx context.Context, controller Controller, logger *slog.Logger, opts ...OptFunc) (*Main, error) {
 	keys := ckey.DefaultMap
 
 	m := Main{
 		ctx:               ctx,
 		commandController: controller,
+		runner:            run.New(),
+		checker:           prereq.New(),
 		activityChan:      make(chan msgs.AsyncMsg),
+		reindex:           make(chan struct{}, 1),
 		titleStyle:        style.Title,
 		keys:              keys,
 		explorerPanel:     panel.NewExplorer(keys),
@@ -71,6 +103,9 @@ func New(ctx context.Context, controller controller, logger *slog.Logger, opts .
 		editPanel:         panel.NewEdit(keys, logger),
 		explainPanel:      panel.NewExplain(keys, logger),
 		historyPanel:      panel.NewHistory(keys, logger),
+		revisionPanel:     panel.NewRevisions(keys, logger),
+		trashPanel:        panel.NewTrash(keys, logger),
+		runPanel:          panel.NewRun(keys, logger),
 		help:              help.New(),
 		focus:             navigationFocus,
 		logger:            logger,
@@ -80,6 +115,14 @@ func New(ctx context.Context, controller controller, logger *slog.Logger, opts .
 		opt(&m)
 	}
 
+	names := make([]string, 0, len(m.targets))
+	for _, target := range m.targets {
+		names = append(names, target.Name())
+	}
+	m.executePanel.SetTargets(names)
+	m.executePanel.SetExecutionTargets(m.executionTargets)
+	m.executePanel.SetChecker(m.checker)
+
 	cmds, err := m.fechCommands()
 	if err != nil {
 		return nil, err
@@ -96,6 +139,11 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	// key input
 	case tea.KeyMsg:
+		// cancel the running command
+		if m.focus == runFocus && m.runPanel.Running() && key.Matches(msg, m.keys.Cancel) {
+			m.cancelRun()
+			return m, nil
+		}
 		// exit the app
 		if key.Matches(msg, m.keys.ForceQuit) {
 			return m, tea.Quit
@@ -115,20 +163,64 @@ func (m *Main) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// handle outcome
 	case msgs.ExecuteCommandMsg:
 		m.logger.Debug("execute msg received")
-		if err := m.saveUsage(msg.CommandID, msg.Command); err != nil {
+		if err := m.saveUsage(msg.CommandID, command.Usage{Command: msg.Command, Target: msg.ExecutionTarget.String()}); err != nil {
 			m.logger.Error("error storing command usage",
 				slog.Any("commandID", msg.CommandID),
 				slog.String("usage", msg.Command),
 				slog.Any("error", err),
 			)
 		}
-		m.Output = msg.Command
+		m.Output = msg.ExecutionTarget.Wrap(msg.Command)
+		m.Target = m.target(msg.Target)
 		return m, tea.Quit
+	case msgs.RunCommandMsg:
+		return m, changeFocus(runFocus, func(m *Main) {
+			m.startRun(msg.CommandID, msg.Invocation, msg.ExecutionTarget)
+		})
+	case msgs.RequestFollowUpMsg:
+		go m.fetchFollowUp(msg)
+		return m, nil
 	case msgs.NewCommandMsg:
 		if err := m.saveCommand(msg.Command); err != nil {
 			m.logger.Error("error storing new command", slog.Any("error", err))
 		}
 		return m, changeFocus(navigationFocus, nil)
+	case msgs.RestoreRevisionMsg:
+		if err := m.restoreRevision(msg.CommandID, msg.RevisionID); err != nil {
+			m.logger.Error("error restoring revision", slog.Any("error", err))
+		}
+		return m, changeFocus(navigationFocus, nil)
+	case msgs.RestoreCommandMsg:
+		if err := m.restoreCommand(msg.CommandID); err != nil {
+			m.logger.Error("error restoring command", slog.Any("error", err))
+		}
+		return m, changeFocus(navigationFocus, nil)
+	case msgs.PurgeCommandMsg:
+		if err := m.purgeCommand(msg.CommandID); err != nil {
+			m.logger.Error("error purging command", slog.Any("error", err))
+			return m, nil
+		}
+		m.trashPanel.Reset()
+		msgs.PublishAsyncMsg(m.activityChan, msgs.HandleRequestTrashMsg())
+		return m, m.trashPanel.Init()
+	case semanticSearchMsg:
+		if !m.searching || msg.Terms != m.searchPanel.Content() {
+			return m, nil
+		}
+		return m, m.searchSemantic(msg.Terms)
+	case searchResultsMsg:
+		// the results of a query already changed are dropped.
+		if !m.searching || msg.Terms != m.searchPanel.Content() {
+			return m, nil
+		}
+		m.showResults(msg.Terms, msg.Results, msg.Err)
+		return m, nil
+	case undoExpiredMsg:
+		if m.lastDeleted != nil && m.lastDeleted.ID == msg.CommandID {
+			m.lastDeleted = nil
+			m.detailPanel.SetToast("")
+		}
+		return m, nil
 	case msgs.UpdateCommandMsg:
 		if err := m.editCommand(msg.Command); err != nil {
 			m.logger.Error("error editing command", slog.Any("error", err))
@@ -151,6 +243,12 @@ func (m *Main) View() string {
 		help = m.help.View(&m.explainPanel)
 	case historyFocus:
 		help = m.help.View(&m.historyPanel)
+	case revisionsFocus:
+		help = m.help.View(&m.revisionPanel)
+	case trashFocus:
+		help = m.help.View(&m.trashPanel)
+	case runFocus:
+		help = m.help.View(&m.runPanel)
 	default:
 		help = m.help.View(&m.explorerPanel)
 	}
@@ -185,6 +283,15 @@ func (m *Main) View() string {
 func (m *Main) Init() tea.Cmd {
 	tea.SetWindowTitle(title)
 
+	// the commands whose programs aren't installed are greyed out once they are checked
+	go m.checkPrograms()
+
+	// the embeddings are computed in the background, they are slow with a remote embedder.
+	if index, ok := m.commandController.(embeddingIndex); ok {
+		go m.indexEmbeddings(index)
+		m.requestEmbeddings()
+	}
+
 	return tea.Batch(
 		msgs.AsyncHandler(m.activityChan),
 		m.editPanel.Init(),
@@ -199,6 +306,20 @@ func (m *Main) Close() {
 	close(m.activityChan)
 }
 
+// target returns the output target with the name, the default one if it's not found.
+func (m *Main) target(name string) out.Target {
+	for _, target := range m.targets {
+		if target.Name() == name {
+			return target
+		}
+	}
+
+	if len(m.targets) == 0 {
+		return nil
+	}
+	return m.targets[0]
+}
+
 func (m *Main) updateComponentsDimensions(width, height int) {
 	// help
 	m.help.Width = width
@@ -218,6 +339,9 @@ func (m *Main) updateComponentsDimensions(width, height int) {
 	m.editPanel.SetSize(w, h)
 	m.explainPanel.SetSize(w, h)
 	m.historyPanel.SetSize(w, h)
+	m.revisionPanel.SetSize(w, h)
+	m.trashPanel.SetSize(w, h)
+	m.runPanel.SetSize(w, h)
 }
 
 func (m *Main) setContent(cmds []command.Command) error {
@@ -234,6 +358,44 @@ func (m *Main) setContent(cmds []command.Command) error {
 	return nil
 }
 
+// showResults shows the results of the search, or the problem with the query.
+func (m *Main) showResults(terms string, results []command.SearchResult, err error) {
+	if errors.Is(err, search.ErrInvalidQuery) {
+		m.searchPanel.SetError(err)
+		return
+	}
+	m.searchPanel.SetError(nil)
+	if err != nil {
+		m.logger.Error("error searching for commands",
+			slog.String("terms", terms),
+			slog.Any("error", err),
+		)
+		return
+	}
+
+	if err := m.setResults(results); err != nil {
+		m.logger.Error("error setting the search results", slog.Any("error", err))
+		return
+	}
+	if len(results) > 0 {
+		m.explorerPanel.Select(0)
+	}
+}
+
+func (m *Main) setResults(results []command.SearchResult) error {
+	if len(results) > 0 {
+		cmd, err := m.fechFullCommand(results[0].Command.ID.String())
+		if err != nil {
+			return err
+		}
+		results[0].Command = cmd
+		m.detailPanel.SetCommand(cmd)
+	}
+
+	m.explorerPanel.SetResults(results)
+	return nil
+}
+
 func (m *Main) initFocusedPanel() tea.Cmd {
 	switch m.focus {
 	case searchFocus:
@@ -246,6 +408,12 @@ func (m *Main) initFocusedPanel() tea.Cmd {
 		return m.explainPanel.Init()
 	case historyFocus:
 		return m.historyPanel.Init()
+	case revisionsFocus:
+		return m.revisionPanel.Init()
+	case trashFocus:
+		return m.trashPanel.Init()
+	case runFocus:
+		return m.runPanel.Init()
 	}
 	return nil
 }
@@ -260,6 +428,12 @@ func (m *Main) getPanelView() string {
 		return m.explainPanel.View()
 	case historyFocus:
 		return m.historyPanel.View()
+	case revisionsFocus:
+		return m.revisionPanel.View()
+	case trashFocus:
+		return m.trashPanel.View()
+	case runFocus:
+		return m.runPanel.View()
 	default:
 		return m.detailPanel.View()
 	}